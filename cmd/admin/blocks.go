@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/database"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/dates"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+)
+
+// listBlocks prints the restrictions of one or all laptops, by default for the next 30 days
+func listBlocks(repo database.DBRepository, args []string, w io.Writer) error {
+	fs := newFlagSet("blocks list")
+	laptopID := fs.Int("laptop", 0, "laptop id, all laptops if 0")
+	from := fs.String("from", "", "first day, defaults to today")
+	to := fs.String("to", "", "last day, defaults to 30 days after -from")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	start, err := parseDate("from", *from)
+	if err != nil {
+		return err
+	}
+	if start.IsZero() {
+		start = dates.Today()
+	}
+	end, err := parseDate("to", *to)
+	if err != nil {
+		return err
+	}
+	if end.IsZero() {
+		end = start.AddDate(0, 0, 30)
+	}
+
+	var laptops []models.Laptop
+	if *laptopID == 0 {
+		laptops, err = repo.AllLaptops()
+	} else {
+		var laptop models.Laptop
+		laptop, err = repo.GetLaptopByID(*laptopID)
+		laptops = append(laptops, laptop)
+	}
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tLAPTOP\tTYPE\tSTART\tEND\tRESERVATION")
+	for _, laptop := range laptops {
+		restrictions, err := repo.GetLaptopRestrictionsByDate(laptop.ID, start, end)
+		if err != nil {
+			return err
+		}
+		for _, lr := range restrictions {
+			kind, reservation := "block", "-"
+			switch lr.RestrictionID {
+			case models.RestrictionReservation:
+				kind, reservation = "reservation", fmt.Sprint(lr.ReservationID)
+			case models.RestrictionHold:
+				kind = "hold"
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", lr.ID, laptop.LaptopName, kind,
+				lr.StartDate.Format(dateLayout), lr.EndDate.Format(dateLayout), reservation)
+		}
+	}
+	return tw.Flush()
+}
+
+// createBlock blocks a laptop from -from to -to
+func createBlock(repo database.DBRepository, args []string, w io.Writer) error {
+	fs := newFlagSet("blocks create")
+	laptopID := fs.Int("laptop", 0, "laptop id")
+	from := fs.String("from", "", "first blocked day")
+	to := fs.String("to", "", "last blocked day, defaults to -from")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	laptop, start, end, err := blockArgs(repo, *laptopID, *from, *to)
+	if err != nil {
+		return err
+	}
+
+	err = repo.InsertBlockByLaptopID(laptop.ID, start, end)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "blocked %s from %s to %s\n", laptop.LaptopName, start.Format(dateLayout), end.Format(dateLayout))
+	return nil
+}
+
+// unblock deletes the blocks of a laptop overlapping -from to -to, or only the one given by -id
+func unblock(repo database.DBRepository, args []string, w io.Writer) error {
+	fs := newFlagSet("blocks unblock")
+	laptopID := fs.Int("laptop", 0, "laptop id")
+	from := fs.String("from", "", "first day")
+	to := fs.String("to", "", "last day, defaults to -from")
+	blockID := fs.Int("id", 0, "only delete the block with this id")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	laptop, start, end, err := blockArgs(repo, *laptopID, *from, *to)
+	if err != nil {
+		return err
+	}
+
+	restrictions, err := repo.GetLaptopRestrictionsByDate(laptop.ID, start, end)
+	if err != nil {
+		return err
+	}
+
+	deleted := 0
+	for _, lr := range restrictions {
+		// reservations are cancelled from the admin pages, only blocks are removed here
+		if lr.RestrictionID != models.RestrictionBlock || (*blockID != 0 && lr.ID != *blockID) {
+			continue
+		}
+		err = repo.DeleteBlockByID(lr.ID)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "unblocked %s from %s to %s\n", laptop.LaptopName, lr.StartDate.Format(dateLayout), lr.EndDate.Format(dateLayout))
+		deleted++
+	}
+
+	if deleted == 0 {
+		return errors.New("no matching blocks")
+	}
+
+	return notifyWaitlist(repo, laptop.ID, w)
+}
+
+// blockArgs validates the laptop and date range of a block command
+func blockArgs(repo database.DBRepository, laptopID int, from, to string) (models.Laptop, time.Time, time.Time, error) {
+	if laptopID == 0 || from == "" {
+		return models.Laptop{}, time.Time{}, time.Time{}, errors.New("-laptop and -from are required")
+	}
+
+	start, end, err := parseDateRange(from, to)
+	if err != nil {
+		return models.Laptop{}, start, end, err
+	}
+
+	laptop, err := repo.GetLaptopByID(laptopID)
+	if err != nil {
+		return laptop, start, end, fmt.Errorf("no laptop with id %d: %w", laptopID, err)
+	}
+
+	return laptop, start, end, nil
+}
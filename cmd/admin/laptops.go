@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/database"
+)
+
+// listLaptops prints all laptops with their turnaround buffers
+func listLaptops(repo database.DBRepository, args []string, w io.Writer) error {
+	fs := newFlagSet("laptops list")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	laptops, err := repo.AllLaptops()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tLAPTOP\tDAYS BEFORE\tDAYS AFTER")
+	for _, laptop := range laptops {
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%d\n", laptop.ID, laptop.LaptopName, laptop.BufferDaysBefore, laptop.BufferDaysAfter)
+	}
+	return tw.Flush()
+}
+
+// setBuffers sets the turnaround days kept free before and after each reservation of a laptop
+func setBuffers(repo database.DBRepository, args []string, w io.Writer) error {
+	fs := newFlagSet("laptops set-buffers")
+	laptopID := fs.Int("laptop", 0, "laptop id")
+	before := fs.Int("before", 0, "days kept free before each reservation")
+	after := fs.Int("after", 0, "days kept free after each reservation")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *laptopID == 0 {
+		return errors.New("-laptop is required")
+	}
+	if *before < 0 || *after < 0 {
+		return errors.New("-before and -after can't be negative")
+	}
+
+	laptop, err := repo.GetLaptopByID(*laptopID)
+	if err != nil {
+		return fmt.Errorf("laptop %d: %w", *laptopID, err)
+	}
+	err = repo.UpdateLaptopBuffers(laptop.ID, *before, *after)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "%s keeps %d day(s) free before and %d day(s) after each reservation\n", laptop.LaptopName, *before, *after)
+	return nil
+}
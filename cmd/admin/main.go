@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/config"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/database"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/dates"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/driver"
+)
+
+// dateLayout is the format of dates on the command line, the same as the web forms
+const dateLayout = dates.Layout
+
+const usage = `usage:
+  admin users list
+  admin users create -email <email> -first <name> -last <name> [-access <level>] [-password <password>]
+  admin users set-access -email <email> -level <level>
+  admin users reset-password -email <email> [-password <password>]
+  admin blocks list [-laptop <id>] [-from <date>] [-to <date>]
+  admin blocks create -laptop <id> -from <date> [-to <date>]
+  admin blocks unblock -laptop <id> -from <date> [-to <date>] [-id <block id>]
+  admin laptops list
+  admin laptops set-buffers -laptop <id> [-before <days>] [-after <days>]
+  admin rules show [-laptop <id>]
+  admin rules set [-laptop <id>] [-min <days>] [-max <days>] [-advance <days>] [-pickup <weekdays>]
+  admin rules clear [-laptop <id>]
+  admin blackouts list [-laptop <id>] [-from <date>] [-to <date>]
+  admin blackouts create [-laptop <id>] -from <date> [-to <date>] [-reason <text>]
+  admin blackouts delete -id <blackout id>
+  admin reservations export [-from <date>] [-to <date>] [-new] [-o <file>]
+
+dates are YYYY-MM-DD, weekdays are comma separated like mon,fri,
+rules and blackouts without -laptop apply to every laptop, a password is generated and printed when -password is omitted,
+the database is selected by the same environment variables (or .env file) as the web server`
+
+var errUsage = errors.New(usage)
+
+// main is the admin command line tool
+func main() {
+	// the environment may come from the service manager instead of a .env file
+	err := godotenv.Load()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Fatal("Error loading .env file")
+	}
+
+	if len(os.Args) < 3 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cfg := driver.ConfigFromEnv()
+	err = cfg.Validate()
+	if err != nil {
+		log.Fatalf("Missing required flags: %s", err)
+	}
+	loc, err := dates.LoadLocation(os.Getenv("timezone"))
+	if err != nil {
+		log.Fatalf("Invalid timezone: %s", err)
+	}
+	dates.SetLocation(loc)
+
+	if cfg.Driver == driver.Memory {
+		log.Println("Warning: the memory database is not shared with the web server, changes are lost on exit")
+	}
+
+	db, err := driver.Connect(cfg)
+	if err != nil {
+		log.Fatalf("Cannot connect to database: %s", err)
+	}
+
+	app := config.AppConfig{
+		InfoLog:  log.New(os.Stderr, "INFO\t", log.Ldate|log.Ltime),
+		ErrorLog: log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile),
+	}
+
+	err = run(database.NewRepository(db, &app), os.Args[1:], os.Stdout)
+	db.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run runs the command in args against repo and writes its output to w
+func run(repo database.DBRepository, args []string, w io.Writer) error {
+	if len(args) < 2 {
+		return errUsage
+	}
+
+	command, flags := args[0]+" "+args[1], args[2:]
+	switch command {
+	case "users list":
+		return listUsers(repo, flags, w)
+	case "users create":
+		return createUser(repo, flags, w)
+	case "users set-access":
+		return setAccessLevel(repo, flags, w)
+	case "users reset-password":
+		return resetPassword(repo, flags, w)
+	case "blocks list":
+		return listBlocks(repo, flags, w)
+	case "blocks create":
+		return createBlock(repo, flags, w)
+	case "blocks unblock":
+		return unblock(repo, flags, w)
+	case "laptops list":
+		return listLaptops(repo, flags, w)
+	case "laptops set-buffers":
+		return setBuffers(repo, flags, w)
+	case "rules show":
+		return showRules(repo, flags, w)
+	case "rules set":
+		return setRules(repo, flags, w)
+	case "rules clear":
+		return clearRules(repo, flags, w)
+	case "blackouts list":
+		return listBlackouts(repo, flags, w)
+	case "blackouts create":
+		return createBlackout(repo, flags, w)
+	case "blackouts delete":
+		return deleteBlackout(repo, flags, w)
+	case "reservations export":
+		return exportReservations(repo, flags, w)
+	}
+
+	return errUsage
+}
+
+// newFlagSet returns a flag set for a subcommand that reports errors instead of exiting
+func newFlagSet(command string) *flag.FlagSet {
+	return flag.NewFlagSet(command, flag.ContinueOnError)
+}
+
+// parseDate parses a command line date, an empty value returns the zero time
+func parseDate(name, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := dates.Parse(value)
+	if err != nil {
+		return t, fmt.Errorf("invalid -%s date %q, expected YYYY-MM-DD", name, value)
+	}
+	return t, nil
+}
+
+// parseDateRange parses -from and -to, to defaults to from
+func parseDateRange(from, to string) (time.Time, time.Time, error) {
+	start, err := parseDate("from", from)
+	if err != nil {
+		return start, start, err
+	}
+	end, err := parseDate("to", to)
+	if err != nil {
+		return start, end, err
+	}
+	if end.IsZero() {
+		end = start
+	}
+	if end.Before(start) {
+		return start, end, errors.New("-to is before -from")
+	}
+	return start, end, nil
+}
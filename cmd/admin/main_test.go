@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/config"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/database"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+)
+
+func runCommand(t *testing.T, repo database.DBRepository, args ...string) (string, error) {
+	t.Helper()
+
+	var out bytes.Buffer
+	err := run(repo, args, &out)
+	return out.String(), err
+}
+
+func TestRun_Usage(t *testing.T) {
+	repo := database.NewMemory(&config.AppConfig{})
+
+	for _, args := range [][]string{{}, {"users"}, {"users", "delete"}, {"laptops", "delete"}} {
+		_, err := runCommand(t, repo, args...)
+		if err != errUsage {
+			t.Errorf("%v: expected usage error, got %v", args, err)
+		}
+	}
+}
+
+func TestUsers(t *testing.T) {
+	repo := database.NewMemory(&config.AppConfig{})
+
+	out, err := runCommand(t, repo, "users", "create", "-email", "ops@example.com", "-first", "Ops", "-last", "Team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "access level 1") {
+		t.Errorf("unexpected output: %s", out)
+	}
+	i := strings.Index(out, "password: ")
+	if i < 0 {
+		t.Fatalf("generated password not printed: %s", out)
+	}
+	password := strings.TrimSpace(out[i+len("password: "):])
+	if _, _, err := repo.Authenticate("ops@example.com", password); err != nil {
+		t.Errorf("can't log in with the generated password: %s", err)
+	}
+
+	_, err = runCommand(t, repo, "users", "create", "-email", "ops@example.com", "-first", "Ops", "-last", "Team")
+	if err == nil {
+		t.Error("created a duplicate user")
+	}
+	_, err = runCommand(t, repo, "users", "create", "-email", "not-an-email", "-first", "Ops", "-last", "Team")
+	if err == nil {
+		t.Error("created a user with an invalid email")
+	}
+	_, err = runCommand(t, repo, "users", "create", "-email", "short@example.com", "-first", "Ops", "-last", "Team", "-password", "short")
+	if err == nil {
+		t.Error("created a user with a short password")
+	}
+
+	_, err = runCommand(t, repo, "users", "set-access", "-email", "ops@example.com", "-level", "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := repo.GetUserByEmail("ops@example.com")
+	if u.AccessLevel != 3 || u.FirstName != "Ops" {
+		t.Errorf("set-access changed the user unexpectedly: %+v", u)
+	}
+
+	out, err = runCommand(t, repo, "users", "reset-password", "-email", "ops@example.com", "-password", "new-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out, "new-password") {
+		t.Error("given password echoed")
+	}
+	if _, _, err := repo.Authenticate("ops@example.com", "new-password"); err != nil {
+		t.Errorf("can't log in after reset: %s", err)
+	}
+
+	_, err = runCommand(t, repo, "users", "reset-password", "-email", "nobody@example.com")
+	if err == nil {
+		t.Error("reset the password of a missing user")
+	}
+
+	out, err = runCommand(t, repo, "users", "list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "admin@admin.com") || !strings.Contains(out, "ops@example.com") {
+		t.Errorf("users missing from list:\n%s", out)
+	}
+}
+
+func TestBlocks(t *testing.T) {
+	repo := database.NewMemory(&config.AppConfig{})
+
+	_, err := runCommand(t, repo, "blocks", "create", "-laptop", "1", "-from", "2099-01-05", "-to", "2099-01-03")
+	if err == nil {
+		t.Error("created a block ending before it starts")
+	}
+	_, err = runCommand(t, repo, "blocks", "create", "-laptop", "99", "-from", "2099-01-03")
+	if err == nil {
+		t.Error("blocked a missing laptop")
+	}
+
+	_, err = runCommand(t, repo, "blocks", "create", "-laptop", "1", "-from", "2099-01-03", "-to", "2099-01-05")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = runCommand(t, repo, "blocks", "create", "-laptop", "1", "-from", "2099-01-10")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := runCommand(t, repo, "blocks", "list", "-from", "2099-01-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(out, "block ") != 2 || !strings.Contains(out, "2099-01-05") {
+		t.Errorf("unexpected block list:\n%s", out)
+	}
+
+	start := time.Date(2099, 1, 4, 0, 0, 0, 0, time.UTC)
+	if available, _ := repo.SearchAvailabilityByDatesByLaptopID(start, start, 1); available {
+		t.Error("blocked day is available")
+	}
+
+	_, err = repo.InsertWaitlistEntry(&models.WaitlistEntry{Email: "wait@example.com", LaptopID: 1, StartDate: start, EndDate: start})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err = runCommand(t, repo, "blocks", "unblock", "-laptop", "1", "-from", "2099-01-04")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the freed day is held for the waitlist, the hold link is queued for the web server to mail
+	if !strings.Contains(out, "notified 1 customers on the waitlist") {
+		t.Errorf("expected the waitlist to be notified, got:\n%s", out)
+	}
+	entries, _ := repo.GetWaitlistByLaptopID(1)
+	if len(entries) != 1 || entries[0].Status != models.WaitlistNotified || entries[0].HoldID == 0 {
+		t.Errorf("expected the entry to hold the freed day, got %+v", entries)
+	}
+	mails, _ := repo.GetDueMail(time.Now(), 10)
+	if len(mails) != 1 || mails[0].Mail.To != "wait@example.com" ||
+		!strings.Contains(mails[0].Mail.Content, defaultSiteURL+"/waitlist/claim?token=") {
+		t.Errorf("expected the hold link queued, got %+v", mails)
+	}
+	repo.DeleteHold(entries[0].HoldID)
+
+	_, err = runCommand(t, repo, "blocks", "unblock", "-laptop", "1", "-from", "2099-01-04")
+	if err == nil {
+		t.Error("expected an error unblocking a day without blocks")
+	}
+
+	// reservations are left alone
+	resID, _ := repo.InsertReservation(&models.Reservation{FirstName: "John", StartDate: start, EndDate: start, LaptopID: 1})
+	repo.InsertLaptopRestriction(&models.LaptopRestriction{StartDate: start, EndDate: start, LaptopID: 1, ReservationID: resID, RestrictionID: 1})
+	_, err = runCommand(t, repo, "blocks", "unblock", "-laptop", "1", "-from", "2099-01-04")
+	if err == nil {
+		t.Error("unblock removed a reservation")
+	}
+}
+
+func TestLaptops(t *testing.T) {
+	repo := database.NewMemory(&config.AppConfig{})
+
+	_, err := runCommand(t, repo, "laptops", "set-buffers", "-laptop", "99", "-after", "1")
+	if err == nil {
+		t.Error("set the buffers of a missing laptop")
+	}
+	_, err = runCommand(t, repo, "laptops", "set-buffers", "-laptop", "1", "-before", "-1")
+	if err == nil {
+		t.Error("set a negative buffer")
+	}
+
+	_, err = runCommand(t, repo, "laptops", "set-buffers", "-laptop", "1", "-before", "1", "-after", "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	laptop, _ := repo.GetLaptopByID(1)
+	if laptop.BufferDaysBefore != 1 || laptop.BufferDaysAfter != 2 {
+		t.Errorf("buffers not set, got %+v", laptop)
+	}
+
+	out, err := runCommand(t, repo, "laptops", "list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(strings.Join(strings.Fields(out), " "), "1 Alienware M15 R2 1 2") {
+		t.Errorf("unexpected laptop list:\n%s", out)
+	}
+}
+
+func TestRules(t *testing.T) {
+	repo := database.NewMemory(&config.AppConfig{})
+
+	for _, args := range [][]string{
+		{"-min", "-1"},
+		{"-min", "5", "-max", "3"},
+		{"-pickup", "mon,someday"},
+		{"-laptop", "99", "-min", "2"},
+	} {
+		_, err := runCommand(t, repo, append([]string{"rules", "set"}, args...)...)
+		if err == nil {
+			t.Errorf("%v: expected an error", args)
+		}
+	}
+
+	_, err := runCommand(t, repo, "rules", "set", "-min", "2", "-pickup", "Mon,fri")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = runCommand(t, repo, "rules", "set", "-laptop", "1", "-max", "7")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := runCommand(t, repo, "rules", "show", "-laptop", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = strings.Join(strings.Fields(out), " ")
+	if !strings.Contains(out, "minimum days 2") || !strings.Contains(out, "maximum days 7") ||
+		!strings.Contains(out, "pickup days Monday, Friday") {
+		t.Errorf("unexpected rules of laptop 1:\n%s", out)
+	}
+
+	_, err = runCommand(t, repo, "rules", "clear")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = runCommand(t, repo, "rules", "clear")
+	if err == nil {
+		t.Error("cleared missing rules")
+	}
+	r, _ := repo.GetBookingRules(1)
+	if r.MaxDays != 7 {
+		t.Errorf("the laptop rules were cleared with the global ones, got %+v", r)
+	}
+}
+
+func TestBlackouts(t *testing.T) {
+	repo := database.NewMemory(&config.AppConfig{})
+
+	_, err := runCommand(t, repo, "blackouts", "create", "-laptop", "99", "-from", "2099-12-24")
+	if err == nil {
+		t.Error("blacked out a missing laptop")
+	}
+	_, err = runCommand(t, repo, "blackouts", "create", "-from", "2099-12-24", "-to", "2099-12-20")
+	if err == nil {
+		t.Error("created a reversed blackout")
+	}
+
+	_, err = runCommand(t, repo, "blackouts", "create", "-from", "2099-12-24", "-to", "2099-12-26", "-reason", "Christmas")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := runCommand(t, repo, "blackouts", "list", "-from", "2099-12-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(strings.Join(strings.Fields(out), " "), "1 all 2099-12-24 2099-12-26 Christmas") {
+		t.Errorf("unexpected blackout list:\n%s", out)
+	}
+
+	_, err = runCommand(t, repo, "blackouts", "delete", "-id", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = runCommand(t, repo, "blackouts", "delete", "-id", "1")
+	if err == nil {
+		t.Error("deleted a missing blackout")
+	}
+}
+
+func TestExportReservations(t *testing.T) {
+	repo := database.NewMemory(&config.AppConfig{})
+	for i, day := range []int{1, 10, 20} {
+		start := time.Date(2099, 1, day, 0, 0, 0, 0, time.UTC)
+		id, _ := repo.InsertReservation(&models.Reservation{
+			FirstName: "John",
+			LastName:  "Smith, Jr.",
+			Email:     "john@smith.com",
+			Phone:     "+81 90 1234 5678",
+			StartDate: start,
+			EndDate:   start.AddDate(0, 0, 2),
+			LaptopID:  1 + i%2,
+		})
+		if day == 20 {
+			repo.UpdateReservationProcessed(id, 1)
+		}
+	}
+
+	out, err := runCommand(t, repo, "reservations", "export", "-from", "2099-01-05")
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected header and 2 reservations, got %d records", len(records))
+	}
+	// cells a spreadsheet would read as a formula are kept as text
+	if records[1][2] != "Smith, Jr." || records[1][4] != "'+81 90 1234 5678" || records[1][5] != "Macbook Pro 15 inch" ||
+		records[1][6] != "2099-01-10" {
+		t.Errorf("unexpected record: %v", records[1])
+	}
+
+	file := filepath.Join(t.TempDir(), "export.csv")
+	_, err = runCommand(t, repo, "reservations", "export", "-new", "-to", "2099-01-15", "-o", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(content), "\n"); n != 3 {
+		t.Errorf("expected header and 2 new reservations, got %d lines:\n%s", n, content)
+	}
+}
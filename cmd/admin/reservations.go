@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/csvsafe"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/database"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+)
+
+// exportReservations writes reservations as CSV, optionally only those overlapping -from to -to
+func exportReservations(repo database.DBRepository, args []string, w io.Writer) error {
+	fs := newFlagSet("reservations export")
+	from := fs.String("from", "", "only reservations ending on or after this day")
+	to := fs.String("to", "", "only reservations starting on or before this day")
+	onlyNew := fs.Bool("new", false, "only reservations that have not been processed")
+	output := fs.String("o", "", "output file, defaults to standard output")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	start, err := parseDate("from", *from)
+	if err != nil {
+		return err
+	}
+	end, err := parseDate("to", *to)
+	if err != nil {
+		return err
+	}
+
+	var reservations []models.Reservation
+	if *onlyNew {
+		reservations, err = repo.AllNewReservations()
+	} else {
+		reservations, err = repo.AllReservations()
+	}
+	if err != nil {
+		return err
+	}
+
+	dst := w
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		dst = f
+	}
+
+	cw := csvsafe.NewWriter(dst)
+	cw.Write([]string{"id", "first_name", "last_name", "email", "phone", "laptop", "start_date", "end_date", "processed", "created_at"})
+
+	n := 0
+	for _, r := range reservations {
+		if (!start.IsZero() && r.EndDate.Before(start)) || (!end.IsZero() && r.StartDate.After(end)) {
+			continue
+		}
+		cw.Write([]string{
+			strconv.Itoa(r.ID),
+			r.FirstName,
+			r.LastName,
+			r.Email,
+			r.Phone,
+			r.Laptop.LaptopName,
+			r.StartDate.Format(dateLayout),
+			r.EndDate.Format(dateLayout),
+			strconv.Itoa(r.Processed),
+			r.CreatedAt.Format("2006-01-02 15:04:05"),
+		})
+		n++
+	}
+
+	cw.Flush()
+	if err = cw.Error(); err != nil {
+		return err
+	}
+
+	if *output != "" {
+		fmt.Fprintf(w, "exported %d reservations to %s\n", n, *output)
+	}
+	return nil
+}
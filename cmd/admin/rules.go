@@ -0,0 +1,265 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/database"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/dates"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/rules"
+)
+
+// showRules prints the booking rules of a laptop, or the global rules
+func showRules(repo database.DBRepository, args []string, w io.Writer) error {
+	fs := newFlagSet("rules show")
+	laptopID := fs.Int("laptop", 0, "laptop id, the global rules if 0")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *laptopID != 0 {
+		_, err = repo.GetLaptopByID(*laptopID)
+		if err != nil {
+			return fmt.Errorf("laptop %d: %w", *laptopID, err)
+		}
+	}
+
+	// the rules in effect, the global ones merged with the laptop's
+	r, err := rules.For(repo, *laptopID)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "minimum days\t%s\n", limit(r.MinDays))
+	fmt.Fprintf(tw, "maximum days\t%s\n", limit(r.MaxDays))
+	fmt.Fprintf(tw, "maximum days ahead\t%s\n", limit(r.MaxAdvanceDays))
+	pickup := "any"
+	if len(r.PickupWeekdays) > 0 {
+		names := make([]string, len(r.PickupWeekdays))
+		for i, d := range r.PickupWeekdays {
+			names[i] = d.String()
+		}
+		pickup = strings.Join(names, ", ")
+	}
+	fmt.Fprintf(tw, "pickup days\t%s\n", pickup)
+	return tw.Flush()
+}
+
+// limit returns a rule value in words, 0 is no limit
+func limit(n int) string {
+	if n == 0 {
+		return "none"
+	}
+	return fmt.Sprint(n)
+}
+
+// setRules sets the booking rules of a laptop, or the global rules,
+// a laptop's rules left at 0 or empty fall back to the global ones
+func setRules(repo database.DBRepository, args []string, w io.Writer) error {
+	fs := newFlagSet("rules set")
+	laptopID := fs.Int("laptop", 0, "laptop id, the global rules if 0")
+	minDays := fs.Int("min", 0, "minimum rental length in days, 0 for no minimum")
+	maxDays := fs.Int("max", 0, "maximum rental length in days, 0 for no maximum")
+	advance := fs.Int("advance", 0, "how many days ahead a rental can start, 0 for no limit")
+	pickup := fs.String("pickup", "", "comma separated weekdays a laptop can be picked up, like mon,fri, any day if empty")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *minDays < 0 || *maxDays < 0 || *advance < 0 {
+		return errors.New("-min, -max and -advance can't be negative")
+	}
+	if *maxDays > 0 && *minDays > *maxDays {
+		return errors.New("-min is greater than -max")
+	}
+	weekdays, err := parseWeekdays(*pickup)
+	if err != nil {
+		return err
+	}
+
+	name := "global"
+	if *laptopID != 0 {
+		laptop, err := repo.GetLaptopByID(*laptopID)
+		if err != nil {
+			return fmt.Errorf("laptop %d: %w", *laptopID, err)
+		}
+		name = laptop.LaptopName
+	}
+
+	err = repo.SaveBookingRules(&models.BookingRules{
+		LaptopID:       *laptopID,
+		MinDays:        *minDays,
+		MaxDays:        *maxDays,
+		MaxAdvanceDays: *advance,
+		PickupWeekdays: weekdays,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "saved the %s booking rules\n", name)
+	return nil
+}
+
+// clearRules deletes the booking rules of a laptop, or the global rules
+func clearRules(repo database.DBRepository, args []string, w io.Writer) error {
+	fs := newFlagSet("rules clear")
+	laptopID := fs.Int("laptop", 0, "laptop id, the global rules if 0")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	err = repo.DeleteBookingRules(*laptopID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("no booking rules to clear")
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "cleared the booking rules")
+	return nil
+}
+
+// parseWeekdays parses comma separated weekday names, full or abbreviated to three letters
+func parseWeekdays(s string) ([]time.Weekday, error) {
+	var weekdays []time.Weekday
+	if s == "" {
+		return weekdays, nil
+	}
+
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		found := false
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			full := strings.ToLower(d.String())
+			if name == full || name == full[:3] {
+				weekdays = append(weekdays, d)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("invalid -pickup weekday %q", name)
+		}
+	}
+	return weekdays, nil
+}
+
+// listBlackouts prints the blackouts of every laptop and, with -laptop, of that laptop, by default for the next year
+func listBlackouts(repo database.DBRepository, args []string, w io.Writer) error {
+	fs := newFlagSet("blackouts list")
+	laptopID := fs.Int("laptop", 0, "laptop id, only the blackouts of every laptop if 0")
+	from := fs.String("from", "", "first day, defaults to today")
+	to := fs.String("to", "", "last day, defaults to a year after -from")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	start, err := parseDate("from", *from)
+	if err != nil {
+		return err
+	}
+	if start.IsZero() {
+		start = dates.Today()
+	}
+	end, err := parseDate("to", *to)
+	if err != nil {
+		return err
+	}
+	if end.IsZero() {
+		end = start.AddDate(1, 0, 0)
+	}
+
+	blackouts, err := repo.GetBlackoutsByDate(*laptopID, start, end)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tLAPTOP\tSTART\tEND\tREASON")
+	for _, b := range blackouts {
+		laptop := "all"
+		if b.LaptopID != 0 {
+			laptop = fmt.Sprint(b.LaptopID)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", b.ID, laptop,
+			b.StartDate.Format(dateLayout), b.EndDate.Format(dateLayout), b.Reason)
+	}
+	return tw.Flush()
+}
+
+// createBlackout stops rentals of one or every laptop that overlap -from to -to
+func createBlackout(repo database.DBRepository, args []string, w io.Writer) error {
+	fs := newFlagSet("blackouts create")
+	laptopID := fs.Int("laptop", 0, "laptop id, every laptop if 0")
+	from := fs.String("from", "", "first blacked out day")
+	to := fs.String("to", "", "last blacked out day, defaults to -from")
+	reason := fs.String("reason", "", "reason shown to customers, like a holiday closure")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *from == "" {
+		return errors.New("-from is required")
+	}
+	start, end, err := parseDateRange(*from, *to)
+	if err != nil {
+		return err
+	}
+	if *laptopID != 0 {
+		_, err = repo.GetLaptopByID(*laptopID)
+		if err != nil {
+			return fmt.Errorf("laptop %d: %w", *laptopID, err)
+		}
+	}
+
+	id, err := repo.InsertBlackout(&models.Blackout{
+		LaptopID:  *laptopID,
+		StartDate: start,
+		EndDate:   end,
+		Reason:    *reason,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "created blackout %d from %s to %s\n", id, start.Format(dateLayout), end.Format(dateLayout))
+	return nil
+}
+
+// deleteBlackout deletes a blackout by id
+func deleteBlackout(repo database.DBRepository, args []string, w io.Writer) error {
+	fs := newFlagSet("blackouts delete")
+	id := fs.Int("id", 0, "blackout id")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *id == 0 {
+		return errors.New("-id is required")
+	}
+	err = repo.DeleteBlackout(*id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("blackout %d not found", *id)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "deleted blackout %d\n", *id)
+	return nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/database"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+)
+
+// minPasswordLength is the shortest password accepted from the command line
+const minPasswordLength = 8
+
+// listUsers prints all users
+func listUsers(repo database.DBRepository, args []string, w io.Writer) error {
+	fs := newFlagSet("users list")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	users, err := repo.AllUsers()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tEMAIL\tNAME\tACCESS\tCREATED")
+	for _, u := range users {
+		fmt.Fprintf(tw, "%d\t%s\t%s %s\t%d\t%s\n", u.ID, u.Email, u.FirstName, u.LastName, u.AccessLevel, u.CreatedAt.Format(dateLayout))
+	}
+	return tw.Flush()
+}
+
+// createUser creates a user, printing the generated password if none is given
+func createUser(repo database.DBRepository, args []string, w io.Writer) error {
+	fs := newFlagSet("users create")
+	email := fs.String("email", "", "email address, used to log in")
+	firstName := fs.String("first", "", "first name")
+	lastName := fs.String("last", "", "last name")
+	accessLevel := fs.Int("access", 1, "access level, the seeded admin has 3")
+	password := fs.String("password", "", "password, generated if empty")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *email == "" || *firstName == "" || *lastName == "" {
+		return errors.New("-email, -first and -last are required")
+	}
+	if !govalidator.IsEmail(*email) {
+		return fmt.Errorf("invalid email address: %s", *email)
+	}
+	if *accessLevel < 1 {
+		return errors.New("-access must be at least 1")
+	}
+	pw, generated, err := passwordOrGenerate(*password)
+	if err != nil {
+		return err
+	}
+
+	_, err = repo.GetUserByEmail(*email)
+	if err == nil {
+		return fmt.Errorf("a user with email %s already exists", *email)
+	}
+
+	u := models.User{
+		FirstName:   *firstName,
+		LastName:    *lastName,
+		Email:       *email,
+		AccessLevel: *accessLevel,
+	}
+	id, err := repo.InsertUser(&u, pw)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "created user %d %s with access level %d\n", id, u.Email, u.AccessLevel)
+	if generated {
+		fmt.Fprintf(w, "password: %s\n", pw)
+	}
+	return nil
+}
+
+// setAccessLevel changes the access level of a user
+func setAccessLevel(repo database.DBRepository, args []string, w io.Writer) error {
+	fs := newFlagSet("users set-access")
+	email := fs.String("email", "", "email address of the user")
+	level := fs.Int("level", 0, "new access level")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *level < 1 {
+		return errors.New("-level must be at least 1")
+	}
+	u, err := findUser(repo, *email)
+	if err != nil {
+		return err
+	}
+
+	u.AccessLevel = *level
+	err = repo.UpdateUser(&u)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "set access level of %s to %d\n", u.Email, u.AccessLevel)
+	return nil
+}
+
+// resetPassword replaces the password of a user, printing the generated password if none is given
+func resetPassword(repo database.DBRepository, args []string, w io.Writer) error {
+	fs := newFlagSet("users reset-password")
+	email := fs.String("email", "", "email address of the user")
+	password := fs.String("password", "", "new password, generated if empty")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	u, err := findUser(repo, *email)
+	if err != nil {
+		return err
+	}
+	pw, generated, err := passwordOrGenerate(*password)
+	if err != nil {
+		return err
+	}
+
+	err = repo.UpdateUserPassword(u.ID, pw)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "reset password of %s\n", u.Email)
+	if generated {
+		fmt.Fprintf(w, "password: %s\n", pw)
+	}
+	return nil
+}
+
+// findUser returns the user with email
+func findUser(repo database.DBRepository, email string) (models.User, error) {
+	if email == "" {
+		return models.User{}, errors.New("-email is required")
+	}
+	u, err := repo.GetUserByEmail(email)
+	if err != nil {
+		return u, fmt.Errorf("no user with email %s: %w", email, err)
+	}
+	return u, nil
+}
+
+// passwordOrGenerate returns password, or a random one if it is empty
+func passwordOrGenerate(password string) (string, bool, error) {
+	if password != "" {
+		if len(password) < minPasswordLength {
+			return "", false, fmt.Errorf("password must be at least %d characters long", minPasswordLength)
+		}
+		return password, false, nil
+	}
+
+	b := make([]byte, 12)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", false, err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), true, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/config"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/database"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/waitlist"
+)
+
+// defaultSiteURL is the address of the web server in the hold links when siteurl isn't set, the same as its own
+const defaultSiteURL = "http://localhost:8080"
+
+// notifyWaitlist offers the dates freed on laptopID to its waitlist. This tool doesn't send mail, the hold links
+// are queued in the database for the mail-retry job of the web server.
+func notifyWaitlist(repo database.DBRepository, laptopID int, w io.Writer) error {
+	app := &config.AppConfig{
+		SiteURL:  strings.TrimSuffix(os.Getenv("siteurl"), "/"),
+		MailChan: make(chan models.MailData),
+	}
+	if app.SiteURL == "" {
+		app.SiteURL = defaultSiteURL
+	}
+
+	queued := make(chan error)
+	go func() {
+		var err error
+		for m := range app.MailChan {
+			if err == nil {
+				_, err = repo.QueueMail(&models.QueuedMail{Mail: m, Status: models.MailPending, NextAttemptAt: time.Now()})
+			}
+		}
+		queued <- err
+	}()
+
+	n, err := waitlist.Notify(app, repo, laptopID)
+	close(app.MailChan)
+	if queueErr := <-queued; err == nil {
+		err = queueErr
+	}
+	if err != nil {
+		return fmt.Errorf("notifying the waitlist: %w", err)
+	}
+
+	if n > 0 {
+		fmt.Fprintf(w, "notified %d customers on the waitlist\n", n)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/driver"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/migrate"
+	"github.com/kaitolucifer/go-laptop-rental-site/migrations"
+)
+
+const commandUsage = `usage:
+  app [flags] migrate up          apply all pending migrations
+  app [flags] migrate down [n]    roll back the last n migrations (default 1)
+  app [flags] migrate status      list migrations and whether they are applied
+  app [flags] seed                load demo data`
+
+// runCommand runs a subcommand given on the command line instead of starting the server
+func runCommand(args []string) error {
+	checkDBFlags()
+
+	db, err := connectDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	m, err := newMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "migrate":
+		if len(args) < 2 {
+			return errors.New(commandUsage)
+		}
+		switch args[1] {
+		case "up":
+			return migrateUp(m)
+		case "down":
+			steps := 1
+			if len(args) > 2 {
+				steps, err = strconv.Atoi(args[2])
+				if err != nil || steps < 1 {
+					return fmt.Errorf("invalid number of migrations to roll back: %s", args[2])
+				}
+			}
+			return migrateDown(m, steps)
+		case "status":
+			return migrateStatus(m)
+		}
+	case "seed":
+		files, err := m.Seed(migrations.FS)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			fmt.Printf("seeded %s\n", file)
+		}
+		return nil
+	}
+
+	return errors.New(commandUsage)
+}
+
+// newMigrator returns a migrator for the embedded migrations of the database driver
+func newMigrator(db *driver.DB) (*migrate.Migrator, error) {
+	dialect, err := migrate.Dialect(db.Driver)
+	if err != nil {
+		return nil, err
+	}
+	return migrate.New(db.Conn, dialect, migrations.FS)
+}
+
+func migrateUp(m *migrate.Migrator) error {
+	applied, err := m.Up()
+	for _, migration := range applied {
+		fmt.Printf("applied %s_%s\n", migration.Version, migration.Name)
+	}
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		fmt.Println("no pending migrations")
+	}
+	return nil
+}
+
+func migrateDown(m *migrate.Migrator, steps int) error {
+	rolledBack, err := m.Down(steps)
+	for _, migration := range rolledBack {
+		fmt.Printf("rolled back %s_%s\n", migration.Version, migration.Name)
+	}
+	if err != nil {
+		return err
+	}
+	if len(rolledBack) == 0 {
+		fmt.Println("no applied migrations")
+	}
+	return nil
+}
+
+func migrateStatus(m *migrate.Migrator) error {
+	statuses, err := m.Status()
+	if err != nil {
+		return err
+	}
+	for _, s := range statuses {
+		status := "pending"
+		if s.Applied {
+			status = "applied"
+		}
+		fmt.Printf("%-8s %s_%s\n", status, s.Version, s.Name)
+	}
+	return nil
+}
+
+// checkPendingMigrations returns an error if the database has migrations that have not been applied
+func checkPendingMigrations(db *driver.DB) error {
+	if db.Driver == driver.Memory {
+		return nil
+	}
+
+	m, err := newMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	pending, err := m.Pending()
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%d pending migrations, the first is %s_%s, run \"migrate up\" first",
+			len(pending), pending[0].Version, pending[0].Name)
+	}
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/database"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/dates"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/driver"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/jobs"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/reminders"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/reports"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/sessions"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/waitlist"
+)
+
+// jobHistoryDays is how long the runs of the background jobs are kept
+const jobHistoryDays = 30
+
+// reportSender is the address the weekly report is mailed from
+const reportSender = "kaito@laptop-rental.com"
+
+// jobOwner names this instance in the job leases and history
+func jobOwner() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// newScheduler returns the scheduler of the background jobs, with the sessions kept in db cleaned up by it when
+// cleanSessions is set and the weekly report mailed when reportemail is set
+func newScheduler(db *driver.DB, repo database.DBRepository, cleanSessions bool) (*jobs.Scheduler, error) {
+	s := jobs.New(repo, jobOwner(), app.InfoLog, app.ErrorLog)
+
+	all := []jobs.Job{
+		{
+			// abandoned checkouts don't keep their dates
+			Name:     "expire-holds",
+			Schedule: jobs.MustParseSchedule("* * * * *"),
+			Lease:    time.Minute,
+			Run: func(ctx context.Context) (string, error) {
+				n, err := repo.DeleteExpiredHolds(time.Now())
+				if err != nil || n == 0 {
+					return "", err
+				}
+				return fmt.Sprintf("released %d expired holds", n), nil
+			},
+		},
+		{
+			// the dates freed by expired holds, and the holds of customers who didn't book, go to the next
+			// customer on the waitlist
+			Name:     "waitlist",
+			Schedule: jobs.MustParseSchedule("* * * * *"),
+			Lease:    time.Minute,
+			Run: func(ctx context.Context) (string, error) {
+				n, err := waitlist.NotifyAll(&app, repo)
+				if err != nil || n == 0 {
+					return "", err
+				}
+				return fmt.Sprintf("notified %d customers on the waitlist", n), nil
+			},
+		},
+		{
+			// every reminder is recorded before it is mailed, so a retry never mails one twice
+			Name:       "reminders",
+			Schedule:   jobs.MustParseSchedule("@hourly"),
+			Retries:    2,
+			RetryDelay: time.Minute,
+			Lease:      10 * time.Minute,
+			Run: func(ctx context.Context) (string, error) {
+				n, err := reminders.Send(&app, repo, remindersConfig, dates.Today())
+				if err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("mailed %d reminders", n), nil
+			},
+		},
+		{
+			// the mails that couldn't be sent right away, and the ones queued to be sent in the background
+			Name:     "mail-retry",
+			Schedule: jobs.MustParseSchedule("* * * * *"),
+			Lease:    5 * time.Minute,
+			Run: func(ctx context.Context) (string, error) {
+				sent, failed, err := sendQueuedMail(repo, sendMail, time.Now())
+				if err != nil || sent+failed == 0 {
+					return "", err
+				}
+				return fmt.Sprintf("sent %d queued mails, gave up %d", sent, failed), nil
+			},
+		},
+		{
+			Name:       "prune-job-history",
+			Schedule:   jobs.MustParseSchedule("30 3 * * *"),
+			Retries:    1,
+			RetryDelay: time.Minute,
+			Run: func(ctx context.Context) (string, error) {
+				n, err := repo.DeleteJobRunsBefore(time.Now().AddDate(0, 0, -jobHistoryDays))
+				if err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("deleted %d runs older than %d days", n, jobHistoryDays), nil
+			},
+		},
+	}
+	if reportEmail != "" {
+		all = append(all, jobs.Job{
+			// the report of the last week, Monday to Sunday, is mailed on Monday morning
+			Name:       "weekly-report",
+			Schedule:   jobs.MustParseSchedule("0 7 * * 1"),
+			Retries:    2,
+			RetryDelay: time.Minute,
+			Run: func(ctx context.Context) (string, error) {
+				end := dates.Today().AddDate(0, 0, -1)
+				start := end.AddDate(0, 0, -6)
+				report, err := reports.Build(repo, start, end)
+				if err != nil {
+					return "", err
+				}
+				_, err = repo.QueueMail(&models.QueuedMail{
+					Mail:          report.Mail(reportSender, reportEmail),
+					Status:        models.MailPending,
+					NextAttemptAt: time.Now(),
+				})
+				if err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("queued the report from %s to %s for %s", start.Format(dates.Layout),
+					end.Format(dates.Layout), reportEmail), nil
+			},
+		})
+	}
+	if cleanSessions {
+		all = append(all, jobs.Job{
+			Name:     "session-cleanup",
+			Schedule: jobs.MustParseSchedule("*/5 * * * *"),
+			Run: func(ctx context.Context) (string, error) {
+				n, err := sessions.DeleteExpired(ctx, db)
+				if err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("deleted %d expired sessions", n), nil
+			},
+		})
+	}
+
+	for _, j := range all {
+		err := s.Add(j)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
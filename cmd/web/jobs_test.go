@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/database"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/driver"
+)
+
+func TestNewScheduler(t *testing.T) {
+	db := driver.ConnectMemory()
+	repo := database.NewMemory(&app)
+
+	s, err := newScheduler(db, repo, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, j := range s.Jobs() {
+		names = append(names, j.Name)
+	}
+	if len(names) != 5 || names[0] != "expire-holds" || names[1] != "waitlist" || names[2] != "reminders" ||
+		names[3] != "mail-retry" || names[4] != "prune-job-history" {
+		t.Errorf("unexpected jobs %v", names)
+	}
+
+	s, _ = newScheduler(db, repo, true)
+	if jobs := s.Jobs(); len(jobs) != 6 || jobs[5].Name != "session-cleanup" {
+		t.Errorf("expected the session cleanup job, got %+v", jobs)
+	}
+
+	defer func() { reportEmail = "" }()
+	reportEmail = "owner@example.com"
+	s, _ = newScheduler(db, repo, false)
+	if jobs := s.Jobs(); len(jobs) != 6 || jobs[5].Name != "weekly-report" {
+		t.Errorf("expected the weekly report job, got %+v", jobs)
+	}
+}
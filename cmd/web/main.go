@@ -7,28 +7,48 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/alexedwards/scs/v2"
 	"github.com/joho/godotenv"
 	"github.com/kaitolucifer/go-laptop-rental-site/internal/config"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/dates"
 	"github.com/kaitolucifer/go-laptop-rental-site/internal/driver"
 	"github.com/kaitolucifer/go-laptop-rental-site/internal/handlers"
 	"github.com/kaitolucifer/go-laptop-rental-site/internal/helpers"
 	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
 	"github.com/kaitolucifer/go-laptop-rental-site/internal/render"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/sessions"
+	"github.com/kaitolucifer/go-laptop-rental-site/static"
+	"github.com/kaitolucifer/go-laptop-rental-site/templates"
 )
 
 // portNumber is the server port number to use
 const portNumber = ":8080"
 
+// pathStatic is the static files directory, served in place of the embedded files when templates are reloaded
+const pathStatic = "./static"
+
+// templateReloadInterval is how often changed templates are looked for when they are read from disk
+const templateReloadInterval = time.Second
+
 // read flags
 var (
 	inProduction                                      = flag.Bool("production", true, "Application is in production")
-	useCache                                          = flag.Bool("cache", true, "Use template cache")
+	useCache                                          = flag.Bool("cache", true, "Serve the embedded templates and static files, false reads them from disk and reloads changed templates")
+	requireMigrations                                 = flag.Bool("require-migrations", false, "Refuse to start while database migrations are pending")
 	dbHost, dbName, dbUser, dbPassword, dbPort, dbSSL string
+	dbDriver, dbPath                                  string
+	sessionStore, redisAddr, redisPassword            string
+	siteURL, holdMinutes, timezone, reportEmail       string
+	reminderDays, reminderTemplates                   [3]string
 )
 
+// defaultHoldDuration is how long dates are held during checkout unless holdminutes is set
+const defaultHoldDuration = 15 * time.Minute
+
 // app contains all app config
 var app config.AppConfig
 
@@ -43,17 +63,42 @@ func main() {
 	dbUser = os.Getenv("dbuser")
 	dbPassword = os.Getenv("dbpassword")
 	dbPort = os.Getenv("dbport")
-	dbSSL = os.Getenv("dbssl") // (disbale, prefer, require)
+	dbSSL = os.Getenv("dbssl")               // (disbale, prefer, require)
+	dbDriver = os.Getenv("dbdriver")         // (postgres, sqlite, memory)
+	dbPath = os.Getenv("dbpath")             // sqlite database file
+	sessionStore = os.Getenv("sessionstore") // (memory, database, redis)
+	redisAddr = os.Getenv("redisaddr")
+	redisPassword = os.Getenv("redispassword")
+	siteURL = os.Getenv("siteurl")         // public address for links in emails
+	holdMinutes = os.Getenv("holdminutes") // minutes the dates are held during checkout
+	timezone = os.Getenv("timezone")       // business timezone like Asia/Tokyo, the server's if empty
+	reportEmail = os.Getenv("reportemail") // address the weekly report is mailed to, none if empty
+	// days before pickup, before return and after return the reminders are mailed, 0 for on the day and negative for never
+	reminderDays = [3]string{os.Getenv("pickupreminderdays"), os.Getenv("returnreminderdays"), os.Getenv("overduedays")}
+	// email templates of the reminders, basic.email.html if empty
+	reminderTemplates = [3]string{os.Getenv("pickupremindertemplate"), os.Getenv("returnremindertemplate"), os.Getenv("overduetemplate")}
+
+	flag.Parse()
+	if flag.NArg() > 0 {
+		err = runCommand(flag.Args())
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
 	db, err := run()
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer db.Conn.Close()
+	defer db.Close()
 
 	defer close(app.MailChan)
 	log.Println("Starting mail listener")
-	listenForMail()
+	listenForMail(handlers.Repo.DB)
+
+	log.Println("Starting background jobs")
+	handlers.Repo.Jobs.Start()
 
 	log.Printf("Starting application on port %s\n", portNumber)
 
@@ -77,16 +122,35 @@ func run() (*driver.DB, error) {
 
 	flag.Parse()
 
-	if dbName == "" || dbUser == "" {
-		log.Fatal("Missing required flags")
-		os.Exit(1)
-	}
+	checkDBFlags()
 
 	mailChan := make(chan models.MailData)
 	app.MailChan = mailChan
 
 	app.InProduction = *inProduction
 
+	app.SiteURL = strings.TrimSuffix(siteURL, "/")
+	if app.SiteURL == "" {
+		app.SiteURL = "http://localhost" + portNumber
+	}
+
+	app.HoldDuration = defaultHoldDuration
+	if holdMinutes != "" {
+		minutes, err := strconv.Atoi(holdMinutes)
+		if err != nil || minutes <= 0 {
+			log.Printf("Invalid holdminutes %q\n", holdMinutes)
+			return nil, fmt.Errorf("invalid holdminutes %q", holdMinutes)
+		}
+		app.HoldDuration = time.Duration(minutes) * time.Minute
+	}
+
+	loc, err := dates.LoadLocation(timezone)
+	if err != nil {
+		log.Printf("Invalid timezone %q\n", timezone)
+		return nil, fmt.Errorf("invalid timezone %q", timezone)
+	}
+	dates.SetLocation(loc)
+
 	app.InfoLog = log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
 	app.ErrorLog = log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
 
@@ -97,27 +161,107 @@ func run() (*driver.DB, error) {
 	app.Session.Cookie.Secure = app.InProduction
 
 	// connect to database
-	app.InfoLog.Println("Connecting to database...")
-	connectionString := fmt.Sprintf("host=%s port=%s dbname=%s user=%s password=%s sslmode=%s", dbHost, dbPort, dbName, dbUser, dbPassword, dbSSL)
-	db, err := driver.ConnectSQL(connectionString)
+	app.InfoLog.Printf("Connecting to %s database...\n", dbDriver)
+	db, err := connectDB()
 	if err != nil {
 		log.Printf("Cannot connect to database: %s\n", err)
 		return nil, err
 	}
 	log.Println("Connected to database")
 
-	tc, err := render.CreateTemplateCache(render.PathTemplates)
+	sessionConfig := sessions.Config{
+		Store:         sessionStore,
+		RedisAddr:     redisAddr,
+		RedisPassword: redisPassword,
+	}
+	err = sessionConfig.Validate()
+	if err != nil {
+		log.Printf("Invalid session settings: %s\n", err)
+		return db, err
+	}
+	app.Session.Store, err = sessions.NewStore(sessionConfig, db)
+	if err != nil {
+		log.Printf("Cannot create %s session store: %s\n", sessionConfig.Store, err)
+		return db, err
+	}
+	app.InfoLog.Printf("Keeping sessions in %s\n", sessionConfig.Store)
+
+	if *requireMigrations {
+		err = checkPendingMigrations(db)
+		if err != nil {
+			log.Printf("Cannot start with an outdated database: %s\n", err)
+			return db, err
+		}
+	}
+
+	app.UseCache = *useCache
+	if app.UseCache {
+		app.Templates = templates.FS
+		app.Static = static.FS
+	} else {
+		app.Templates = os.DirFS(render.PathTemplates)
+		app.Static = os.DirFS(pathStatic)
+	}
+
+	tc, err := render.CreateTemplateCacheFS(app.Templates)
 	if err != nil {
 		log.Printf("Cannot create template cache: %s\n", err)
 		return db, err
 	}
 	app.TemplateCache = tc
-	app.UseCache = *useCache
+
+	remindersConfig, err = reminderConfig(app.Templates)
+	if err != nil {
+		log.Printf("Invalid reminder settings: %s\n", err)
+		return db, err
+	}
 
 	repo := handlers.NewRepo(&app, db)
+	repo.Jobs, err = newScheduler(db, repo.DB, sessionConfig.Store == sessions.Database && db.Driver != driver.Memory)
+	if err != nil {
+		log.Printf("Cannot schedule the background jobs: %s\n", err)
+		return db, err
+	}
 	handlers.NewHandlers(repo)
 	render.NewRenderer(&app)
 	helpers.NewHelpers(&app)
 
+	if !app.UseCache {
+		err = render.WatchTemplates(render.PathTemplates, templateReloadInterval)
+		if err != nil {
+			log.Printf("Cannot watch templates: %s\n", err)
+			return db, err
+		}
+	}
+
 	return db, nil
 }
+
+// checkDBFlags exits if the database settings are incomplete
+func checkDBFlags() {
+	cfg := dbConfig()
+	err := cfg.Validate()
+	if err != nil {
+		log.Fatalf("Missing required flags: %s", err)
+	}
+	dbDriver = cfg.Driver
+}
+
+// dbConfig returns the database settings read from the environment
+func dbConfig() driver.Config {
+	return driver.Config{
+		Driver:   dbDriver,
+		Host:     dbHost,
+		Name:     dbName,
+		User:     dbUser,
+		Password: dbPassword,
+		Port:     dbPort,
+		SSL:      dbSSL,
+		Path:     dbPath,
+	}
+}
+
+// connectDB connects to the database selected by dbDriver
+func connectDB() (*driver.DB, error) {
+	return driver.Connect(dbConfig())
+}
@@ -23,6 +23,14 @@ func TestRun(t *testing.T) {
 	dbPassword = os.Getenv("dbpassword")
 	dbPort = os.Getenv("dbport")
 	dbSSL = os.Getenv("dbssl") // (disbale, prefer, require)
+	dbDriver = os.Getenv("dbdriver")
+	dbPath = os.Getenv("dbpath")
+	sessionStore = os.Getenv("sessionstore")
+	redisAddr = os.Getenv("redisaddr")
+	redisPassword = os.Getenv("redispassword")
+	siteURL = os.Getenv("siteurl")
+	holdMinutes = os.Getenv("holdminutes")
+	timezone = os.Getenv("timezone")
 
 	_, err = run()
 	if err != nil {
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/justinas/nosurf"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/apperrors"
 	"github.com/kaitolucifer/go-laptop-rental-site/internal/helpers"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/i18n"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/render"
 )
 
 // NoSurf adds CSRF protection to all POST requests
@@ -17,14 +21,54 @@ func NoSurf(next http.Handler) http.Handler {
 		Secure:   app.InProduction,
 		SameSite: http.SameSiteLaxMode,
 	})
+	csrfHandler.SetFailureHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		render.Error(w, r, apperrors.Forbidden(nosurf.Reason(r), "The form has expired or was sent from another site, please reload the page and try again"))
+	}))
 	return csrfHandler
 }
 
+// Recover answers a request whose handler panicked with the 500 page. It runs after the session and the
+// language are loaded, which the page needs, chi's Recoverer catches panics before that
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+			render.Error(w, r, apperrors.Internal(fmt.Errorf("panic: %v", rec)))
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
 // SessionLoad loads and saves the ssion on every request
 func SessionLoad(next http.Handler) http.Handler {
 	return app.Session.LoadAndSave(next)
 }
 
+// Locale decides the language of the request, a ?lang= link saves the choice in the session,
+// without one the Accept-Language header of the browser decides
+func Locale(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if lang := r.URL.Query().Get("lang"); i18n.Supported(lang) {
+			app.Session.Put(r.Context(), "locale", lang)
+		}
+
+		locale := app.Session.GetString(r.Context(), "locale")
+		if locale == "" {
+			locale = i18n.Negotiate(r.Header.Get("Accept-Language"))
+		}
+
+		w.Header().Set("Content-Language", locale)
+		w.Header().Add("Vary", "Accept-Language")
+		next.ServeHTTP(w, r.WithContext(i18n.WithLocale(r.Context(), locale)))
+	})
+}
+
 // Auth request user to log in first
 func Auth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -1,10 +1,35 @@
 package main
 
 import (
+	"log"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
 	"testing"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/i18n"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/render"
+	"github.com/kaitolucifer/go-laptop-rental-site/templates"
 )
 
+// setupErrorPages sets up what the error pages need: a session, logs and the template cache
+func setupErrorPages(t *testing.T) {
+	t.Helper()
+	app.Session = scs.New()
+	app.InfoLog = log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
+	app.ErrorLog = log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
+
+	tc, err := render.CreateTemplateCacheFS(templates.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.TemplateCache = tc
+	render.NewRenderer(&app)
+}
+
 func TestNoSurve(t *testing.T) {
 	var handler http.Handler
 	h := NoSurf(handler)
@@ -28,3 +53,92 @@ func TestSessionLoad(t *testing.T) {
 		t.Errorf("return type is not http.Handler: %s", v)
 	}
 }
+
+func TestLocale(t *testing.T) {
+	app.Session = scs.New()
+
+	var got string
+	h := SessionLoad(Locale(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = i18n.FromContext(r.Context())
+	})))
+
+	get := func(url, acceptLanguage string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		req.Header.Set("Accept-Language", acceptLanguage)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	get("/", "ja-JP,ja;q=0.9,en;q=0.8", nil)
+	if got != "ja" {
+		t.Errorf("expected ja from the browser, got %q", got)
+	}
+	get("/", "fr-FR", nil)
+	if got != "en" {
+		t.Errorf("expected en for an unsupported language, got %q", got)
+	}
+
+	// the choice of a ?lang= link outweighs the browser for the rest of the session
+	rr := get("/?lang=en", "ja", nil)
+	if got != "en" || rr.Header().Get("Content-Language") != "en" {
+		t.Errorf("expected the chosen en, got %q", got)
+	}
+	get("/about", "ja", rr.Result().Cookies())
+	if got != "en" {
+		t.Errorf("expected the chosen en to stick, got %q", got)
+	}
+	get("/?lang=xx", "ja", nil)
+	if got != "ja" {
+		t.Errorf("expected an unknown ?lang= to be ignored, got %q", got)
+	}
+}
+
+func TestNoSurf_Failure(t *testing.T) {
+	setupErrorPages(t)
+
+	h := SessionLoad(Locale(NoSurf(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("a POST without a CSRF token got through")
+	}))))
+
+	req := httptest.NewRequest("POST", "/make-reservation", strings.NewReader(url.Values{"first_name": {"Jane"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept-Language", "ja")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("got status %d, want %d", rr.Code, http.StatusForbidden)
+	}
+	if !strings.Contains(rr.Body.String(), i18n.T("ja", "Forbidden")) {
+		t.Error("expected the 403 page in the language of the request")
+	}
+}
+
+func TestRecover(t *testing.T) {
+	setupErrorPages(t)
+
+	h := SessionLoad(Locale(Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var laptops map[int]string
+		laptops[1] = "Alienware M15 R2"
+	}))))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Code != http.StatusInternalServerError || !strings.Contains(rr.Body.String(), "Something went wrong") {
+		t.Errorf("expected the 500 page, got %d", rr.Code)
+	}
+	if strings.Contains(rr.Body.String(), "nil map") {
+		t.Error("the 500 page shows the panic")
+	}
+
+	req := httptest.NewRequest("GET", "/laptops/1/calendar.json", nil)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusInternalServerError || rr.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("expected a JSON 500, got %d %s", rr.Code, rr.Header().Get("Content-Type"))
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"strconv"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/reminders"
+)
+
+// remindersConfig is when the reminders are mailed and their templates
+var remindersConfig = reminders.DefaultConfig
+
+// reminderKinds are the reminders in the order of reminderDays and reminderTemplates
+var reminderKinds = [3]string{models.ReminderPickup, models.ReminderReturn, models.ReminderOverdue}
+
+// reminderConfig returns the reminder settings read from the environment, the defaults for the ones not set.
+// The templates must be among templates.
+func reminderConfig(templates fs.FS) (reminders.Config, error) {
+	cfg := reminders.DefaultConfig
+	days := [3]*int{&cfg.PickupDays, &cfg.ReturnDays, &cfg.OverdueDays}
+	cfg.Templates = make(map[string]string)
+
+	for i, kind := range reminderKinds {
+		if reminderDays[i] != "" {
+			n, err := strconv.Atoi(reminderDays[i])
+			if err != nil {
+				return cfg, fmt.Errorf("invalid days %q for the %s reminder", reminderDays[i], kind)
+			}
+			*days[i] = n
+		}
+
+		if reminderTemplates[i] != "" {
+			_, err := fs.Stat(templates, reminderTemplates[i])
+			if err != nil {
+				return cfg, fmt.Errorf("template %q of the %s reminder: %w", reminderTemplates[i], kind, err)
+			}
+			cfg.Templates[kind] = reminderTemplates[i]
+		}
+	}
+
+	return cfg, nil
+}
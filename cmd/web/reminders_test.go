@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+)
+
+func TestReminderConfig(t *testing.T) {
+	templates := fstest.MapFS{"pickup.email.html": {Data: []byte("[%body%]")}}
+	defer func() { reminderDays, reminderTemplates = [3]string{}, [3]string{} }()
+
+	cfg, err := reminderConfig(templates)
+	if err != nil || cfg.PickupDays != 2 || cfg.ReturnDays != 1 || cfg.OverdueDays != 1 || len(cfg.Templates) != 0 {
+		t.Fatalf("expected the defaults, got %+v %v", cfg, err)
+	}
+
+	reminderDays = [3]string{"5", "", "-1"}
+	reminderTemplates = [3]string{"pickup.email.html", "", ""}
+	cfg, err = reminderConfig(templates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.PickupDays != 5 || cfg.ReturnDays != 1 || cfg.OverdueDays != -1 || cfg.Templates[models.ReminderPickup] != "pickup.email.html" {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+
+	reminderDays = [3]string{"two", "", ""}
+	if _, err = reminderConfig(templates); err == nil {
+		t.Error("expected invalid days to be refused")
+	}
+
+	reminderDays = [3]string{}
+	reminderTemplates = [3]string{"", "missing.email.html", ""}
+	if _, err = reminderConfig(templates); err == nil {
+		t.Error("expected a missing template to be refused")
+	}
+}
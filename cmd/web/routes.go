@@ -17,10 +17,12 @@ func routes(app *config.AppConfig) http.Handler {
 
 	mux := chi.NewRouter()
 
-	// middleware
+	// middleware, the session and the language come before anything that can answer with an error page
 	mux.Use(middleware.Recoverer)
-	mux.Use(NoSurf)
 	mux.Use(SessionLoad)
+	mux.Use(Locale)
+	mux.Use(NoSurf)
+	mux.Use(Recover)
 
 	// endpoint
 	mux.Get("/", handlers.Repo.Home)
@@ -33,6 +35,11 @@ func routes(app *config.AppConfig) http.Handler {
 	mux.Post("/search-availability-modal", handlers.Repo.SearchAvailabilityModal)
 	mux.Get("/choose-laptop/{id}", handlers.Repo.ChooseLaptop)
 	mux.Get("/rent-laptop", handlers.Repo.RentLaptop)
+	mux.Get("/laptops/{id}/calendar", handlers.Repo.LaptopCalendar)
+	mux.Get("/laptops/{id}/calendar.json", handlers.Repo.LaptopCalendarJSON)
+	mux.Get("/waitlist", handlers.Repo.JoinWaitlist)
+	mux.Post("/waitlist", handlers.Repo.PostJoinWaitlist)
+	mux.Get("/waitlist/claim", handlers.Repo.ClaimWaitlist)
 	mux.Get("/make-reservation", handlers.Repo.MakeReservation)
 	mux.Post("/make-reservation", handlers.Repo.PostMakeReservation)
 	mux.Get("/reservation-summary", handlers.Repo.ReservationSummary)
@@ -50,14 +57,26 @@ func routes(app *config.AppConfig) http.Handler {
 		mux.Post("/reservations/{type}/{id}", handlers.Repo.PostAdminShowReservation)
 		mux.Get("/process-reservation/{type}/{id}/do", handlers.Repo.AdminProcessReservation)
 		mux.Get("/delete-reservation/{type}/{id}/do", handlers.Repo.AdminDeleteReservation)
+		mux.Post("/reservations/bulk", handlers.Repo.PostAdminBulkReservations)
+		mux.Get("/create-reservation", handlers.Repo.AdminCreateReservation)
+		mux.Post("/create-reservation", handlers.Repo.PostAdminCreateReservation)
 		mux.Get("/reservations-calendar", handlers.Repo.AdminReservationsCalendar)
 		mux.Post("/reservations-calendar", handlers.Repo.PostAdminReservationsCalendar)
+		mux.Get("/timeline", handlers.Repo.AdminTimeline)
+		mux.Get("/timeline.json", handlers.Repo.AdminTimelineJSON)
+		mux.Post("/timeline/reservations/{id}.json", handlers.Repo.PostAdminTimelineReservation)
+		mux.Post("/timeline/blocks/{id}.json", handlers.Repo.PostAdminTimelineBlock)
+		mux.Get("/reports", handlers.Repo.AdminReports)
+		mux.Get("/reports.csv", handlers.Repo.AdminReportsCSV)
+		mux.Get("/sessions", handlers.Repo.AdminSessions)
+		mux.Post("/sessions/revoke", handlers.Repo.PostAdminRevokeSession)
+		mux.Get("/jobs", handlers.Repo.AdminJobs)
 	})
 
 	mux.NotFound(handlers.Repo.NotFound)
 
 	// static files
-	fileServer := http.FileServer(http.Dir("./static/"))
+	fileServer := http.FileServer(http.FS(app.Static))
 	mux.Handle("/static/*", http.StripPrefix("/static/", fileServer))
 	return mux
 }
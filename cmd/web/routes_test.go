@@ -1,10 +1,13 @@
 package main
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/go-chi/chi"
 	"github.com/kaitolucifer/go-laptop-rental-site/internal/config"
+	"github.com/kaitolucifer/go-laptop-rental-site/static"
 )
 
 func TestRoutes(t *testing.T) {
@@ -18,3 +21,23 @@ func TestRoutes(t *testing.T) {
 		t.Errorf("return type is not *chi.Mux: %s", v)
 	}
 }
+
+func TestRoutes_EmbeddedStatic(t *testing.T) {
+	var app config.AppConfig
+	app.Static = static.FS
+
+	mux := routes(&app)
+	for _, path := range []string{"/static/css/style.css", "/static/js/app.js", "/static/admin/css/style.css"} {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != http.StatusOK {
+			t.Errorf("%s: got status %d, want %d", path, rr.Code, http.StatusOK)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/static/admin/package.json", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("theme sources are served: got status %d", rr.Code)
+	}
+}
@@ -2,26 +2,94 @@ package main
 
 import (
 	"fmt"
-	"io/ioutil"
+	"io/fs"
 	"log"
 	"strings"
 	"time"
 
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/database"
 	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
 	mail "github.com/xhit/go-simple-mail/v2"
 )
 
-// listenForMail() listens for app.MailChan and send mail
-func listenForMail() {
+// mailAttempts is how many times a mail is attempted before it is given up and left as failed in the queue
+const mailAttempts = 6
+
+// mailRetryDelay is how long a mail that can't be sent waits before the next attempt, doubled after every attempt
+const mailRetryDelay = 5 * time.Minute
+
+// mailBatch is how many queued mails a run of the mail-retry job sends at most
+const mailBatch = 20
+
+// listenForMail() listens for app.MailChan and send mail, the mails that can't be sent are queued in repo for the
+// mail-retry job
+func listenForMail(repo database.DBRepository) {
 	go func() {
 		for m := range app.MailChan {
-			sendMail(m)
+			err := sendMail(m)
+			if err == nil {
+				continue
+			}
+			app.ErrorLog.Printf("cannot send mail to %s, queued for retry: %s\n", m.To, err)
+
+			_, err = repo.QueueMail(&models.QueuedMail{
+				Mail:          m,
+				Status:        models.MailPending,
+				Attempts:      1,
+				LastError:     err.Error(),
+				NextAttemptAt: nextMailAttempt(time.Now(), 1),
+			})
+			if err != nil {
+				app.ErrorLog.Printf("cannot queue mail to %s: %s\n", m.To, err)
+			}
 		}
 	}()
 }
 
+// nextMailAttempt returns when a mail that failed attempts times at now is attempted again
+func nextMailAttempt(now time.Time, attempts int) time.Time {
+	return now.Add(mailRetryDelay << uint(attempts-1))
+}
+
+// sendQueuedMail sends with send the mails of repo due at now, and returns how many were sent and how many
+// were given up
+func sendQueuedMail(repo database.DBRepository, send func(models.MailData) error, now time.Time) (int, int, error) {
+	due, err := repo.GetDueMail(now, mailBatch)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	sent, failed := 0, 0
+	for _, q := range due {
+		err = send(q.Mail)
+		if err == nil {
+			sent++
+			err = repo.DeleteQueuedMail(q.ID)
+			if err != nil {
+				return sent, failed, err
+			}
+			continue
+		}
+
+		q.Attempts++
+		q.LastError = err.Error()
+		q.NextAttemptAt = nextMailAttempt(now, q.Attempts)
+		if q.Attempts >= mailAttempts {
+			q.Status = models.MailFailed
+			failed++
+			app.ErrorLog.Printf("gave up mail %d to %s after %d attempts: %s\n", q.ID, q.Mail.To, q.Attempts, err)
+		}
+		err = repo.UpdateQueuedMail(q)
+		if err != nil {
+			return sent, failed, err
+		}
+	}
+
+	return sent, failed, nil
+}
+
 // sendMail sends mail
-func sendMail(m models.MailData) {
+func sendMail(m models.MailData) error {
 	server := mail.NewSMTPClient()
 	server.Host = "localhost" // mailhog smtp test host
 	server.Port = 1025        // mailhog smtp test port
@@ -33,19 +101,14 @@ func sendMail(m models.MailData) {
 	// server.Password
 	// server.Encryption
 
-	client, err := server.Connect()
-	if err != nil {
-		app.ErrorLog.Println(err)
-	}
-
 	email := mail.NewMSG()
 	email.SetFrom(m.From).AddTo(m.To).SetSubject(m.Subject)
 	if m.Template == "" {
 		email.SetBody(mail.TextHTML, m.Content)
 	} else {
-		data, err := ioutil.ReadFile(fmt.Sprintf("./templates/%s", m.Template))
+		data, err := fs.ReadFile(app.Templates, m.Template)
 		if err != nil {
-			app.ErrorLog.Println(err)
+			return fmt.Errorf("mail template %s: %w", m.Template, err)
 		}
 
 		mailTemplate := string(data)
@@ -53,10 +116,16 @@ func sendMail(m models.MailData) {
 		email.SetBody(mail.TextHTML, msgToSend)
 	}
 
+	client, err := server.Connect()
+	if err != nil {
+		return err
+	}
+
 	err = email.Send(client)
 	if err != nil {
-		log.Println(err)
-	} else {
-		log.Println("Email sent!")
+		return err
 	}
+	log.Println("Email sent!")
+
+	return nil
 }
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/database"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+)
+
+func TestSendQueuedMail(t *testing.T) {
+	app.ErrorLog = log.New(ioutil.Discard, "", 0)
+	repo := database.NewMemory(&app)
+	now := time.Date(2099, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	for i, to := range []string{"ok@example.com", "down@example.com", "later@example.com"} {
+		repo.QueueMail(&models.QueuedMail{
+			Mail:          models.MailData{To: to, Subject: "queued"},
+			Status:        models.MailPending,
+			NextAttemptAt: now.Add(time.Duration(i) * time.Hour),
+		})
+	}
+	var sentTo []string
+	send := func(m models.MailData) error {
+		if m.To == "down@example.com" {
+			return errors.New("connection refused")
+		}
+		sentTo = append(sentTo, m.To)
+		return nil
+	}
+
+	// the third mail isn't due yet
+	sent, failed, err := sendQueuedMail(repo, send, now.Add(time.Hour))
+	if err != nil || sent != 1 || failed != 0 || len(sentTo) != 1 || sentTo[0] != "ok@example.com" {
+		t.Fatalf("expected the first mail sent, got %d %d %v %v", sent, failed, sentTo, err)
+	}
+	due, _ := repo.GetDueMail(now.Add(time.Hour+mailRetryDelay), 10)
+	if len(due) != 1 || due[0].Mail.To != "down@example.com" || due[0].Attempts != 1 ||
+		due[0].LastError != "connection refused" || !due[0].NextAttemptAt.Equal(now.Add(time.Hour+mailRetryDelay)) {
+		t.Fatalf("expected the failed mail retried after %s, got %+v", mailRetryDelay, due)
+	}
+
+	// the retries back off until the mail is given up
+	at := now.Add(time.Hour)
+	for attempts := 1; attempts < mailAttempts; attempts++ {
+		at = nextMailAttempt(at, attempts)
+		sent, failed, err = sendQueuedMail(repo, send, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if failed != 1 || len(sentTo) != 2 {
+		t.Errorf("expected the mail given up after %d attempts and the other one sent, got %d failed, sent to %v",
+			mailAttempts, failed, sentTo)
+	}
+	due, _ = repo.GetDueMail(at.AddDate(1, 0, 0), 10)
+	if len(due) != 0 {
+		t.Errorf("expected no mail left to send, got %+v", due)
+	}
+}
+
+func TestNextMailAttempt(t *testing.T) {
+	now := time.Date(2099, 1, 1, 9, 0, 0, 0, time.UTC)
+	if got := nextMailAttempt(now, 1); !got.Equal(now.Add(mailRetryDelay)) {
+		t.Errorf("expected the first retry after %s, got %s", mailRetryDelay, got)
+	}
+	if got := nextMailAttempt(now, 3); !got.Equal(now.Add(4 * mailRetryDelay)) {
+		t.Errorf("expected the delay doubled twice, got %s", got)
+	}
+}
@@ -3,15 +3,20 @@ module github.com/kaitolucifer/go-laptop-rental-site
 go 1.16
 
 require (
-	github.com/alexedwards/scs/v2 v2.4.0
+	github.com/alexedwards/scs/postgresstore v0.0.0-20240316134038-7e11d57e8885
+	github.com/alexedwards/scs/redisstore v0.0.0-20240316134038-7e11d57e8885
+	github.com/alexedwards/scs/sqlite3store v0.0.0-20231113091146-cef4b05350c8
+	github.com/alexedwards/scs/v2 v2.8.0
 	github.com/asaskevich/govalidator v0.0.0-20210307081110-f21760c49a8d
 	github.com/go-chi/chi v1.5.4
 	github.com/gofrs/uuid v4.0.0+incompatible // indirect
+	github.com/gomodule/redigo v1.8.0
 	github.com/jackc/pgconn v1.8.1
 	github.com/jackc/pgx/v4 v4.11.0
 	github.com/joho/godotenv v1.3.0
 	github.com/justinas/nosurf v1.1.1
 	github.com/lib/pq v1.10.1 // indirect
+	github.com/mattn/go-sqlite3 v1.14.16
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/xhit/go-simple-mail/v2 v2.9.0
 	golang.org/x/crypto v0.0.0-20210322153248-0c34fe9e7dc2
@@ -0,0 +1,77 @@
+package apperrors
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/i18n"
+)
+
+// Error is an error answered with an HTTP status. Message is shown to the user, Err holds the details,
+// which are logged but never shown
+type Error struct {
+	Status int
+	// Message is in English, its verbs are filled in with Args
+	Message string
+	Args    []interface{}
+	Err     error
+}
+
+// New returns an error answered with status, err may be nil
+func New(status int, err error, msg string, args ...interface{}) *Error {
+	return &Error{Status: status, Message: msg, Args: args, Err: err}
+}
+
+// BadRequest returns an error for a request that is malformed, like a date that doesn't parse
+func BadRequest(err error, msg string, args ...interface{}) *Error {
+	return New(http.StatusBadRequest, err, msg, args...)
+}
+
+// Forbidden returns an error for a request that is refused, like one with a missing CSRF token
+func Forbidden(err error, msg string, args ...interface{}) *Error {
+	return New(http.StatusForbidden, err, msg, args...)
+}
+
+// NotFound returns an error for a page or record that doesn't exist
+func NotFound(err error, msg string, args ...interface{}) *Error {
+	return New(http.StatusNotFound, err, msg, args...)
+}
+
+// Internal returns an error for a failure on our side, like a database error, its message says nothing of err
+func Internal(err error) *Error {
+	return New(http.StatusInternalServerError, err, "Something went wrong on our side, please try again later")
+}
+
+// Error returns the message and the details, for logs
+func (e *Error) Error() string {
+	msg := fmt.Sprintf(e.Message, e.Args...)
+	if e.Err == nil {
+		return msg
+	}
+	return fmt.Sprintf("%s: %s", msg, e.Err)
+}
+
+// Unwrap returns the details
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// In returns the message in locale
+func (e *Error) In(locale string) string {
+	return i18n.T(locale, e.Message, e.Args...)
+}
+
+// From returns err as an *Error: an *Error is kept, a missing database row is not found and anything
+// else is an internal error
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return NotFound(err, "The page you are looking for does not exist")
+	}
+	return Internal(err)
+}
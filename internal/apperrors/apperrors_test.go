@@ -0,0 +1,55 @@
+package apperrors
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestFrom(t *testing.T) {
+	details := errors.New("connection refused")
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"bad request", BadRequest(details, "Invalid Laptop ID"), http.StatusBadRequest},
+		{"wrapped", fmt.Errorf("showing the reservation: %w", Forbidden(nil, "No")), http.StatusForbidden},
+		{"missing row", fmt.Errorf("laptop 3: %w", sql.ErrNoRows), http.StatusNotFound},
+		{"anything else", details, http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		e := From(test.err)
+		if e.Status != test.status {
+			t.Errorf("%s: got status %d, want %d", test.name, e.Status, test.status)
+		}
+	}
+}
+
+func TestError(t *testing.T) {
+	details := errors.New("dial tcp 10.0.0.5:5432: connection refused")
+
+	e := Internal(details)
+	if !errors.Is(e, details) {
+		t.Error("the details are not wrapped")
+	}
+	if !strings.Contains(e.Error(), details.Error()) {
+		t.Errorf("the details are missing from %q, logs need them", e.Error())
+	}
+	if msg := e.In("en"); strings.Contains(msg, "10.0.0.5") {
+		t.Errorf("the message %q shows the details", msg)
+	}
+
+	e = NotFound(nil, "The requested URL %s was not found on this server", "/nope")
+	if got := e.In("ja"); got != "リクエストされた URL /nope はこのサーバーに見つかりませんでした" {
+		t.Errorf("got %q in Japanese", got)
+	}
+	if e.Error() != "The requested URL /nope was not found on this server" {
+		t.Errorf("got %q without details", e.Error())
+	}
+}
@@ -1,8 +1,10 @@
 package config
 
 import (
+	"html/template"
+	"io/fs"
 	"log"
-	"text/template"
+	"time"
 
 	"github.com/alexedwards/scs/v2"
 	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
@@ -12,9 +14,17 @@ import (
 type AppConfig struct {
 	UseCache      bool
 	TemplateCache map[string]*template.Template
-	InfoLog       *log.Logger
-	ErrorLog      *log.Logger
-	InProduction  bool
-	Session       *scs.SessionManager
-	MailChan      chan models.MailData
+	// Templates and Static are the templates and static files, embedded in the binary when UseCache is set
+	// and read from disk otherwise
+	Templates    fs.FS
+	Static       fs.FS
+	InfoLog      *log.Logger
+	ErrorLog     *log.Logger
+	InProduction bool
+	Session      *scs.SessionManager
+	MailChan     chan models.MailData
+	// SiteURL is the public address of the site, for links in emails
+	SiteURL string
+	// HoldDuration is how long the dates of a reservation are held while the customer fills in the reservation form
+	HoldDuration time.Duration
 }
@@ -0,0 +1,37 @@
+package csvsafe
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+// formulaPrefixes are the first characters that make a spreadsheet read a cell as a formula
+const formulaPrefixes = "=+-@\t\r"
+
+// Writer is a csv.Writer that escapes every cell it writes with Cell
+type Writer struct {
+	*csv.Writer
+}
+
+// NewWriter returns a Writer writing to w
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{Writer: csv.NewWriter(w)}
+}
+
+// Write writes record with its cells escaped
+func (w *Writer) Write(record []string) error {
+	escaped := make([]string, len(record))
+	for i, cell := range record {
+		escaped[i] = Cell(cell)
+	}
+	return w.Writer.Write(escaped)
+}
+
+// Cell returns cell prefixed with ' when it starts like a formula, so that it is shown as text
+func Cell(cell string) string {
+	if cell != "" && strings.IndexByte(formulaPrefixes, cell[0]) >= 0 {
+		return "'" + cell
+	}
+	return cell
+}
@@ -0,0 +1,38 @@
+package csvsafe
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCell(t *testing.T) {
+	tests := map[string]string{
+		"":                  "",
+		"John":              "John",
+		"=HYPERLINK(\"x\")": "'=HYPERLINK(\"x\")",
+		"+81 90 1234 5678":  "'+81 90 1234 5678",
+		"-2+3":              "'-2+3",
+		"@SUM(A1)":          "'@SUM(A1)",
+		"\t=1":              "'\t=1",
+		"john=smith@x.com":  "john=smith@x.com",
+		"2099-01-01":        "2099-01-01",
+	}
+	for cell, want := range tests {
+		if got := Cell(cell); got != want {
+			t.Errorf("Cell(%q) = %q, want %q", cell, got, want)
+		}
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Write([]string{"1", "=cmd|' /C calc'!A0", "Smith"})
+	w.Flush()
+	if err := w.Error(); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "1,'=cmd|' /C calc'!A0,Smith\n" {
+		t.Errorf("unexpected CSV %q", got)
+	}
+}
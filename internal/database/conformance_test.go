@@ -0,0 +1,1656 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/config"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/driver"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/migrate"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+	"github.com/kaitolucifer/go-laptop-rental-site/migrations"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func init() {
+	// hashing at the production cost takes seconds under the race detector, longer than the query timeouts
+	passwordCost = bcrypt.MinCost
+}
+
+// testBackends returns the backends to run the conformance suite against.
+// TEST_DB_BACKENDS is a comma separated list of memory, sqlite and postgres,
+// postgres also needs TEST_DATABASE_URL, the embedded migrations are applied to it.
+func testBackends() []string {
+	backends := os.Getenv("TEST_DB_BACKENDS")
+	if backends == "" {
+		backends = "memory,sqlite"
+	}
+	return strings.Split(backends, ",")
+}
+
+// newTestRepo returns a freshly set up repository for backend
+func newTestRepo(t *testing.T, backend string) DBRepository {
+	var app config.AppConfig
+
+	switch backend {
+	case driver.Memory:
+		return NewMemory(&app)
+	case driver.SQLite:
+		db, err := driver.ConnectSQLite(filepath.Join(t.TempDir(), "test.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		migrateTestDB(t, db.Conn, "sqlite3")
+		return NewSQLite(db.Conn, &app)
+	case driver.Postgres:
+		dsn := os.Getenv("TEST_DATABASE_URL")
+		if dsn == "" {
+			t.Skip("TEST_DATABASE_URL is not set")
+		}
+		conn, err := driver.NewDatabase(dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		migrateTestDB(t, conn, "postgres")
+		return NewPostgres(conn, &app)
+	}
+
+	t.Fatalf("unknown backend %q", backend)
+	return nil
+}
+
+// migrateTestDB applies the embedded migrations to a test database
+func migrateTestDB(t *testing.T, conn *sql.DB, dialect string) {
+	m, err := migrate.New(conn, dialect, migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// conformanceTests is the behaviour every DBRepository implementation has to share
+var conformanceTests = []struct {
+	name string
+	test func(t *testing.T, repo DBRepository)
+}{
+	{"laptops", testLaptops},
+	{"reservations", testReservations},
+	{"availability", testAvailability},
+	{"blocks", testBlocks},
+	{"block ranges", testBlockRanges},
+	{"users", testUsers},
+	{"user management", testUserManagement},
+	{"waitlist", testWaitlist},
+	{"holds", testHolds},
+	{"insert reservation checked", testInsertReservationChecked},
+	{"buffers", testBuffers},
+	{"booking rules", testBookingRules},
+	{"dashboard", testDashboard},
+	{"reports", testReports},
+	{"search reservations", testSearchReservations},
+	{"bulk reservations", testBulkReservations},
+	{"reschedule reservation", testRescheduleReservation},
+	{"move block", testMoveBlock},
+	{"change blocks", testChangeBlocks},
+	{"reminders", testReminders},
+	{"job leases", testJobLeases},
+	{"job runs", testJobRuns},
+	{"mail queue", testMailQueue},
+}
+
+func TestConformance(t *testing.T) {
+	for _, backend := range testBackends() {
+		backend := strings.TrimSpace(backend)
+		t.Run(backend, func(t *testing.T) {
+			repo := newTestRepo(t, backend)
+			for _, ct := range conformanceTests {
+				t.Run(ct.name, func(t *testing.T) {
+					ct.test(t, repo)
+				})
+			}
+		})
+	}
+}
+
+// testDate returns a date far enough in the future not to collide with real data
+func testDate(offset int) time.Time {
+	return time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, offset)
+}
+
+// insertTestReservation inserts a reservation with its laptop restriction
+func insertTestReservation(t *testing.T, repo DBRepository, laptopID int, start, end time.Time) int {
+	t.Helper()
+
+	res := models.Reservation{
+		FirstName: "John",
+		LastName:  "Smith",
+		Email:     fmt.Sprintf("john-%d@smith.com", time.Now().UnixNano()),
+		Phone:     "555-555-5555",
+		StartDate: start,
+		EndDate:   end,
+		LaptopID:  laptopID,
+		Locale:    "ja",
+	}
+	id, err := repo.InsertReservation(&res)
+	if err != nil {
+		t.Fatalf("InsertReservation: %s", err)
+	}
+	if id == 0 {
+		t.Fatal("InsertReservation returned id 0")
+	}
+
+	err = repo.InsertLaptopRestriction(&models.LaptopRestriction{
+		StartDate:     start,
+		EndDate:       end,
+		LaptopID:      laptopID,
+		ReservationID: id,
+		RestrictionID: 1,
+	})
+	if err != nil {
+		t.Fatalf("InsertLaptopRestriction: %s", err)
+	}
+
+	t.Cleanup(func() { repo.DeleteReservation(id) })
+
+	return id
+}
+
+func testLaptops(t *testing.T, repo DBRepository) {
+	laptops, err := repo.AllLaptops()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(laptops) < 2 {
+		t.Fatalf("expected the seeded laptops, got %d", len(laptops))
+	}
+	for i := 1; i < len(laptops); i++ {
+		if laptops[i-1].LaptopName > laptops[i].LaptopName {
+			t.Errorf("laptops are not ordered by name: %q before %q", laptops[i-1].LaptopName, laptops[i].LaptopName)
+		}
+	}
+
+	laptop, err := repo.GetLaptopByID(laptops[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if laptop.LaptopName != laptops[0].LaptopName {
+		t.Errorf("GetLaptopByID returned %q, expected %q", laptop.LaptopName, laptops[0].LaptopName)
+	}
+
+	_, err = repo.GetLaptopByID(-1)
+	if err == nil {
+		t.Error("GetLaptopByID returned no error for a non-existent laptop")
+	}
+}
+
+func testReservations(t *testing.T, repo DBRepository) {
+	id := insertTestReservation(t, repo, 1, testDate(0), testDate(2))
+
+	res, err := repo.GetReservatioByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.FirstName != "John" || res.LaptopID != 1 || res.Laptop.ID != 1 || res.Laptop.LaptopName == "" || res.Locale != "ja" {
+		t.Errorf("GetReservatioByID returned unexpected reservation: %+v", res)
+	}
+	if !res.StartDate.Equal(testDate(0)) || !res.EndDate.Equal(testDate(2)) {
+		t.Errorf("GetReservatioByID returned dates %s - %s, expected %s - %s", res.StartDate, res.EndDate, testDate(0), testDate(2))
+	}
+	if res.Processed != 0 {
+		t.Errorf("new reservation is processed: %d", res.Processed)
+	}
+
+	res.FirstName = "Jane"
+	res.Phone = "123"
+	err = repo.UpdateReservation(&res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, _ = repo.GetReservatioByID(id)
+	if res.FirstName != "Jane" || res.Phone != "123" {
+		t.Errorf("UpdateReservation did not update the reservation: %+v", res)
+	}
+
+	if !containsReservation(t, repo.AllNewReservations, id) {
+		t.Error("unprocessed reservation missing from AllNewReservations")
+	}
+
+	err = repo.UpdateReservationProcessed(id, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if containsReservation(t, repo.AllNewReservations, id) {
+		t.Error("processed reservation returned by AllNewReservations")
+	}
+	if !containsReservation(t, repo.AllReservations, id) {
+		t.Error("processed reservation missing from AllReservations")
+	}
+
+	err = repo.DeleteReservation(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repo.GetReservatioByID(id)
+	if err == nil {
+		t.Error("deleted reservation still exists")
+	}
+
+	// the laptop restriction is deleted with the reservation
+	available, err := repo.SearchAvailabilityByDatesByLaptopID(testDate(0), testDate(2), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !available {
+		t.Error("laptop is still restricted after deleting the reservation")
+	}
+}
+
+func containsReservation(t *testing.T, list func() ([]models.Reservation, error), id int) bool {
+	t.Helper()
+
+	reservations, err := list()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range reservations {
+		if r.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func testAvailability(t *testing.T, repo DBRepository) {
+	insertTestReservation(t, repo, 1, testDate(10), testDate(12))
+
+	tests := []struct {
+		name      string
+		start     int
+		end       int
+		available bool
+	}{
+		{"before", 7, 9, true},
+		{"ends on start date", 8, 10, false},
+		{"inside", 11, 11, false},
+		{"covers", 9, 13, false},
+		{"starts on end date", 12, 14, false},
+		{"after", 13, 15, true},
+	}
+
+	for _, test := range tests {
+		available, err := repo.SearchAvailabilityByDatesByLaptopID(testDate(test.start), testDate(test.end), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if available != test.available {
+			t.Errorf("%s: expected available %v, got %v", test.name, test.available, available)
+		}
+
+		laptops, err := repo.SearchAvailabilityForAllLaptops(testDate(test.start), testDate(test.end))
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := false
+		for _, laptop := range laptops {
+			if laptop.ID == 1 {
+				found = true
+			}
+		}
+		if found != test.available {
+			t.Errorf("%s: expected laptop 1 in SearchAvailabilityForAllLaptops %v, got %v", test.name, test.available, found)
+		}
+	}
+
+	// the other laptop is not affected
+	available, err := repo.SearchAvailabilityByDatesByLaptopID(testDate(10), testDate(12), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !available {
+		t.Error("reservation of laptop 1 restricted laptop 2")
+	}
+}
+
+func testBlocks(t *testing.T, repo DBRepository) {
+	resID := insertTestReservation(t, repo, 2, testDate(20), testDate(21))
+
+	err := repo.InsertOneDayBlockByLaptopID(2, testDate(23))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restrictions, err := repo.GetLaptopRestrictionsByDate(2, testDate(20), testDate(25))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restrictions) != 2 {
+		t.Fatalf("expected 2 restrictions, got %d", len(restrictions))
+	}
+
+	blockID := 0
+	for _, lr := range restrictions {
+		if lr.LaptopID != 2 {
+			t.Errorf("restriction of laptop %d returned for laptop 2", lr.LaptopID)
+		}
+		switch lr.RestrictionID {
+		case 1:
+			if lr.ReservationID != resID {
+				t.Errorf("reservation restriction has reservation id %d, expected %d", lr.ReservationID, resID)
+			}
+		case 2:
+			if lr.ReservationID != 0 {
+				t.Errorf("block has reservation id %d", lr.ReservationID)
+			}
+			if !lr.StartDate.Equal(testDate(23)) || !lr.EndDate.Equal(testDate(23)) {
+				t.Errorf("block spans %s - %s, expected one day on %s", lr.StartDate, lr.EndDate, testDate(23))
+			}
+			blockID = lr.ID
+		}
+	}
+	if blockID == 0 {
+		t.Fatal("block not returned by GetLaptopRestrictionsByDate")
+	}
+
+	available, _ := repo.SearchAvailabilityByDatesByLaptopID(testDate(23), testDate(23), 2)
+	if available {
+		t.Error("blocked day is available")
+	}
+
+	err = repo.DeleteBlockByID(blockID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	available, _ = repo.SearchAvailabilityByDatesByLaptopID(testDate(23), testDate(23), 2)
+	if !available {
+		t.Error("day is still blocked after deleting the block")
+	}
+}
+
+func testUsers(t *testing.T, repo DBRepository) {
+	id, _, err := repo.Authenticate("admin@admin.com", "password")
+	if err != nil {
+		t.Fatalf("seeded admin can't log in: %s", err)
+	}
+
+	_, _, err = repo.Authenticate("admin@admin.com", "wrong")
+	if err == nil {
+		t.Error("authenticated with a wrong password")
+	}
+	_, _, err = repo.Authenticate("nobody@admin.com", "password")
+	if err == nil {
+		t.Error("authenticated a non-existent user")
+	}
+
+	u, err := repo.GetUserByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Email != "admin@admin.com" || u.AccessLevel != 3 {
+		t.Errorf("GetUserByID returned unexpected user: %+v", u)
+	}
+
+	original := u
+	u.FirstName = "Little"
+	err = repo.UpdateUser(&u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.UpdateUser(&original) })
+
+	u, _ = repo.GetUserByID(id)
+	if u.FirstName != "Little" {
+		t.Errorf("UpdateUser did not update the user: %+v", u)
+	}
+}
+
+func testBlockRanges(t *testing.T, repo DBRepository) {
+	err := repo.InsertBlockByLaptopID(1, testDate(40), testDate(44))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restrictions, err := repo.GetLaptopRestrictionsByDate(1, testDate(42), testDate(42))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restrictions) != 1 {
+		t.Fatalf("expected 1 restriction, got %d", len(restrictions))
+	}
+	block := restrictions[0]
+	t.Cleanup(func() { repo.DeleteBlockByID(block.ID) })
+
+	if block.RestrictionID != 2 || block.ReservationID != 0 {
+		t.Errorf("unexpected block: %+v", block)
+	}
+	if !block.StartDate.Equal(testDate(40)) || !block.EndDate.Equal(testDate(44)) {
+		t.Errorf("block spans %s - %s, expected %s - %s", block.StartDate, block.EndDate, testDate(40), testDate(44))
+	}
+
+	for _, day := range []int{40, 44} {
+		available, _ := repo.SearchAvailabilityByDatesByLaptopID(testDate(day), testDate(day), 1)
+		if available {
+			t.Errorf("blocked day %s is available", testDate(day))
+		}
+	}
+	available, _ := repo.SearchAvailabilityByDatesByLaptopID(testDate(45), testDate(45), 1)
+	if !available {
+		t.Error("day after the block is not available")
+	}
+}
+
+func testUserManagement(t *testing.T, repo DBRepository) {
+	u := models.User{
+		FirstName:   "Jane",
+		LastName:    "Doe",
+		Email:       fmt.Sprintf("jane-%d@doe.com", time.Now().UnixNano()),
+		AccessLevel: 1,
+	}
+	id, err := repo.InsertUser(&u, "first-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id == 0 {
+		t.Fatal("InsertUser returned id 0")
+	}
+
+	_, err = repo.InsertUser(&u, "first-password")
+	if err == nil {
+		t.Error("inserted a second user with the same email")
+	}
+
+	got, err := repo.GetUserByEmail(u.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != id || got.FirstName != "Jane" || got.AccessLevel != 1 {
+		t.Errorf("GetUserByEmail returned unexpected user: %+v", got)
+	}
+	if got.Password == "first-password" {
+		t.Error("password stored in plain text")
+	}
+
+	_, err = repo.GetUserByEmail("nobody@doe.com")
+	if err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows for a missing user, got %v", err)
+	}
+
+	users, err := repo.AllUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for i, user := range users {
+		if i > 0 && users[i-1].ID >= user.ID {
+			t.Error("users are not ordered by id")
+		}
+		if user.ID == id {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("inserted user missing from AllUsers")
+	}
+
+	authID, _, err := repo.Authenticate(u.Email, "first-password")
+	if err != nil || authID != id {
+		t.Errorf("new user can't log in: %d, %v", authID, err)
+	}
+
+	err = repo.UpdateUserPassword(id, "second-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = repo.Authenticate(u.Email, "first-password")
+	if err == nil {
+		t.Error("old password still works after a reset")
+	}
+	_, _, err = repo.Authenticate(u.Email, "second-password")
+	if err != nil {
+		t.Errorf("new password doesn't work: %s", err)
+	}
+
+	err = repo.UpdateUserPassword(-1, "password")
+	if err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows resetting a missing user, got %v", err)
+	}
+}
+
+func testWaitlist(t *testing.T, repo DBRepository) {
+	first := models.WaitlistEntry{
+		FirstName: "John",
+		LastName:  "Smith",
+		Email:     "john@smith.com",
+		LaptopID:  2,
+		StartDate: testDate(500),
+		EndDate:   testDate(502),
+		Locale:    "ja",
+	}
+	firstID, err := repo.InsertWaitlistEntry(&first)
+	if err != nil {
+		t.Fatalf("InsertWaitlistEntry: %s", err)
+	}
+	second := first
+	second.Email = "jane@smith.com"
+	secondID, err := repo.InsertWaitlistEntry(&second)
+	if err != nil {
+		t.Fatalf("InsertWaitlistEntry: %s", err)
+	}
+
+	entries, err := repo.GetWaitlistByLaptopID(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].ID != firstID || entries[1].ID != secondID {
+		t.Fatalf("expected entries %d and %d in order, got %+v", firstID, secondID, entries)
+	}
+	e := entries[0]
+	if e.Status != models.WaitlistWaiting || e.Token != "" || !e.HoldUntil.IsZero() || e.HoldID != 0 {
+		t.Errorf("new entry is not waiting: %+v", e)
+	}
+	if e.Laptop.LaptopName != "Macbook Pro 15 inch" || e.Email != "john@smith.com" || e.Locale != "ja" {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+	if !e.StartDate.Equal(testDate(500)) || !e.EndDate.Equal(testDate(502)) {
+		t.Errorf("unexpected dates %s - %s", e.StartDate, e.EndDate)
+	}
+
+	// waiting entries have no token to be found by
+	_, err = repo.GetWaitlistEntryByToken("")
+	if err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows for an empty token, got %v", err)
+	}
+
+	holdUntil := time.Now().Add(time.Hour).Truncate(time.Second)
+	e.Status = models.WaitlistNotified
+	e.Token = "secret"
+	e.HoldUntil = holdUntil
+	e.HoldID = 42
+	err = repo.UpdateWaitlistEntry(&e)
+	if err != nil {
+		t.Fatalf("UpdateWaitlistEntry: %s", err)
+	}
+
+	got, err := repo.GetWaitlistEntryByToken("secret")
+	if err != nil {
+		t.Fatalf("GetWaitlistEntryByToken: %s", err)
+	}
+	if got.ID != firstID || got.Status != models.WaitlistNotified || !got.HoldUntil.Equal(holdUntil) || got.HoldID != 42 {
+		t.Errorf("expected the notified entry held until %s, got %+v", holdUntil, got)
+	}
+
+	// claimed entries leave the waitlist
+	got.Status = models.WaitlistClaimed
+	err = repo.UpdateWaitlistEntry(&got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err = repo.GetWaitlistByLaptopID(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].ID != secondID {
+		t.Errorf("expected only entry %d to be left, got %+v", secondID, entries)
+	}
+
+	_, err = repo.GetWaitlistEntryByToken("unknown")
+	if err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows for an unknown token, got %v", err)
+	}
+	err = repo.UpdateWaitlistEntry(&models.WaitlistEntry{ID: 9999, Status: models.WaitlistExpired})
+	if err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows updating a missing entry, got %v", err)
+	}
+	_, err = repo.InsertWaitlistEntry(&models.WaitlistEntry{LaptopID: 9999, StartDate: testDate(500), EndDate: testDate(501)})
+	if err == nil {
+		t.Error("expected an error for a missing laptop")
+	}
+}
+
+func testHolds(t *testing.T, repo DBRepository) {
+	start, end := testDate(600), testDate(602)
+
+	holdID, err := repo.InsertHold(1, start, end, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("InsertHold: %s", err)
+	}
+	if holdID == 0 {
+		t.Fatal("InsertHold returned id 0")
+	}
+
+	// a hold takes the dates like a reservation
+	available, err := repo.SearchAvailabilityByDatesByLaptopID(testDate(601), testDate(601), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if available {
+		t.Error("held dates are available")
+	}
+	laptops, _ := repo.SearchAvailabilityForAllLaptops(start, end)
+	if len(laptops) != 1 || laptops[0].ID != 2 {
+		t.Errorf("expected only laptop 2 to be available, got %+v", laptops)
+	}
+	_, err = repo.InsertHold(1, testDate(602), testDate(603), time.Now().Add(time.Hour))
+	if err != ErrNotAvailable {
+		t.Errorf("expected ErrNotAvailable holding overlapping dates, got %v", err)
+	}
+
+	restrictions, _ := repo.GetLaptopRestrictionsByDate(1, start, end)
+	if len(restrictions) != 1 || restrictions[0].RestrictionID != models.RestrictionHold || restrictions[0].ExpiresAt.IsZero() {
+		t.Fatalf("expected the hold, got %+v", restrictions)
+	}
+
+	res := models.Reservation{
+		FirstName: "Jane",
+		LastName:  "Doe",
+		Email:     "jane-hold@doe.com",
+		LaptopID:  1,
+		StartDate: start,
+		EndDate:   end,
+	}
+	resID, err := repo.ConvertHold(holdID, &res)
+	if err != nil {
+		t.Fatalf("ConvertHold: %s", err)
+	}
+	restrictions, _ = repo.GetLaptopRestrictionsByDate(1, start, end)
+	if len(restrictions) != 1 || restrictions[0].ID != holdID || restrictions[0].ReservationID != resID ||
+		restrictions[0].RestrictionID != models.RestrictionReservation || !restrictions[0].ExpiresAt.IsZero() {
+		t.Errorf("expected the hold to become the reservation restriction, got %+v", restrictions)
+	}
+	stored, err := repo.GetReservatioByID(resID)
+	if err != nil || stored.Email != "jane-hold@doe.com" {
+		t.Errorf("reservation not stored: %+v %v", stored, err)
+	}
+
+	// expired holds free the dates and are swept
+	expiredID, err := repo.InsertHold(2, start, end, time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("InsertHold: %s", err)
+	}
+	available, _ = repo.SearchAvailabilityByDatesByLaptopID(start, end, 2)
+	if !available {
+		t.Error("expired hold still takes the dates")
+	}
+	restrictions, _ = repo.GetLaptopRestrictionsByDate(2, start, end)
+	if len(restrictions) != 0 {
+		t.Errorf("expired hold is listed: %+v", restrictions)
+	}
+
+	// converting an expired hold books the dates while they are free
+	res.Email = "jane-expired@doe.com"
+	res.LaptopID = 2
+	_, err = repo.ConvertHold(expiredID, &res)
+	if err != nil {
+		t.Fatalf("ConvertHold of an expired hold: %s", err)
+	}
+	restrictions, _ = repo.GetLaptopRestrictionsByDate(2, start, end)
+	if len(restrictions) != 1 || restrictions[0].RestrictionID != models.RestrictionReservation {
+		t.Errorf("expected a reservation restriction, got %+v", restrictions)
+	}
+
+	// and fails once they are taken
+	res.Email = "jane-late@doe.com"
+	_, err = repo.ConvertHold(0, &res)
+	if err != ErrNotAvailable {
+		t.Errorf("expected ErrNotAvailable, got %v", err)
+	}
+	all, _ := repo.AllReservations()
+	for _, r := range all {
+		if r.Email == "jane-late@doe.com" {
+			t.Error("failed conversion left a reservation behind")
+		}
+	}
+
+	_, _ = repo.InsertHold(1, testDate(610), testDate(610), time.Now().Add(-time.Second))
+	otherID, _ := repo.InsertHold(1, testDate(611), testDate(611), time.Now().Add(time.Hour))
+	n, err := repo.DeleteExpiredHolds(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 expired hold to be deleted, got %d", n)
+	}
+
+	err = repo.DeleteHold(otherID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	available, _ = repo.SearchAvailabilityByDatesByLaptopID(testDate(611), testDate(611), 1)
+	if !available {
+		t.Error("released hold still takes the dates")
+	}
+
+	// DeleteHold leaves other restrictions alone
+	restrictions, _ = repo.GetLaptopRestrictionsByDate(1, start, end)
+	_ = repo.DeleteHold(restrictions[0].ID)
+	restrictions, _ = repo.GetLaptopRestrictionsByDate(1, start, end)
+	if len(restrictions) != 1 {
+		t.Error("DeleteHold deleted a reservation restriction")
+	}
+}
+
+func testBuffers(t *testing.T, repo DBRepository) {
+	err := repo.UpdateLaptopBuffers(2, 1, 2)
+	if err != nil {
+		t.Fatalf("UpdateLaptopBuffers: %s", err)
+	}
+	defer repo.UpdateLaptopBuffers(2, 0, 0)
+
+	laptop, err := repo.GetLaptopByID(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if laptop.BufferDaysBefore != 1 || laptop.BufferDaysAfter != 2 || laptop.Turnaround() != 3 {
+		t.Errorf("buffers not stored, got %+v", laptop)
+	}
+	if err = repo.UpdateLaptopBuffers(999, 1, 1); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows for a missing laptop, got %v", err)
+	}
+
+	resID, err := repo.InsertReservation(&models.Reservation{
+		FirstName: "Jane",
+		LastName:  "Doe",
+		Email:     "jane-buffer@doe.com",
+		LaptopID:  2,
+		StartDate: testDate(700),
+		EndDate:   testDate(702),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.InsertLaptopRestriction(&models.LaptopRestriction{
+		StartDate:     testDate(700),
+		EndDate:       testDate(702),
+		LaptopID:      2,
+		ReservationID: resID,
+		RestrictionID: models.RestrictionReservation,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the turnaround after the reservation and before the next one are both kept free
+	for offset, want := range map[int]bool{696: true, 697: false, 703: false, 705: false, 706: true} {
+		available, err := repo.SearchAvailabilityByDatesByLaptopID(testDate(offset), testDate(offset), 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if available != want {
+			t.Errorf("availability of testDate(%d): got %v, expected %v", offset, available, want)
+		}
+	}
+
+	laptops, err := repo.SearchAvailabilityForAllLaptops(testDate(705), testDate(705))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range laptops {
+		if l.ID == 2 {
+			t.Error("laptop 2 is available during its turnaround")
+		}
+	}
+	laptops, _ = repo.SearchAvailabilityForAllLaptops(testDate(706), testDate(706))
+	found := false
+	for _, l := range laptops {
+		if l.ID == 2 {
+			found = l.BufferDaysBefore == 1 && l.BufferDaysAfter == 2
+		}
+	}
+	if !found {
+		t.Errorf("expected laptop 2 with its buffers to be available after its turnaround, got %+v", laptops)
+	}
+
+	_, err = repo.InsertHold(2, testDate(704), testDate(704), time.Now().Add(time.Hour))
+	if err != ErrNotAvailable {
+		t.Errorf("expected ErrNotAvailable holding the turnaround, got %v", err)
+	}
+
+	// blocks don't need a turnaround
+	err = repo.InsertOneDayBlockByLaptopID(2, testDate(720))
+	if err != nil {
+		t.Fatal(err)
+	}
+	available, _ := repo.SearchAvailabilityByDatesByLaptopID(testDate(721), testDate(721), 2)
+	if !available {
+		t.Error("the day after a block is unavailable")
+	}
+}
+
+func testBookingRules(t *testing.T, repo DBRepository) {
+	_, err := repo.GetBookingRules(0)
+	if err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows without rules, got %v", err)
+	}
+
+	global := models.BookingRules{MinDays: 2, MaxDays: 14, MaxAdvanceDays: 90, PickupWeekdays: []time.Weekday{time.Monday, time.Friday}}
+	err = repo.SaveBookingRules(&global)
+	if err != nil {
+		t.Fatalf("SaveBookingRules: %s", err)
+	}
+	if global.ID == 0 {
+		t.Error("SaveBookingRules didn't set the id")
+	}
+	err = repo.SaveBookingRules(&models.BookingRules{LaptopID: 1, MaxDays: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// saving again replaces the rules
+	global.MaxDays = 21
+	id := global.ID
+	err = repo.SaveBookingRules(&global)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := repo.GetBookingRules(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.ID != id || stored.MinDays != 2 || stored.MaxDays != 21 || stored.MaxAdvanceDays != 90 ||
+		len(stored.PickupWeekdays) != 2 || stored.PickupWeekdays[0] != time.Monday || stored.PickupWeekdays[1] != time.Friday {
+		t.Errorf("unexpected global rules %+v", stored)
+	}
+	stored, _ = repo.GetBookingRules(1)
+	if stored.LaptopID != 1 || stored.MaxDays != 7 || stored.MinDays != 0 || len(stored.PickupWeekdays) != 0 {
+		t.Errorf("unexpected rules of laptop 1 %+v", stored)
+	}
+
+	for _, laptopID := range []int{0, 1} {
+		err = repo.DeleteBookingRules(laptopID)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = repo.DeleteBookingRules(1); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows deleting missing rules, got %v", err)
+	}
+
+	everyID, err := repo.InsertBlackout(&models.Blackout{StartDate: testDate(800), EndDate: testDate(802), Reason: "inventory"})
+	if err != nil {
+		t.Fatalf("InsertBlackout: %s", err)
+	}
+	laptopID, err := repo.InsertBlackout(&models.Blackout{LaptopID: 1, StartDate: testDate(801), EndDate: testDate(801)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blackouts, err := repo.GetBlackoutsByDate(1, testDate(802), testDate(810))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blackouts) != 1 || blackouts[0].ID != everyID || blackouts[0].Reason != "inventory" ||
+		!blackouts[0].StartDate.Equal(testDate(800)) || !blackouts[0].EndDate.Equal(testDate(802)) {
+		t.Errorf("expected the blackout of every laptop, got %+v", blackouts)
+	}
+	blackouts, _ = repo.GetBlackoutsByDate(1, testDate(790), testDate(801))
+	if len(blackouts) != 2 || blackouts[0].ID != everyID || blackouts[1].ID != laptopID {
+		t.Errorf("expected both blackouts in order, got %+v", blackouts)
+	}
+	blackouts, _ = repo.GetBlackoutsByDate(2, testDate(801), testDate(801))
+	if len(blackouts) != 1 {
+		t.Errorf("the blackout of laptop 1 applies to laptop 2: %+v", blackouts)
+	}
+
+	for _, id := range []int{everyID, laptopID} {
+		err = repo.DeleteBlackout(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = repo.DeleteBlackout(everyID); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows deleting a missing blackout, got %v", err)
+	}
+}
+
+func testDashboard(t *testing.T, repo DBRepository) {
+	pending, err := repo.CountNewReservations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// laptop 1 is rented the whole window, laptop 2 from the day before the window to its third day
+	first := insertTestReservation(t, repo, 1, testDate(1000), testDate(1009))
+	second := insertTestReservation(t, repo, 2, testDate(999), testDate(1002))
+
+	n, err := repo.CountNewReservations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != pending+2 {
+		t.Errorf("expected %d new reservations, got %d", pending+2, n)
+	}
+	err = repo.UpdateReservationProcessed(first, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, _ = repo.CountNewReservations()
+	if n != pending+1 {
+		t.Errorf("expected %d new reservations after processing one, got %d", pending+1, n)
+	}
+
+	pickups, err := repo.GetReservationsStartingOn(testDate(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pickups) != 1 || pickups[0].ID != first || pickups[0].Laptop.LaptopName == "" {
+		t.Errorf("unexpected pickups: %+v", pickups)
+	}
+	returns, err := repo.GetReservationsEndingOn(testDate(1002))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(returns) != 1 || returns[0].ID != second {
+		t.Errorf("unexpected returns: %+v", returns)
+	}
+
+	utilization, err := repo.GetLaptopUtilization(testDate(1000), testDate(1009))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(utilization) != 2 {
+		t.Fatalf("expected the utilization of 2 laptops, got %d", len(utilization))
+	}
+	for _, u := range utilization {
+		expected := map[int]int{1: 10, 2: 3}[u.Laptop.ID]
+		if u.RentedDays != expected || u.PeriodDays != 10 || u.Laptop.LaptopName == "" {
+			t.Errorf("unexpected utilization of laptop %d: %+v", u.Laptop.ID, u)
+		}
+	}
+
+	err = repo.InsertBlockByLaptopID(2, testDate(1005), testDate(1006))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repo.InsertOneDayBlockByLaptopID(1, testDate(1020))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks, err := repo.GetUpcomingBlocks(testDate(1006), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range blocks {
+		id := b.ID
+		t.Cleanup(func() { repo.DeleteBlockByID(id) })
+	}
+	if len(blocks) != 2 {
+		t.Fatalf("expected 2 upcoming blocks, got %d", len(blocks))
+	}
+	if blocks[0].LaptopID != 2 || !blocks[0].StartDate.Equal(testDate(1005)) || blocks[0].Laptop.LaptopName == "" {
+		t.Errorf("unexpected first block: %+v", blocks[0])
+	}
+	if blocks[1].LaptopID != 1 || !blocks[1].EndDate.Equal(testDate(1020)) {
+		t.Errorf("unexpected second block: %+v", blocks[1])
+	}
+	blocks, _ = repo.GetUpcomingBlocks(testDate(1007), 1)
+	if len(blocks) != 1 || blocks[0].LaptopID != 1 {
+		t.Errorf("expected only the block of laptop 1, got %+v", blocks)
+	}
+
+	counts, err := repo.CountReservationsByStartDate(testDate(990), testDate(1009))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != 2 {
+		t.Fatalf("expected pickups on 2 days, got %+v", counts)
+	}
+	if !counts[0].Date.Equal(testDate(999)) || counts[0].Count != 1 || !counts[1].Date.Equal(testDate(1000)) || counts[1].Count != 1 {
+		t.Errorf("unexpected pickups per day: %+v", counts)
+	}
+}
+
+func testReports(t *testing.T, repo DBRepository) {
+	kept := insertTestReservation(t, repo, 1, testDate(1100), testDate(1102))
+	cancelled := insertTestReservation(t, repo, 2, testDate(1104), testDate(1105))
+	insertTestReservation(t, repo, 2, testDate(1120), testDate(1121))
+
+	err := repo.InsertBlockByLaptopID(1, testDate(1103), testDate(1104))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks, err := repo.GetBlocksByDate(testDate(1104), testDate(1110))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range blocks {
+		id := b.ID
+		t.Cleanup(func() { repo.DeleteBlockByID(id) })
+	}
+	if len(blocks) != 1 || blocks[0].LaptopID != 1 || blocks[0].RestrictionID != models.RestrictionBlock || blocks[0].Laptop.LaptopName == "" {
+		t.Fatalf("unexpected blocks: %+v", blocks)
+	}
+	if !blocks[0].StartDate.Equal(testDate(1103)) || !blocks[0].EndDate.Equal(testDate(1104)) {
+		t.Errorf("block spans %s - %s, expected %s - %s", blocks[0].StartDate, blocks[0].EndDate, testDate(1103), testDate(1104))
+	}
+
+	reservations, err := repo.GetReservationsByDate(testDate(1102), testDate(1110))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reservations) != 2 || reservations[0].ID != kept || reservations[1].ID != cancelled || reservations[0].Laptop.LaptopName == "" {
+		t.Fatalf("unexpected reservations: %+v", reservations)
+	}
+
+	err = repo.DeleteReservation(cancelled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancellations, err := repo.GetCancellationsByDate(testDate(1100), testDate(1110))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cancellations) != 1 {
+		t.Fatalf("expected 1 cancellation, got %+v", cancellations)
+	}
+	c := cancellations[0]
+	if c.ReservationID != cancelled || c.LaptopID != 2 || !c.StartDate.Equal(testDate(1104)) || !c.EndDate.Equal(testDate(1105)) {
+		t.Errorf("unexpected cancellation: %+v", c)
+	}
+	if c.BookedAt.IsZero() || c.CreatedAt.Before(c.BookedAt) {
+		t.Errorf("cancellation booked at %s and cancelled at %s", c.BookedAt, c.CreatedAt)
+	}
+	reservations, _ = repo.GetReservationsByDate(testDate(1102), testDate(1110))
+	if len(reservations) != 1 {
+		t.Errorf("cancelled reservation still returned: %+v", reservations)
+	}
+	cancellations, _ = repo.GetCancellationsByDate(testDate(1106), testDate(1110))
+	if len(cancellations) != 0 {
+		t.Errorf("cancellation returned outside of its dates: %+v", cancellations)
+	}
+}
+
+func testSearchReservations(t *testing.T, repo DBRepository) {
+	insert := func(first, last, email, phone string, laptopID, start, end int) int {
+		id, err := repo.InsertReservation(&models.Reservation{
+			FirstName: first,
+			LastName:  last,
+			Email:     email,
+			Phone:     phone,
+			StartDate: testDate(start),
+			EndDate:   testDate(end),
+			LaptopID:  laptopID,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { repo.DeleteReservation(id) })
+		return id
+	}
+	ada := insert("Ada", "Lovelace", "ada@example.com", "555-0100", 1, 1200, 1201)
+	alan := insert("Alan", "turing", "alan@example.com", "555-0199", 2, 1202, 1203)
+	grace := insert("Grace", "Hopper", "grace_h@example.com", "555-0142", 2, 1204, 1206)
+	err := repo.UpdateReservationProcessed(alan, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every filter is limited to the dates of these reservations
+	within := func(f models.ReservationFilter) models.ReservationFilter {
+		if f.Start.IsZero() {
+			f.Start, f.End = testDate(1200), testDate(1210)
+		}
+		if f.Sort == "" {
+			f.Sort = "name"
+		}
+		return f
+	}
+
+	tests := []struct {
+		name     string
+		filter   models.ReservationFilter
+		expected []int
+		total    int
+	}{
+		{"everything by name", models.ReservationFilter{}, []int{grace, ada, alan}, 3},
+		{"search ignores case", models.ReservationFilter{Search: "ALAN"}, []int{alan}, 1},
+		{"search matches every word", models.ReservationFilter{Search: "555-01 ada"}, []int{ada}, 1},
+		{"search by phone", models.ReservationFilter{Search: "0142"}, []int{grace}, 1},
+		{"search wildcards are literal", models.ReservationFilter{Search: "%"}, nil, 0},
+		{"search underscore is literal", models.ReservationFilter{Search: "_h"}, []int{grace}, 1},
+		{"laptop", models.ReservationFilter{LaptopID: 2}, []int{grace, alan}, 2},
+		{"new", models.ReservationFilter{Processed: models.ReservationsNew}, []int{grace, ada}, 2},
+		{"processed", models.ReservationFilter{Processed: models.ReservationsProcessed}, []int{alan}, 1},
+		{"dates", models.ReservationFilter{Start: testDate(1203), End: testDate(1203)}, []int{alan}, 1},
+		{"first page", models.ReservationFilter{Sort: "start", Desc: true, Limit: 2}, []int{grace, alan}, 3},
+		{"last page", models.ReservationFilter{Sort: "start", Desc: true, Limit: 2, Offset: 2}, []int{ada}, 3},
+		{"by laptop", models.ReservationFilter{Sort: "laptop", Desc: true}, []int{grace, alan, ada}, 3},
+		{"by id", models.ReservationFilter{Sort: "id"}, []int{ada, alan, grace}, 3},
+	}
+
+	for _, test := range tests {
+		reservations, total, err := repo.SearchReservations(within(test.filter))
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		var ids []int
+		for _, r := range reservations {
+			ids = append(ids, r.ID)
+		}
+		if fmt.Sprint(ids) != fmt.Sprint(test.expected) || total != test.total {
+			t.Errorf("%s: expected %v of %d, got %v of %d", test.name, test.expected, test.total, ids, total)
+		}
+	}
+
+	reservations, _, _ := repo.SearchReservations(within(models.ReservationFilter{Search: "ada"}))
+	if len(reservations) != 1 || reservations[0].Laptop.LaptopName == "" || reservations[0].Email != "ada@example.com" {
+		t.Errorf("unexpected reservation %+v", reservations)
+	}
+}
+
+func testBulkReservations(t *testing.T, repo DBRepository) {
+	first := insertTestReservation(t, repo, 1, testDate(1300), testDate(1301))
+	second := insertTestReservation(t, repo, 2, testDate(1302), testDate(1302))
+	missing := second + 1000
+
+	results, err := repo.ProcessReservations([]int{first, missing, second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 || results[0].ReservationID != first || results[1].ReservationID != missing {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	if results[0].Err != nil || results[2].Err != nil || !errors.Is(results[1].Err, sql.ErrNoRows) {
+		t.Errorf("unexpected errors %v %v %v", results[0].Err, results[1].Err, results[2].Err)
+	}
+	if results[0].Reservation.Processed != 0 || results[0].Reservation.Laptop.LaptopName == "" {
+		t.Errorf("expected the reservation before it was processed, got %+v", results[0].Reservation)
+	}
+	for _, id := range []int{first, second} {
+		res, err := repo.GetReservatioByID(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Processed != 1 {
+			t.Errorf("reservation %d wasn't processed", id)
+		}
+	}
+
+	results, err = repo.CancelReservations([]int{first, missing})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Err != nil || results[0].Reservation.ID != first || results[1].Err == nil {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	if _, err := repo.GetReservatioByID(first); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected the reservation to be deleted, got %v", err)
+	}
+	available, err := repo.SearchAvailabilityByDatesByLaptopID(testDate(1300), testDate(1301), 1)
+	if err != nil || !available {
+		t.Errorf("expected the cancelled dates to be available, got %v %v", available, err)
+	}
+	cancellations, err := repo.GetCancellationsByDate(testDate(1300), testDate(1301))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cancellations) != 1 || cancellations[0].ReservationID != first {
+		t.Errorf("expected the cancellation of reservation %d, got %+v", first, cancellations)
+	}
+	if _, err := repo.GetReservatioByID(second); err != nil {
+		t.Errorf("expected reservation %d to be kept, got %v", second, err)
+	}
+}
+
+func testRescheduleReservation(t *testing.T, repo DBRepository) {
+	id := insertTestReservation(t, repo, 1, testDate(1400), testDate(1402))
+	insertTestReservation(t, repo, 1, testDate(1405), testDate(1406))
+
+	restrictionOf := func(laptopID int) (models.LaptopRestriction, bool) {
+		restrictions, err := repo.GetLaptopRestrictionsByDate(laptopID, testDate(1390), testDate(1410))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, lr := range restrictions {
+			if lr.ReservationID == id {
+				return lr, true
+			}
+		}
+		return models.LaptopRestriction{}, false
+	}
+
+	res, err := repo.GetReservatioByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// overlapping its own dates is fine, the customer details are saved with the move
+	res.FirstName, res.StartDate, res.EndDate = "Jane", testDate(1401), testDate(1403)
+	err = repo.RescheduleReservation(&res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err = repo.GetReservatioByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.StartDate.Equal(testDate(1401)) || !res.EndDate.Equal(testDate(1403)) {
+		t.Errorf("expected the reservation to be moved, got %s to %s", res.StartDate, res.EndDate)
+	}
+	if res.FirstName != "Jane" {
+		t.Errorf("expected the first name to be saved, got %q", res.FirstName)
+	}
+	if lr, ok := restrictionOf(1); !ok || !lr.StartDate.Equal(testDate(1401)) || !lr.EndDate.Equal(testDate(1403)) {
+		t.Errorf("expected the restriction to be moved, got %+v", lr)
+	}
+
+	conflict := res
+	conflict.FirstName, conflict.StartDate, conflict.EndDate = "Joan", testDate(1404), testDate(1405)
+	err = repo.RescheduleReservation(&conflict)
+	if !errors.Is(err, ErrNotAvailable) {
+		t.Errorf("expected ErrNotAvailable, got %v", err)
+	}
+	if res, _ := repo.GetReservatioByID(id); !res.StartDate.Equal(testDate(1401)) || res.FirstName != "Jane" {
+		t.Errorf("the failed move changed the reservation to %+v", res)
+	}
+
+	res.LaptopID, res.StartDate, res.EndDate = 2, testDate(1405), testDate(1406)
+	err = repo.RescheduleReservation(&res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, _ := repo.GetReservatioByID(id); res.LaptopID != 2 || res.Laptop.ID != 2 {
+		t.Errorf("expected the reservation to be on laptop 2, got %d", res.LaptopID)
+	}
+	if _, ok := restrictionOf(1); ok {
+		t.Error("the restriction was left on laptop 1")
+	}
+	if _, ok := restrictionOf(2); !ok {
+		t.Error("the restriction wasn't moved to laptop 2")
+	}
+	available, err := repo.SearchAvailabilityByDatesByLaptopID(testDate(1401), testDate(1403), 1)
+	if err != nil || !available {
+		t.Errorf("expected the old dates to be free, got %v %v", available, err)
+	}
+
+	missing := models.Reservation{ID: id + 1000, LaptopID: 2, StartDate: testDate(1408), EndDate: testDate(1408)}
+	if err = repo.RescheduleReservation(&missing); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows for a missing reservation, got %v", err)
+	}
+}
+
+func testMoveBlock(t *testing.T, repo DBRepository) {
+	insertTestReservation(t, repo, 1, testDate(1500), testDate(1501))
+	err := repo.InsertBlockByLaptopID(1, testDate(1503), testDate(1504))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks, err := repo.GetBlocksByDate(testDate(1503), testDate(1504))
+	if err != nil || len(blocks) != 1 {
+		t.Fatalf("expected the block, got %v %v", blocks, err)
+	}
+	id := blocks[0].ID
+	t.Cleanup(func() { repo.DeleteBlockByID(id) })
+
+	// overlapping itself is fine
+	from, err := repo.MoveBlock(id, 1, testDate(1502), testDate(1505))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != 1 {
+		t.Errorf("expected the block to have been on laptop 1, got %d", from)
+	}
+	blocks, _ = repo.GetBlocksByDate(testDate(1490), testDate(1510))
+	if len(blocks) != 1 || !blocks[0].StartDate.Equal(testDate(1502)) || !blocks[0].EndDate.Equal(testDate(1505)) {
+		t.Errorf("expected the block to be moved, got %+v", blocks)
+	}
+
+	_, err = repo.MoveBlock(id, 1, testDate(1501), testDate(1502))
+	if !errors.Is(err, ErrNotAvailable) {
+		t.Errorf("expected ErrNotAvailable over the reservation, got %v", err)
+	}
+
+	// like ChangeBlocks, the turnaround of a reservation can be blocked
+	err = repo.UpdateLaptopBuffers(1, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.UpdateLaptopBuffers(1, 0, 0)
+	if _, err = repo.MoveBlock(id, 1, testDate(1502), testDate(1502)); err != nil {
+		t.Errorf("expected the block to be moved into the turnaround, got %v", err)
+	}
+
+	from, err = repo.MoveBlock(id, 2, testDate(1500), testDate(1500))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != 1 {
+		t.Errorf("expected the block to have been on laptop 1, got %d", from)
+	}
+	blocks, _ = repo.GetBlocksByDate(testDate(1490), testDate(1510))
+	if len(blocks) != 1 || blocks[0].LaptopID != 2 || !blocks[0].StartDate.Equal(testDate(1500)) {
+		t.Errorf("expected the block on laptop 2, got %+v", blocks)
+	}
+
+	// a reservation isn't a block
+	restrictions, _ := repo.GetLaptopRestrictionsByDate(1, testDate(1500), testDate(1501))
+	if len(restrictions) != 1 {
+		t.Fatalf("expected the reservation, got %+v", restrictions)
+	}
+	if _, err = repo.MoveBlock(restrictions[0].ID, 1, testDate(1508), testDate(1508)); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows moving a reservation as a block, got %v", err)
+	}
+}
+
+func testChangeBlocks(t *testing.T, repo DBRepository) {
+	insertTestReservation(t, repo, 1, testDate(1600), testDate(1601))
+	err := repo.InsertOneDayBlockByLaptopID(1, testDate(1603))
+	if err != nil {
+		t.Fatal(err)
+	}
+	restrictions, _ := repo.GetLaptopRestrictionsByDate(1, testDate(1603), testDate(1603))
+	if len(restrictions) != 1 || restrictions[0].Version != 1 {
+		t.Fatalf("expected the block at version 1, got %+v", restrictions)
+	}
+	block := restrictions[0]
+	t.Cleanup(func() {
+		blocks, _ := repo.GetBlocksByDate(testDate(1590), testDate(1610))
+		for _, b := range blocks {
+			repo.DeleteBlockByID(b.ID)
+		}
+	})
+
+	// a block moved since it was read isn't removed
+	_, err = repo.MoveBlock(block.ID, 1, testDate(1604), testDate(1604))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repo.ChangeBlocks(nil, []models.LaptopRestriction{block})
+	if !errors.Is(err, ErrBlockChanged) {
+		t.Fatalf("expected ErrBlockChanged removing a stale block, got %v", err)
+	}
+	block.Version = 2
+
+	// the day of the reservation can't be blocked, nothing is changed then
+	add := []models.LaptopRestriction{
+		{LaptopID: 1, StartDate: testDate(1605), EndDate: testDate(1605)},
+		{LaptopID: 1, StartDate: testDate(1601), EndDate: testDate(1601)},
+	}
+	_, err = repo.ChangeBlocks(add, []models.LaptopRestriction{block})
+	if !errors.Is(err, ErrNotAvailable) {
+		t.Fatalf("expected ErrNotAvailable blocking a reserved day, got %v", err)
+	}
+	blocks, _ := repo.GetBlocksByDate(testDate(1590), testDate(1610))
+	if len(blocks) != 1 || blocks[0].ID != block.ID {
+		t.Fatalf("a refused change was applied, got %+v", blocks)
+	}
+
+	// the turnaround of a reservation can be blocked
+	add = []models.LaptopRestriction{
+		{LaptopID: 1, StartDate: testDate(1602), EndDate: testDate(1602)},
+		{LaptopID: 2, StartDate: testDate(1602), EndDate: testDate(1603)},
+	}
+	unblocked, err := repo.ChangeBlocks(add, []models.LaptopRestriction{block})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unblocked) != 1 || unblocked[0] != 1 {
+		t.Errorf("expected laptop 1 to be unblocked, got %v", unblocked)
+	}
+	blocks, _ = repo.GetBlocksByDate(testDate(1590), testDate(1610))
+	if len(blocks) != 2 {
+		t.Fatalf("expected the 2 new blocks, got %+v", blocks)
+	}
+	for _, b := range blocks {
+		if b.ID == block.ID {
+			t.Error("the removed block is still there")
+		}
+	}
+
+	// removing it again is a conflict, somebody else removed it
+	if _, err = repo.ChangeBlocks(nil, []models.LaptopRestriction{block}); !errors.Is(err, ErrBlockChanged) {
+		t.Errorf("expected ErrBlockChanged removing a removed block, got %v", err)
+	}
+
+	// a reservation can't be removed as a block
+	restrictions, _ = repo.GetLaptopRestrictionsByDate(1, testDate(1600), testDate(1600))
+	if len(restrictions) != 1 || restrictions[0].RestrictionID != models.RestrictionReservation {
+		t.Fatalf("expected the reservation, got %+v", restrictions)
+	}
+	if _, err = repo.ChangeBlocks(nil, restrictions); !errors.Is(err, ErrBlockChanged) {
+		t.Errorf("expected ErrBlockChanged removing a reservation, got %v", err)
+	}
+}
+
+func testReminders(t *testing.T, repo DBRepository) {
+	id := insertTestReservation(t, repo, 1, testDate(1700), testDate(1701))
+	sentAt := time.Date(2099, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	recorded, err := repo.RecordReminder(id, models.ReminderPickup, sentAt)
+	if err != nil || !recorded {
+		t.Fatalf("expected the first pickup reminder to be recorded, got %v %v", recorded, err)
+	}
+	recorded, err = repo.RecordReminder(id, models.ReminderPickup, sentAt.Add(time.Hour))
+	if err != nil || recorded {
+		t.Fatalf("expected the second pickup reminder to be refused, got %v %v", recorded, err)
+	}
+	recorded, err = repo.RecordReminder(id, models.ReminderReturn, sentAt.Add(time.Hour))
+	if err != nil || !recorded {
+		t.Fatalf("expected the return reminder to be recorded, got %v %v", recorded, err)
+	}
+
+	reminders, err := repo.GetRemindersByReservationID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reminders) != 2 || reminders[0].Kind != models.ReminderPickup || reminders[1].Kind != models.ReminderReturn {
+		t.Fatalf("expected the pickup and return reminders, got %+v", reminders)
+	}
+	if !reminders[0].SentAt.Equal(sentAt) || reminders[0].ReservationID != id {
+		t.Errorf("unexpected reminder %+v", reminders[0])
+	}
+}
+
+func testJobLeases(t *testing.T, repo DBRepository) {
+	slot := time.Date(2099, 1, 1, 9, 0, 0, 0, time.UTC)
+	until := time.Now().Add(time.Hour)
+
+	acquired, err := repo.AcquireJobLease("lease test", "a", slot, until)
+	if err != nil || !acquired {
+		t.Fatalf("expected the first lease to be acquired, got %v %v", acquired, err)
+	}
+	// another instance can't run the same slot, even after the lease is released
+	acquired, err = repo.AcquireJobLease("lease test", "b", slot, until)
+	if err != nil || acquired {
+		t.Fatalf("expected the slot to be taken, got %v %v", acquired, err)
+	}
+	// nor the next slot while the lease is held
+	acquired, _ = repo.AcquireJobLease("lease test", "b", slot.Add(time.Minute), until)
+	if acquired {
+		t.Fatal("expected the next slot to wait for the lease")
+	}
+	// releasing a lease held by someone else does nothing
+	repo.ReleaseJobLease("lease test", "b")
+	acquired, _ = repo.AcquireJobLease("lease test", "b", slot.Add(time.Minute), until)
+	if acquired {
+		t.Fatal("expected the lease of a to be kept")
+	}
+
+	err = repo.ReleaseJobLease("lease test", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	acquired, _ = repo.AcquireJobLease("lease test", "b", slot, until)
+	if acquired {
+		t.Fatal("expected the released slot to stay taken")
+	}
+	acquired, err = repo.AcquireJobLease("lease test", "b", slot.Add(time.Minute), until)
+	if err != nil || !acquired {
+		t.Fatalf("expected the next slot to be acquired after the release, got %v %v", acquired, err)
+	}
+
+	// an expired lease is free for the next slot
+	repo.AcquireJobLease("expired test", "a", slot, time.Now().Add(-time.Minute))
+	acquired, _ = repo.AcquireJobLease("expired test", "b", slot.Add(time.Minute), until)
+	if !acquired {
+		t.Error("expected the expired lease to be acquired")
+	}
+}
+
+func testJobRuns(t *testing.T, repo DBRepository) {
+	started := time.Date(2099, 1, 1, 9, 0, 0, 0, time.UTC)
+	for i, name := range []string{"runs a", "runs b", "runs a"} {
+		_, err := repo.InsertJobRun(&models.JobRun{
+			Name:        name,
+			Owner:       "host-1",
+			ScheduledAt: started.Add(time.Duration(i) * time.Hour),
+			StartedAt:   started.Add(time.Duration(i) * time.Hour),
+			FinishedAt:  started.Add(time.Duration(i)*time.Hour + time.Second),
+			Attempts:    i + 1,
+			Status:      models.JobSucceeded,
+			Message:     fmt.Sprintf("run %d", i),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runs, err := repo.GetJobRuns("runs a", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || runs[0].Message != "run 2" || runs[1].Message != "run 0" {
+		t.Fatalf("expected the 2 runs of a, latest first, got %+v", runs)
+	}
+	r := runs[0]
+	if r.Owner != "host-1" || r.Attempts != 3 || r.Status != models.JobSucceeded ||
+		!r.StartedAt.Equal(started.Add(2*time.Hour)) || !r.FinishedAt.Equal(started.Add(2*time.Hour+time.Second)) {
+		t.Errorf("unexpected run %+v", r)
+	}
+
+	runs, _ = repo.GetJobRuns("", 2)
+	if len(runs) != 2 || runs[0].Message != "run 2" || runs[1].Message != "run 1" {
+		t.Errorf("expected the last 2 runs of every job, got %+v", runs)
+	}
+
+	n, err := repo.DeleteJobRunsBefore(started.Add(90 * time.Minute))
+	if err != nil || n != 2 {
+		t.Errorf("expected 2 runs deleted, got %d %v", n, err)
+	}
+	runs, _ = repo.GetJobRuns("", 10)
+	if len(runs) != 1 || runs[0].Message != "run 2" {
+		t.Errorf("expected only the last run left, got %+v", runs)
+	}
+}
+
+func testMailQueue(t *testing.T, repo DBRepository) {
+	due := time.Date(2099, 1, 1, 9, 0, 0, 0, time.UTC)
+	var ids []int
+	for i, at := range []time.Time{due.Add(time.Minute), due, due.Add(time.Hour)} {
+		id, err := repo.QueueMail(&models.QueuedMail{
+			Mail: models.MailData{
+				From:     "shop@example.com",
+				To:       fmt.Sprintf("customer%d@example.com", i),
+				Subject:  "Queued",
+				Content:  "<p>queued</p>",
+				Template: "basic.email.html",
+			},
+			Status:        models.MailPending,
+			NextAttemptAt: at,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	mails, err := repo.GetDueMail(due.Add(time.Minute), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mails) != 2 || mails[0].ID != ids[1] || mails[1].ID != ids[0] {
+		t.Fatalf("expected the 2 mails due, the longest waiting first, got %+v", mails)
+	}
+	q := mails[0]
+	if q.Mail.To != "customer1@example.com" || q.Mail.Template != "basic.email.html" || q.Status != models.MailPending ||
+		!q.NextAttemptAt.Equal(due) {
+		t.Errorf("unexpected queued mail %+v", q)
+	}
+	mails, _ = repo.GetDueMail(due.Add(time.Minute), 1)
+	if len(mails) != 1 || mails[0].ID != ids[1] {
+		t.Errorf("expected the limit to be kept, got %+v", mails)
+	}
+
+	q.Attempts = 1
+	q.LastError = "connection refused"
+	q.NextAttemptAt = due.Add(2 * time.Hour)
+	err = repo.UpdateQueuedMail(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mails, _ = repo.GetDueMail(due.Add(time.Hour), 10)
+	if len(mails) != 2 || mails[0].ID != ids[0] || mails[1].ID != ids[2] {
+		t.Fatalf("expected the retried mail to wait, got %+v", mails)
+	}
+	mails, _ = repo.GetDueMail(due.Add(2*time.Hour), 10)
+	if len(mails) != 3 || mails[2].Attempts != 1 || mails[2].LastError != "connection refused" {
+		t.Fatalf("expected the retried mail due again, got %+v", mails)
+	}
+
+	q.Status = models.MailFailed
+	repo.UpdateQueuedMail(q)
+	err = repo.DeleteQueuedMail(ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	mails, _ = repo.GetDueMail(due.Add(2*time.Hour), 10)
+	if len(mails) != 1 || mails[0].ID != ids[2] {
+		t.Errorf("expected only the last mail left, got %+v", mails)
+	}
+
+	err = repo.UpdateQueuedMail(models.QueuedMail{ID: ids[0], Status: models.MailFailed})
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows updating a deleted mail, got %v", err)
+	}
+}
+
+func testInsertReservationChecked(t *testing.T, repo DBRepository) {
+	start, end := testDate(1800), testDate(1802)
+	res := models.Reservation{
+		FirstName: "Walk",
+		LastName:  "In",
+		Email:     "walk-in@example.com",
+		LaptopID:  1,
+		StartDate: start,
+		EndDate:   end,
+		Locale:    "ja",
+	}
+
+	id, err := repo.InsertReservationChecked(&res)
+	if err != nil {
+		t.Fatalf("InsertReservationChecked: %s", err)
+	}
+	restrictions, _ := repo.GetLaptopRestrictionsByDate(1, start, end)
+	if len(restrictions) != 1 || restrictions[0].ReservationID != id ||
+		restrictions[0].RestrictionID != models.RestrictionReservation {
+		t.Fatalf("expected the reservation restriction, got %+v", restrictions)
+	}
+	stored, err := repo.GetReservatioByID(id)
+	if err != nil || stored.Email != "walk-in@example.com" || !stored.StartDate.Equal(start) || stored.Locale != "ja" {
+		t.Errorf("reservation not stored: %+v %v", stored, err)
+	}
+
+	// overlapping dates are refused without leaving a reservation behind
+	res.Email = "walk-in-late@example.com"
+	res.StartDate, res.EndDate = end, end.AddDate(0, 0, 1)
+	_, err = repo.InsertReservationChecked(&res)
+	if err != ErrNotAvailable {
+		t.Errorf("expected ErrNotAvailable, got %v", err)
+	}
+	all, _ := repo.AllReservations()
+	for _, r := range all {
+		if r.Email == "walk-in-late@example.com" {
+			t.Error("refused reservation left behind")
+		}
+	}
+}
@@ -1,9 +1,17 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/kaitolucifer/go-laptop-rental-site/internal/config"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/driver"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
 )
 
 type postgres struct {
@@ -11,9 +19,14 @@ type postgres struct {
 	DB  *sql.DB
 }
 
+type sqlite struct {
+	App *config.AppConfig
+	DB  *sql.DB
+}
+
 type mockPostgres struct {
 	App *config.AppConfig
-	DB *sql.DB
+	DB  *sql.DB
 }
 
 func NewPostgres(conn *sql.DB, a *config.AppConfig) DBRepository {
@@ -23,8 +36,420 @@ func NewPostgres(conn *sql.DB, a *config.AppConfig) DBRepository {
 	}
 }
 
+func NewSQLite(conn *sql.DB, a *config.AppConfig) DBRepository {
+	return &sqlite{
+		App: a,
+		DB:  conn,
+	}
+}
+
 func NewMockPostgres(a *config.AppConfig) DBRepository {
 	return &mockPostgres{
 		App: a,
 	}
 }
+
+// NewRepository returns the repository implementation matching the driver of db
+func NewRepository(db *driver.DB, a *config.AppConfig) DBRepository {
+	switch db.Driver {
+	case driver.SQLite:
+		return NewSQLite(db.Conn, a)
+	case driver.Memory:
+		return NewMemory(a)
+	default:
+		return NewPostgres(db.Conn, a)
+	}
+}
+
+// ErrNotAvailable is returned when a hold or reservation overlaps another restriction of the laptop
+var ErrNotAvailable = errors.New("laptop is not available for those dates")
+
+// ErrBlockChanged is returned when a block to remove was moved or removed since it was read
+var ErrBlockChanged = errors.New("block was changed since it was read")
+
+// turnaroundSQL is the number of days kept free around the restriction lr of the laptop l,
+// reservations and holds need the turnaround of the laptop but blocks don't
+var turnaroundSQL = fmt.Sprintf(`(CASE WHEN lr.restriction_id = %d THEN 0 ELSE l.buffer_days_before + l.buffer_days_after END)`,
+	models.RestrictionBlock)
+
+// passwordCost is the bcrypt cost of stored passwords, the same as the seeded admin, tests lower it
+var passwordCost = 12
+
+// expectOneRow returns sql.ErrNoRows if a statement changed no rows
+func expectOneRow(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// appendUnique appends id to ids unless it is in there already
+func appendUnique(ids []int, id int) []int {
+	for _, i := range ids {
+		if i == id {
+			return ids
+		}
+	}
+	return append(ids, id)
+}
+
+// nullTime stores the zero time as NULL, for nullable timestamp columns
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// scanner is a *sql.Row or *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanWaitlistEntry scans the waitlist_entries columns joined with the laptop id and name
+func scanWaitlistEntry(row scanner) (models.WaitlistEntry, error) {
+	var e models.WaitlistEntry
+	var holdUntil sql.NullTime
+
+	err := row.Scan(
+		&e.ID,
+		&e.FirstName,
+		&e.LastName,
+		&e.Email,
+		&e.LaptopID,
+		&e.StartDate,
+		&e.EndDate,
+		&e.Status,
+		&e.Token,
+		&holdUntil,
+		&e.HoldID,
+		&e.Locale,
+		&e.CreatedAt,
+		&e.UpdatedAt,
+		&e.Laptop.ID,
+		&e.Laptop.LaptopName,
+	)
+	e.HoldUntil = holdUntil.Time
+
+	return e, err
+}
+
+// formatWeekdays stores weekdays as their comma separated numbers, Sunday is 0
+func formatWeekdays(days []time.Weekday) string {
+	s := make([]string, len(days))
+	for i, d := range days {
+		s[i] = strconv.Itoa(int(d))
+	}
+	return strings.Join(s, ",")
+}
+
+// parseWeekdays reads weekdays stored by formatWeekdays
+func parseWeekdays(s string) ([]time.Weekday, error) {
+	var days []time.Weekday
+	for _, f := range strings.Split(s, ",") {
+		if f == "" {
+			continue
+		}
+		d, err := strconv.Atoi(f)
+		if err != nil || d < 0 || d > 6 {
+			return nil, fmt.Errorf("invalid weekday %q", f)
+		}
+		days = append(days, time.Weekday(d))
+	}
+	return days, nil
+}
+
+// scanBookingRules scans the booking_rules columns
+func scanBookingRules(row scanner) (models.BookingRules, error) {
+	var r models.BookingRules
+	var weekdays string
+
+	err := row.Scan(
+		&r.ID,
+		&r.LaptopID,
+		&r.MinDays,
+		&r.MaxDays,
+		&r.MaxAdvanceDays,
+		&weekdays,
+		&r.CreatedAt,
+		&r.UpdatedAt,
+	)
+	if err != nil {
+		return r, err
+	}
+	r.PickupWeekdays, err = parseWeekdays(weekdays)
+
+	return r, err
+}
+
+// scanBlackout scans the blackouts columns
+func scanBlackout(row scanner) (models.Blackout, error) {
+	var b models.Blackout
+
+	err := row.Scan(
+		&b.ID,
+		&b.LaptopID,
+		&b.StartDate,
+		&b.EndDate,
+		&b.Reason,
+		&b.CreatedAt,
+		&b.UpdatedAt,
+	)
+
+	return b, err
+}
+
+// periodDays returns the number of days from start to end, both included
+func periodDays(start, end time.Time) int {
+	return int(truncateDate(end).Sub(truncateDate(start)).Hours()/24) + 1
+}
+
+// scanUtilization scans a laptop id and name with the number of days it was rented from start to end
+func scanUtilization(row scanner, start, end time.Time) (models.LaptopUtilization, error) {
+	u := models.LaptopUtilization{
+		PeriodDays: periodDays(start, end),
+		Start:      truncateDate(start),
+		End:        truncateDate(end),
+	}
+
+	err := row.Scan(
+		&u.Laptop.ID,
+		&u.Laptop.LaptopName,
+		&u.RentedDays,
+	)
+
+	return u, err
+}
+
+// scanBlock scans the laptop_restrictions columns of a block joined with the laptop name
+func scanBlock(row scanner) (models.LaptopRestriction, error) {
+	var lr models.LaptopRestriction
+
+	err := row.Scan(
+		&lr.ID,
+		&lr.LaptopID,
+		&lr.RestrictionID,
+		&lr.StartDate,
+		&lr.EndDate,
+		&lr.Laptop.LaptopName,
+	)
+	lr.Laptop.ID = lr.LaptopID
+
+	return lr, err
+}
+
+// scanCancellation scans the reservation_cancellations columns
+func scanCancellation(row scanner) (models.Cancellation, error) {
+	var c models.Cancellation
+
+	err := row.Scan(
+		&c.ID,
+		&c.ReservationID,
+		&c.LaptopID,
+		&c.StartDate,
+		&c.EndDate,
+		&c.BookedAt,
+		&c.CreatedAt,
+		&c.UpdatedAt,
+	)
+
+	return c, err
+}
+
+// scanReservation scans the reservations columns joined with the laptop id and name
+func scanReservation(row scanner) (models.Reservation, error) {
+	var r models.Reservation
+
+	err := row.Scan(
+		&r.ID,
+		&r.FirstName,
+		&r.LastName,
+		&r.Email,
+		&r.Phone,
+		&r.StartDate,
+		&r.EndDate,
+		&r.LaptopID,
+		&r.CreatedAt,
+		&r.UpdatedAt,
+		&r.Processed,
+		&r.Locale,
+		&r.Laptop.ID,
+		&r.Laptop.LaptopName,
+	)
+
+	return r, err
+}
+
+// bulkAction applies a bulk action to one reservation inside the bulk transaction
+type bulkAction func(ctx context.Context, tx *sql.Tx, id int) error
+
+// bulkReservations looks up each reservation with query and applies action to it in one transaction,
+// a missing reservation fails only its own result while any other error rolls back every reservation
+func bulkReservations(db *sql.DB, query string, ids []int, action bulkAction) ([]models.BulkResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	results := make([]models.BulkResult, 0, len(ids))
+	for _, id := range ids {
+		result := models.BulkResult{ReservationID: id}
+		result.Reservation, err = scanReservation(tx.QueryRowContext(ctx, query, id))
+		if errors.Is(err, sql.ErrNoRows) {
+			result.Err = err
+			results = append(results, result)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err = action(ctx, tx, id); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// reservationSortColumns are the columns of models.ReservationSorts
+var reservationSortColumns = map[string]string{
+	"id":      "r.id",
+	"name":    "LOWER(r.last_name)",
+	"laptop":  "lp.laptop_name",
+	"start":   "r.start_date",
+	"end":     "r.end_date",
+	"created": "r.created_at",
+}
+
+// likeEscaper escapes the LIKE wildcards of a search word, for LIKE ... ESCAPE '\'
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// ilikeSearch matches col against a search pattern with ILIKE, which the pg_trgm indexes of the searched
+// reservation columns serve on postgres
+func ilikeSearch(col, pattern string) string {
+	return fmt.Sprintf(`%s ILIKE %s ESCAPE '\'`, col, pattern)
+}
+
+// lowerLikeSearch matches col against a lowercase search pattern with LIKE, sqlite has no trigram indexes
+// so a search scans the reservations
+func lowerLikeSearch(col, pattern string) string {
+	return fmt.Sprintf(`LOWER(%s) LIKE %s ESCAPE '\'`, col, pattern)
+}
+
+// reservationFilterSQL returns the WHERE and ORDER BY clauses of a reservation query joined with laptops
+// as r and lp, bind adds an argument to the query and returns its placeholder and search matches a column
+// against the LIKE pattern of a search word
+func reservationFilterSQL(f models.ReservationFilter, bind func(v interface{}) string, date func(t time.Time) interface{},
+	search func(col, pattern string) string) (string, string) {
+	where := []string{"1 = 1"}
+
+	for _, word := range strings.Fields(f.Search) {
+		p := bind("%" + likeEscaper.Replace(strings.ToLower(word)) + "%")
+		var or []string
+		for _, col := range []string{"r.first_name", "r.last_name", "r.email", "r.phone"} {
+			or = append(or, search(col, p))
+		}
+		where = append(where, "("+strings.Join(or, " OR ")+")")
+	}
+	if f.LaptopID != 0 {
+		where = append(where, "r.laptop_id = "+bind(f.LaptopID))
+	}
+	if !f.Start.IsZero() {
+		where = append(where, "r.end_date >= "+bind(date(f.Start)))
+	}
+	if !f.End.IsZero() {
+		where = append(where, "r.start_date <= "+bind(date(f.End)))
+	}
+	switch f.Processed {
+	case models.ReservationsNew:
+		where = append(where, "r.processed = 0")
+	case models.ReservationsProcessed:
+		where = append(where, "r.processed <> 0")
+	}
+
+	col, ok := reservationSortColumns[f.Sort]
+	if !ok {
+		col = reservationSortColumns["id"]
+	}
+	dir := "ASC"
+	if f.Desc {
+		dir = "DESC"
+	}
+
+	return strings.Join(where, " AND "), fmt.Sprintf("%s %s, r.id %s", col, dir, dir)
+}
+
+// queryReminders returns the reminders selected by query, the reservation_reminders columns in order
+func queryReminders(ctx context.Context, db *sql.DB, query string, args ...interface{}) ([]models.Reminder, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reminders []models.Reminder
+	for rows.Next() {
+		var r models.Reminder
+		err = rows.Scan(&r.ID, &r.ReservationID, &r.Kind, &r.SentAt, &r.CreatedAt, &r.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		reminders = append(reminders, r)
+	}
+
+	return reminders, rows.Err()
+}
+
+// queryJobRuns returns the job runs selected by query, the job_runs columns in order
+func queryJobRuns(ctx context.Context, db *sql.DB, query string, args ...interface{}) ([]models.JobRun, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []models.JobRun
+	for rows.Next() {
+		var r models.JobRun
+		err = rows.Scan(&r.ID, &r.Name, &r.Owner, &r.ScheduledAt, &r.StartedAt, &r.FinishedAt, &r.Attempts,
+			&r.Status, &r.Message, &r.CreatedAt, &r.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, r)
+	}
+
+	return runs, rows.Err()
+}
+
+// queryQueuedMail returns the queued mails selected by query, the mail_queue columns in order
+func queryQueuedMail(ctx context.Context, db *sql.DB, query string, args ...interface{}) ([]models.QueuedMail, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mails []models.QueuedMail
+	for rows.Next() {
+		var q models.QueuedMail
+		err = rows.Scan(&q.ID, &q.Mail.From, &q.Mail.To, &q.Mail.Subject, &q.Mail.Content, &q.Mail.Template,
+			&q.Status, &q.Attempts, &q.LastError, &q.NextAttemptAt, &q.CreatedAt, &q.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		mails = append(mails, q)
+	}
+
+	return mails, rows.Err()
+}
@@ -7,7 +7,8 @@ import (
 )
 
 type DBRepository interface {
-	AllUsers() bool
+	AllUsers() ([]models.User, error)
+	InsertUser(u *models.User, password string) (int, error)
 
 	InsertReservation(res *models.Reservation) (int, error)
 	InsertLaptopRestriction(lr *models.LaptopRestriction) error
@@ -16,15 +17,67 @@ type DBRepository interface {
 	GetLaptopByID(id int) (models.Laptop, error)
 	GetUserByID(id int) (models.User, error)
 	UpdateUser(u *models.User) error
+	GetUserByEmail(email string) (models.User, error)
+	UpdateUserPassword(id int, password string) error
 	Authenticate(email, password string) (int, string, error)
 	AllReservations() ([]models.Reservation, error)
 	AllNewReservations() ([]models.Reservation, error)
+	SearchReservations(f models.ReservationFilter) ([]models.Reservation, int, error)
 	GetReservatioByID(id int) (models.Reservation, error)
 	UpdateReservation(res *models.Reservation) error
+	RescheduleReservation(res *models.Reservation) error
 	DeleteReservation(id int) error
 	UpdateReservationProcessed(id, processed int) error
+	ProcessReservations(ids []int) ([]models.BulkResult, error)
+	CancelReservations(ids []int) ([]models.BulkResult, error)
 	AllLaptops() ([]models.Laptop, error)
+	UpdateLaptopBuffers(id, before, after int) error
 	GetLaptopRestrictionsByDate(laptopID int, start, end time.Time) ([]models.LaptopRestriction, error)
 	InsertOneDayBlockByLaptopID(id int, startDate time.Time) error
+	InsertBlockByLaptopID(id int, startDate, endDate time.Time) error
 	DeleteBlockByID(id int) error
+	MoveBlock(id, laptopID int, start, end time.Time) (int, error)
+	ChangeBlocks(add, remove []models.LaptopRestriction) ([]int, error)
+	InsertHold(laptopID int, start, end, expiresAt time.Time) (int, error)
+	ConvertHold(holdID int, res *models.Reservation) (int, error)
+	InsertReservationChecked(res *models.Reservation) (int, error)
+	DeleteHold(id int) error
+	DeleteExpiredHolds(now time.Time) (int, error)
+
+	InsertWaitlistEntry(e *models.WaitlistEntry) (int, error)
+	GetWaitlistByLaptopID(laptopID int) ([]models.WaitlistEntry, error)
+	GetWaitlistEntryByToken(token string) (models.WaitlistEntry, error)
+	UpdateWaitlistEntry(e *models.WaitlistEntry) error
+
+	GetBookingRules(laptopID int) (models.BookingRules, error)
+	SaveBookingRules(r *models.BookingRules) error
+	DeleteBookingRules(laptopID int) error
+	InsertBlackout(b *models.Blackout) (int, error)
+	GetBlackoutsByDate(laptopID int, start, end time.Time) ([]models.Blackout, error)
+	DeleteBlackout(id int) error
+
+	CountNewReservations() (int, error)
+	GetReservationsStartingOn(date time.Time) ([]models.Reservation, error)
+	GetReservationsEndingOn(date time.Time) ([]models.Reservation, error)
+	GetLaptopUtilization(start, end time.Time) ([]models.LaptopUtilization, error)
+	GetUpcomingBlocks(from time.Time, limit int) ([]models.LaptopRestriction, error)
+	CountReservationsByStartDate(start, end time.Time) ([]models.DailyCount, error)
+
+	GetReservationsByDate(start, end time.Time) ([]models.Reservation, error)
+	GetBlocksByDate(start, end time.Time) ([]models.LaptopRestriction, error)
+	GetCancellationsByDate(start, end time.Time) ([]models.Cancellation, error)
+
+	RecordReminder(reservationID int, kind string, sentAt time.Time) (bool, error)
+	GetRemindersByReservationID(reservationID int) ([]models.Reminder, error)
+
+	AcquireJobLease(name, owner string, scheduledAt, until time.Time) (bool, error)
+	ReleaseJobLease(name, owner string) error
+	InsertJobRun(run *models.JobRun) (int, error)
+	GetJobRuns(name string, limit int) ([]models.JobRun, error)
+	DeleteJobRunsBefore(t time.Time) (int, error)
+
+	QueueMail(q *models.QueuedMail) (int, error)
+	GetDueMail(now time.Time, limit int) ([]models.QueuedMail, error)
+	UpdateQueuedMail(q models.QueuedMail) error
+	DeleteQueuedMail(id int) error
 }
@@ -0,0 +1,1620 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/config"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// memory is a DBRepository that keeps all data in memory, for demos and tests without a database server
+type memory struct {
+	App *config.AppConfig
+
+	mu                 sync.RWMutex
+	users              map[int]models.User
+	laptops            map[int]models.Laptop
+	restrictions       map[int]models.Restriction
+	reservations       map[int]models.Reservation
+	laptopRestrictions map[int]models.LaptopRestriction
+	waitlist           map[int]models.WaitlistEntry
+	bookingRules       map[int]models.BookingRules
+	blackouts          map[int]models.Blackout
+	cancellations      map[int]models.Cancellation
+	reminders          map[int]models.Reminder
+	jobLeases          map[string]jobLease
+	jobRuns            map[int]models.JobRun
+	mailQueue          map[int]models.QueuedMail
+	lastID             map[string]int
+}
+
+// jobLease is the row of a job in the job_leases table
+type jobLease struct {
+	owner       string
+	scheduledAt time.Time
+	leaseUntil  time.Time
+}
+
+// NewMemory returns an in-memory repository seeded with the same data as the database migrations
+func NewMemory(a *config.AppConfig) DBRepository {
+	m := &memory{
+		App:                a,
+		users:              make(map[int]models.User),
+		laptops:            make(map[int]models.Laptop),
+		restrictions:       make(map[int]models.Restriction),
+		reservations:       make(map[int]models.Reservation),
+		laptopRestrictions: make(map[int]models.LaptopRestriction),
+		waitlist:           make(map[int]models.WaitlistEntry),
+		bookingRules:       make(map[int]models.BookingRules),
+		blackouts:          make(map[int]models.Blackout),
+		cancellations:      make(map[int]models.Cancellation),
+		reminders:          make(map[int]models.Reminder),
+		jobLeases:          make(map[string]jobLease),
+		jobRuns:            make(map[int]models.JobRun),
+		mailQueue:          make(map[int]models.QueuedMail),
+		lastID:             make(map[string]int),
+	}
+
+	seeded := time.Date(2021, 5, 9, 0, 0, 0, 0, time.UTC)
+	for _, name := range []string{"Alienware M15 R2", "Macbook Pro 15 inch"} {
+		id := m.nextID("laptops")
+		m.laptops[id] = models.Laptop{ID: id, LaptopName: name, CreatedAt: seeded, UpdatedAt: seeded}
+	}
+	for _, name := range []string{"Reservation", "Block", "Hold"} {
+		id := m.nextID("restrictions")
+		m.restrictions[id] = models.Restriction{ID: id, RestrictionName: name, CreatedAt: seeded, UpdatedAt: seeded}
+	}
+	id := m.nextID("users")
+	m.users[id] = models.User{
+		ID:          id,
+		FirstName:   "Big",
+		LastName:    "Brother",
+		Email:       "admin@admin.com",
+		Password:    "$2a$12$m//Xx2T.WoZCD3QMMJZ.D.mNzzSE5dI8APom/IavcK6HNXuDjrQqa",
+		AccessLevel: 3,
+		CreatedAt:   seeded,
+		UpdatedAt:   seeded,
+	}
+
+	return m
+}
+
+// nextID returns the next value of the id sequence of table, the caller must hold the lock
+func (m *memory) nextID(table string) int {
+	m.lastID[table]++
+	return m.lastID[table]
+}
+
+// truncateDate drops the time of day, like a date column does
+func truncateDate(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// overlaps reports whether the restriction lr overlaps the date range from start to end,
+// expired holds don't restrict anything anymore
+func overlaps(lr models.LaptopRestriction, start, end time.Time) bool {
+	if !lr.ExpiresAt.IsZero() && !lr.ExpiresAt.After(time.Now()) {
+		return false
+	}
+	return !truncateDate(start).After(lr.EndDate) && !truncateDate(end).Before(lr.StartDate)
+}
+
+// restricts reports whether lr keeps its laptop from being booked from start to end, reservations and holds
+// also keep the turnaround days of the laptop around them free, the caller must hold the lock
+func (m *memory) restricts(lr models.LaptopRestriction, start, end time.Time) bool {
+	turnaround := 0
+	if lr.RestrictionID != models.RestrictionBlock {
+		turnaround = m.laptops[lr.LaptopID].Turnaround()
+	}
+	return overlaps(lr, start.AddDate(0, 0, -turnaround), end.AddDate(0, 0, turnaround))
+}
+
+// AllUsers returns a slice of all users ordered by id
+func (m *memory) AllUsers() ([]models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var users []models.User
+	for _, u := range m.users {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+
+	return users, nil
+}
+
+// InsertUser inserts a user with the bcrypt hash of password and returns its id
+func (m *memory) InsertUser(u *models.User, password string) (int, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
+	if err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// the users table has a unique index on email
+	if _, ok := m.userByEmail(u.Email); ok {
+		return 0, errors.New("email already exists")
+	}
+
+	nu := *u
+	nu.ID = m.nextID("users")
+	nu.Password = string(hashedPassword)
+	nu.CreatedAt = time.Now()
+	nu.UpdatedAt = time.Now()
+	m.users[nu.ID] = nu
+
+	return nu.ID, nil
+}
+
+// userByEmail returns the user with email, the caller must hold the lock
+func (m *memory) userByEmail(email string) (models.User, bool) {
+	for _, u := range m.users {
+		if u.Email == email {
+			return u, true
+		}
+	}
+	return models.User{}, false
+}
+
+// InsertReservation inserts a reservation into the database
+func (m *memory) InsertReservation(res *models.Reservation) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.laptops[res.LaptopID]; !ok {
+		return 0, errors.New("laptop does not exist")
+	}
+
+	r := *res
+	r.ID = m.nextID("reservations")
+	r.StartDate = truncateDate(res.StartDate)
+	r.EndDate = truncateDate(res.EndDate)
+	r.CreatedAt = time.Now()
+	r.UpdatedAt = time.Now()
+	r.Laptop = models.Laptop{}
+	m.reservations[r.ID] = r
+
+	return r.ID, nil
+}
+
+// InsertLaptopRestriction inserts a laptop restriction into the database
+func (m *memory) InsertLaptopRestriction(lr *models.LaptopRestriction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.laptops[lr.LaptopID]; !ok {
+		return errors.New("laptop does not exist")
+	}
+	if _, ok := m.reservations[lr.ReservationID]; !ok {
+		return errors.New("reservation does not exist")
+	}
+	if _, ok := m.restrictions[lr.RestrictionID]; !ok {
+		return errors.New("restriction does not exist")
+	}
+
+	m.insertLaptopRestriction(models.LaptopRestriction{
+		StartDate:     lr.StartDate,
+		EndDate:       lr.EndDate,
+		LaptopID:      lr.LaptopID,
+		ReservationID: lr.ReservationID,
+		RestrictionID: lr.RestrictionID,
+	})
+
+	return nil
+}
+
+// insertLaptopRestriction stores lr with a new id, the caller must hold the lock
+func (m *memory) insertLaptopRestriction(lr models.LaptopRestriction) int {
+	lr.ID = m.nextID("laptop_restrictions")
+	lr.StartDate = truncateDate(lr.StartDate)
+	lr.EndDate = truncateDate(lr.EndDate)
+	lr.Version = 1
+	lr.CreatedAt = time.Now()
+	lr.UpdatedAt = time.Now()
+	m.laptopRestrictions[lr.ID] = lr
+	return lr.ID
+}
+
+// SearchAvailabilityByDatesLaptopID returns true if availability exists for laptop id, and false if no availability exists
+func (m *memory) SearchAvailabilityByDatesByLaptopID(start, end time.Time, laptopID int) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, lr := range m.laptopRestrictions {
+		if lr.LaptopID == laptopID && m.restricts(lr, start, end) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// SearchAvailabilityForAllLaptops returns a slice of available laptops if any, for given date range
+func (m *memory) SearchAvailabilityForAllLaptops(start, end time.Time) ([]models.Laptop, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var laptops []models.Laptop
+
+	restricted := make(map[int]bool)
+	for _, lr := range m.laptopRestrictions {
+		if m.restricts(lr, start, end) {
+			restricted[lr.LaptopID] = true
+		}
+	}
+
+	for _, laptop := range m.sortedLaptops() {
+		if !restricted[laptop.ID] {
+			laptops = append(laptops, models.Laptop{
+				ID:               laptop.ID,
+				LaptopName:       laptop.LaptopName,
+				BufferDaysBefore: laptop.BufferDaysBefore,
+				BufferDaysAfter:  laptop.BufferDaysAfter,
+			})
+		}
+	}
+
+	return laptops, nil
+}
+
+// sortedLaptops returns all laptops ordered by id, the caller must hold the lock
+func (m *memory) sortedLaptops() []models.Laptop {
+	laptops := make([]models.Laptop, 0, len(m.laptops))
+	for _, laptop := range m.laptops {
+		laptops = append(laptops, laptop)
+	}
+	sort.Slice(laptops, func(i, j int) bool {
+		return laptops[i].ID < laptops[j].ID
+	})
+	return laptops
+}
+
+// GetLaptopByID gets a laptop by id
+func (m *memory) GetLaptopByID(id int) (models.Laptop, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	laptop, ok := m.laptops[id]
+	if !ok {
+		return models.Laptop{}, sql.ErrNoRows
+	}
+
+	return laptop, nil
+}
+
+// GetUserByID returns a user by id
+func (m *memory) GetUserByID(id int) (models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	u, ok := m.users[id]
+	if !ok {
+		return models.User{}, sql.ErrNoRows
+	}
+
+	return u, nil
+}
+
+// UpdateUser updates a user in the database
+func (m *memory) UpdateUser(u *models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cur, ok := m.users[u.ID]
+	if !ok {
+		return nil
+	}
+
+	cur.FirstName = u.FirstName
+	cur.LastName = u.LastName
+	cur.Email = u.Email
+	cur.AccessLevel = u.AccessLevel
+	cur.UpdatedAt = time.Now()
+	m.users[u.ID] = cur
+
+	return nil
+}
+
+// GetUserByEmail returns a user by email
+func (m *memory) GetUserByEmail(email string) (models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	u, ok := m.userByEmail(email)
+	if !ok {
+		return models.User{}, sql.ErrNoRows
+	}
+
+	return u, nil
+}
+
+// UpdateUserPassword replaces the password of a user with the bcrypt hash of password
+func (m *memory) UpdateUserPassword(id int, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.users[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	u.Password = string(hashedPassword)
+	u.UpdatedAt = time.Now()
+	m.users[id] = u
+
+	return nil
+}
+
+// Authenticate authenticates a user
+func (m *memory) Authenticate(email, password string) (int, string, error) {
+	m.mu.RLock()
+	found, ok := m.userByEmail(email)
+	m.mu.RUnlock()
+
+	if !ok {
+		return 0, "", sql.ErrNoRows
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(found.Password), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return 0, "", errors.New("incorrect password")
+	} else if err != nil {
+		return 0, "", err
+	}
+
+	return found.ID, found.Password, nil
+}
+
+// AllReservations returns a slice of all reservations
+func (m *memory) AllReservations() ([]models.Reservation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.filterReservations(func(models.Reservation) bool { return true }), nil
+}
+
+// AllNewReservations returns a slice of all new reservations
+func (m *memory) AllNewReservations() ([]models.Reservation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.filterReservations(func(r models.Reservation) bool { return r.Processed == 0 }), nil
+}
+
+// filterReservations returns the reservations matching keep joined with their laptop
+// and ordered by start and end date, the caller must hold the lock
+func (m *memory) filterReservations(keep func(models.Reservation) bool) []models.Reservation {
+	var reservations []models.Reservation
+	for _, r := range m.reservations {
+		if keep(r) {
+			reservations = append(reservations, m.withLaptop(r))
+		}
+	}
+
+	sort.Slice(reservations, func(i, j int) bool {
+		a, b := reservations[i], reservations[j]
+		if !a.StartDate.Equal(b.StartDate) {
+			return a.StartDate.Before(b.StartDate)
+		}
+		if !a.EndDate.Equal(b.EndDate) {
+			return a.EndDate.Before(b.EndDate)
+		}
+		return a.ID < b.ID
+	})
+
+	return reservations
+}
+
+// withLaptop fills in the laptop of r, the caller must hold the lock
+func (m *memory) withLaptop(r models.Reservation) models.Reservation {
+	laptop := m.laptops[r.LaptopID]
+	r.Laptop = models.Laptop{ID: laptop.ID, LaptopName: laptop.LaptopName}
+	return r
+}
+
+// GetReservatioByID returns one reservation by id
+func (m *memory) GetReservatioByID(id int) (models.Reservation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	r, ok := m.reservations[id]
+	if !ok {
+		return models.Reservation{}, sql.ErrNoRows
+	}
+
+	return m.withLaptop(r), nil
+}
+
+// UpdateReservation updates a reservation in the database
+func (m *memory) UpdateReservation(res *models.Reservation) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cur, ok := m.reservations[res.ID]
+	if !ok {
+		return nil
+	}
+
+	cur.FirstName = res.FirstName
+	cur.LastName = res.LastName
+	cur.Email = res.Email
+	cur.Phone = res.Phone
+	cur.UpdatedAt = time.Now()
+	m.reservations[res.ID] = cur
+
+	return nil
+}
+
+// DeleteReservation deletes one reservation by id and records it as cancelled
+func (m *memory) DeleteReservation(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.cancelReservation(id)
+
+	return nil
+}
+
+// cancelReservation deletes a reservation and records its cancellation, the caller holds the write lock
+func (m *memory) cancelReservation(id int) {
+	if r, ok := m.reservations[id]; ok {
+		c := models.Cancellation{
+			ID:            m.nextID("reservation_cancellations"),
+			ReservationID: r.ID,
+			LaptopID:      r.LaptopID,
+			StartDate:     r.StartDate,
+			EndDate:       r.EndDate,
+			BookedAt:      r.CreatedAt,
+			CreatedAt:     time.Now(),
+			UpdatedAt:     time.Now(),
+		}
+		m.cancellations[c.ID] = c
+	}
+	delete(m.reservations, id)
+
+	// laptop_restrictions.reservation_id cascades on delete
+	for lrID, lr := range m.laptopRestrictions {
+		if lr.ReservationID == id {
+			delete(m.laptopRestrictions, lrID)
+		}
+	}
+}
+
+// UpdateReservationProcessed updates a reservation processed status by id
+func (m *memory) UpdateReservationProcessed(id, processed int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cur, ok := m.reservations[id]
+	if !ok {
+		return nil
+	}
+
+	cur.Processed = processed
+	m.reservations[id] = cur
+
+	return nil
+}
+
+// AllLaptops returns all laptops
+func (m *memory) AllLaptops() ([]models.Laptop, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	laptops := m.sortedLaptops()
+	sort.SliceStable(laptops, func(i, j int) bool {
+		return laptops[i].LaptopName < laptops[j].LaptopName
+	})
+
+	return laptops, nil
+}
+
+// UpdateLaptopBuffers sets the turnaround days kept free before and after each reservation of a laptop
+func (m *memory) UpdateLaptopBuffers(id, before, after int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	laptop, ok := m.laptops[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	laptop.BufferDaysBefore = before
+	laptop.BufferDaysAfter = after
+	laptop.UpdatedAt = time.Now()
+	m.laptops[id] = laptop
+
+	return nil
+}
+
+// GetLaptopRestrictionsByDate returns restrictions for a laptop by date range
+func (m *memory) GetLaptopRestrictionsByDate(laptopID int, start, end time.Time) ([]models.LaptopRestriction, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var restrictions []models.LaptopRestriction
+	for _, lr := range m.laptopRestrictions {
+		if lr.LaptopID == laptopID && overlaps(lr, start, end) {
+			restrictions = append(restrictions, models.LaptopRestriction{
+				ID:            lr.ID,
+				ReservationID: lr.ReservationID,
+				RestrictionID: lr.RestrictionID,
+				LaptopID:      lr.LaptopID,
+				StartDate:     lr.StartDate,
+				EndDate:       lr.EndDate,
+				ExpiresAt:     lr.ExpiresAt,
+				Version:       lr.Version,
+			})
+		}
+	}
+
+	sort.Slice(restrictions, func(i, j int) bool {
+		return restrictions[i].ID < restrictions[j].ID
+	})
+
+	return restrictions, nil
+}
+
+// InsertOneDayBlockByLaptopID inserts a one day block restriction by laptop id
+func (m *memory) InsertOneDayBlockByLaptopID(id int, startDate time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.laptops[id]; !ok {
+		return errors.New("laptop does not exist")
+	}
+
+	m.insertLaptopRestriction(models.LaptopRestriction{
+		StartDate:     startDate,
+		EndDate:       startDate,
+		LaptopID:      id,
+		RestrictionID: 2,
+	})
+
+	return nil
+}
+
+// InsertBlockByLaptopID inserts a block restriction for a laptop from startDate to endDate
+func (m *memory) InsertBlockByLaptopID(id int, startDate, endDate time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.laptops[id]; !ok {
+		return errors.New("laptop does not exist")
+	}
+
+	m.insertLaptopRestriction(models.LaptopRestriction{
+		StartDate:     startDate,
+		EndDate:       endDate,
+		LaptopID:      id,
+		RestrictionID: 2,
+	})
+
+	return nil
+}
+
+// DeleteBlockByLaptopID deletes a laptop restriction by id
+func (m *memory) DeleteBlockByID(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.laptopRestrictions, id)
+
+	return nil
+}
+
+// InsertWaitlistEntry puts a customer on the waitlist of a laptop and returns the id of the entry
+func (m *memory) InsertWaitlistEntry(e *models.WaitlistEntry) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.laptops[e.LaptopID]; !ok {
+		return 0, errors.New("laptop does not exist")
+	}
+
+	ne := *e
+	ne.ID = m.nextID("waitlist_entries")
+	ne.StartDate = truncateDate(e.StartDate)
+	ne.EndDate = truncateDate(e.EndDate)
+	ne.Status = models.WaitlistWaiting
+	ne.Token = ""
+	ne.HoldUntil = time.Time{}
+	ne.HoldID = 0
+	ne.CreatedAt = time.Now()
+	ne.UpdatedAt = time.Now()
+	ne.Laptop = models.Laptop{}
+	m.waitlist[ne.ID] = ne
+
+	return ne.ID, nil
+}
+
+// GetWaitlistByLaptopID returns the waiting and notified entries of a laptop in the order they joined
+func (m *memory) GetWaitlistByLaptopID(laptopID int) ([]models.WaitlistEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var entries []models.WaitlistEntry
+	for _, e := range m.waitlist {
+		if e.LaptopID == laptopID && (e.Status == models.WaitlistWaiting || e.Status == models.WaitlistNotified) {
+			entries = append(entries, m.withWaitlistLaptop(e))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ID < entries[j].ID
+	})
+
+	return entries, nil
+}
+
+// GetWaitlistEntryByToken returns the entry a hold link was sent for
+func (m *memory) GetWaitlistEntryByToken(token string) (models.WaitlistEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if token != "" {
+		for _, e := range m.waitlist {
+			if e.Token == token {
+				return m.withWaitlistLaptop(e), nil
+			}
+		}
+	}
+
+	return models.WaitlistEntry{}, sql.ErrNoRows
+}
+
+// UpdateWaitlistEntry updates the status and hold of a waitlist entry
+func (m *memory) UpdateWaitlistEntry(e *models.WaitlistEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cur, ok := m.waitlist[e.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	cur.Status = e.Status
+	cur.Token = e.Token
+	cur.HoldUntil = e.HoldUntil
+	cur.HoldID = e.HoldID
+	cur.UpdatedAt = time.Now()
+	m.waitlist[e.ID] = cur
+
+	return nil
+}
+
+// withWaitlistLaptop fills in the laptop of e like the join on laptops does, the caller must hold the lock
+func (m *memory) withWaitlistLaptop(e models.WaitlistEntry) models.WaitlistEntry {
+	lp := m.laptops[e.LaptopID]
+	e.Laptop = models.Laptop{ID: lp.ID, LaptopName: lp.LaptopName}
+	return e
+}
+
+// InsertHold holds a laptop from start to end until expiresAt and returns the id of the hold,
+// ErrNotAvailable if the dates overlap another restriction of the laptop
+func (m *memory) InsertHold(laptopID int, start, end, expiresAt time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := m.checkAvailability(laptopID, start, end, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	return m.insertLaptopRestriction(models.LaptopRestriction{
+		StartDate:     start,
+		EndDate:       end,
+		LaptopID:      laptopID,
+		RestrictionID: models.RestrictionHold,
+		ExpiresAt:     expiresAt,
+	}), nil
+}
+
+// ConvertHold inserts res and turns the hold holdID into its reservation restriction. When the hold has
+// expired res is booked directly if its dates are still free, otherwise ErrNotAvailable is returned.
+func (m *memory) ConvertHold(holdID int, res *models.Reservation) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.laptops[res.LaptopID]; !ok {
+		return 0, errors.New("laptop does not exist")
+	}
+
+	hold, ok := m.laptopRestrictions[holdID]
+	held := ok && hold.RestrictionID == models.RestrictionHold && hold.ExpiresAt.After(time.Now()) &&
+		hold.LaptopID == res.LaptopID && hold.StartDate.Equal(truncateDate(res.StartDate)) &&
+		hold.EndDate.Equal(truncateDate(res.EndDate))
+
+	if !held {
+		// the hold is gone, book the dates if nobody took them in the meantime
+		if ok && hold.RestrictionID == models.RestrictionHold {
+			delete(m.laptopRestrictions, holdID)
+		}
+		err := m.checkAvailability(res.LaptopID, res.StartDate, res.EndDate, 0)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	r := *res
+	r.ID = m.nextID("reservations")
+	r.StartDate = truncateDate(res.StartDate)
+	r.EndDate = truncateDate(res.EndDate)
+	r.CreatedAt = time.Now()
+	r.UpdatedAt = time.Now()
+	r.Laptop = models.Laptop{}
+	m.reservations[r.ID] = r
+
+	if held {
+		hold.RestrictionID = models.RestrictionReservation
+		hold.ReservationID = r.ID
+		hold.ExpiresAt = time.Time{}
+		hold.UpdatedAt = time.Now()
+		m.laptopRestrictions[holdID] = hold
+	} else {
+		m.insertLaptopRestriction(models.LaptopRestriction{
+			StartDate:     r.StartDate,
+			EndDate:       r.EndDate,
+			LaptopID:      r.LaptopID,
+			ReservationID: r.ID,
+			RestrictionID: models.RestrictionReservation,
+		})
+	}
+
+	return r.ID, nil
+}
+
+// InsertReservationChecked inserts res with its reservation restriction in one transaction and returns its id,
+// ErrNotAvailable if its dates overlap another restriction of the laptop
+func (m *memory) InsertReservationChecked(res *models.Reservation) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.laptops[res.LaptopID]; !ok {
+		return 0, errors.New("laptop does not exist")
+	}
+	err := m.checkAvailability(res.LaptopID, res.StartDate, res.EndDate, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	r := *res
+	r.ID = m.nextID("reservations")
+	r.StartDate = truncateDate(res.StartDate)
+	r.EndDate = truncateDate(res.EndDate)
+	r.CreatedAt = time.Now()
+	r.UpdatedAt = time.Now()
+	r.Laptop = models.Laptop{}
+	m.reservations[r.ID] = r
+
+	m.insertLaptopRestriction(models.LaptopRestriction{
+		StartDate:     r.StartDate,
+		EndDate:       r.EndDate,
+		LaptopID:      r.LaptopID,
+		ReservationID: r.ID,
+		RestrictionID: models.RestrictionReservation,
+	})
+
+	return r.ID, nil
+}
+
+// checkAvailability returns ErrNotAvailable if start to end overlaps a restriction of the laptop other than
+// the restriction except, 0 for none. The caller must hold the lock
+func (m *memory) checkAvailability(laptopID int, start, end time.Time, except int) error {
+	if _, ok := m.laptops[laptopID]; !ok {
+		return errors.New("laptop does not exist")
+	}
+	for _, lr := range m.laptopRestrictions {
+		if lr.LaptopID == laptopID && lr.ID != except && m.restricts(lr, start, end) {
+			return ErrNotAvailable
+		}
+	}
+	return nil
+}
+
+// checkBlockDays is checkAvailability for a block from start to end, blocks don't keep a turnaround free so only
+// the blocked days themselves must not overlap a restriction that isn't in except. The caller holds the lock.
+func (m *memory) checkBlockDays(laptopID int, start, end time.Time, except map[int]bool) error {
+	if _, ok := m.laptops[laptopID]; !ok {
+		return sql.ErrNoRows
+	}
+	for _, lr := range m.laptopRestrictions {
+		if lr.LaptopID == laptopID && !except[lr.ID] && overlaps(lr, start, end) {
+			return ErrNotAvailable
+		}
+	}
+	return nil
+}
+
+// DeleteHold releases a hold
+func (m *memory) DeleteHold(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if lr, ok := m.laptopRestrictions[id]; ok && lr.RestrictionID == models.RestrictionHold {
+		delete(m.laptopRestrictions, id)
+	}
+
+	return nil
+}
+
+// DeleteExpiredHolds deletes the holds that expired by now and returns how many there were
+func (m *memory) DeleteExpiredHolds(now time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := 0
+	for id, lr := range m.laptopRestrictions {
+		if lr.RestrictionID == models.RestrictionHold && !lr.ExpiresAt.After(now) {
+			delete(m.laptopRestrictions, id)
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// GetBookingRules returns the booking rules of a laptop, or the global ones for laptop id 0
+func (m *memory) GetBookingRules(laptopID int) (models.BookingRules, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	r, ok := m.bookingRules[laptopID]
+	if !ok {
+		return models.BookingRules{}, sql.ErrNoRows
+	}
+	r.PickupWeekdays = append([]time.Weekday(nil), r.PickupWeekdays...)
+
+	return r, nil
+}
+
+// SaveBookingRules inserts or replaces the booking rules of r.LaptopID
+func (m *memory) SaveBookingRules(r *models.BookingRules) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.bookingRules[r.LaptopID]
+	if !ok {
+		stored = models.BookingRules{ID: m.nextID("booking_rules"), LaptopID: r.LaptopID, CreatedAt: time.Now()}
+	}
+	stored.MinDays = r.MinDays
+	stored.MaxDays = r.MaxDays
+	stored.MaxAdvanceDays = r.MaxAdvanceDays
+	stored.PickupWeekdays = append([]time.Weekday(nil), r.PickupWeekdays...)
+	stored.UpdatedAt = time.Now()
+	m.bookingRules[r.LaptopID] = stored
+
+	r.ID = stored.ID
+	return nil
+}
+
+// DeleteBookingRules deletes the booking rules of a laptop, or the global ones for laptop id 0
+func (m *memory) DeleteBookingRules(laptopID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.bookingRules[laptopID]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(m.bookingRules, laptopID)
+
+	return nil
+}
+
+// InsertBlackout inserts a blackout and returns its id
+func (m *memory) InsertBlackout(b *models.Blackout) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := *b
+	stored.ID = m.nextID("blackouts")
+	stored.StartDate = truncateDate(b.StartDate)
+	stored.EndDate = truncateDate(b.EndDate)
+	stored.CreatedAt = time.Now()
+	stored.UpdatedAt = time.Now()
+	m.blackouts[stored.ID] = stored
+
+	return stored.ID, nil
+}
+
+// GetBlackoutsByDate returns the blackouts of a laptop and of every laptop overlapping start to end,
+// only the ones of every laptop for laptop id 0
+func (m *memory) GetBlackoutsByDate(laptopID int, start, end time.Time) ([]models.Blackout, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var blackouts []models.Blackout
+	for _, b := range m.blackouts {
+		if (b.LaptopID == 0 || b.LaptopID == laptopID) &&
+			!truncateDate(start).After(b.EndDate) && !truncateDate(end).Before(b.StartDate) {
+			blackouts = append(blackouts, b)
+		}
+	}
+
+	sort.Slice(blackouts, func(i, j int) bool {
+		if !blackouts[i].StartDate.Equal(blackouts[j].StartDate) {
+			return blackouts[i].StartDate.Before(blackouts[j].StartDate)
+		}
+		return blackouts[i].ID < blackouts[j].ID
+	})
+
+	return blackouts, nil
+}
+
+// DeleteBlackout deletes a blackout by id
+func (m *memory) DeleteBlackout(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.blackouts[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(m.blackouts, id)
+
+	return nil
+}
+
+// CountNewReservations returns the number of reservations that haven't been processed yet
+func (m *memory) CountNewReservations() (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	n := 0
+	for _, r := range m.reservations {
+		if r.Processed == 0 {
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// GetReservationsStartingOn returns the reservations picked up on date
+func (m *memory) GetReservationsStartingOn(date time.Time) ([]models.Reservation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	day := truncateDate(date)
+	return m.reservationsByLaptopName(func(r models.Reservation) bool { return r.StartDate.Equal(day) }), nil
+}
+
+// GetReservationsEndingOn returns the reservations returned on date
+func (m *memory) GetReservationsEndingOn(date time.Time) ([]models.Reservation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	day := truncateDate(date)
+	return m.reservationsByLaptopName(func(r models.Reservation) bool { return r.EndDate.Equal(day) }), nil
+}
+
+// reservationsByLaptopName returns the reservations matching keep joined with their laptop
+// and ordered by laptop name and id, the caller must hold the lock
+func (m *memory) reservationsByLaptopName(keep func(models.Reservation) bool) []models.Reservation {
+	reservations := m.filterReservations(keep)
+	sort.SliceStable(reservations, func(i, j int) bool {
+		a, b := reservations[i], reservations[j]
+		if a.Laptop.LaptopName != b.Laptop.LaptopName {
+			return a.Laptop.LaptopName < b.Laptop.LaptopName
+		}
+		return a.ID < b.ID
+	})
+
+	return reservations
+}
+
+// GetLaptopUtilization returns how many days from start to end each laptop was rented, ordered by laptop name
+func (m *memory) GetLaptopUtilization(start, end time.Time) ([]models.LaptopUtilization, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	start, end = truncateDate(start), truncateDate(end)
+
+	rented := make(map[int]int)
+	for _, r := range m.reservations {
+		if r.StartDate.After(end) || r.EndDate.Before(start) {
+			continue
+		}
+		from, to := r.StartDate, r.EndDate
+		if from.Before(start) {
+			from = start
+		}
+		if to.After(end) {
+			to = end
+		}
+		rented[r.LaptopID] += periodDays(from, to)
+	}
+
+	var utilization []models.LaptopUtilization
+	for _, laptop := range m.sortedLaptops() {
+		utilization = append(utilization, models.LaptopUtilization{
+			Laptop:     models.Laptop{ID: laptop.ID, LaptopName: laptop.LaptopName},
+			RentedDays: rented[laptop.ID],
+			PeriodDays: periodDays(start, end),
+			Start:      start,
+			End:        end,
+		})
+	}
+	sort.SliceStable(utilization, func(i, j int) bool {
+		return utilization[i].Laptop.LaptopName < utilization[j].Laptop.LaptopName
+	})
+
+	return utilization, nil
+}
+
+// GetUpcomingBlocks returns at most limit blocks of every laptop that don't end before from, the earliest first
+func (m *memory) GetUpcomingBlocks(from time.Time, limit int) ([]models.LaptopRestriction, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	from = truncateDate(from)
+
+	var blocks []models.LaptopRestriction
+	for _, lr := range m.laptopRestrictions {
+		if lr.RestrictionID == models.RestrictionBlock && !lr.EndDate.Before(from) {
+			laptop := m.laptops[lr.LaptopID]
+			blocks = append(blocks, models.LaptopRestriction{
+				ID:            lr.ID,
+				LaptopID:      lr.LaptopID,
+				RestrictionID: lr.RestrictionID,
+				StartDate:     lr.StartDate,
+				EndDate:       lr.EndDate,
+				Laptop:        models.Laptop{ID: laptop.ID, LaptopName: laptop.LaptopName},
+			})
+		}
+	}
+
+	sort.Slice(blocks, func(i, j int) bool {
+		if !blocks[i].StartDate.Equal(blocks[j].StartDate) {
+			return blocks[i].StartDate.Before(blocks[j].StartDate)
+		}
+		return blocks[i].ID < blocks[j].ID
+	})
+	if len(blocks) > limit {
+		blocks = blocks[:limit]
+	}
+
+	return blocks, nil
+}
+
+// CountReservationsByStartDate returns the number of reservations picked up on each day from start to end,
+// days without any are left out
+func (m *memory) CountReservationsByStartDate(start, end time.Time) ([]models.DailyCount, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	start, end = truncateDate(start), truncateDate(end)
+
+	byDate := make(map[time.Time]int)
+	for _, r := range m.reservations {
+		if !r.StartDate.Before(start) && !r.StartDate.After(end) {
+			byDate[r.StartDate]++
+		}
+	}
+
+	var counts []models.DailyCount
+	for date, n := range byDate {
+		counts = append(counts, models.DailyCount{Date: date, Count: n})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		return counts[i].Date.Before(counts[j].Date)
+	})
+
+	return counts, nil
+}
+
+// GetReservationsByDate returns the reservations of every laptop overlapping start to end
+func (m *memory) GetReservationsByDate(start, end time.Time) ([]models.Reservation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	start, end = truncateDate(start), truncateDate(end)
+	return m.filterReservations(func(r models.Reservation) bool {
+		return !start.After(r.EndDate) && !end.Before(r.StartDate)
+	}), nil
+}
+
+// GetBlocksByDate returns the blocks of every laptop overlapping start to end, joined with the laptop name
+func (m *memory) GetBlocksByDate(start, end time.Time) ([]models.LaptopRestriction, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var blocks []models.LaptopRestriction
+	for _, lr := range m.laptopRestrictions {
+		if lr.RestrictionID == models.RestrictionBlock && overlaps(lr, start, end) {
+			laptop := m.laptops[lr.LaptopID]
+			blocks = append(blocks, models.LaptopRestriction{
+				ID:            lr.ID,
+				LaptopID:      lr.LaptopID,
+				RestrictionID: lr.RestrictionID,
+				StartDate:     lr.StartDate,
+				EndDate:       lr.EndDate,
+				Laptop:        models.Laptop{ID: laptop.ID, LaptopName: laptop.LaptopName},
+			})
+		}
+	}
+
+	sort.Slice(blocks, func(i, j int) bool {
+		if !blocks[i].StartDate.Equal(blocks[j].StartDate) {
+			return blocks[i].StartDate.Before(blocks[j].StartDate)
+		}
+		return blocks[i].ID < blocks[j].ID
+	})
+
+	return blocks, nil
+}
+
+// GetCancellationsByDate returns the cancelled reservations of every laptop overlapping start to end
+func (m *memory) GetCancellationsByDate(start, end time.Time) ([]models.Cancellation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	start, end = truncateDate(start), truncateDate(end)
+
+	var cancellations []models.Cancellation
+	for _, c := range m.cancellations {
+		if !start.After(c.EndDate) && !end.Before(c.StartDate) {
+			cancellations = append(cancellations, c)
+		}
+	}
+
+	sort.Slice(cancellations, func(i, j int) bool {
+		if !cancellations[i].StartDate.Equal(cancellations[j].StartDate) {
+			return cancellations[i].StartDate.Before(cancellations[j].StartDate)
+		}
+		return cancellations[i].ID < cancellations[j].ID
+	})
+
+	return cancellations, nil
+}
+
+// compareTimes returns -1 if a is before b, 1 if it is after and 0 if they are equal
+func compareTimes(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
+
+// SearchReservations returns the page of reservations selected by f joined with their laptop,
+// and how many reservations f selects on all pages
+func (m *memory) SearchReservations(f models.ReservationFilter) ([]models.Reservation, int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	words := strings.Fields(strings.ToLower(f.Search))
+	start, end := truncateDate(f.Start), truncateDate(f.End)
+	reservations := m.filterReservations(func(r models.Reservation) bool {
+		for _, word := range words {
+			if !strings.Contains(strings.ToLower(r.FirstName), word) && !strings.Contains(strings.ToLower(r.LastName), word) &&
+				!strings.Contains(strings.ToLower(r.Email), word) && !strings.Contains(strings.ToLower(r.Phone), word) {
+				return false
+			}
+		}
+		switch {
+		case f.LaptopID != 0 && r.LaptopID != f.LaptopID,
+			!f.Start.IsZero() && r.EndDate.Before(start),
+			!f.End.IsZero() && r.StartDate.After(end),
+			f.Processed == models.ReservationsNew && r.Processed != 0,
+			f.Processed == models.ReservationsProcessed && r.Processed == 0:
+			return false
+		}
+		return true
+	})
+
+	compare := func(a, b models.Reservation) int {
+		switch f.Sort {
+		case "name":
+			return strings.Compare(strings.ToLower(a.LastName), strings.ToLower(b.LastName))
+		case "laptop":
+			return strings.Compare(a.Laptop.LaptopName, b.Laptop.LaptopName)
+		case "start":
+			return compareTimes(a.StartDate, b.StartDate)
+		case "end":
+			return compareTimes(a.EndDate, b.EndDate)
+		case "created":
+			return compareTimes(a.CreatedAt, b.CreatedAt)
+		}
+		return 0
+	}
+	sort.Slice(reservations, func(i, j int) bool {
+		c := compare(reservations[i], reservations[j])
+		if c == 0 {
+			c = reservations[i].ID - reservations[j].ID
+		}
+		if f.Desc {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	total := len(reservations)
+	if f.Limit > 0 {
+		if f.Offset > total {
+			f.Offset = total
+		}
+		reservations = reservations[f.Offset:]
+		if f.Limit < len(reservations) {
+			reservations = reservations[:f.Limit]
+		}
+	}
+
+	return reservations, total, nil
+}
+
+// bulkReservations applies action to each reservation under one write lock,
+// a missing reservation fails only its own result
+func (m *memory) bulkReservations(ids []int, action func(id int)) []models.BulkResult {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	results := make([]models.BulkResult, 0, len(ids))
+	for _, id := range ids {
+		result := models.BulkResult{ReservationID: id}
+		r, ok := m.reservations[id]
+		if !ok {
+			result.Err = sql.ErrNoRows
+		} else {
+			result.Reservation = m.withLaptop(r)
+			action(id)
+		}
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// ProcessReservations marks reservations as processed in one transaction
+func (m *memory) ProcessReservations(ids []int) ([]models.BulkResult, error) {
+	return m.bulkReservations(ids, func(id int) {
+		r := m.reservations[id]
+		r.Processed = 1
+		r.UpdatedAt = time.Now()
+		m.reservations[id] = r
+	}), nil
+}
+
+// CancelReservations deletes reservations and records their cancellations in one transaction
+func (m *memory) CancelReservations(ids []int) ([]models.BulkResult, error) {
+	return m.bulkReservations(ids, m.cancelReservation), nil
+}
+
+// RescheduleReservation saves the customer details of res and moves it and its laptop restriction to its laptop
+// and dates in one transaction, ErrNotAvailable is returned if they overlap any other restriction of the laptop
+func (m *memory) RescheduleReservation(res *models.Reservation) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, ok := m.reservations[res.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	lrID := 0
+	for _, lr := range m.laptopRestrictions {
+		if lr.ReservationID == res.ID && lr.RestrictionID == models.RestrictionReservation {
+			lrID = lr.ID
+		}
+	}
+	if lrID == 0 {
+		return sql.ErrNoRows
+	}
+
+	err := m.moveRestriction(lrID, res.LaptopID, res.StartDate, res.EndDate)
+	if err != nil {
+		return err
+	}
+
+	r.FirstName = res.FirstName
+	r.LastName = res.LastName
+	r.Email = res.Email
+	r.Phone = res.Phone
+	r.LaptopID = res.LaptopID
+	r.StartDate = truncateDate(res.StartDate)
+	r.EndDate = truncateDate(res.EndDate)
+	r.UpdatedAt = time.Now()
+	m.reservations[res.ID] = r
+
+	return nil
+}
+
+// MoveBlock moves a block to the laptop and dates and returns the laptop it was on, ErrNotAvailable is returned
+// if the days overlap any other restriction of the laptop
+func (m *memory) MoveBlock(id, laptopID int, start, end time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	lr, ok := m.laptopRestrictions[id]
+	if !ok || lr.RestrictionID != models.RestrictionBlock {
+		return 0, sql.ErrNoRows
+	}
+
+	err := m.checkBlockDays(laptopID, start, end, map[int]bool{id: true})
+	if err != nil {
+		return 0, err
+	}
+	m.updateRestriction(id, laptopID, start, end)
+
+	return lr.LaptopID, nil
+}
+
+// moveRestriction moves the restriction lrID to the laptop and dates, ErrNotAvailable is returned if they
+// overlap any other restriction of the laptop. The caller holds the write lock.
+func (m *memory) moveRestriction(lrID, laptopID int, start, end time.Time) error {
+	err := m.checkAvailability(laptopID, start, end, lrID)
+	if err != nil {
+		return err
+	}
+	m.updateRestriction(lrID, laptopID, start, end)
+
+	return nil
+}
+
+// updateRestriction sets the laptop and dates of the restriction lrID, the caller holds the write lock
+func (m *memory) updateRestriction(lrID, laptopID int, start, end time.Time) {
+	lr := m.laptopRestrictions[lrID]
+	lr.LaptopID = laptopID
+	lr.StartDate = truncateDate(start)
+	lr.EndDate = truncateDate(end)
+	lr.Version++
+	lr.UpdatedAt = time.Now()
+	m.laptopRestrictions[lrID] = lr
+}
+
+// ChangeBlocks adds and removes blocks at once and returns the laptops blocks were removed from.
+// A block is only removed at the version it was read at, ErrBlockChanged is returned if it was moved or removed
+// since and ErrNotAvailable if the days of an added block were restricted since, nothing is changed then.
+func (m *memory) ChangeBlocks(add, remove []models.LaptopRestriction) ([]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// everything is checked before anything is changed
+	var unblocked []int
+	removed := make(map[int]bool)
+	for _, b := range remove {
+		lr, ok := m.laptopRestrictions[b.ID]
+		if !ok || removed[b.ID] || lr.RestrictionID != models.RestrictionBlock || lr.Version != b.Version {
+			return nil, ErrBlockChanged
+		}
+		removed[b.ID] = true
+		unblocked = appendUnique(unblocked, lr.LaptopID)
+	}
+	for i, b := range add {
+		err := m.checkBlockDays(b.LaptopID, b.StartDate, b.EndDate, removed)
+		if err != nil {
+			return nil, err
+		}
+		for _, other := range add[:i] {
+			if other.LaptopID == b.LaptopID && overlaps(other, b.StartDate, b.EndDate) {
+				return nil, ErrNotAvailable
+			}
+		}
+	}
+
+	for id := range removed {
+		delete(m.laptopRestrictions, id)
+	}
+	for _, b := range add {
+		m.insertLaptopRestriction(models.LaptopRestriction{
+			StartDate:     b.StartDate,
+			EndDate:       b.EndDate,
+			LaptopID:      b.LaptopID,
+			RestrictionID: models.RestrictionBlock,
+		})
+	}
+
+	return unblocked, nil
+}
+
+// RecordReminder records that the reminder of kind was mailed for a reservation, false is returned without
+// recording anything if it already was
+func (m *memory) RecordReminder(reservationID int, kind string, sentAt time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, r := range m.reminders {
+		if r.ReservationID == reservationID && r.Kind == kind {
+			return false, nil
+		}
+	}
+
+	id := m.nextID("reservation_reminders")
+	m.reminders[id] = models.Reminder{
+		ID:            id,
+		ReservationID: reservationID,
+		Kind:          kind,
+		SentAt:        sentAt,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+
+	return true, nil
+}
+
+// GetRemindersByReservationID returns the reminders mailed for a reservation in the order they were sent
+func (m *memory) GetRemindersByReservationID(reservationID int) ([]models.Reminder, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var reminders []models.Reminder
+	for _, r := range m.reminders {
+		if r.ReservationID == reservationID {
+			reminders = append(reminders, r)
+		}
+	}
+
+	sort.Slice(reminders, func(i, j int) bool {
+		if !reminders[i].SentAt.Equal(reminders[j].SentAt) {
+			return reminders[i].SentAt.Before(reminders[j].SentAt)
+		}
+		return reminders[i].ID < reminders[j].ID
+	})
+
+	return reminders, nil
+}
+
+// AcquireJobLease takes the lease of the job name until until for its run scheduled at scheduledAt, false is
+// returned if the run was already taken or the last run still holds the lease
+func (m *memory) AcquireJobLease(name, owner string, scheduledAt, until time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l, ok := m.jobLeases[name]
+	if ok && (!l.scheduledAt.Before(scheduledAt) || l.leaseUntil.After(time.Now())) {
+		return false, nil
+	}
+
+	m.jobLeases[name] = jobLease{owner: owner, scheduledAt: scheduledAt, leaseUntil: until}
+	return true, nil
+}
+
+// ReleaseJobLease ends the lease owner holds on the job name, so that the next run doesn't wait for it
+func (m *memory) ReleaseJobLease(name, owner string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l, ok := m.jobLeases[name]
+	if ok && l.owner == owner {
+		l.leaseUntil = time.Now()
+		m.jobLeases[name] = l
+	}
+
+	return nil
+}
+
+// InsertJobRun records a run of a job
+func (m *memory) InsertJobRun(run *models.JobRun) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r := *run
+	r.ID = m.nextID("job_runs")
+	r.CreatedAt = time.Now()
+	r.UpdatedAt = time.Now()
+	m.jobRuns[r.ID] = r
+
+	return r.ID, nil
+}
+
+// GetJobRuns returns the last limit runs of the job name, of every job if name is empty, latest first
+func (m *memory) GetJobRuns(name string, limit int) ([]models.JobRun, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var runs []models.JobRun
+	for _, r := range m.jobRuns {
+		if name == "" || r.Name == name {
+			runs = append(runs, r)
+		}
+	}
+
+	sort.Slice(runs, func(i, j int) bool {
+		if !runs[i].StartedAt.Equal(runs[j].StartedAt) {
+			return runs[i].StartedAt.After(runs[j].StartedAt)
+		}
+		return runs[i].ID > runs[j].ID
+	})
+	if len(runs) > limit {
+		runs = runs[:limit]
+	}
+
+	return runs, nil
+}
+
+// DeleteJobRunsBefore deletes the job runs started before t and returns how many there were
+func (m *memory) DeleteJobRunsBefore(t time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := 0
+	for id, r := range m.jobRuns {
+		if r.StartedAt.Before(t) {
+			delete(m.jobRuns, id)
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// QueueMail adds a mail to the mail queue, to be sent from its NextAttemptAt
+func (m *memory) QueueMail(q *models.QueuedMail) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	queued := *q
+	queued.ID = m.nextID("mail_queue")
+	queued.CreatedAt = time.Now()
+	queued.UpdatedAt = time.Now()
+	m.mailQueue[queued.ID] = queued
+
+	return queued.ID, nil
+}
+
+// GetDueMail returns up to limit pending mails whose next attempt is due at now, the longest waiting first
+func (m *memory) GetDueMail(now time.Time, limit int) ([]models.QueuedMail, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var mails []models.QueuedMail
+	for _, q := range m.mailQueue {
+		if q.Status == models.MailPending && !q.NextAttemptAt.After(now) {
+			mails = append(mails, q)
+		}
+	}
+
+	sort.Slice(mails, func(i, j int) bool {
+		if !mails[i].NextAttemptAt.Equal(mails[j].NextAttemptAt) {
+			return mails[i].NextAttemptAt.Before(mails[j].NextAttemptAt)
+		}
+		return mails[i].ID < mails[j].ID
+	})
+	if len(mails) > limit {
+		mails = mails[:limit]
+	}
+
+	return mails, nil
+}
+
+// UpdateQueuedMail saves the status, attempts, last error and next attempt of a queued mail
+func (m *memory) UpdateQueuedMail(q models.QueuedMail) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	queued, ok := m.mailQueue[q.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	queued.Status = q.Status
+	queued.Attempts = q.Attempts
+	queued.LastError = q.LastError
+	queued.NextAttemptAt = q.NextAttemptAt
+	queued.UpdatedAt = time.Now()
+	m.mailQueue[q.ID] = queued
+
+	return nil
+}
+
+// DeleteQueuedMail removes a mail that was sent from the mail queue
+func (m *memory) DeleteQueuedMail(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.mailQueue, id)
+	return nil
+}
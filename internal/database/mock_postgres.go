@@ -1,14 +1,22 @@
 package database
 
 import (
+	"database/sql"
 	"errors"
 	"time"
 
 	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
 )
 
-func (p *mockPostgres) AllUsers() bool {
-	return true
+// AllUsers returns a slice of all users
+func (p *mockPostgres) AllUsers() ([]models.User, error) {
+	var users []models.User
+	return users, nil
+}
+
+// InsertUser inserts a user and returns its id
+func (p *mockPostgres) InsertUser(u *models.User, password string) (int, error) {
+	return 1, nil
 }
 
 // InsertReservation inserts a reservation into the database
@@ -85,6 +93,17 @@ func (p *mockPostgres) Authenticate(email, password string) (int, string, error)
 	return 1, "", nil
 }
 
+// GetUserByEmail returns a user by email
+func (p *mockPostgres) GetUserByEmail(email string) (models.User, error) {
+	var u models.User
+	return u, nil
+}
+
+// UpdateUserPassword replaces the password of a user
+func (p *mockPostgres) UpdateUserPassword(id int, password string) error {
+	return nil
+}
+
 // AllReservations returns a slice of all reservations
 func (p *mockPostgres) AllReservations() ([]models.Reservation, error) {
 	var reservations []models.Reservation
@@ -128,6 +147,11 @@ func (p *mockPostgres) AllLaptops() ([]models.Laptop, error) {
 	return laptops, nil
 }
 
+// UpdateLaptopBuffers sets the turnaround days kept free before and after each reservation of a laptop
+func (p *mockPostgres) UpdateLaptopBuffers(id, before, after int) error {
+	return nil
+}
+
 // GetLaptopRestrictionsByDate returns restrictions for a laptop by date range
 func (p *mockPostgres) GetLaptopRestrictionsByDate(laptopID int, start, end time.Time) ([]models.LaptopRestriction, error) {
 
@@ -141,7 +165,254 @@ func (p *mockPostgres) InsertOneDayBlockByLaptopID(id int, startDate time.Time)
 	return nil
 }
 
+// InsertBlockByLaptopID inserts a block restriction for a laptop from startDate to endDate
+func (p *mockPostgres) InsertBlockByLaptopID(id int, startDate, endDate time.Time) error {
+	return nil
+}
+
 // DeleteBlockByLaptopID deletes a laptop restriction by id
 func (p *mockPostgres) DeleteBlockByID(id int) error {
 	return nil
 }
+
+// InsertWaitlistEntry puts a customer on the waitlist of a laptop and returns the id of the entry
+func (p *mockPostgres) InsertWaitlistEntry(e *models.WaitlistEntry) (int, error) {
+	// fail the insert for a non-existent laptop
+	if e.LaptopID > 2 {
+		return 0, errors.New("laptop does not exist")
+	}
+	return 1, nil
+}
+
+// GetWaitlistByLaptopID returns the waiting and notified entries of a laptop in the order they joined
+func (p *mockPostgres) GetWaitlistByLaptopID(laptopID int) ([]models.WaitlistEntry, error) {
+	var entries []models.WaitlistEntry
+
+	return entries, nil
+}
+
+// GetWaitlistEntryByToken returns the entry a hold link was sent for
+func (p *mockPostgres) GetWaitlistEntryByToken(token string) (models.WaitlistEntry, error) {
+	return models.WaitlistEntry{}, sql.ErrNoRows
+}
+
+// UpdateWaitlistEntry updates the status and hold of a waitlist entry
+func (p *mockPostgres) UpdateWaitlistEntry(e *models.WaitlistEntry) error {
+	return nil
+}
+
+// InsertHold holds a laptop from start to end until expiresAt and returns the id of the hold
+func (p *mockPostgres) InsertHold(laptopID int, start, end, expiresAt time.Time) (int, error) {
+	// laptop 1000 is never available, like in SearchAvailabilityByDatesByLaptopID
+	if laptopID == 1000 {
+		return 0, ErrNotAvailable
+	}
+	return 1, nil
+}
+
+// ConvertHold inserts res and turns the hold holdID into its reservation restriction
+func (p *mockPostgres) ConvertHold(holdID int, res *models.Reservation) (int, error) {
+	// if the first name is Test, then failed, like InsertReservation
+	if res.FirstName == "Test" {
+		return 0, errors.New("error")
+	}
+	// laptop 1000 is never available, like in InsertHold
+	if res.LaptopID == 1000 {
+		return 0, ErrNotAvailable
+	}
+	return 1, nil
+}
+
+// InsertReservationChecked inserts res with its reservation restriction if its dates are free
+func (p *mockPostgres) InsertReservationChecked(res *models.Reservation) (int, error) {
+	// if the first name is Test, then failed, like InsertReservation
+	if res.FirstName == "Test" {
+		return 0, errors.New("error")
+	}
+	// laptop 1000 is never available, like in InsertHold
+	if res.LaptopID == 1000 {
+		return 0, ErrNotAvailable
+	}
+	return 1, nil
+}
+
+// DeleteHold releases a hold
+func (p *mockPostgres) DeleteHold(id int) error {
+	return nil
+}
+
+// DeleteExpiredHolds deletes the holds that expired by now and returns how many there were
+func (p *mockPostgres) DeleteExpiredHolds(now time.Time) (int, error) {
+	return 0, nil
+}
+
+// GetBookingRules returns the booking rules of a laptop, there are none
+func (p *mockPostgres) GetBookingRules(laptopID int) (models.BookingRules, error) {
+	return models.BookingRules{}, sql.ErrNoRows
+}
+
+// SaveBookingRules inserts or replaces the booking rules of r.LaptopID
+func (p *mockPostgres) SaveBookingRules(r *models.BookingRules) error {
+	return nil
+}
+
+// DeleteBookingRules deletes the booking rules of a laptop
+func (p *mockPostgres) DeleteBookingRules(laptopID int) error {
+	return nil
+}
+
+// InsertBlackout inserts a blackout and returns its id
+func (p *mockPostgres) InsertBlackout(b *models.Blackout) (int, error) {
+	return 1, nil
+}
+
+// GetBlackoutsByDate returns the blackouts overlapping start to end, there are none
+func (p *mockPostgres) GetBlackoutsByDate(laptopID int, start, end time.Time) ([]models.Blackout, error) {
+	return nil, nil
+}
+
+// DeleteBlackout deletes a blackout by id
+func (p *mockPostgres) DeleteBlackout(id int) error {
+	return nil
+}
+
+// CountNewReservations returns the number of reservations that haven't been processed yet
+func (p *mockPostgres) CountNewReservations() (int, error) {
+	return 0, nil
+}
+
+// GetReservationsStartingOn returns the reservations picked up on date, there are none
+func (p *mockPostgres) GetReservationsStartingOn(date time.Time) ([]models.Reservation, error) {
+	return nil, nil
+}
+
+// GetReservationsEndingOn returns the reservations returned on date, there are none
+func (p *mockPostgres) GetReservationsEndingOn(date time.Time) ([]models.Reservation, error) {
+	return nil, nil
+}
+
+// GetLaptopUtilization returns how many days from start to end each laptop was rented, none of them was
+func (p *mockPostgres) GetLaptopUtilization(start, end time.Time) ([]models.LaptopUtilization, error) {
+	utilization := []models.LaptopUtilization{
+		{Laptop: models.Laptop{ID: 1, LaptopName: "Alienware M15 R2"}},
+		{Laptop: models.Laptop{ID: 2, LaptopName: "Macbook Pro 15 inch"}},
+	}
+	for i := range utilization {
+		utilization[i].PeriodDays = periodDays(start, end)
+		utilization[i].Start = start
+		utilization[i].End = end
+	}
+
+	return utilization, nil
+}
+
+// GetUpcomingBlocks returns the upcoming blocks, there are none
+func (p *mockPostgres) GetUpcomingBlocks(from time.Time, limit int) ([]models.LaptopRestriction, error) {
+	return nil, nil
+}
+
+// CountReservationsByStartDate returns the number of reservations picked up on each day, there are none
+func (p *mockPostgres) CountReservationsByStartDate(start, end time.Time) ([]models.DailyCount, error) {
+	return nil, nil
+}
+
+// GetReservationsByDate returns the reservations overlapping start to end, there are none
+func (p *mockPostgres) GetReservationsByDate(start, end time.Time) ([]models.Reservation, error) {
+	return nil, nil
+}
+
+// GetBlocksByDate returns the blocks overlapping start to end, there are none
+func (p *mockPostgres) GetBlocksByDate(start, end time.Time) ([]models.LaptopRestriction, error) {
+	return nil, nil
+}
+
+// GetCancellationsByDate returns the cancelled reservations overlapping start to end, there are none
+func (p *mockPostgres) GetCancellationsByDate(start, end time.Time) ([]models.Cancellation, error) {
+	return nil, nil
+}
+
+// RecordReminder records a reminder, every reminder is new
+func (p *mockPostgres) RecordReminder(reservationID int, kind string, sentAt time.Time) (bool, error) {
+	return true, nil
+}
+
+// GetRemindersByReservationID returns the reminders of a reservation, there are none
+func (p *mockPostgres) GetRemindersByReservationID(reservationID int) ([]models.Reminder, error) {
+	return nil, nil
+}
+
+// SearchReservations returns the page of reservations selected by f, there are none
+func (p *mockPostgres) SearchReservations(f models.ReservationFilter) ([]models.Reservation, int, error) {
+	return nil, 0, nil
+}
+
+// ProcessReservations marks reservations as processed in one transaction
+func (p *mockPostgres) ProcessReservations(ids []int) ([]models.BulkResult, error) {
+	return nil, nil
+}
+
+// CancelReservations deletes reservations and records their cancellations in one transaction
+func (p *mockPostgres) CancelReservations(ids []int) ([]models.BulkResult, error) {
+	return nil, nil
+}
+
+// RescheduleReservation saves the customer details of res and moves it and its laptop restriction to its laptop
+// and dates in one transaction
+func (p *mockPostgres) RescheduleReservation(res *models.Reservation) error {
+	return nil
+}
+
+// MoveBlock moves a block to the laptop and dates, it was on laptop 1
+func (p *mockPostgres) MoveBlock(id, laptopID int, start, end time.Time) (int, error) {
+	return 1, nil
+}
+
+// ChangeBlocks adds and removes blocks, none are removed from any laptop
+func (p *mockPostgres) ChangeBlocks(add, remove []models.LaptopRestriction) ([]int, error) {
+	return nil, nil
+}
+
+// AcquireJobLease takes the lease of a job, it is always free
+func (p *mockPostgres) AcquireJobLease(name, owner string, scheduledAt, until time.Time) (bool, error) {
+	return true, nil
+}
+
+// ReleaseJobLease ends the lease of a job
+func (p *mockPostgres) ReleaseJobLease(name, owner string) error {
+	return nil
+}
+
+// InsertJobRun records a run of a job
+func (p *mockPostgres) InsertJobRun(run *models.JobRun) (int, error) {
+	return 1, nil
+}
+
+// GetJobRuns returns the runs of a job, there are none
+func (p *mockPostgres) GetJobRuns(name string, limit int) ([]models.JobRun, error) {
+	return nil, nil
+}
+
+// DeleteJobRunsBefore deletes old job runs, there are none
+func (p *mockPostgres) DeleteJobRunsBefore(t time.Time) (int, error) {
+	return 0, nil
+}
+
+// QueueMail queues a mail
+func (p *mockPostgres) QueueMail(q *models.QueuedMail) (int, error) {
+	return 1, nil
+}
+
+// GetDueMail returns the mails due, there are none
+func (p *mockPostgres) GetDueMail(now time.Time, limit int) ([]models.QueuedMail, error) {
+	return nil, nil
+}
+
+// UpdateQueuedMail saves a queued mail
+func (p *mockPostgres) UpdateQueuedMail(q models.QueuedMail) error {
+	return nil
+}
+
+// DeleteQueuedMail removes a queued mail
+func (p *mockPostgres) DeleteQueuedMail(id int) error {
+	return nil
+}
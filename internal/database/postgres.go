@@ -2,15 +2,84 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"strconv"
 	"time"
 
 	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
 	"golang.org/x/crypto/bcrypt"
 )
 
-func (p *postgres) AllUsers() bool {
-	return true
+// AllUsers returns a slice of all users ordered by id
+func (p *postgres) AllUsers() ([]models.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var users []models.User
+
+	query := `SELECT id, first_name, last_name, email, password, access_level, created_at, updated_at
+			  FROM users ORDER BY id`
+
+	rows, err := p.DB.QueryContext(ctx, query)
+	if err != nil {
+		return users, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var u models.User
+		err := rows.Scan(
+			&u.ID,
+			&u.FirstName,
+			&u.LastName,
+			&u.Email,
+			&u.Password,
+			&u.AccessLevel,
+			&u.CreatedAt,
+			&u.UpdatedAt,
+		)
+		if err != nil {
+			return users, err
+		}
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return users, err
+	}
+
+	return users, nil
+}
+
+// InsertUser inserts a user with the bcrypt hash of password and returns its id
+func (p *postgres) InsertUser(u *models.User, password string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
+	if err != nil {
+		return 0, err
+	}
+
+	var newID int
+	query := `INSERT INTO users (first_name, last_name, email, password, access_level, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+
+	err = p.DB.QueryRowContext(ctx, query,
+		u.FirstName,
+		u.LastName,
+		u.Email,
+		string(hashedPassword),
+		u.AccessLevel,
+		time.Now(),
+		time.Now(),
+	).Scan(&newID)
+	if err != nil {
+		return 0, err
+	}
+
+	return newID, nil
 }
 
 // InsertReservation inserts a reservation into the database
@@ -21,8 +90,8 @@ func (p *postgres) InsertReservation(res *models.Reservation) (int, error) {
 	var newID int
 
 	query := `INSERT INTO reservations (first_name, last_name, email, phone,
-			  start_date, end_date, laptop_id, created_at, updated_at)
-			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			  start_date, end_date, laptop_id, locale, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 			  RETURNING id`
 
 	err := p.DB.QueryRowContext(ctx, query,
@@ -33,6 +102,7 @@ func (p *postgres) InsertReservation(res *models.Reservation) (int, error) {
 		res.StartDate,
 		res.EndDate,
 		res.LaptopID,
+		res.Locale,
 		time.Now(),
 		time.Now(),
 	).Scan(&newID)
@@ -72,11 +142,14 @@ func (p *postgres) SearchAvailabilityByDatesByLaptopID(start, end time.Time, lap
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := `SELECT Count(id) FROM laptop_restrictions
-		      WHERE laptop_id = $1 and $2 <= end_date and $3 >= start_date`
+	query := `SELECT Count(lr.id) FROM laptop_restrictions lr
+		      JOIN laptops l ON l.id = lr.laptop_id
+		      WHERE lr.laptop_id = $1 and $2 <= lr.end_date + ` + turnaroundSQL + `
+		      and $3 >= lr.start_date - ` + turnaroundSQL + `
+		      AND (lr.expires_at IS NULL OR lr.expires_at > $4)`
 
 	var numRows int
-	row := p.DB.QueryRowContext(ctx, query, laptopID, start, end)
+	row := p.DB.QueryRowContext(ctx, query, laptopID, start, end, time.Now())
 	err := row.Scan(&numRows)
 	if err != nil {
 		return false, err
@@ -96,21 +169,27 @@ func (p *postgres) SearchAvailabilityForAllLaptops(start, end time.Time) ([]mode
 
 	var laptops []models.Laptop
 
-	query := `SELECT l.id, l.laptop_name
+	query := `SELECT l.id, l.laptop_name, l.buffer_days_before, l.buffer_days_after
 			  FROM laptops l
-			  WHERE l.id not in (
-				  SELECT lr.laptop_id FROM laptop_restrictions lr
-				  WHERE $1 <= lr.end_date AND $2 >= lr.start_date)`
-	rows, err := p.DB.QueryContext(ctx, query, start, end)
+			  WHERE NOT EXISTS (
+				  SELECT lr.id FROM laptop_restrictions lr
+				  WHERE lr.laptop_id = l.id
+				  AND $1 <= lr.end_date + ` + turnaroundSQL + `
+				  AND $2 >= lr.start_date - ` + turnaroundSQL + `
+				  AND (lr.expires_at IS NULL OR lr.expires_at > $3))`
+	rows, err := p.DB.QueryContext(ctx, query, start, end, time.Now())
 	if err != nil {
 		return laptops, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var laptop models.Laptop
 		err = rows.Scan(
 			&laptop.ID,
 			&laptop.LaptopName,
+			&laptop.BufferDaysBefore,
+			&laptop.BufferDaysAfter,
 		)
 		if err != nil {
 			return laptops, err
@@ -133,12 +212,14 @@ func (p *postgres) GetLaptopByID(id int) (models.Laptop, error) {
 
 	var laptop models.Laptop
 
-	query := `SELECT id, laptop_name, created_at, updated_at FROM laptops
+	query := `SELECT id, laptop_name, buffer_days_before, buffer_days_after, created_at, updated_at FROM laptops
 			 WHERE id = $1`
 	row := p.DB.QueryRowContext(ctx, query, id)
 	err := row.Scan(
 		&laptop.ID,
 		&laptop.LaptopName,
+		&laptop.BufferDaysBefore,
+		&laptop.BufferDaysAfter,
 		&laptop.CreatedAt,
 		&laptop.UpdatedAt,
 	)
@@ -182,7 +263,8 @@ func (p *postgres) UpdateUser(u *models.User) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := `UPDATE users SET first_name = $1, last_name = $2, email = $3, access_level = $4, updated_at = $5`
+	query := `UPDATE users SET first_name = $1, last_name = $2, email = $3, access_level = $4, updated_at = $5
+			  WHERE id = $6`
 
 	_, err := p.DB.ExecContext(ctx, query,
 		u.FirstName,
@@ -190,6 +272,7 @@ func (p *postgres) UpdateUser(u *models.User) error {
 		u.Email,
 		u.AccessLevel,
 		time.Now(),
+		u.ID,
 	)
 	if err != nil {
 		return err
@@ -198,6 +281,53 @@ func (p *postgres) UpdateUser(u *models.User) error {
 	return nil
 }
 
+// GetUserByEmail returns a user by email
+func (p *postgres) GetUserByEmail(email string) (models.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT id, first_name, last_name, email, password, access_level, created_at, updated_at
+			  FROM users WHERE email = $1`
+	row := p.DB.QueryRowContext(ctx, query, email)
+
+	var u models.User
+	err := row.Scan(
+		&u.ID,
+		&u.FirstName,
+		&u.LastName,
+		&u.Email,
+		&u.Password,
+		&u.AccessLevel,
+		&u.CreatedAt,
+		&u.UpdatedAt,
+	)
+	if err != nil {
+		return u, err
+	}
+
+	return u, nil
+}
+
+// UpdateUserPassword replaces the password of a user with the bcrypt hash of password
+func (p *postgres) UpdateUserPassword(id int, password string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE users SET password = $1, updated_at = $2 WHERE id = $3`
+
+	result, err := p.DB.ExecContext(ctx, query, string(hashedPassword), time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	return expectOneRow(result)
+}
+
 // Authenticate authenticates a user
 func (p *postgres) Authenticate(email, password string) (int, string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -229,7 +359,7 @@ func (p *postgres) AllReservations() ([]models.Reservation, error) {
 	var reservations []models.Reservation
 
 	query := `SELECT r.id, r.first_name, r.last_name, r.email, r.phone,
-			  r.start_date, r.end_date, r.laptop_id, r.created_at, r.updated_at, r.processed,
+			  r.start_date, r.end_date, r.laptop_id, r.created_at, r.updated_at, r.processed, r.locale,
 			  lp.id, lp.laptop_name
 			  FROM reservations r
 		   	  LEFT JOIN laptops lp ON (r.laptop_id = lp.id)
@@ -254,6 +384,7 @@ func (p *postgres) AllReservations() ([]models.Reservation, error) {
 			&r.CreatedAt,
 			&r.UpdatedAt,
 			&r.Processed,
+			&r.Locale,
 			&r.Laptop.ID,
 			&r.Laptop.LaptopName,
 		)
@@ -279,7 +410,7 @@ func (p *postgres) AllNewReservations() ([]models.Reservation, error) {
 	var reservations []models.Reservation
 
 	query := `SELECT r.id, r.first_name, r.last_name, r.email, r.phone,
-			  r.start_date, r.end_date, r.laptop_id, r.created_at, r.updated_at, r.processed,
+			  r.start_date, r.end_date, r.laptop_id, r.created_at, r.updated_at, r.processed, r.locale,
 			  lp.id, lp.laptop_name
 			  FROM reservations r
 		   	  LEFT JOIN laptops lp ON (r.laptop_id = lp.id)
@@ -305,6 +436,7 @@ func (p *postgres) AllNewReservations() ([]models.Reservation, error) {
 			&r.CreatedAt,
 			&r.UpdatedAt,
 			&r.Processed,
+			&r.Locale,
 			&r.Laptop.ID,
 			&r.Laptop.LaptopName,
 		)
@@ -330,7 +462,7 @@ func (p *postgres) GetReservatioByID(id int) (models.Reservation, error) {
 	var res models.Reservation
 
 	query := `SELECT r.id, r.first_name, r.last_name, r.email, r.phone,
-			  r.start_date, r.end_date, r.laptop_id, r.created_at, r.updated_at, r.processed,
+			  r.start_date, r.end_date, r.laptop_id, r.created_at, r.updated_at, r.processed, r.locale,
 			  lp.id, lp.laptop_name
 			  FROM reservations r
 			  LEFT JOIN laptops lp ON (r.laptop_id = lp.id)
@@ -348,6 +480,7 @@ func (p *postgres) GetReservatioByID(id int) (models.Reservation, error) {
 		&res.CreatedAt,
 		&res.UpdatedAt,
 		&res.Processed,
+		&res.Locale,
 		&res.Laptop.ID,
 		&res.Laptop.LaptopName,
 	)
@@ -382,13 +515,19 @@ func (p *postgres) UpdateReservation(res *models.Reservation) error {
 	return nil
 }
 
-// DeleteReservation deletes one reservation by id
+// postgresCancelReservation deletes a reservation and records its cancellation
+const postgresCancelReservation = `WITH deleted AS (DELETE FROM reservations where id = $1
+			  RETURNING id, laptop_id, start_date, end_date, created_at)
+			  INSERT INTO reservation_cancellations (reservation_id, laptop_id, start_date, end_date, booked_at,
+			  created_at, updated_at)
+			  SELECT id, laptop_id, start_date, end_date, created_at, $2, $2 FROM deleted`
+
+// DeleteReservation deletes one reservation by id and records it as cancelled
 func (p *postgres) DeleteReservation(id int) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := `DELETE FROM reservations where id = $1`
-	_, err := p.DB.ExecContext(ctx, query, id)
+	_, err := p.DB.ExecContext(ctx, postgresCancelReservation, id, time.Now())
 	if err != nil {
 		return err
 	}
@@ -419,7 +558,7 @@ func (p *postgres) AllLaptops() ([]models.Laptop, error) {
 
 	var laptops []models.Laptop
 
-	query := `SELECT id, laptop_name, created_at, updated_at
+	query := `SELECT id, laptop_name, buffer_days_before, buffer_days_after, created_at, updated_at
 			  FROM laptops order by laptop_name`
 	rows, err := p.DB.QueryContext(ctx, query)
 	if err != nil {
@@ -432,6 +571,8 @@ func (p *postgres) AllLaptops() ([]models.Laptop, error) {
 		err := rows.Scan(
 			&laptop.ID,
 			&laptop.LaptopName,
+			&laptop.BufferDaysBefore,
+			&laptop.BufferDaysAfter,
 			&laptop.CreatedAt,
 			&laptop.UpdatedAt,
 		)
@@ -448,6 +589,21 @@ func (p *postgres) AllLaptops() ([]models.Laptop, error) {
 	return laptops, nil
 }
 
+// UpdateLaptopBuffers sets the turnaround days kept free before and after each reservation of a laptop
+func (p *postgres) UpdateLaptopBuffers(id, before, after int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE laptops SET buffer_days_before = $1, buffer_days_after = $2, updated_at = $3
+			  WHERE id = $4`
+	result, err := p.DB.ExecContext(ctx, query, before, after, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	return expectOneRow(result)
+}
+
 // GetLaptopRestrictionsByDate returns restrictions for a laptop by date range
 func (p *postgres) GetLaptopRestrictionsByDate(laptopID int, start, end time.Time) ([]models.LaptopRestriction, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -455,11 +611,13 @@ func (p *postgres) GetLaptopRestrictionsByDate(laptopID int, start, end time.Tim
 
 	var restrictions []models.LaptopRestriction
 
-	query := `SELECT id, COALESCE(reservation_id, 0) reservation_id, restriction_id, laptop_id, start_date, end_date
+	query := `SELECT id, COALESCE(reservation_id, 0) reservation_id, restriction_id, laptop_id, start_date, end_date, expires_at,
+			  version
 			  FROM laptop_restrictions 
-			  WHERE $1 <= end_date AND $2 >= start_date AND laptop_id = $3`
+			  WHERE $1 <= end_date AND $2 >= start_date AND laptop_id = $3
+			  AND (expires_at IS NULL OR expires_at > $4)`
 
-	rows, err := p.DB.QueryContext(ctx, query, start, end, laptopID)
+	rows, err := p.DB.QueryContext(ctx, query, start, end, laptopID, time.Now())
 	if err != nil {
 		return restrictions, nil
 	}
@@ -467,6 +625,7 @@ func (p *postgres) GetLaptopRestrictionsByDate(laptopID int, start, end time.Tim
 
 	for rows.Next() {
 		var l models.LaptopRestriction
+		var expiresAt sql.NullTime
 		err := rows.Scan(
 			&l.ID,
 			&l.ReservationID,
@@ -474,10 +633,13 @@ func (p *postgres) GetLaptopRestrictionsByDate(laptopID int, start, end time.Tim
 			&l.LaptopID,
 			&l.StartDate,
 			&l.EndDate,
+			&expiresAt,
+			&l.Version,
 		)
 		if err != nil {
 			return restrictions, err
 		}
+		l.ExpiresAt = expiresAt.Time
 		restrictions = append(restrictions, l)
 	}
 
@@ -504,6 +666,22 @@ func (p *postgres) InsertOneDayBlockByLaptopID(id int, startDate time.Time) erro
 	return nil
 }
 
+// InsertBlockByLaptopID inserts a block restriction for a laptop from startDate to endDate
+func (p *postgres) InsertBlockByLaptopID(id int, startDate, endDate time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO laptop_restrictions (start_date, end_date, laptop_id, restriction_id, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6)`
+
+	_, err := p.DB.ExecContext(ctx, query, startDate, endDate, id, 2, time.Now(), time.Now())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // DeleteBlockByLaptopID deletes a laptop restriction by id
 func (p *postgres) DeleteBlockByID(id int) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -518,3 +696,1063 @@ func (p *postgres) DeleteBlockByID(id int) error {
 	
 	return nil
 }
+
+// InsertWaitlistEntry puts a customer on the waitlist of a laptop and returns the id of the entry
+func (p *postgres) InsertWaitlistEntry(e *models.WaitlistEntry) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var newID int
+
+	query := `INSERT INTO waitlist_entries (first_name, last_name, email, laptop_id,
+			  start_date, end_date, locale, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			  RETURNING id`
+
+	err := p.DB.QueryRowContext(ctx, query,
+		e.FirstName,
+		e.LastName,
+		e.Email,
+		e.LaptopID,
+		e.StartDate,
+		e.EndDate,
+		e.Locale,
+		time.Now(),
+		time.Now(),
+	).Scan(&newID)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return newID, nil
+}
+
+// GetWaitlistByLaptopID returns the waiting and notified entries of a laptop in the order they joined
+func (p *postgres) GetWaitlistByLaptopID(laptopID int) ([]models.WaitlistEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var entries []models.WaitlistEntry
+
+	query := `SELECT w.id, w.first_name, w.last_name, w.email, w.laptop_id, w.start_date, w.end_date,
+			  w.status, w.token, w.hold_until, w.hold_id, w.locale, w.created_at, w.updated_at, lp.id, lp.laptop_name
+			  FROM waitlist_entries w
+			  LEFT JOIN laptops lp ON (w.laptop_id = lp.id)
+			  WHERE w.laptop_id = $1 AND w.status IN ($2, $3)
+			  ORDER BY w.id`
+
+	rows, err := p.DB.QueryContext(ctx, query, laptopID, models.WaitlistWaiting, models.WaitlistNotified)
+	if err != nil {
+		return entries, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := scanWaitlistEntry(rows)
+		if err != nil {
+			return entries, err
+		}
+		entries = append(entries, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return entries, err
+	}
+
+	return entries, nil
+}
+
+// GetWaitlistEntryByToken returns the entry a hold link was sent for
+func (p *postgres) GetWaitlistEntryByToken(token string) (models.WaitlistEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT w.id, w.first_name, w.last_name, w.email, w.laptop_id, w.start_date, w.end_date,
+			  w.status, w.token, w.hold_until, w.hold_id, w.locale, w.created_at, w.updated_at, lp.id, lp.laptop_name
+			  FROM waitlist_entries w
+			  LEFT JOIN laptops lp ON (w.laptop_id = lp.id)
+			  WHERE w.token = $1 AND w.token <> ''`
+
+	return scanWaitlistEntry(p.DB.QueryRowContext(ctx, query, token))
+}
+
+// UpdateWaitlistEntry updates the status and hold of a waitlist entry
+func (p *postgres) UpdateWaitlistEntry(e *models.WaitlistEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE waitlist_entries SET status = $1, token = $2, hold_until = $3, hold_id = $4, updated_at = $5
+			  WHERE id = $6`
+
+	result, err := p.DB.ExecContext(ctx, query, e.Status, e.Token, nullTime(e.HoldUntil), e.HoldID, time.Now(), e.ID)
+	if err != nil {
+		return err
+	}
+
+	return expectOneRow(result)
+}
+
+// InsertHold holds a laptop from start to end until expiresAt and returns the id of the hold,
+// ErrNotAvailable if the dates overlap another restriction of the laptop
+func (p *postgres) InsertHold(laptopID int, start, end, expiresAt time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := p.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	err = p.lockAvailability(ctx, tx, laptopID, start, end, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	var newID int
+
+	query := `INSERT INTO laptop_restrictions (start_date, end_date, laptop_id, restriction_id, expires_at,
+			  created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7)
+			  RETURNING id`
+	err = tx.QueryRowContext(ctx, query, start, end, laptopID, models.RestrictionHold, expiresAt,
+		time.Now(), time.Now()).Scan(&newID)
+	if err != nil {
+		return 0, err
+	}
+
+	return newID, tx.Commit()
+}
+
+// ConvertHold inserts res and turns the hold holdID into its reservation restriction. When the hold has
+// expired res is booked directly if its dates are still free, otherwise ErrNotAvailable is returned.
+func (p *postgres) ConvertHold(holdID int, res *models.Reservation) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := p.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var newID int
+
+	query := `INSERT INTO reservations (first_name, last_name, email, phone,
+			  start_date, end_date, laptop_id, locale, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			  RETURNING id`
+	err = tx.QueryRowContext(ctx, query,
+		res.FirstName,
+		res.LastName,
+		res.Email,
+		res.Phone,
+		res.StartDate,
+		res.EndDate,
+		res.LaptopID,
+		res.Locale,
+		time.Now(),
+		time.Now(),
+	).Scan(&newID)
+	if err != nil {
+		return 0, err
+	}
+
+	query = `UPDATE laptop_restrictions SET restriction_id = $1, reservation_id = $2, expires_at = NULL, updated_at = $3
+			 WHERE id = $4 AND restriction_id = $5 AND expires_at > $3
+			 AND laptop_id = $6 AND start_date = $7 AND end_date = $8`
+	result, err := tx.ExecContext(ctx, query, models.RestrictionReservation, newID, time.Now(),
+		holdID, models.RestrictionHold, res.LaptopID, res.StartDate, res.EndDate)
+	if err != nil {
+		return 0, err
+	}
+
+	if expectOneRow(result) != nil {
+		// the hold is gone, book the dates if nobody took them in the meantime
+		_, err = tx.ExecContext(ctx, `DELETE FROM laptop_restrictions WHERE id = $1 AND restriction_id = $2`,
+			holdID, models.RestrictionHold)
+		if err != nil {
+			return 0, err
+		}
+		err = p.lockAvailability(ctx, tx, res.LaptopID, res.StartDate, res.EndDate, 0)
+		if err != nil {
+			return 0, err
+		}
+
+		query = `INSERT INTO laptop_restrictions (start_date, end_date, laptop_id, reservation_id,
+				 created_at, updated_at, restriction_id)
+				 VALUES ($1, $2, $3, $4, $5, $6, $7)`
+		_, err = tx.ExecContext(ctx, query, res.StartDate, res.EndDate, res.LaptopID, newID,
+			time.Now(), time.Now(), models.RestrictionReservation)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return newID, tx.Commit()
+}
+
+// InsertReservationChecked inserts res with its reservation restriction in one transaction and returns its id,
+// ErrNotAvailable if its dates overlap another restriction of the laptop
+func (p *postgres) InsertReservationChecked(res *models.Reservation) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := p.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	err = p.lockAvailability(ctx, tx, res.LaptopID, res.StartDate, res.EndDate, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	var newID int
+
+	query := `INSERT INTO reservations (first_name, last_name, email, phone,
+			  start_date, end_date, laptop_id, locale, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			  RETURNING id`
+	err = tx.QueryRowContext(ctx, query, res.FirstName, res.LastName, res.Email, res.Phone,
+		res.StartDate, res.EndDate, res.LaptopID, res.Locale, time.Now(), time.Now()).Scan(&newID)
+	if err != nil {
+		return 0, err
+	}
+
+	query = `INSERT INTO laptop_restrictions (start_date, end_date, laptop_id, reservation_id,
+			 created_at, updated_at, restriction_id)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err = tx.ExecContext(ctx, query, res.StartDate, res.EndDate, res.LaptopID, newID,
+		time.Now(), time.Now(), models.RestrictionReservation)
+	if err != nil {
+		return 0, err
+	}
+
+	return newID, tx.Commit()
+}
+
+// lockAvailability locks the laptop for the rest of tx, so that concurrent holds and bookings of it
+// wait for each other, and returns ErrNotAvailable if start to end overlaps a restriction other than
+// the restriction except, 0 for none
+func (p *postgres) lockAvailability(ctx context.Context, tx *sql.Tx, laptopID int, start, end time.Time, except int) error {
+	var id int
+	err := tx.QueryRowContext(ctx, `SELECT id FROM laptops WHERE id = $1 FOR UPDATE`, laptopID).Scan(&id)
+	if err != nil {
+		return err
+	}
+
+	query := `SELECT Count(lr.id) FROM laptop_restrictions lr
+			  JOIN laptops l ON l.id = lr.laptop_id
+			  WHERE lr.laptop_id = $1 and $2 <= lr.end_date + ` + turnaroundSQL + `
+			  and $3 >= lr.start_date - ` + turnaroundSQL + `
+			  AND (lr.expires_at IS NULL OR lr.expires_at > $4)
+			  AND lr.id <> $5`
+
+	var numRows int
+	err = tx.QueryRowContext(ctx, query, laptopID, start, end, time.Now(), except).Scan(&numRows)
+	if err != nil {
+		return err
+	}
+	if numRows > 0 {
+		return ErrNotAvailable
+	}
+
+	return nil
+}
+
+// lockBlockDays locks the laptop like lockAvailability for a block from start to end, blocks don't keep a
+// turnaround free so only the blocked days themselves must not overlap a restriction other than except
+func (p *postgres) lockBlockDays(ctx context.Context, tx *sql.Tx, laptopID int, start, end time.Time, except int) error {
+	var id int
+	err := tx.QueryRowContext(ctx, `SELECT id FROM laptops WHERE id = $1 FOR UPDATE`, laptopID).Scan(&id)
+	if err != nil {
+		return err
+	}
+
+	query := `SELECT Count(id) FROM laptop_restrictions
+			  WHERE laptop_id = $1 AND $2 <= end_date AND $3 >= start_date
+			  AND (expires_at IS NULL OR expires_at > $4)
+			  AND id <> $5`
+
+	var numRows int
+	err = tx.QueryRowContext(ctx, query, laptopID, start, end, time.Now(), except).Scan(&numRows)
+	if err != nil {
+		return err
+	}
+	if numRows > 0 {
+		return ErrNotAvailable
+	}
+
+	return nil
+}
+
+// DeleteHold releases a hold
+func (p *postgres) DeleteHold(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM laptop_restrictions WHERE id = $1 AND restriction_id = $2`
+
+	_, err := p.DB.ExecContext(ctx, query, id, models.RestrictionHold)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteExpiredHolds deletes the holds that expired by now and returns how many there were
+func (p *postgres) DeleteExpiredHolds(now time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM laptop_restrictions WHERE restriction_id = $1 AND expires_at <= $2`
+
+	result, err := p.DB.ExecContext(ctx, query, models.RestrictionHold, now)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// GetBookingRules returns the booking rules of a laptop, or the global ones for laptop id 0
+func (p *postgres) GetBookingRules(laptopID int) (models.BookingRules, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT id, laptop_id, min_days, max_days, max_advance_days, pickup_weekdays, created_at, updated_at
+			  FROM booking_rules WHERE laptop_id = $1`
+
+	return scanBookingRules(p.DB.QueryRowContext(ctx, query, laptopID))
+}
+
+// SaveBookingRules inserts or replaces the booking rules of r.LaptopID
+func (p *postgres) SaveBookingRules(r *models.BookingRules) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO booking_rules (laptop_id, min_days, max_days, max_advance_days, pickup_weekdays,
+			  created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $6)
+			  ON CONFLICT (laptop_id) DO UPDATE SET min_days = excluded.min_days, max_days = excluded.max_days,
+			  max_advance_days = excluded.max_advance_days, pickup_weekdays = excluded.pickup_weekdays,
+			  updated_at = excluded.updated_at
+			  RETURNING id`
+
+	return p.DB.QueryRowContext(ctx, query,
+		r.LaptopID,
+		r.MinDays,
+		r.MaxDays,
+		r.MaxAdvanceDays,
+		formatWeekdays(r.PickupWeekdays),
+		time.Now(),
+	).Scan(&r.ID)
+}
+
+// DeleteBookingRules deletes the booking rules of a laptop, or the global ones for laptop id 0
+func (p *postgres) DeleteBookingRules(laptopID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := p.DB.ExecContext(ctx, `DELETE FROM booking_rules WHERE laptop_id = $1`, laptopID)
+	if err != nil {
+		return err
+	}
+
+	return expectOneRow(result)
+}
+
+// InsertBlackout inserts a blackout and returns its id
+func (p *postgres) InsertBlackout(b *models.Blackout) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var newID int
+
+	query := `INSERT INTO blackouts (laptop_id, start_date, end_date, reason, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6)
+			  RETURNING id`
+
+	err := p.DB.QueryRowContext(ctx, query,
+		b.LaptopID,
+		b.StartDate,
+		b.EndDate,
+		b.Reason,
+		time.Now(),
+		time.Now(),
+	).Scan(&newID)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return newID, nil
+}
+
+// GetBlackoutsByDate returns the blackouts of a laptop and of every laptop overlapping start to end,
+// only the ones of every laptop for laptop id 0
+func (p *postgres) GetBlackoutsByDate(laptopID int, start, end time.Time) ([]models.Blackout, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var blackouts []models.Blackout
+
+	query := `SELECT id, laptop_id, start_date, end_date, reason, created_at, updated_at
+			  FROM blackouts
+			  WHERE (laptop_id = 0 OR laptop_id = $1) AND $2 <= end_date AND $3 >= start_date
+			  ORDER BY start_date, id`
+
+	rows, err := p.DB.QueryContext(ctx, query, laptopID, start, end)
+	if err != nil {
+		return blackouts, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		b, err := scanBlackout(rows)
+		if err != nil {
+			return blackouts, err
+		}
+		blackouts = append(blackouts, b)
+	}
+
+	if err = rows.Err(); err != nil {
+		return blackouts, err
+	}
+
+	return blackouts, nil
+}
+
+// DeleteBlackout deletes a blackout by id
+func (p *postgres) DeleteBlackout(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := p.DB.ExecContext(ctx, `DELETE FROM blackouts WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	return expectOneRow(result)
+}
+
+// CountNewReservations returns the number of reservations that haven't been processed yet
+func (p *postgres) CountNewReservations() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var n int
+	err := p.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM reservations WHERE processed = 0`).Scan(&n)
+
+	return n, err
+}
+
+// GetReservationsStartingOn returns the reservations picked up on date
+func (p *postgres) GetReservationsStartingOn(date time.Time) ([]models.Reservation, error) {
+	query := `SELECT r.id, r.first_name, r.last_name, r.email, r.phone,
+			  r.start_date, r.end_date, r.laptop_id, r.created_at, r.updated_at, r.processed, r.locale,
+			  lp.id, lp.laptop_name
+			  FROM reservations r
+			  LEFT JOIN laptops lp ON (r.laptop_id = lp.id)
+			  WHERE r.start_date = $1
+			  ORDER BY lp.laptop_name, r.id`
+
+	return p.queryReservations(query, date)
+}
+
+// GetReservationsEndingOn returns the reservations returned on date
+func (p *postgres) GetReservationsEndingOn(date time.Time) ([]models.Reservation, error) {
+	query := `SELECT r.id, r.first_name, r.last_name, r.email, r.phone,
+			  r.start_date, r.end_date, r.laptop_id, r.created_at, r.updated_at, r.processed, r.locale,
+			  lp.id, lp.laptop_name
+			  FROM reservations r
+			  LEFT JOIN laptops lp ON (r.laptop_id = lp.id)
+			  WHERE r.end_date = $1
+			  ORDER BY lp.laptop_name, r.id`
+
+	return p.queryReservations(query, date)
+}
+
+// queryReservations runs a reservation query joined with laptops and scans the result
+func (p *postgres) queryReservations(query string, args ...interface{}) ([]models.Reservation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var reservations []models.Reservation
+
+	rows, err := p.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return reservations, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r models.Reservation
+		err := rows.Scan(
+			&r.ID,
+			&r.FirstName,
+			&r.LastName,
+			&r.Email,
+			&r.Phone,
+			&r.StartDate,
+			&r.EndDate,
+			&r.LaptopID,
+			&r.CreatedAt,
+			&r.UpdatedAt,
+			&r.Processed,
+			&r.Locale,
+			&r.Laptop.ID,
+			&r.Laptop.LaptopName,
+		)
+		if err != nil {
+			return reservations, err
+		}
+		reservations = append(reservations, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return reservations, err
+	}
+
+	return reservations, nil
+}
+
+// GetLaptopUtilization returns how many days from start to end each laptop was rented, ordered by laptop name
+func (p *postgres) GetLaptopUtilization(start, end time.Time) ([]models.LaptopUtilization, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var utilization []models.LaptopUtilization
+
+	query := `SELECT lp.id, lp.laptop_name,
+			  COALESCE(SUM(LEAST(r.end_date, $2::date) - GREATEST(r.start_date, $1::date) + 1), 0)
+			  FROM laptops lp
+			  LEFT JOIN reservations r ON (r.laptop_id = lp.id AND r.start_date <= $2::date AND r.end_date >= $1::date)
+			  GROUP BY lp.id, lp.laptop_name
+			  ORDER BY lp.laptop_name, lp.id`
+
+	rows, err := p.DB.QueryContext(ctx, query, start, end)
+	if err != nil {
+		return utilization, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		u, err := scanUtilization(rows, start, end)
+		if err != nil {
+			return utilization, err
+		}
+		utilization = append(utilization, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return utilization, err
+	}
+
+	return utilization, nil
+}
+
+// GetUpcomingBlocks returns at most limit blocks of every laptop that don't end before from, the earliest first
+func (p *postgres) GetUpcomingBlocks(from time.Time, limit int) ([]models.LaptopRestriction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var blocks []models.LaptopRestriction
+
+	query := `SELECT lr.id, lr.laptop_id, lr.restriction_id, lr.start_date, lr.end_date, lp.laptop_name
+			  FROM laptop_restrictions lr
+			  LEFT JOIN laptops lp ON (lr.laptop_id = lp.id)
+			  WHERE lr.restriction_id = $1 AND lr.end_date >= $2
+			  ORDER BY lr.start_date, lr.id
+			  LIMIT $3`
+
+	rows, err := p.DB.QueryContext(ctx, query, models.RestrictionBlock, from, limit)
+	if err != nil {
+		return blocks, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		lr, err := scanBlock(rows)
+		if err != nil {
+			return blocks, err
+		}
+		blocks = append(blocks, lr)
+	}
+
+	if err = rows.Err(); err != nil {
+		return blocks, err
+	}
+
+	return blocks, nil
+}
+
+// CountReservationsByStartDate returns the number of reservations picked up on each day from start to end,
+// days without any are left out
+func (p *postgres) CountReservationsByStartDate(start, end time.Time) ([]models.DailyCount, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var counts []models.DailyCount
+
+	query := `SELECT start_date, COUNT(*)
+			  FROM reservations
+			  WHERE start_date >= $1 AND start_date <= $2
+			  GROUP BY start_date
+			  ORDER BY start_date`
+
+	rows, err := p.DB.QueryContext(ctx, query, start, end)
+	if err != nil {
+		return counts, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c models.DailyCount
+		if err := rows.Scan(&c.Date, &c.Count); err != nil {
+			return counts, err
+		}
+		counts = append(counts, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return counts, err
+	}
+
+	return counts, nil
+}
+
+// GetReservationsByDate returns the reservations of every laptop overlapping start to end
+func (p *postgres) GetReservationsByDate(start, end time.Time) ([]models.Reservation, error) {
+	query := `SELECT r.id, r.first_name, r.last_name, r.email, r.phone,
+			  r.start_date, r.end_date, r.laptop_id, r.created_at, r.updated_at, r.processed, r.locale,
+			  lp.id, lp.laptop_name
+			  FROM reservations r
+			  LEFT JOIN laptops lp ON (r.laptop_id = lp.id)
+			  WHERE $1 <= r.end_date AND $2 >= r.start_date
+			  ORDER BY r.start_date, r.end_date, r.id`
+
+	return p.queryReservations(query, start, end)
+}
+
+// GetBlocksByDate returns the blocks of every laptop overlapping start to end, joined with the laptop name
+func (p *postgres) GetBlocksByDate(start, end time.Time) ([]models.LaptopRestriction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var blocks []models.LaptopRestriction
+
+	query := `SELECT lr.id, lr.laptop_id, lr.restriction_id, lr.start_date, lr.end_date, lp.laptop_name
+			  FROM laptop_restrictions lr
+			  LEFT JOIN laptops lp ON (lr.laptop_id = lp.id)
+			  WHERE lr.restriction_id = $1 AND $2 <= lr.end_date AND $3 >= lr.start_date
+			  ORDER BY lr.start_date, lr.id`
+
+	rows, err := p.DB.QueryContext(ctx, query, models.RestrictionBlock, start, end)
+	if err != nil {
+		return blocks, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		lr, err := scanBlock(rows)
+		if err != nil {
+			return blocks, err
+		}
+		blocks = append(blocks, lr)
+	}
+
+	if err = rows.Err(); err != nil {
+		return blocks, err
+	}
+
+	return blocks, nil
+}
+
+// GetCancellationsByDate returns the cancelled reservations of every laptop overlapping start to end
+func (p *postgres) GetCancellationsByDate(start, end time.Time) ([]models.Cancellation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var cancellations []models.Cancellation
+
+	query := `SELECT id, reservation_id, laptop_id, start_date, end_date, booked_at, created_at, updated_at
+			  FROM reservation_cancellations
+			  WHERE $1 <= end_date AND $2 >= start_date
+			  ORDER BY start_date, id`
+
+	rows, err := p.DB.QueryContext(ctx, query, start, end)
+	if err != nil {
+		return cancellations, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		c, err := scanCancellation(rows)
+		if err != nil {
+			return cancellations, err
+		}
+		cancellations = append(cancellations, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return cancellations, err
+	}
+
+	return cancellations, nil
+}
+
+// SearchReservations returns the page of reservations selected by f joined with their laptop,
+// and how many reservations f selects on all pages
+func (p *postgres) SearchReservations(f models.ReservationFilter) ([]models.Reservation, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var args []interface{}
+	bind := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	where, order := reservationFilterSQL(f, bind, func(t time.Time) interface{} { return t }, ilikeSearch)
+
+	var total int
+	query := `SELECT COUNT(*) FROM reservations r LEFT JOIN laptops lp ON (r.laptop_id = lp.id) WHERE ` + where
+	err := p.DB.QueryRowContext(ctx, query, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query = `SELECT r.id, r.first_name, r.last_name, r.email, r.phone,
+			 r.start_date, r.end_date, r.laptop_id, r.created_at, r.updated_at, r.processed, r.locale,
+			 lp.id, lp.laptop_name
+			 FROM reservations r
+			 LEFT JOIN laptops lp ON (r.laptop_id = lp.id)
+			 WHERE ` + where + ` ORDER BY ` + order
+	if f.Limit > 0 {
+		query += " LIMIT " + bind(f.Limit) + " OFFSET " + bind(f.Offset)
+	}
+
+	reservations, err := p.queryReservations(query, args...)
+	return reservations, total, err
+}
+
+// postgresBulkReservation locks one reservation of a bulk action
+const postgresBulkReservation = `SELECT r.id, r.first_name, r.last_name, r.email, r.phone,
+			  r.start_date, r.end_date, r.laptop_id, r.created_at, r.updated_at, r.processed, r.locale,
+			  lp.id, lp.laptop_name
+			  FROM reservations r
+			  LEFT JOIN laptops lp ON (r.laptop_id = lp.id)
+			  WHERE r.id = $1
+			  FOR UPDATE OF r`
+
+// ProcessReservations marks reservations as processed in one transaction
+func (p *postgres) ProcessReservations(ids []int) ([]models.BulkResult, error) {
+	return bulkReservations(p.DB, postgresBulkReservation, ids, func(ctx context.Context, tx *sql.Tx, id int) error {
+		_, err := tx.ExecContext(ctx, `UPDATE reservations SET processed = 1, updated_at = $2 WHERE id = $1`, id, time.Now())
+		return err
+	})
+}
+
+// CancelReservations deletes reservations and records their cancellations in one transaction
+func (p *postgres) CancelReservations(ids []int) ([]models.BulkResult, error) {
+	return bulkReservations(p.DB, postgresBulkReservation, ids, func(ctx context.Context, tx *sql.Tx, id int) error {
+		_, err := tx.ExecContext(ctx, postgresCancelReservation, id, time.Now())
+		return err
+	})
+}
+
+// RescheduleReservation saves the customer details of res and moves it and its laptop restriction to its laptop
+// and dates in one transaction, ErrNotAvailable is returned if they overlap any other restriction of the laptop
+func (p *postgres) RescheduleReservation(res *models.Reservation) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := p.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var lrID int
+	query := `SELECT id FROM laptop_restrictions WHERE reservation_id = $1 AND restriction_id = $2`
+	err = tx.QueryRowContext(ctx, query, res.ID, models.RestrictionReservation).Scan(&lrID)
+	if err != nil {
+		return err
+	}
+
+	err = p.moveRestriction(ctx, tx, lrID, res.LaptopID, res.StartDate, res.EndDate)
+	if err != nil {
+		return err
+	}
+
+	query = `UPDATE reservations SET first_name = $1, last_name = $2, email = $3, phone = $4,
+			 laptop_id = $5, start_date = $6, end_date = $7, updated_at = $8
+			 WHERE id = $9`
+	result, err := tx.ExecContext(ctx, query, res.FirstName, res.LastName, res.Email, res.Phone,
+		res.LaptopID, res.StartDate, res.EndDate, time.Now(), res.ID)
+	if err != nil {
+		return err
+	}
+	if err = expectOneRow(result); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// MoveBlock moves a block to the laptop and dates and returns the laptop it was on, ErrNotAvailable is returned
+// if the days overlap any other restriction of the laptop
+func (p *postgres) MoveBlock(id, laptopID int, start, end time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := p.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var from int
+	query := `SELECT laptop_id FROM laptop_restrictions WHERE id = $1 AND restriction_id = $2`
+	err = tx.QueryRowContext(ctx, query, id, models.RestrictionBlock).Scan(&from)
+	if err != nil {
+		return 0, err
+	}
+
+	err = p.lockBlockDays(ctx, tx, laptopID, start, end, id)
+	if err != nil {
+		return 0, err
+	}
+
+	err = p.updateRestriction(ctx, tx, id, laptopID, start, end)
+	if err != nil {
+		return 0, err
+	}
+
+	return from, tx.Commit()
+}
+
+// moveRestriction moves the restriction lrID to the laptop and dates within tx, ErrNotAvailable is returned
+// if they overlap any other restriction of the laptop
+func (p *postgres) moveRestriction(ctx context.Context, tx *sql.Tx, lrID, laptopID int, start, end time.Time) error {
+	err := p.lockAvailability(ctx, tx, laptopID, start, end, lrID)
+	if err != nil {
+		return err
+	}
+
+	return p.updateRestriction(ctx, tx, lrID, laptopID, start, end)
+}
+
+// updateRestriction sets the laptop and dates of the restriction lrID within tx
+func (p *postgres) updateRestriction(ctx context.Context, tx *sql.Tx, lrID, laptopID int, start, end time.Time) error {
+	query := `UPDATE laptop_restrictions SET laptop_id = $1, start_date = $2, end_date = $3, updated_at = $4,
+			  version = version + 1
+			  WHERE id = $5`
+	result, err := tx.ExecContext(ctx, query, laptopID, start, end, time.Now(), lrID)
+	if err != nil {
+		return err
+	}
+
+	return expectOneRow(result)
+}
+
+// ChangeBlocks adds and removes blocks in one transaction and returns the laptops blocks were removed from.
+// A block is only removed at the version it was read at, ErrBlockChanged is returned if it was moved or removed
+// since and ErrNotAvailable if the days of an added block were restricted since, nothing is changed then.
+func (p *postgres) ChangeBlocks(add, remove []models.LaptopRestriction) ([]int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := p.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var unblocked []int
+	for _, b := range remove {
+		var laptopID int
+		query := `DELETE FROM laptop_restrictions WHERE id = $1 AND restriction_id = $2 AND version = $3
+				  RETURNING laptop_id`
+		err = tx.QueryRowContext(ctx, query, b.ID, models.RestrictionBlock, b.Version).Scan(&laptopID)
+		if err == sql.ErrNoRows {
+			return nil, ErrBlockChanged
+		}
+		if err != nil {
+			return nil, err
+		}
+		unblocked = appendUnique(unblocked, laptopID)
+	}
+
+	for _, b := range add {
+		err = p.lockBlockDays(ctx, tx, b.LaptopID, b.StartDate, b.EndDate, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		query := `INSERT INTO laptop_restrictions (start_date, end_date, laptop_id, restriction_id, created_at, updated_at)
+				 VALUES ($1, $2, $3, $4, $5, $6)`
+		_, err = tx.ExecContext(ctx, query, b.StartDate, b.EndDate, b.LaptopID, models.RestrictionBlock, time.Now(), time.Now())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return unblocked, tx.Commit()
+}
+
+// RecordReminder records that the reminder of kind was mailed for a reservation, false is returned without
+// recording anything if it already was
+func (p *postgres) RecordReminder(reservationID int, kind string, sentAt time.Time) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO reservation_reminders (reservation_id, kind, sent_at, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5)
+			  ON CONFLICT (reservation_id, kind) DO NOTHING`
+	result, err := p.DB.ExecContext(ctx, query, reservationID, kind, sentAt, time.Now(), time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// GetRemindersByReservationID returns the reminders mailed for a reservation in the order they were sent
+func (p *postgres) GetRemindersByReservationID(reservationID int) ([]models.Reminder, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT id, reservation_id, kind, sent_at, created_at, updated_at FROM reservation_reminders
+			  WHERE reservation_id = $1 ORDER BY sent_at, id`
+	return queryReminders(ctx, p.DB, query, reservationID)
+}
+
+// AcquireJobLease takes the lease of the job name until until for its run scheduled at scheduledAt, false is
+// returned if the run was already taken or the last run still holds the lease
+func (p *postgres) AcquireJobLease(name, owner string, scheduledAt, until time.Time) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO job_leases (name, owner, scheduled_at, lease_until, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $5)
+			  ON CONFLICT (name) DO UPDATE SET owner = EXCLUDED.owner, scheduled_at = EXCLUDED.scheduled_at,
+			  lease_until = EXCLUDED.lease_until, updated_at = EXCLUDED.updated_at
+			  WHERE job_leases.scheduled_at < EXCLUDED.scheduled_at AND job_leases.lease_until <= $5`
+	result, err := p.DB.ExecContext(ctx, query, name, owner, scheduledAt, until, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// ReleaseJobLease ends the lease owner holds on the job name, so that the next run doesn't wait for it
+func (p *postgres) ReleaseJobLease(name, owner string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE job_leases SET lease_until = $1, updated_at = $1 WHERE name = $2 AND owner = $3`
+	_, err := p.DB.ExecContext(ctx, query, time.Now(), name, owner)
+	return err
+}
+
+// InsertJobRun records a run of a job
+func (p *postgres) InsertJobRun(run *models.JobRun) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var newID int
+	query := `INSERT INTO job_runs (name, owner, scheduled_at, started_at, finished_at, attempts, status, message,
+			  created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`
+	err := p.DB.QueryRowContext(ctx, query, run.Name, run.Owner, run.ScheduledAt, run.StartedAt, run.FinishedAt,
+		run.Attempts, run.Status, run.Message, time.Now(), time.Now()).Scan(&newID)
+	if err != nil {
+		return 0, err
+	}
+
+	return newID, nil
+}
+
+// GetJobRuns returns the last limit runs of the job name, of every job if name is empty, latest first
+func (p *postgres) GetJobRuns(name string, limit int) ([]models.JobRun, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT id, name, owner, scheduled_at, started_at, finished_at, attempts, status, message, created_at,
+			  updated_at FROM job_runs WHERE $1 = '' OR name = $1 ORDER BY started_at DESC, id DESC LIMIT $2`
+	return queryJobRuns(ctx, p.DB, query, name, limit)
+}
+
+// DeleteJobRunsBefore deletes the job runs started before t and returns how many there were
+func (p *postgres) DeleteJobRunsBefore(t time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := p.DB.ExecContext(ctx, `DELETE FROM job_runs WHERE started_at < $1`, t)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// QueueMail adds a mail to the mail queue, to be sent from its NextAttemptAt
+func (p *postgres) QueueMail(q *models.QueuedMail) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var newID int
+	query := `INSERT INTO mail_queue (sender, recipient, subject, content, template, status, attempts, last_error,
+			  next_attempt_at, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $10) RETURNING id`
+	err := p.DB.QueryRowContext(ctx, query, q.Mail.From, q.Mail.To, q.Mail.Subject, q.Mail.Content, q.Mail.Template,
+		q.Status, q.Attempts, q.LastError, q.NextAttemptAt, time.Now()).Scan(&newID)
+	if err != nil {
+		return 0, err
+	}
+
+	return newID, nil
+}
+
+// GetDueMail returns up to limit pending mails whose next attempt is due at now, the longest waiting first
+func (p *postgres) GetDueMail(now time.Time, limit int) ([]models.QueuedMail, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT id, sender, recipient, subject, content, template, status, attempts, last_error, next_attempt_at,
+			  created_at, updated_at FROM mail_queue WHERE status = $1 AND next_attempt_at <= $2
+			  ORDER BY next_attempt_at, id LIMIT $3`
+	return queryQueuedMail(ctx, p.DB, query, models.MailPending, now, limit)
+}
+
+// UpdateQueuedMail saves the status, attempts, last error and next attempt of a queued mail
+func (p *postgres) UpdateQueuedMail(q models.QueuedMail) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE mail_queue SET status = $1, attempts = $2, last_error = $3, next_attempt_at = $4, updated_at = $5
+			  WHERE id = $6`
+	result, err := p.DB.ExecContext(ctx, query, q.Status, q.Attempts, q.LastError, q.NextAttemptAt, time.Now(), q.ID)
+	if err != nil {
+		return err
+	}
+
+	return expectOneRow(result)
+}
+
+// DeleteQueuedMail removes a mail that was sent from the mail queue
+func (p *postgres) DeleteQueuedMail(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := p.DB.ExecContext(ctx, `DELETE FROM mail_queue WHERE id = $1`, id)
+	return err
+}
@@ -0,0 +1,1754 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// sqliteDateLayout is how date columns are stored, so that they compare correctly as text
+const sqliteDateLayout = "2006-01-02"
+
+// sqliteTimeLayout is how expires_at is stored, always in UTC so that it compares correctly as text
+const sqliteTimeLayout = "2006-01-02 15:04:05"
+
+// sqliteNow returns the current time formatted like expires_at
+func sqliteNow() string {
+	return time.Now().UTC().Format(sqliteTimeLayout)
+}
+
+// sqliteTurnaroundEnd and sqliteTurnaroundStart are the end and start date of the restriction lr
+// extended by turnaroundSQL, for the overlap checks
+var (
+	sqliteTurnaroundEnd   = `date(lr.end_date, '+' || ` + turnaroundSQL + ` || ' days')`
+	sqliteTurnaroundStart = `date(lr.start_date, '-' || ` + turnaroundSQL + ` || ' days')`
+)
+
+// AllUsers returns a slice of all users ordered by id
+func (s *sqlite) AllUsers() ([]models.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var users []models.User
+
+	query := `SELECT id, first_name, last_name, email, password, access_level, created_at, updated_at
+			  FROM users ORDER BY id`
+
+	rows, err := s.DB.QueryContext(ctx, query)
+	if err != nil {
+		return users, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var u models.User
+		err := rows.Scan(
+			&u.ID,
+			&u.FirstName,
+			&u.LastName,
+			&u.Email,
+			&u.Password,
+			&u.AccessLevel,
+			&u.CreatedAt,
+			&u.UpdatedAt,
+		)
+		if err != nil {
+			return users, err
+		}
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return users, err
+	}
+
+	return users, nil
+}
+
+// InsertUser inserts a user with the bcrypt hash of password and returns its id
+func (s *sqlite) InsertUser(u *models.User, password string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `INSERT INTO users (first_name, last_name, email, password, access_level, created_at, updated_at)
+			  VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	result, err := s.DB.ExecContext(ctx, query,
+		u.FirstName,
+		u.LastName,
+		u.Email,
+		string(hashedPassword),
+		u.AccessLevel,
+		time.Now(),
+		time.Now(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	newID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(newID), nil
+}
+
+// InsertReservation inserts a reservation into the database
+func (s *sqlite) InsertReservation(res *models.Reservation) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO reservations (first_name, last_name, email, phone,
+			  start_date, end_date, laptop_id, locale, created_at, updated_at)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	result, err := s.DB.ExecContext(ctx, query,
+		res.FirstName,
+		res.LastName,
+		res.Email,
+		res.Phone,
+		res.StartDate.Format(sqliteDateLayout),
+		res.EndDate.Format(sqliteDateLayout),
+		res.LaptopID,
+		res.Locale,
+		time.Now(),
+		time.Now(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	newID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(newID), nil
+}
+
+// InsertLaptopRestriction inserts a laptop restriction into the database
+func (s *sqlite) InsertLaptopRestriction(lr *models.LaptopRestriction) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO laptop_restrictions (start_date, end_date, laptop_id, reservation_id,
+			  created_at, updated_at, restriction_id)
+			  VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err := s.DB.ExecContext(ctx, query,
+		lr.StartDate.Format(sqliteDateLayout),
+		lr.EndDate.Format(sqliteDateLayout),
+		lr.LaptopID,
+		lr.ReservationID,
+		time.Now(),
+		time.Now(),
+		lr.RestrictionID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SearchAvailabilityByDatesLaptopID returns true if availability exists for laptop id, and false if no availability exists
+func (s *sqlite) SearchAvailabilityByDatesByLaptopID(start, end time.Time, laptopID int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT Count(lr.id) FROM laptop_restrictions lr
+		      JOIN laptops l ON l.id = lr.laptop_id
+		      WHERE lr.laptop_id = ? and ? <= ` + sqliteTurnaroundEnd + `
+		      and ? >= ` + sqliteTurnaroundStart + `
+		      AND (lr.expires_at IS NULL OR lr.expires_at > ?)`
+
+	var numRows int
+	row := s.DB.QueryRowContext(ctx, query, laptopID, start.Format(sqliteDateLayout), end.Format(sqliteDateLayout), sqliteNow())
+	err := row.Scan(&numRows)
+	if err != nil {
+		return false, err
+	}
+
+	if numRows == 0 {
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// SearchAvailabilityForAllLaptops returns a slice of available laptops if any, for given date range
+func (s *sqlite) SearchAvailabilityForAllLaptops(start, end time.Time) ([]models.Laptop, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var laptops []models.Laptop
+
+	query := `SELECT l.id, l.laptop_name, l.buffer_days_before, l.buffer_days_after
+			  FROM laptops l
+			  WHERE NOT EXISTS (
+				  SELECT lr.id FROM laptop_restrictions lr
+				  WHERE lr.laptop_id = l.id
+				  AND ? <= ` + sqliteTurnaroundEnd + `
+				  AND ? >= ` + sqliteTurnaroundStart + `
+				  AND (lr.expires_at IS NULL OR lr.expires_at > ?))`
+	rows, err := s.DB.QueryContext(ctx, query, start.Format(sqliteDateLayout), end.Format(sqliteDateLayout), sqliteNow())
+	if err != nil {
+		return laptops, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var laptop models.Laptop
+		err = rows.Scan(
+			&laptop.ID,
+			&laptop.LaptopName,
+			&laptop.BufferDaysBefore,
+			&laptop.BufferDaysAfter,
+		)
+		if err != nil {
+			return laptops, err
+		}
+
+		laptops = append(laptops, laptop)
+	}
+
+	if err = rows.Err(); err != nil {
+		return laptops, err
+	}
+
+	return laptops, nil
+}
+
+// GetLaptopByID gets a laptop by id
+func (s *sqlite) GetLaptopByID(id int) (models.Laptop, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var laptop models.Laptop
+
+	query := `SELECT id, laptop_name, buffer_days_before, buffer_days_after, created_at, updated_at FROM laptops
+			 WHERE id = ?`
+	row := s.DB.QueryRowContext(ctx, query, id)
+	err := row.Scan(
+		&laptop.ID,
+		&laptop.LaptopName,
+		&laptop.BufferDaysBefore,
+		&laptop.BufferDaysAfter,
+		&laptop.CreatedAt,
+		&laptop.UpdatedAt,
+	)
+
+	if err != nil {
+		return laptop, err
+	}
+
+	return laptop, nil
+}
+
+// GetUserByID returns a user by id
+func (s *sqlite) GetUserByID(id int) (models.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT id, first_name, last_name, email, password, access_level, created_at, updated_at
+			  FROM users WHERE id = ?`
+	row := s.DB.QueryRowContext(ctx, query, id)
+
+	var u models.User
+	err := row.Scan(
+		&u.ID,
+		&u.FirstName,
+		&u.LastName,
+		&u.Email,
+		&u.Password,
+		&u.AccessLevel,
+		&u.CreatedAt,
+		&u.UpdatedAt,
+	)
+	if err != nil {
+		return u, err
+	}
+
+	return u, nil
+}
+
+// UpdateUser updates a user in the database
+func (s *sqlite) UpdateUser(u *models.User) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE users SET first_name = ?, last_name = ?, email = ?, access_level = ?, updated_at = ?
+			  WHERE id = ?`
+
+	_, err := s.DB.ExecContext(ctx, query,
+		u.FirstName,
+		u.LastName,
+		u.Email,
+		u.AccessLevel,
+		time.Now(),
+		u.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetUserByEmail returns a user by email
+func (s *sqlite) GetUserByEmail(email string) (models.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT id, first_name, last_name, email, password, access_level, created_at, updated_at
+			  FROM users WHERE email = ?`
+	row := s.DB.QueryRowContext(ctx, query, email)
+
+	var u models.User
+	err := row.Scan(
+		&u.ID,
+		&u.FirstName,
+		&u.LastName,
+		&u.Email,
+		&u.Password,
+		&u.AccessLevel,
+		&u.CreatedAt,
+		&u.UpdatedAt,
+	)
+	if err != nil {
+		return u, err
+	}
+
+	return u, nil
+}
+
+// UpdateUserPassword replaces the password of a user with the bcrypt hash of password
+func (s *sqlite) UpdateUserPassword(id int, password string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE users SET password = ?, updated_at = ? WHERE id = ?`
+
+	result, err := s.DB.ExecContext(ctx, query, string(hashedPassword), time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	return expectOneRow(result)
+}
+
+// Authenticate authenticates a user
+func (s *sqlite) Authenticate(email, password string) (int, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var id int
+	var hashedPassword string
+	row := s.DB.QueryRowContext(ctx, "SELECT id, password FROM users WHERE email = ?", email)
+	err := row.Scan(&id, &hashedPassword)
+	if err != nil {
+		return id, "", err
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return 0, "", errors.New("incorrect password")
+	} else if err != nil {
+		return 0, "", err
+	}
+
+	return id, hashedPassword, nil
+}
+
+// AllReservations returns a slice of all reservations
+func (s *sqlite) AllReservations() ([]models.Reservation, error) {
+	query := `SELECT r.id, r.first_name, r.last_name, r.email, r.phone,
+			  r.start_date, r.end_date, r.laptop_id, r.created_at, r.updated_at, r.processed, r.locale,
+			  lp.id, lp.laptop_name
+			  FROM reservations r
+		   	  LEFT JOIN laptops lp ON (r.laptop_id = lp.id)
+			  ORDER BY r.start_date asc, r.end_date asc`
+
+	return s.queryReservations(query)
+}
+
+// AllNewReservations returns a slice of all new reservations
+func (s *sqlite) AllNewReservations() ([]models.Reservation, error) {
+	query := `SELECT r.id, r.first_name, r.last_name, r.email, r.phone,
+			  r.start_date, r.end_date, r.laptop_id, r.created_at, r.updated_at, r.processed, r.locale,
+			  lp.id, lp.laptop_name
+			  FROM reservations r
+		   	  LEFT JOIN laptops lp ON (r.laptop_id = lp.id)
+			  WHERE processed = 0
+			  ORDER BY r.start_date asc, r.end_date asc`
+
+	return s.queryReservations(query)
+}
+
+// queryReservations runs a reservation query joined with laptops and scans the result
+func (s *sqlite) queryReservations(query string, args ...interface{}) ([]models.Reservation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var reservations []models.Reservation
+
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return reservations, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r models.Reservation
+		err := rows.Scan(
+			&r.ID,
+			&r.FirstName,
+			&r.LastName,
+			&r.Email,
+			&r.Phone,
+			&r.StartDate,
+			&r.EndDate,
+			&r.LaptopID,
+			&r.CreatedAt,
+			&r.UpdatedAt,
+			&r.Processed,
+			&r.Locale,
+			&r.Laptop.ID,
+			&r.Laptop.LaptopName,
+		)
+
+		if err != nil {
+			return reservations, err
+		}
+		reservations = append(reservations, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return reservations, err
+	}
+
+	return reservations, nil
+}
+
+// GetReservatioByID returns one reservation by id
+func (s *sqlite) GetReservatioByID(id int) (models.Reservation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var res models.Reservation
+
+	query := `SELECT r.id, r.first_name, r.last_name, r.email, r.phone,
+			  r.start_date, r.end_date, r.laptop_id, r.created_at, r.updated_at, r.processed, r.locale,
+			  lp.id, lp.laptop_name
+			  FROM reservations r
+			  LEFT JOIN laptops lp ON (r.laptop_id = lp.id)
+			  WHERE r.id = ?`
+	row := s.DB.QueryRowContext(ctx, query, id)
+	err := row.Scan(
+		&res.ID,
+		&res.FirstName,
+		&res.LastName,
+		&res.Email,
+		&res.Phone,
+		&res.StartDate,
+		&res.EndDate,
+		&res.LaptopID,
+		&res.CreatedAt,
+		&res.UpdatedAt,
+		&res.Processed,
+		&res.Locale,
+		&res.Laptop.ID,
+		&res.Laptop.LaptopName,
+	)
+
+	if err != nil {
+		return res, err
+	}
+
+	return res, nil
+}
+
+// UpdateReservation updates a reservation in the database
+func (s *sqlite) UpdateReservation(res *models.Reservation) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE reservations SET first_name = ?, last_name = ?, email = ?, phone = ?, updated_at = ?
+			  WHERE id = ?`
+
+	_, err := s.DB.ExecContext(ctx, query,
+		res.FirstName,
+		res.LastName,
+		res.Email,
+		res.Phone,
+		time.Now(),
+		res.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteReservation deletes one reservation by id and records it as cancelled
+func (s *sqlite) DeleteReservation(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = sqliteCancelReservation(ctx, tx, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// sqliteCancelReservation deletes a reservation and records its cancellation
+func sqliteCancelReservation(ctx context.Context, tx *sql.Tx, id int) error {
+	query := `INSERT INTO reservation_cancellations (reservation_id, laptop_id, start_date, end_date, booked_at,
+			  created_at, updated_at)
+			  SELECT id, laptop_id, start_date, end_date, created_at, ?, ? FROM reservations WHERE id = ?`
+	_, err := tx.ExecContext(ctx, query, time.Now(), time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM reservations where id = ?`, id)
+
+	return err
+}
+
+// UpdateReservationProcessed updates a reservation processed status by id
+func (s *sqlite) UpdateReservationProcessed(id, processed int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE reservations SET processed = ?
+			  WHERE id = ?`
+
+	_, err := s.DB.ExecContext(ctx, query, processed, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// AllLaptops returns all laptops
+func (s *sqlite) AllLaptops() ([]models.Laptop, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var laptops []models.Laptop
+
+	query := `SELECT id, laptop_name, buffer_days_before, buffer_days_after, created_at, updated_at
+			  FROM laptops order by laptop_name`
+	rows, err := s.DB.QueryContext(ctx, query)
+	if err != nil {
+		return laptops, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var laptop models.Laptop
+		err := rows.Scan(
+			&laptop.ID,
+			&laptop.LaptopName,
+			&laptop.BufferDaysBefore,
+			&laptop.BufferDaysAfter,
+			&laptop.CreatedAt,
+			&laptop.UpdatedAt,
+		)
+		if err != nil {
+			return laptops, err
+		}
+		laptops = append(laptops, laptop)
+	}
+
+	if err = rows.Err(); err != nil {
+		return laptops, err
+	}
+
+	return laptops, nil
+}
+
+// UpdateLaptopBuffers sets the turnaround days kept free before and after each reservation of a laptop
+func (s *sqlite) UpdateLaptopBuffers(id, before, after int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE laptops SET buffer_days_before = ?, buffer_days_after = ?, updated_at = ?
+			  WHERE id = ?`
+	result, err := s.DB.ExecContext(ctx, query, before, after, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	return expectOneRow(result)
+}
+
+// GetLaptopRestrictionsByDate returns restrictions for a laptop by date range
+func (s *sqlite) GetLaptopRestrictionsByDate(laptopID int, start, end time.Time) ([]models.LaptopRestriction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var restrictions []models.LaptopRestriction
+
+	query := `SELECT id, COALESCE(reservation_id, 0) reservation_id, restriction_id, laptop_id, start_date, end_date, expires_at,
+			  version
+			  FROM laptop_restrictions
+			  WHERE ? <= end_date AND ? >= start_date AND laptop_id = ?
+			  AND (expires_at IS NULL OR expires_at > ?)`
+
+	rows, err := s.DB.QueryContext(ctx, query, start.Format(sqliteDateLayout), end.Format(sqliteDateLayout), laptopID, sqliteNow())
+	if err != nil {
+		return restrictions, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var l models.LaptopRestriction
+		var expiresAt sql.NullTime
+		err := rows.Scan(
+			&l.ID,
+			&l.ReservationID,
+			&l.RestrictionID,
+			&l.LaptopID,
+			&l.StartDate,
+			&l.EndDate,
+			&expiresAt,
+			&l.Version,
+		)
+		if err != nil {
+			return restrictions, err
+		}
+		l.ExpiresAt = expiresAt.Time
+		restrictions = append(restrictions, l)
+	}
+
+	if err = rows.Err(); err != nil {
+		return restrictions, err
+	}
+
+	return restrictions, nil
+}
+
+// InsertOneDayBlockByLaptopID inserts a one day block restriction by laptop id
+func (s *sqlite) InsertOneDayBlockByLaptopID(id int, startDate time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO laptop_restrictions (start_date, end_date, laptop_id, restriction_id, created_at, updated_at)
+			  VALUES (?, ?, ?, ?, ?, ?)`
+
+	_, err := s.DB.ExecContext(ctx, query,
+		startDate.Format(sqliteDateLayout),
+		startDate.Format(sqliteDateLayout),
+		id, 2, time.Now(), time.Now())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// InsertBlockByLaptopID inserts a block restriction for a laptop from startDate to endDate
+func (s *sqlite) InsertBlockByLaptopID(id int, startDate, endDate time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO laptop_restrictions (start_date, end_date, laptop_id, restriction_id, created_at, updated_at)
+			  VALUES (?, ?, ?, ?, ?, ?)`
+
+	_, err := s.DB.ExecContext(ctx, query,
+		startDate.Format(sqliteDateLayout),
+		endDate.Format(sqliteDateLayout),
+		id, 2, time.Now(), time.Now())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteBlockByLaptopID deletes a laptop restriction by id
+func (s *sqlite) DeleteBlockByID(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM laptop_restrictions WHERE id = ?`
+
+	_, err := s.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// InsertWaitlistEntry puts a customer on the waitlist of a laptop and returns the id of the entry
+func (s *sqlite) InsertWaitlistEntry(e *models.WaitlistEntry) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO waitlist_entries (first_name, last_name, email, laptop_id,
+			  start_date, end_date, locale, created_at, updated_at)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	result, err := s.DB.ExecContext(ctx, query,
+		e.FirstName,
+		e.LastName,
+		e.Email,
+		e.LaptopID,
+		e.StartDate.Format(sqliteDateLayout),
+		e.EndDate.Format(sqliteDateLayout),
+		e.Locale,
+		time.Now(),
+		time.Now(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	newID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(newID), nil
+}
+
+// GetWaitlistByLaptopID returns the waiting and notified entries of a laptop in the order they joined
+func (s *sqlite) GetWaitlistByLaptopID(laptopID int) ([]models.WaitlistEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var entries []models.WaitlistEntry
+
+	query := `SELECT w.id, w.first_name, w.last_name, w.email, w.laptop_id, w.start_date, w.end_date,
+			  w.status, w.token, w.hold_until, w.hold_id, w.locale, w.created_at, w.updated_at, lp.id, lp.laptop_name
+			  FROM waitlist_entries w
+			  LEFT JOIN laptops lp ON (w.laptop_id = lp.id)
+			  WHERE w.laptop_id = ? AND w.status IN (?, ?)
+			  ORDER BY w.id`
+
+	rows, err := s.DB.QueryContext(ctx, query, laptopID, models.WaitlistWaiting, models.WaitlistNotified)
+	if err != nil {
+		return entries, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := scanWaitlistEntry(rows)
+		if err != nil {
+			return entries, err
+		}
+		entries = append(entries, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return entries, err
+	}
+
+	return entries, nil
+}
+
+// GetWaitlistEntryByToken returns the entry a hold link was sent for
+func (s *sqlite) GetWaitlistEntryByToken(token string) (models.WaitlistEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT w.id, w.first_name, w.last_name, w.email, w.laptop_id, w.start_date, w.end_date,
+			  w.status, w.token, w.hold_until, w.hold_id, w.locale, w.created_at, w.updated_at, lp.id, lp.laptop_name
+			  FROM waitlist_entries w
+			  LEFT JOIN laptops lp ON (w.laptop_id = lp.id)
+			  WHERE w.token = ? AND w.token <> ''`
+
+	return scanWaitlistEntry(s.DB.QueryRowContext(ctx, query, token))
+}
+
+// UpdateWaitlistEntry updates the status and hold of a waitlist entry
+func (s *sqlite) UpdateWaitlistEntry(e *models.WaitlistEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE waitlist_entries SET status = ?, token = ?, hold_until = ?, hold_id = ?, updated_at = ?
+			  WHERE id = ?`
+
+	result, err := s.DB.ExecContext(ctx, query, e.Status, e.Token, nullTime(e.HoldUntil), e.HoldID, time.Now(), e.ID)
+	if err != nil {
+		return err
+	}
+
+	return expectOneRow(result)
+}
+
+// InsertHold holds a laptop from start to end until expiresAt and returns the id of the hold,
+// ErrNotAvailable if the dates overlap another restriction of the laptop
+func (s *sqlite) InsertHold(laptopID int, start, end, expiresAt time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	err = s.checkAvailability(ctx, tx, laptopID, start, end, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `INSERT INTO laptop_restrictions (start_date, end_date, laptop_id, restriction_id, expires_at,
+			  created_at, updated_at)
+			  VALUES (?, ?, ?, ?, ?, ?, ?)`
+	result, err := tx.ExecContext(ctx, query,
+		start.Format(sqliteDateLayout),
+		end.Format(sqliteDateLayout),
+		laptopID,
+		models.RestrictionHold,
+		expiresAt.UTC().Format(sqliteTimeLayout),
+		time.Now(),
+		time.Now(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	newID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(newID), tx.Commit()
+}
+
+// ConvertHold inserts res and turns the hold holdID into its reservation restriction. When the hold has
+// expired res is booked directly if its dates are still free, otherwise ErrNotAvailable is returned.
+func (s *sqlite) ConvertHold(holdID int, res *models.Reservation) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO reservations (first_name, last_name, email, phone,
+			  start_date, end_date, laptop_id, locale, created_at, updated_at)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := tx.ExecContext(ctx, query,
+		res.FirstName,
+		res.LastName,
+		res.Email,
+		res.Phone,
+		res.StartDate.Format(sqliteDateLayout),
+		res.EndDate.Format(sqliteDateLayout),
+		res.LaptopID,
+		res.Locale,
+		time.Now(),
+		time.Now(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	newID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	query = `UPDATE laptop_restrictions SET restriction_id = ?, reservation_id = ?, expires_at = NULL, updated_at = ?
+			 WHERE id = ? AND restriction_id = ? AND expires_at > ?
+			 AND laptop_id = ? AND start_date = ? AND end_date = ?`
+	result, err = tx.ExecContext(ctx, query, models.RestrictionReservation, newID, time.Now(),
+		holdID, models.RestrictionHold, sqliteNow(),
+		res.LaptopID, res.StartDate.Format(sqliteDateLayout), res.EndDate.Format(sqliteDateLayout))
+	if err != nil {
+		return 0, err
+	}
+
+	if expectOneRow(result) != nil {
+		// the hold is gone, book the dates if nobody took them in the meantime
+		_, err = tx.ExecContext(ctx, `DELETE FROM laptop_restrictions WHERE id = ? AND restriction_id = ?`,
+			holdID, models.RestrictionHold)
+		if err != nil {
+			return 0, err
+		}
+		err = s.checkAvailability(ctx, tx, res.LaptopID, res.StartDate, res.EndDate, 0)
+		if err != nil {
+			return 0, err
+		}
+
+		query = `INSERT INTO laptop_restrictions (start_date, end_date, laptop_id, reservation_id,
+				 created_at, updated_at, restriction_id)
+				 VALUES (?, ?, ?, ?, ?, ?, ?)`
+		_, err = tx.ExecContext(ctx, query,
+			res.StartDate.Format(sqliteDateLayout),
+			res.EndDate.Format(sqliteDateLayout),
+			res.LaptopID, newID, time.Now(), time.Now(), models.RestrictionReservation)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return int(newID), tx.Commit()
+}
+
+// InsertReservationChecked inserts res with its reservation restriction in one transaction and returns its id,
+// ErrNotAvailable if its dates overlap another restriction of the laptop
+func (s *sqlite) InsertReservationChecked(res *models.Reservation) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	err = s.checkAvailability(ctx, tx, res.LaptopID, res.StartDate, res.EndDate, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `INSERT INTO reservations (first_name, last_name, email, phone,
+			  start_date, end_date, laptop_id, locale, created_at, updated_at)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := tx.ExecContext(ctx, query, res.FirstName, res.LastName, res.Email, res.Phone,
+		res.StartDate.Format(sqliteDateLayout), res.EndDate.Format(sqliteDateLayout), res.LaptopID, res.Locale, time.Now(), time.Now())
+	if err != nil {
+		return 0, err
+	}
+	newID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	query = `INSERT INTO laptop_restrictions (start_date, end_date, laptop_id, reservation_id,
+			 created_at, updated_at, restriction_id)
+			 VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err = tx.ExecContext(ctx, query, res.StartDate.Format(sqliteDateLayout), res.EndDate.Format(sqliteDateLayout),
+		res.LaptopID, newID, time.Now(), time.Now(), models.RestrictionReservation)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(newID), tx.Commit()
+}
+
+// checkAvailability returns ErrNotAvailable if start to end overlaps a restriction of the laptop other than
+// the restriction except, 0 for none. SQLite has a single connection so nothing else can book
+// the laptop until tx ends
+func (s *sqlite) checkAvailability(ctx context.Context, tx *sql.Tx, laptopID int, start, end time.Time, except int) error {
+	var id int
+	err := tx.QueryRowContext(ctx, `SELECT id FROM laptops WHERE id = ?`, laptopID).Scan(&id)
+	if err != nil {
+		return err
+	}
+
+	query := `SELECT Count(lr.id) FROM laptop_restrictions lr
+			  JOIN laptops l ON l.id = lr.laptop_id
+			  WHERE lr.laptop_id = ? and ? <= ` + sqliteTurnaroundEnd + `
+			  and ? >= ` + sqliteTurnaroundStart + `
+			  AND (lr.expires_at IS NULL OR lr.expires_at > ?)
+			  AND lr.id <> ?`
+
+	var numRows int
+	err = tx.QueryRowContext(ctx, query, laptopID, start.Format(sqliteDateLayout), end.Format(sqliteDateLayout),
+		sqliteNow(), except).Scan(&numRows)
+	if err != nil {
+		return err
+	}
+	if numRows > 0 {
+		return ErrNotAvailable
+	}
+
+	return nil
+}
+
+// checkBlockDays is checkAvailability for a block from start to end, blocks don't keep a turnaround free so only
+// the blocked days themselves must not overlap a restriction other than except
+func (s *sqlite) checkBlockDays(ctx context.Context, tx *sql.Tx, laptopID int, start, end time.Time, except int) error {
+	var id int
+	err := tx.QueryRowContext(ctx, `SELECT id FROM laptops WHERE id = ?`, laptopID).Scan(&id)
+	if err != nil {
+		return err
+	}
+
+	query := `SELECT Count(id) FROM laptop_restrictions
+			  WHERE laptop_id = ? AND ? <= end_date AND ? >= start_date
+			  AND (expires_at IS NULL OR expires_at > ?)
+			  AND id <> ?`
+
+	var numRows int
+	err = tx.QueryRowContext(ctx, query, laptopID, start.Format(sqliteDateLayout), end.Format(sqliteDateLayout),
+		sqliteNow(), except).Scan(&numRows)
+	if err != nil {
+		return err
+	}
+	if numRows > 0 {
+		return ErrNotAvailable
+	}
+
+	return nil
+}
+
+// DeleteHold releases a hold
+func (s *sqlite) DeleteHold(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM laptop_restrictions WHERE id = ? AND restriction_id = ?`
+
+	_, err := s.DB.ExecContext(ctx, query, id, models.RestrictionHold)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteExpiredHolds deletes the holds that expired by now and returns how many there were
+func (s *sqlite) DeleteExpiredHolds(now time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM laptop_restrictions WHERE restriction_id = ? AND expires_at <= ?`
+
+	result, err := s.DB.ExecContext(ctx, query, models.RestrictionHold, now.UTC().Format(sqliteTimeLayout))
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// GetBookingRules returns the booking rules of a laptop, or the global ones for laptop id 0
+func (s *sqlite) GetBookingRules(laptopID int) (models.BookingRules, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT id, laptop_id, min_days, max_days, max_advance_days, pickup_weekdays, created_at, updated_at
+			  FROM booking_rules WHERE laptop_id = ?`
+
+	return scanBookingRules(s.DB.QueryRowContext(ctx, query, laptopID))
+}
+
+// SaveBookingRules inserts or replaces the booking rules of r.LaptopID
+func (s *sqlite) SaveBookingRules(r *models.BookingRules) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO booking_rules (laptop_id, min_days, max_days, max_advance_days, pickup_weekdays,
+			  created_at, updated_at)
+			  VALUES (?, ?, ?, ?, ?, ?, ?)
+			  ON CONFLICT (laptop_id) DO UPDATE SET min_days = excluded.min_days, max_days = excluded.max_days,
+			  max_advance_days = excluded.max_advance_days, pickup_weekdays = excluded.pickup_weekdays,
+			  updated_at = excluded.updated_at
+			  RETURNING id`
+
+	return s.DB.QueryRowContext(ctx, query,
+		r.LaptopID,
+		r.MinDays,
+		r.MaxDays,
+		r.MaxAdvanceDays,
+		formatWeekdays(r.PickupWeekdays),
+		time.Now(),
+		time.Now(),
+	).Scan(&r.ID)
+}
+
+// DeleteBookingRules deletes the booking rules of a laptop, or the global ones for laptop id 0
+func (s *sqlite) DeleteBookingRules(laptopID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := s.DB.ExecContext(ctx, `DELETE FROM booking_rules WHERE laptop_id = ?`, laptopID)
+	if err != nil {
+		return err
+	}
+
+	return expectOneRow(result)
+}
+
+// InsertBlackout inserts a blackout and returns its id
+func (s *sqlite) InsertBlackout(b *models.Blackout) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO blackouts (laptop_id, start_date, end_date, reason, created_at, updated_at)
+			  VALUES (?, ?, ?, ?, ?, ?)`
+
+	result, err := s.DB.ExecContext(ctx, query,
+		b.LaptopID,
+		b.StartDate.Format(sqliteDateLayout),
+		b.EndDate.Format(sqliteDateLayout),
+		b.Reason,
+		time.Now(),
+		time.Now(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	newID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(newID), nil
+}
+
+// GetBlackoutsByDate returns the blackouts of a laptop and of every laptop overlapping start to end,
+// only the ones of every laptop for laptop id 0
+func (s *sqlite) GetBlackoutsByDate(laptopID int, start, end time.Time) ([]models.Blackout, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var blackouts []models.Blackout
+
+	query := `SELECT id, laptop_id, start_date, end_date, reason, created_at, updated_at
+			  FROM blackouts
+			  WHERE (laptop_id = 0 OR laptop_id = ?) AND ? <= end_date AND ? >= start_date
+			  ORDER BY start_date, id`
+
+	rows, err := s.DB.QueryContext(ctx, query, laptopID, start.Format(sqliteDateLayout), end.Format(sqliteDateLayout))
+	if err != nil {
+		return blackouts, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		b, err := scanBlackout(rows)
+		if err != nil {
+			return blackouts, err
+		}
+		blackouts = append(blackouts, b)
+	}
+
+	if err = rows.Err(); err != nil {
+		return blackouts, err
+	}
+
+	return blackouts, nil
+}
+
+// DeleteBlackout deletes a blackout by id
+func (s *sqlite) DeleteBlackout(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := s.DB.ExecContext(ctx, `DELETE FROM blackouts WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	return expectOneRow(result)
+}
+
+// CountNewReservations returns the number of reservations that haven't been processed yet
+func (s *sqlite) CountNewReservations() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var n int
+	err := s.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM reservations WHERE processed = 0`).Scan(&n)
+
+	return n, err
+}
+
+// GetReservationsStartingOn returns the reservations picked up on date
+func (s *sqlite) GetReservationsStartingOn(date time.Time) ([]models.Reservation, error) {
+	query := `SELECT r.id, r.first_name, r.last_name, r.email, r.phone,
+			  r.start_date, r.end_date, r.laptop_id, r.created_at, r.updated_at, r.processed, r.locale,
+			  lp.id, lp.laptop_name
+			  FROM reservations r
+			  LEFT JOIN laptops lp ON (r.laptop_id = lp.id)
+			  WHERE r.start_date = ?
+			  ORDER BY lp.laptop_name, r.id`
+
+	return s.queryReservations(query, date.Format(sqliteDateLayout))
+}
+
+// GetReservationsEndingOn returns the reservations returned on date
+func (s *sqlite) GetReservationsEndingOn(date time.Time) ([]models.Reservation, error) {
+	query := `SELECT r.id, r.first_name, r.last_name, r.email, r.phone,
+			  r.start_date, r.end_date, r.laptop_id, r.created_at, r.updated_at, r.processed, r.locale,
+			  lp.id, lp.laptop_name
+			  FROM reservations r
+			  LEFT JOIN laptops lp ON (r.laptop_id = lp.id)
+			  WHERE r.end_date = ?
+			  ORDER BY lp.laptop_name, r.id`
+
+	return s.queryReservations(query, date.Format(sqliteDateLayout))
+}
+
+// GetLaptopUtilization returns how many days from start to end each laptop was rented, ordered by laptop name
+func (s *sqlite) GetLaptopUtilization(start, end time.Time) ([]models.LaptopUtilization, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var utilization []models.LaptopUtilization
+
+	// the two argument MIN and MAX are the scalar functions, the lesser and greater of two dates
+	query := `SELECT lp.id, lp.laptop_name,
+			  COALESCE(SUM(CAST(julianday(MIN(r.end_date, :end)) - julianday(MAX(r.start_date, :start)) AS INTEGER) + 1), 0)
+			  FROM laptops lp
+			  LEFT JOIN reservations r ON (r.laptop_id = lp.id AND r.start_date <= :end AND r.end_date >= :start)
+			  GROUP BY lp.id, lp.laptop_name
+			  ORDER BY lp.laptop_name, lp.id`
+
+	rows, err := s.DB.QueryContext(ctx, query,
+		sql.Named("start", start.Format(sqliteDateLayout)),
+		sql.Named("end", end.Format(sqliteDateLayout)),
+	)
+	if err != nil {
+		return utilization, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		u, err := scanUtilization(rows, start, end)
+		if err != nil {
+			return utilization, err
+		}
+		utilization = append(utilization, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return utilization, err
+	}
+
+	return utilization, nil
+}
+
+// GetUpcomingBlocks returns at most limit blocks of every laptop that don't end before from, the earliest first
+func (s *sqlite) GetUpcomingBlocks(from time.Time, limit int) ([]models.LaptopRestriction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var blocks []models.LaptopRestriction
+
+	query := `SELECT lr.id, lr.laptop_id, lr.restriction_id, lr.start_date, lr.end_date, lp.laptop_name
+			  FROM laptop_restrictions lr
+			  LEFT JOIN laptops lp ON (lr.laptop_id = lp.id)
+			  WHERE lr.restriction_id = ? AND lr.end_date >= ?
+			  ORDER BY lr.start_date, lr.id
+			  LIMIT ?`
+
+	rows, err := s.DB.QueryContext(ctx, query, models.RestrictionBlock, from.Format(sqliteDateLayout), limit)
+	if err != nil {
+		return blocks, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		lr, err := scanBlock(rows)
+		if err != nil {
+			return blocks, err
+		}
+		blocks = append(blocks, lr)
+	}
+
+	if err = rows.Err(); err != nil {
+		return blocks, err
+	}
+
+	return blocks, nil
+}
+
+// CountReservationsByStartDate returns the number of reservations picked up on each day from start to end,
+// days without any are left out
+func (s *sqlite) CountReservationsByStartDate(start, end time.Time) ([]models.DailyCount, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var counts []models.DailyCount
+
+	query := `SELECT start_date, COUNT(*)
+			  FROM reservations
+			  WHERE start_date >= ? AND start_date <= ?
+			  GROUP BY start_date
+			  ORDER BY start_date`
+
+	rows, err := s.DB.QueryContext(ctx, query, start.Format(sqliteDateLayout), end.Format(sqliteDateLayout))
+	if err != nil {
+		return counts, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c models.DailyCount
+		if err := rows.Scan(&c.Date, &c.Count); err != nil {
+			return counts, err
+		}
+		counts = append(counts, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return counts, err
+	}
+
+	return counts, nil
+}
+
+// GetReservationsByDate returns the reservations of every laptop overlapping start to end
+func (s *sqlite) GetReservationsByDate(start, end time.Time) ([]models.Reservation, error) {
+	query := `SELECT r.id, r.first_name, r.last_name, r.email, r.phone,
+			  r.start_date, r.end_date, r.laptop_id, r.created_at, r.updated_at, r.processed, r.locale,
+			  lp.id, lp.laptop_name
+			  FROM reservations r
+			  LEFT JOIN laptops lp ON (r.laptop_id = lp.id)
+			  WHERE ? <= r.end_date AND ? >= r.start_date
+			  ORDER BY r.start_date, r.end_date, r.id`
+
+	return s.queryReservations(query, start.Format(sqliteDateLayout), end.Format(sqliteDateLayout))
+}
+
+// GetBlocksByDate returns the blocks of every laptop overlapping start to end, joined with the laptop name
+func (s *sqlite) GetBlocksByDate(start, end time.Time) ([]models.LaptopRestriction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var blocks []models.LaptopRestriction
+
+	query := `SELECT lr.id, lr.laptop_id, lr.restriction_id, lr.start_date, lr.end_date, lp.laptop_name
+			  FROM laptop_restrictions lr
+			  LEFT JOIN laptops lp ON (lr.laptop_id = lp.id)
+			  WHERE lr.restriction_id = ? AND ? <= lr.end_date AND ? >= lr.start_date
+			  ORDER BY lr.start_date, lr.id`
+
+	rows, err := s.DB.QueryContext(ctx, query, models.RestrictionBlock, start.Format(sqliteDateLayout), end.Format(sqliteDateLayout))
+	if err != nil {
+		return blocks, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		lr, err := scanBlock(rows)
+		if err != nil {
+			return blocks, err
+		}
+		blocks = append(blocks, lr)
+	}
+
+	if err = rows.Err(); err != nil {
+		return blocks, err
+	}
+
+	return blocks, nil
+}
+
+// GetCancellationsByDate returns the cancelled reservations of every laptop overlapping start to end
+func (s *sqlite) GetCancellationsByDate(start, end time.Time) ([]models.Cancellation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var cancellations []models.Cancellation
+
+	query := `SELECT id, reservation_id, laptop_id, start_date, end_date, booked_at, created_at, updated_at
+			  FROM reservation_cancellations
+			  WHERE ? <= end_date AND ? >= start_date
+			  ORDER BY start_date, id`
+
+	rows, err := s.DB.QueryContext(ctx, query, start.Format(sqliteDateLayout), end.Format(sqliteDateLayout))
+	if err != nil {
+		return cancellations, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		c, err := scanCancellation(rows)
+		if err != nil {
+			return cancellations, err
+		}
+		cancellations = append(cancellations, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return cancellations, err
+	}
+
+	return cancellations, nil
+}
+
+// SearchReservations returns the page of reservations selected by f joined with their laptop,
+// and how many reservations f selects on all pages
+func (s *sqlite) SearchReservations(f models.ReservationFilter) ([]models.Reservation, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var args []interface{}
+	bind := func(v interface{}) string {
+		args = append(args, v)
+		return "?" + strconv.Itoa(len(args))
+	}
+	where, order := reservationFilterSQL(f, bind, func(t time.Time) interface{} { return t.Format(sqliteDateLayout) },
+		lowerLikeSearch)
+
+	var total int
+	query := `SELECT COUNT(*) FROM reservations r LEFT JOIN laptops lp ON (r.laptop_id = lp.id) WHERE ` + where
+	err := s.DB.QueryRowContext(ctx, query, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query = `SELECT r.id, r.first_name, r.last_name, r.email, r.phone,
+			 r.start_date, r.end_date, r.laptop_id, r.created_at, r.updated_at, r.processed, r.locale,
+			 lp.id, lp.laptop_name
+			 FROM reservations r
+			 LEFT JOIN laptops lp ON (r.laptop_id = lp.id)
+			 WHERE ` + where + ` ORDER BY ` + order
+	if f.Limit > 0 {
+		query += " LIMIT " + bind(f.Limit) + " OFFSET " + bind(f.Offset)
+	}
+
+	reservations, err := s.queryReservations(query, args...)
+	return reservations, total, err
+}
+
+// sqliteBulkReservation looks up one reservation of a bulk action
+const sqliteBulkReservation = `SELECT r.id, r.first_name, r.last_name, r.email, r.phone,
+			  r.start_date, r.end_date, r.laptop_id, r.created_at, r.updated_at, r.processed, r.locale,
+			  lp.id, lp.laptop_name
+			  FROM reservations r
+			  LEFT JOIN laptops lp ON (r.laptop_id = lp.id)
+			  WHERE r.id = ?`
+
+// ProcessReservations marks reservations as processed in one transaction
+func (s *sqlite) ProcessReservations(ids []int) ([]models.BulkResult, error) {
+	return bulkReservations(s.DB, sqliteBulkReservation, ids, func(ctx context.Context, tx *sql.Tx, id int) error {
+		_, err := tx.ExecContext(ctx, `UPDATE reservations SET processed = 1, updated_at = ? WHERE id = ?`, time.Now(), id)
+		return err
+	})
+}
+
+// CancelReservations deletes reservations and records their cancellations in one transaction
+func (s *sqlite) CancelReservations(ids []int) ([]models.BulkResult, error) {
+	return bulkReservations(s.DB, sqliteBulkReservation, ids, sqliteCancelReservation)
+}
+
+// RescheduleReservation saves the customer details of res and moves it and its laptop restriction to its laptop
+// and dates in one transaction, ErrNotAvailable is returned if they overlap any other restriction of the laptop
+func (s *sqlite) RescheduleReservation(res *models.Reservation) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var lrID int
+	query := `SELECT id FROM laptop_restrictions WHERE reservation_id = ? AND restriction_id = ?`
+	err = tx.QueryRowContext(ctx, query, res.ID, models.RestrictionReservation).Scan(&lrID)
+	if err != nil {
+		return err
+	}
+
+	err = s.moveRestriction(ctx, tx, lrID, res.LaptopID, res.StartDate, res.EndDate)
+	if err != nil {
+		return err
+	}
+
+	query = `UPDATE reservations SET first_name = ?, last_name = ?, email = ?, phone = ?,
+			 laptop_id = ?, start_date = ?, end_date = ?, updated_at = ?
+			 WHERE id = ?`
+	result, err := tx.ExecContext(ctx, query, res.FirstName, res.LastName, res.Email, res.Phone,
+		res.LaptopID, res.StartDate.Format(sqliteDateLayout), res.EndDate.Format(sqliteDateLayout), time.Now(), res.ID)
+	if err != nil {
+		return err
+	}
+	if err = expectOneRow(result); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// MoveBlock moves a block to the laptop and dates and returns the laptop it was on, ErrNotAvailable is returned
+// if the days overlap any other restriction of the laptop
+func (s *sqlite) MoveBlock(id, laptopID int, start, end time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var from int
+	query := `SELECT laptop_id FROM laptop_restrictions WHERE id = ? AND restriction_id = ?`
+	err = tx.QueryRowContext(ctx, query, id, models.RestrictionBlock).Scan(&from)
+	if err != nil {
+		return 0, err
+	}
+
+	err = s.checkBlockDays(ctx, tx, laptopID, start, end, id)
+	if err != nil {
+		return 0, err
+	}
+
+	err = s.updateRestriction(ctx, tx, id, laptopID, start, end)
+	if err != nil {
+		return 0, err
+	}
+
+	return from, tx.Commit()
+}
+
+// moveRestriction moves the restriction lrID to the laptop and dates within tx, ErrNotAvailable is returned
+// if they overlap any other restriction of the laptop
+func (s *sqlite) moveRestriction(ctx context.Context, tx *sql.Tx, lrID, laptopID int, start, end time.Time) error {
+	err := s.checkAvailability(ctx, tx, laptopID, start, end, lrID)
+	if err != nil {
+		return err
+	}
+
+	return s.updateRestriction(ctx, tx, lrID, laptopID, start, end)
+}
+
+// updateRestriction sets the laptop and dates of the restriction lrID within tx
+func (s *sqlite) updateRestriction(ctx context.Context, tx *sql.Tx, lrID, laptopID int, start, end time.Time) error {
+	query := `UPDATE laptop_restrictions SET laptop_id = ?, start_date = ?, end_date = ?, updated_at = ?,
+			  version = version + 1
+			  WHERE id = ?`
+	result, err := tx.ExecContext(ctx, query, laptopID, start.Format(sqliteDateLayout), end.Format(sqliteDateLayout),
+		time.Now(), lrID)
+	if err != nil {
+		return err
+	}
+
+	return expectOneRow(result)
+}
+
+// ChangeBlocks adds and removes blocks in one transaction and returns the laptops blocks were removed from.
+// A block is only removed at the version it was read at, ErrBlockChanged is returned if it was moved or removed
+// since and ErrNotAvailable if the days of an added block were restricted since, nothing is changed then.
+func (s *sqlite) ChangeBlocks(add, remove []models.LaptopRestriction) ([]int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var unblocked []int
+	for _, b := range remove {
+		var laptopID int
+		query := `SELECT laptop_id FROM laptop_restrictions WHERE id = ? AND restriction_id = ? AND version = ?`
+		err = tx.QueryRowContext(ctx, query, b.ID, models.RestrictionBlock, b.Version).Scan(&laptopID)
+		if err == sql.ErrNoRows {
+			return nil, ErrBlockChanged
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		result, err := tx.ExecContext(ctx, `DELETE FROM laptop_restrictions WHERE id = ? AND version = ?`, b.ID, b.Version)
+		if err != nil {
+			return nil, err
+		}
+		if err = expectOneRow(result); err != nil {
+			return nil, ErrBlockChanged
+		}
+		unblocked = appendUnique(unblocked, laptopID)
+	}
+
+	for _, b := range add {
+		err = s.checkBlockDays(ctx, tx, b.LaptopID, b.StartDate, b.EndDate, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		query := `INSERT INTO laptop_restrictions (start_date, end_date, laptop_id, restriction_id, created_at, updated_at)
+				 VALUES (?, ?, ?, ?, ?, ?)`
+		_, err = tx.ExecContext(ctx, query, b.StartDate.Format(sqliteDateLayout), b.EndDate.Format(sqliteDateLayout),
+			b.LaptopID, models.RestrictionBlock, time.Now(), time.Now())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return unblocked, tx.Commit()
+}
+
+// RecordReminder records that the reminder of kind was mailed for a reservation, false is returned without
+// recording anything if it already was
+func (s *sqlite) RecordReminder(reservationID int, kind string, sentAt time.Time) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `INSERT OR IGNORE INTO reservation_reminders (reservation_id, kind, sent_at, created_at, updated_at)
+			  VALUES (?, ?, ?, ?, ?)`
+	result, err := s.DB.ExecContext(ctx, query, reservationID, kind, sentAt, time.Now(), time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// GetRemindersByReservationID returns the reminders mailed for a reservation in the order they were sent
+func (s *sqlite) GetRemindersByReservationID(reservationID int) ([]models.Reminder, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT id, reservation_id, kind, sent_at, created_at, updated_at FROM reservation_reminders
+			  WHERE reservation_id = ? ORDER BY sent_at, id`
+	return queryReminders(ctx, s.DB, query, reservationID)
+}
+
+// AcquireJobLease takes the lease of the job name until until for its run scheduled at scheduledAt, false is
+// returned if the run was already taken or the last run still holds the lease
+func (s *sqlite) AcquireJobLease(name, owner string, scheduledAt, until time.Time) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO job_leases (name, owner, scheduled_at, lease_until, created_at, updated_at)
+			  VALUES (?1, ?2, ?3, ?4, ?5, ?5)
+			  ON CONFLICT (name) DO UPDATE SET owner = excluded.owner, scheduled_at = excluded.scheduled_at,
+			  lease_until = excluded.lease_until, updated_at = excluded.updated_at
+			  WHERE job_leases.scheduled_at < excluded.scheduled_at AND job_leases.lease_until <= ?5`
+	result, err := s.DB.ExecContext(ctx, query, name, owner, scheduledAt.UTC().Format(sqliteTimeLayout),
+		until.UTC().Format(sqliteTimeLayout), sqliteNow())
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// ReleaseJobLease ends the lease owner holds on the job name, so that the next run doesn't wait for it
+func (s *sqlite) ReleaseJobLease(name, owner string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE job_leases SET lease_until = ?1, updated_at = ?1 WHERE name = ?2 AND owner = ?3`
+	_, err := s.DB.ExecContext(ctx, query, sqliteNow(), name, owner)
+	return err
+}
+
+// InsertJobRun records a run of a job
+func (s *sqlite) InsertJobRun(run *models.JobRun) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO job_runs (name, owner, scheduled_at, started_at, finished_at, attempts, status, message,
+			  created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := s.DB.ExecContext(ctx, query, run.Name, run.Owner, run.ScheduledAt.UTC().Format(sqliteTimeLayout),
+		run.StartedAt.UTC().Format(sqliteTimeLayout), run.FinishedAt.UTC().Format(sqliteTimeLayout), run.Attempts,
+		run.Status, run.Message, time.Now(), time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// GetJobRuns returns the last limit runs of the job name, of every job if name is empty, latest first
+func (s *sqlite) GetJobRuns(name string, limit int) ([]models.JobRun, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT id, name, owner, scheduled_at, started_at, finished_at, attempts, status, message, created_at,
+			  updated_at FROM job_runs WHERE ?1 = '' OR name = ?1 ORDER BY started_at DESC, id DESC LIMIT ?2`
+	return queryJobRuns(ctx, s.DB, query, name, limit)
+}
+
+// DeleteJobRunsBefore deletes the job runs started before t and returns how many there were
+func (s *sqlite) DeleteJobRunsBefore(t time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := s.DB.ExecContext(ctx, `DELETE FROM job_runs WHERE started_at < ?`, t.UTC().Format(sqliteTimeLayout))
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// QueueMail adds a mail to the mail queue, to be sent from its NextAttemptAt
+func (s *sqlite) QueueMail(q *models.QueuedMail) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `INSERT INTO mail_queue (sender, recipient, subject, content, template, status, attempts, last_error,
+			  next_attempt_at, created_at, updated_at) VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?10)`
+	result, err := s.DB.ExecContext(ctx, query, q.Mail.From, q.Mail.To, q.Mail.Subject, q.Mail.Content,
+		q.Mail.Template, q.Status, q.Attempts, q.LastError, q.NextAttemptAt.UTC().Format(sqliteTimeLayout), sqliteNow())
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// GetDueMail returns up to limit pending mails whose next attempt is due at now, the longest waiting first
+func (s *sqlite) GetDueMail(now time.Time, limit int) ([]models.QueuedMail, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT id, sender, recipient, subject, content, template, status, attempts, last_error, next_attempt_at,
+			  created_at, updated_at FROM mail_queue WHERE status = ? AND next_attempt_at <= ?
+			  ORDER BY next_attempt_at, id LIMIT ?`
+	return queryQueuedMail(ctx, s.DB, query, models.MailPending, now.UTC().Format(sqliteTimeLayout), limit)
+}
+
+// UpdateQueuedMail saves the status, attempts, last error and next attempt of a queued mail
+func (s *sqlite) UpdateQueuedMail(q models.QueuedMail) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `UPDATE mail_queue SET status = ?, attempts = ?, last_error = ?, next_attempt_at = ?, updated_at = ?
+			  WHERE id = ?`
+	result, err := s.DB.ExecContext(ctx, query, q.Status, q.Attempts, q.LastError,
+		q.NextAttemptAt.UTC().Format(sqliteTimeLayout), sqliteNow(), q.ID)
+	if err != nil {
+		return err
+	}
+
+	return expectOneRow(result)
+}
+
+// DeleteQueuedMail removes a mail that was sent from the mail queue
+func (s *sqlite) DeleteQueuedMail(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := s.DB.ExecContext(ctx, `DELETE FROM mail_queue WHERE id = ?`, id)
+	return err
+}
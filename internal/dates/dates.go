@@ -0,0 +1,55 @@
+package dates
+
+import (
+	"time"
+
+	// the timezone database, for servers and containers without one
+	_ "time/tzdata"
+)
+
+// Layout is the format of dates in forms, links, emails and on the command line
+const Layout = "2006-01-02"
+
+// location is the business timezone, it decides which day it is
+var location = time.Local
+
+// SetLocation sets the business timezone
+func SetLocation(loc *time.Location) {
+	location = loc
+}
+
+// Location returns the business timezone
+func Location() *time.Location {
+	return location
+}
+
+// LoadLocation returns the timezone named like "Asia/Tokyo", an empty name is the server's local timezone
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(name)
+}
+
+// Parse parses a YYYY-MM-DD date. A date is a calendar day rather than an instant,
+// it is kept at midnight UTC like the dates read from the database, so that days
+// compare, subtract and format the same way on both sides of a DST transition
+func Parse(s string) (time.Time, error) {
+	return time.Parse(Layout, s)
+}
+
+// Day returns the calendar day t falls on in the business timezone, at midnight UTC like Parse
+func Day(t time.Time) time.Time {
+	year, month, day := t.In(location).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// Today returns the current calendar day in the business timezone
+func Today() time.Time {
+	return Day(time.Now())
+}
+
+// In returns the instant t in the business timezone, for showing times like the expiry of a hold
+func In(t time.Time) time.Time {
+	return t.In(location)
+}
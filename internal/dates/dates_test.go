@@ -0,0 +1,107 @@
+package dates
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+
+	loc, err := LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestDay(t *testing.T) {
+	defer SetLocation(Location())
+
+	var tests = []struct {
+		location string
+		instant  string
+		expected string
+	}{
+		{"UTC", "2021-05-09T23:59:59Z", "2021-05-09"},
+		{"Asia/Tokyo", "2021-05-09T14:59:59Z", "2021-05-09"},
+		{"Asia/Tokyo", "2021-05-09T15:00:00Z", "2021-05-10"},
+		// New York springs forward at 2:00 on 2021-03-14, midnight is still 5:00 UTC that night
+		{"America/New_York", "2021-03-14T04:59:59Z", "2021-03-13"},
+		{"America/New_York", "2021-03-14T05:00:00Z", "2021-03-14"},
+		{"America/New_York", "2021-03-15T03:59:59Z", "2021-03-14"},
+		{"America/New_York", "2021-03-15T04:00:00Z", "2021-03-15"},
+		// and falls back at 2:00 on 2021-11-07, 1:30 happens twice
+		{"America/New_York", "2021-11-07T03:59:59Z", "2021-11-06"},
+		{"America/New_York", "2021-11-07T05:30:00Z", "2021-11-07"},
+		{"America/New_York", "2021-11-07T06:30:00Z", "2021-11-07"},
+		{"America/New_York", "2021-11-08T04:59:59Z", "2021-11-07"},
+		{"America/New_York", "2021-11-08T05:00:00Z", "2021-11-08"},
+		// London moves to summer time at 1:00 UTC on 2021-03-28
+		{"Europe/London", "2021-03-27T23:59:59Z", "2021-03-27"},
+		{"Europe/London", "2021-03-28T23:00:00Z", "2021-03-29"},
+	}
+
+	for _, e := range tests {
+		SetLocation(mustLoad(t, e.location))
+		instant, _ := time.Parse(time.RFC3339, e.instant)
+
+		day := Day(instant)
+		if got := day.Format(Layout); got != e.expected {
+			t.Errorf("%s in %s: expected %s, got %s", e.instant, e.location, e.expected, got)
+		}
+		if day.Location() != time.UTC || day.Hour() != 0 {
+			t.Errorf("%s in %s: expected midnight UTC, got %s", e.instant, e.location, day)
+		}
+	}
+}
+
+func TestParse_DST(t *testing.T) {
+	defer SetLocation(Location())
+	SetLocation(mustLoad(t, "America/New_York"))
+
+	// a rental over a DST transition is still a whole number of days
+	for _, r := range [][2]string{{"2021-03-13", "2021-03-15"}, {"2021-11-06", "2021-11-08"}} {
+		start, err := Parse(r[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		end, err := Parse(r[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if end.Sub(start) != 48*time.Hour {
+			t.Errorf("%s to %s: expected 48 hours, got %s", r[0], r[1], end.Sub(start))
+		}
+		if start.AddDate(0, 0, 2) != end {
+			t.Errorf("%s plus 2 days: expected %s, got %s", r[0], end, start.AddDate(0, 0, 2))
+		}
+	}
+
+	_, err := Parse("2021-02-30")
+	if err == nil {
+		t.Error("parsed an invalid date")
+	}
+}
+
+func TestLoadLocation(t *testing.T) {
+	loc, err := LoadLocation("")
+	if err != nil || loc != time.Local {
+		t.Errorf("expected the local timezone, got %v %v", loc, err)
+	}
+
+	_, err = LoadLocation("Mars/Olympus_Mons")
+	if err == nil {
+		t.Error("loaded an unknown timezone")
+	}
+}
+
+func TestIn(t *testing.T) {
+	defer SetLocation(Location())
+	SetLocation(mustLoad(t, "Asia/Tokyo"))
+
+	instant, _ := time.Parse(time.RFC3339, "2021-05-09T15:30:00Z")
+	if got := In(instant).Format("2006-01-02 15:04 MST"); got != "2021-05-10 00:30 JST" {
+		t.Errorf("expected 2021-05-10 00:30 JST, got %s", got)
+	}
+}
@@ -2,16 +2,28 @@ package driver
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"os"
 	"time"
 
 	_ "github.com/jackc/pgconn"
 	_ "github.com/jackc/pgx/v4/stdlib"
 	_ "github.com/jackc/pgx/v4"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// supported database drivers
+const (
+	Postgres = "postgres"
+	SQLite   = "sqlite"
+	Memory   = "memory"
 )
 
 // DB holds the database connection pool
 type DB struct {
-	Conn *sql.DB
+	Conn   *sql.DB
+	Driver string
 }
 
 var db = &DB{}
@@ -30,6 +42,7 @@ func ConnectSQL(dsn string) (*DB, error) {
 	conn.SetMaxIdleConns(maxIdleDbConn)
 	conn.SetConnMaxLifetime(maxDbLifetime)
 	db.Conn = conn
+	db.Driver = Postgres
 
 	if err = conn.Ping(); err != nil {
 		return nil, err
@@ -38,6 +51,41 @@ func ConnectSQL(dsn string) (*DB, error) {
 	return db, nil
 }
 
+// ConnectSQLite opens the SQLite database file at path
+func ConnectSQLite(path string) (*DB, error) {
+	// foreign keys are off by default in SQLite, the schema relies on cascading deletes
+	conn, err := sql.Open("sqlite3", path+"?_foreign_keys=1&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer, more connections only cause "database is locked" errors
+	conn.SetMaxOpenConns(1)
+
+	if err = conn.Ping(); err != nil {
+		return nil, err
+	}
+
+	return &DB{
+		Conn:   conn,
+		Driver: SQLite,
+	}, nil
+}
+
+// ConnectMemory returns a DB without a connection, the repository keeps everything in memory
+func ConnectMemory() *DB {
+	return &DB{
+		Driver: Memory,
+	}
+}
+
+// Close closes the connection pool if there is one
+func (d *DB) Close() error {
+	if d.Conn == nil {
+		return nil
+	}
+	return d.Conn.Close()
+}
+
 // NewDatabase creates a new database for the application
 func NewDatabase(dsn string) (*sql.DB, error) {
 	conn, err := sql.Open("pgx", dsn)
@@ -51,3 +99,65 @@ func NewDatabase(dsn string) (*sql.DB, error) {
 
 	return conn, nil
 }
+
+// Config holds the database settings, read from the environment
+type Config struct {
+	Driver   string
+	Host     string
+	Name     string
+	User     string
+	Password string
+	Port     string
+	SSL      string
+	Path     string
+}
+
+// ConfigFromEnv reads the database settings from the environment, see .env.example
+func ConfigFromEnv() Config {
+	return Config{
+		Driver:   os.Getenv("dbdriver"), // (postgres, sqlite, memory)
+		Host:     os.Getenv("dbhost"),
+		Name:     os.Getenv("dbname"),
+		User:     os.Getenv("dbuser"),
+		Password: os.Getenv("dbpassword"),
+		Port:     os.Getenv("dbport"),
+		SSL:      os.Getenv("dbssl"),  // (disbale, prefer, require)
+		Path:     os.Getenv("dbpath"), // sqlite database file
+	}
+}
+
+// Validate checks that the settings needed by the driver are present, an empty driver means Postgres
+func (c *Config) Validate() error {
+	if c.Driver == "" {
+		c.Driver = Postgres
+	}
+
+	switch c.Driver {
+	case Postgres:
+		if c.Name == "" || c.User == "" {
+			return errors.New("missing database name or user")
+		}
+	case SQLite:
+		if c.Path == "" {
+			return errors.New("missing database path")
+		}
+	case Memory:
+	default:
+		return fmt.Errorf("unknown database driver: %s", c.Driver)
+	}
+
+	return nil
+}
+
+// Connect connects to the database selected by the config
+func Connect(c Config) (*DB, error) {
+	switch c.Driver {
+	case SQLite:
+		return ConnectSQLite(c.Path)
+	case Memory:
+		return ConnectMemory(), nil
+	default:
+		dsn := fmt.Sprintf("host=%s port=%s dbname=%s user=%s password=%s sslmode=%s", c.Host, c.Port, c.Name, c.User, c.Password, c.SSL)
+		return ConnectSQL(dsn)
+	}
+}
@@ -1,18 +1,24 @@
 package forms
 
 import (
-	"fmt"
 	"net/url"
 	"strings"
 	"time"
 
 	"github.com/asaskevich/govalidator"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/dates"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/i18n"
 )
 
+// today returns the current date in the business timezone, a variable so that tests can move it
+var today = dates.Today
+
 // Form creates a custom form struct, embeds a url.Values object
 type Form struct {
 	url.Values
 	Errors errors
+	// Locale is the language of the error messages
+	Locale string
 }
 
 // New initializes a form struct
@@ -20,6 +26,7 @@ func New(data url.Values) *Form {
 	return &Form{
 		data,
 		errors(map[string][]string{}),
+		i18n.English,
 	}
 }
 
@@ -39,7 +46,7 @@ func (f *Form) Required(fields ...string) {
 	for _, field := range fields {
 		value := f.Get(field)
 		if strings.TrimSpace(value) == "" {
-			f.Errors.Add(field, "This field cannot be blank")
+			f.Errors.Add(field, i18n.T(f.Locale, "This field cannot be blank"))
 		}
 	}
 }
@@ -48,7 +55,7 @@ func (f *Form) Required(fields ...string) {
 func (f *Form) IsAboveMinLength(field string, length int) bool {
 	value := f.Get(field)
 	if len(value) < length {
-		f.Errors.Add(field, fmt.Sprintf("This field must be at least %d characters long", length))
+		f.Errors.Add(field, i18n.T(f.Locale, "This field must be at least %d characters long", length))
 		return false
 	}
 	return true
@@ -57,32 +64,24 @@ func (f *Form) IsAboveMinLength(field string, length int) bool {
 // IsEmail checks for valid email address
 func (f *Form) IsEmail(field string) {
 	if !govalidator.IsEmail(f.Get(field)) {
-		f.Errors.Add(field, "Invalid email address")
+		f.Errors.Add(field, i18n.T(f.Locale, "Invalid email address"))
 	}
 }
 
-// GetTimeObj gets the time.Time object date
+// GetTimeObj gets the time.Time object date, a calendar day at midnight UTC
 func (f *Form) GetTimeObj(field string) (time.Time, error) {
-	var date time.Time
-	d := f.Get(field)
-	// 01/02 03:04:05PM '06 -0700
-	layout := "2006-01-02"
-	date, err := time.Parse(layout, d)
-	if err != nil {
-		return date, err
-	}
-	return date, nil
+	return dates.Parse(f.Get(field))
 }
 
 // ValidateDate checks the date
 func (f *Form) ValidateDate(field string) {
 	date, err := f.GetTimeObj(field)
 	if err != nil {
-		f.Errors.Add(field, "Invalid date: date must be YYYY-MM-DD format")
+		f.Errors.Add(field, i18n.T(f.Locale, "Invalid date: date must be YYYY-MM-DD format"))
 	}
 
-	tomorrow := time.Now().Add(24 * time.Hour)
-	if date.Before(tomorrow) {
-		f.Errors.Add(field, "Invalid date: date must be after tomorrow")
+	// the day is decided in the business timezone, not by the server clock
+	if !date.After(today().AddDate(0, 0, 1)) {
+		f.Errors.Add(field, i18n.T(f.Locale, "Invalid date: date must be after tomorrow"))
 	}
 }
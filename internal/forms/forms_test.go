@@ -3,6 +3,9 @@ package forms
 import (
 	"net/url"
 	"testing"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/dates"
 )
 
 func TestNew(t *testing.T) {
@@ -90,3 +93,51 @@ func TestForm_IsEmail(t *testing.T) {
 		t.Error("TestForm_IsEmail: got invalid when it's an valid email")
 	}
 }
+
+func TestForm_ValidateDate(t *testing.T) {
+	defer dates.SetLocation(dates.Location())
+	defer func(f func() time.Time) { today = f }(today)
+
+	tokyo, err := dates.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dates.SetLocation(tokyo)
+
+	// half past midnight in Tokyo is still the day before on a UTC server clock
+	now, _ := time.Parse(time.RFC3339, "2021-05-09T15:30:00Z")
+	today = func() time.Time { return dates.Day(now) }
+
+	var tests = []struct {
+		date  string
+		valid bool
+	}{
+		{"2021-05-10", false},
+		{"2021-05-11", false},
+		{"2021-05-12", true},
+		{"2021-5-12", false},
+		{"", false},
+	}
+
+	for _, e := range tests {
+		form := New(url.Values{"start_date": {e.date}})
+		form.ValidateDate("start_date")
+		if form.Valid() != e.valid {
+			t.Errorf("%q: expected valid %t, got %v", e.date, e.valid, form.Errors)
+		}
+	}
+}
+
+func TestForm_Locale(t *testing.T) {
+	form := New(url.Values{"email": {"x"}})
+	form.Locale = "ja"
+	form.Required("first_name")
+	form.IsEmail("email")
+
+	if got := form.Errors.Get("first_name"); got != "この項目は必須です" {
+		t.Errorf("unexpected required error %q", got)
+	}
+	if got := form.Errors.Get("email"); got != "メールアドレスが正しくありません" {
+		t.Errorf("unexpected email error %q", got)
+	}
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/apperrors"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/database"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/forms"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/i18n"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/render"
+)
+
+// AdminCreateReservation shows the form staff book phone and walk-in reservations with
+func (repo *Repository) AdminCreateReservation(w http.ResponseWriter, r *http.Request) {
+	// the confirmation email is sent unless staff untick it, in English unless they pick the customer's language
+	form := newForm(r, url.Values{"notify": {"1"}, "locale": {i18n.English}})
+	repo.renderCreateReservation(w, r, http.StatusOK, form, nil, false)
+}
+
+// PostAdminCreateReservation books a reservation for any laptop and dates that are free, the booking rules
+// are checked unless override_rules is set. With check set it only shows the conflicts and broken rules.
+func (repo *Repository) PostAdminCreateReservation(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		render.Error(w, r, apperrors.BadRequest(err, "The form could not be read"))
+		return
+	}
+
+	form := newForm(r, r.PostForm)
+	form.Required("laptop_id", "start_date", "end_date", "first_name", "last_name", "email")
+	form.IsEmail("email")
+
+	laptopID, err := strconv.Atoi(r.Form.Get("laptop_id"))
+	if err != nil {
+		render.Error(w, r, apperrors.BadRequest(err, "Invalid Laptop ID"))
+		return
+	}
+	laptop, err := repo.DB.GetLaptopByID(laptopID)
+	if err != nil {
+		render.Error(w, r, apperrors.BadRequest(err, "Invalid Laptop ID"))
+		return
+	}
+
+	// staff can book any dates, past ones included
+	start, errStart := form.GetTimeObj("start_date")
+	if errStart != nil && form.Has("start_date") {
+		form.Errors.Add("start_date", i18n.T(form.Locale, "Invalid date: date must be YYYY-MM-DD format"))
+	}
+	end, errEnd := form.GetTimeObj("end_date")
+	if errEnd != nil && form.Has("end_date") {
+		form.Errors.Add("end_date", i18n.T(form.Locale, "Invalid date: date must be YYYY-MM-DD format"))
+	}
+	if errStart != nil || errEnd != nil {
+		repo.renderCreateReservation(w, r, http.StatusUnprocessableEntity, form, nil, false)
+		return
+	}
+	if end.Before(start) {
+		form.Errors.Add("end_date", i18n.T(form.Locale, "The end date can't be before the start date"))
+		repo.renderCreateReservation(w, r, http.StatusUnprocessableEntity, form, nil, false)
+		return
+	}
+
+	conflicts, err := repo.conflicts(laptop, start, end)
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+	if len(conflicts) > 0 {
+		form.Errors.Add("start_date", i18n.T(form.Locale, "The laptop isn't available for those dates"))
+	}
+
+	if r.Form.Get("override_rules") == "" {
+		err = repo.checkBookingRules(form, laptopID, start, end)
+		if err != nil {
+			render.Error(w, r, err)
+			return
+		}
+	}
+
+	if !form.Valid() {
+		repo.renderCreateReservation(w, r, http.StatusUnprocessableEntity, form, conflicts, r.Form.Get("check") != "")
+		return
+	}
+	if r.Form.Get("check") != "" {
+		repo.renderCreateReservation(w, r, http.StatusOK, form, conflicts, true)
+		return
+	}
+
+	// the customer is mailed in the language staff picked for them
+	locale := r.Form.Get("locale")
+	if !i18n.Supported(locale) {
+		locale = i18n.English
+	}
+
+	res := models.Reservation{
+		FirstName: r.Form.Get("first_name"),
+		LastName:  r.Form.Get("last_name"),
+		Email:     r.Form.Get("email"),
+		Phone:     r.Form.Get("phone"),
+		StartDate: start,
+		EndDate:   end,
+		LaptopID:  laptopID,
+		Laptop:    laptop,
+		Locale:    locale,
+	}
+
+	// the dates are checked again in the transaction booking them, in case somebody took them since the check
+	res.ID, err = repo.DB.InsertReservationChecked(&res)
+	if err == database.ErrNotAvailable {
+		conflicts, err = repo.conflicts(laptop, start, end)
+		if err != nil {
+			render.Error(w, r, err)
+			return
+		}
+		form.Errors.Add("start_date", i18n.T(form.Locale, "The laptop isn't available for those dates"))
+		repo.renderCreateReservation(w, r, http.StatusUnprocessableEntity, form, conflicts, false)
+		return
+	}
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+
+	if r.Form.Get("notify") != "" {
+		repo.mailConfirmation(res.Locale, res)
+	}
+
+	repo.App.Session.Put(r.Context(), "flash", "Reservation created")
+	http.Redirect(w, r, fmt.Sprintf("/admin/reservations/all/%d/show", res.ID), http.StatusSeeOther)
+}
+
+// conflicts returns the reservations, blocks and holds that keep laptop from being rented from start to end,
+// the turnaround days kept free around reservations and holds included
+func (repo *Repository) conflicts(laptop models.Laptop, start, end time.Time) ([]models.LaptopRestriction, error) {
+	turnaround := laptop.Turnaround()
+	restrictions, err := repo.DB.GetLaptopRestrictionsByDate(laptop.ID,
+		start.AddDate(0, 0, -turnaround), end.AddDate(0, 0, turnaround))
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []models.LaptopRestriction
+	for _, lr := range restrictions {
+		if lr.RestrictionID == models.RestrictionHold && !lr.ExpiresAt.After(time.Now()) {
+			continue
+		}
+		if lr.RestrictionID == models.RestrictionBlock && (lr.EndDate.Before(start) || lr.StartDate.After(end)) {
+			continue
+		}
+		conflicts = append(conflicts, lr)
+	}
+
+	return conflicts, nil
+}
+
+// renderCreateReservation shows the new reservation form with status and the conflicts of its laptop and dates,
+// checked tells that the form was only checked
+func (repo *Repository) renderCreateReservation(w http.ResponseWriter, r *http.Request, status int, form *forms.Form,
+	conflicts []models.LaptopRestriction, checked bool) {
+	laptops, err := repo.DB.AllLaptops()
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["laptops"] = laptops
+	data["conflicts"] = conflicts
+	data["checked"] = checked
+
+	render.TemplateStatus(w, r, status, "admin-create-reservation.page.html", &models.TemplateData{
+		Form: form,
+		Data: data,
+	})
+}
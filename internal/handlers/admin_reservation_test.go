@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/i18n"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+)
+
+func bookingForm(laptopID, start, end string) url.Values {
+	return url.Values{
+		"laptop_id":  {laptopID},
+		"start_date": {start},
+		"end_date":   {end},
+		"first_name": {"Jane"},
+		"last_name":  {"Doe"},
+		"email":      {"jane@doe.com"},
+		"phone":      {"555-0100"},
+	}
+}
+
+func TestAdminCreateReservation(t *testing.T) {
+	repo := newCalendarRepo(t)
+
+	rr := getList(repo.AdminCreateReservation, "/admin/create-reservation")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected the form, got %d", rr.Code)
+	}
+	body := rr.Body.String()
+	// only the confirmation email is ticked, the booking rules are kept
+	if strings.Count(body, "checked") != 1 {
+		t.Error("expected the confirmation email to be ticked")
+	}
+	if !strings.Contains(body, "Macbook Pro 15 inch") {
+		t.Error("expected the laptops to choose from")
+	}
+	if !strings.Contains(body, `<option value="en" selected>`) || !strings.Contains(body, `<option value="ja" >`) {
+		t.Error("expected the customer language to default to English")
+	}
+}
+
+func TestPostAdminCreateReservation(t *testing.T) {
+	repo := newCalendarRepo(t)
+	mailApp := app
+	mailApp.MailChan = make(chan models.MailData, 10)
+	repo.App = &mailApp
+
+	// laptop 2 is free while laptop 1 is reserved on those dates
+	data := bookingForm("2", "2099-01-10", "2099-01-12")
+	data.Set("first_name", "<b>Jane</b>")
+	data.Set("notify", "1")
+	data.Set("locale", "ja")
+	rr := postForm(repo.PostAdminCreateReservation, "/admin/create-reservation", data)
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected a redirect, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if loc := rr.Header().Get("Location"); loc != "/admin/reservations/all/2/show" {
+		t.Errorf("expected the new reservation, got %s", loc)
+	}
+
+	res, err := repo.DB.GetReservatioByID(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.LaptopID != 2 || res.Email != "jane@doe.com" || res.Phone != "555-0100" || res.FirstName != "<b>Jane</b>" ||
+		res.Locale != "ja" {
+		t.Errorf("unexpected reservation %+v", res)
+	}
+	available, _ := repo.DB.SearchAvailabilityByDatesByLaptopID(time.Date(2099, 1, 11, 0, 0, 0, 0, time.UTC), time.Date(2099, 1, 11, 0, 0, 0, 0, time.UTC), 2)
+	if available {
+		t.Error("the dates of the new reservation are still available")
+	}
+	if len(mailApp.MailChan) != 1 {
+		t.Fatalf("expected the confirmation email, got %d mails", len(mailApp.MailChan))
+	}
+	// the name staff typed is text in the mail, which is in the customer's language
+	mail := <-mailApp.MailChan
+	if mail.To != "jane@doe.com" || !strings.Contains(mail.Content, "&lt;b&gt;Jane&lt;/b&gt;") {
+		t.Errorf("unexpected mail to %s: %s", mail.To, mail.Content)
+	}
+	if mail.Subject != i18n.T("ja", "Reservation Confirmation") || mail.Subject == "Reservation Confirmation" {
+		t.Errorf("expected the confirmation in Japanese, got %q", mail.Subject)
+	}
+
+	// staff can book past dates and skip the email
+	rr = postForm(repo.PostAdminCreateReservation, "/admin/create-reservation", bookingForm("1", "2020-01-01", "2020-01-02"))
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected a past reservation to be booked, got %d", rr.Code)
+	}
+	if len(mailApp.MailChan) != 0 {
+		t.Error("the customer was emailed without asking")
+	}
+	// without a language the customer is booked in English
+	if res, _ := repo.DB.GetReservatioByID(3); res.Locale != i18n.English {
+		t.Errorf("expected the reservation in English, got %q", res.Locale)
+	}
+}
+
+func TestPostAdminCreateReservation_Conflicts(t *testing.T) {
+	repo := newCalendarRepo(t)
+
+	tests := []struct {
+		name     string
+		data     url.Values
+		expected string
+	}{
+		{"reservation", bookingForm("1", "2099-01-11", "2099-01-11"), `href="/admin/reservations/all/1/show">Reservation 1</a>`},
+		{"block", bookingForm("1", "2099-01-19", "2099-01-21"), "Block"},
+	}
+
+	for _, test := range tests {
+		rr := postForm(repo.PostAdminCreateReservation, "/admin/create-reservation", test.data)
+		if rr.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s: expected the form, got %d", test.name, rr.Code)
+			continue
+		}
+		body := rr.Body.String()
+		if !strings.Contains(body, test.expected) || !strings.Contains(body, "The laptop isn&#39;t available for those dates") {
+			t.Errorf("%s: expected the conflict %q", test.name, test.expected)
+		}
+		if !strings.Contains(body, `value="Jane"`) {
+			t.Errorf("%s: expected the form to keep its values", test.name)
+		}
+	}
+
+	if _, err := repo.DB.GetReservatioByID(2); err == nil {
+		t.Error("a conflicting reservation was booked")
+	}
+}
+
+func TestPostAdminCreateReservation_Check(t *testing.T) {
+	repo := newCalendarRepo(t)
+
+	data := bookingForm("1", "2099-02-01", "2099-02-03")
+	data.Set("check", "Check availability")
+	rr := postForm(repo.PostAdminCreateReservation, "/admin/create-reservation", data)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "The laptop is available") {
+		t.Fatalf("expected the form with the laptop available, got %d", rr.Code)
+	}
+	if _, err := repo.DB.GetReservatioByID(2); err == nil {
+		t.Error("checking the availability booked the reservation")
+	}
+}
+
+func TestPostAdminCreateReservation_OverrideRules(t *testing.T) {
+	repo := newCalendarRepo(t)
+	err := repo.DB.SaveBookingRules(&models.BookingRules{MinDays: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := bookingForm("1", "2099-02-01", "2099-02-01")
+	rr := postForm(repo.PostAdminCreateReservation, "/admin/create-reservation", data)
+	if rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), "Rentals must be at least 3 days long") {
+		t.Fatalf("expected the form with the minimum length error, got %d", rr.Code)
+	}
+
+	data.Set("override_rules", "1")
+	rr = postForm(repo.PostAdminCreateReservation, "/admin/create-reservation", data)
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected the rules to be overridden, got %d", rr.Code)
+	}
+}
+
+func TestPostAdminCreateReservation_Invalid(t *testing.T) {
+	repo := newCalendarRepo(t)
+
+	tests := []struct {
+		name     string
+		data     url.Values
+		code     int
+		expected string
+	}{
+		{"unknown laptop", bookingForm("99", "2099-02-01", "2099-02-01"), http.StatusBadRequest, ""},
+		{"invalid date", bookingForm("1", "2099-02-30", "2099-03-01"), http.StatusUnprocessableEntity, "Invalid date: date must be YYYY-MM-DD format"},
+		{"end before start", bookingForm("1", "2099-02-03", "2099-02-01"), http.StatusUnprocessableEntity, "The end date can&#39;t be before the start date"},
+		{"missing email", func() url.Values { d := bookingForm("1", "2099-02-01", "2099-02-01"); d.Del("email"); return d }(), http.StatusUnprocessableEntity, "This field cannot be blank"},
+	}
+
+	for _, test := range tests {
+		rr := postForm(repo.PostAdminCreateReservation, "/admin/create-reservation", test.data)
+		if rr.Code != test.code || !strings.Contains(rr.Body.String(), test.expected) {
+			t.Errorf("%s: expected %d with %q, got %d", test.name, test.code, test.expected, rr.Code)
+		}
+	}
+
+	if _, err := repo.DB.GetReservatioByID(2); err == nil {
+		t.Error("an invalid reservation was booked")
+	}
+}
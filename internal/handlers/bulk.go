@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/apperrors"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/csvsafe"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/dates"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/render"
+)
+
+// maxBulkReservations is the most reservations one bulk action can change
+const maxBulkReservations = 100
+
+// bulkDone describes a successful result of each bulk action shown on the results page
+var bulkDone = map[string]string{
+	"process": "Marked as processed",
+	"cancel":  "Cancelled",
+	"email":   "Email queued",
+}
+
+// PostAdminBulkReservations applies the action form value to the reservations checked on a reservation list,
+// process and cancel run in one transaction and every action but export shows the result of each reservation
+func (repo *Repository) PostAdminBulkReservations(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		render.Error(w, r, apperrors.BadRequest(err, "The form could not be read"))
+		return
+	}
+
+	ids, err := bulkIDs(r.Form["id"])
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+
+	tp := r.Form.Get("type")
+	if tp != "new" {
+		tp = "all"
+	}
+
+	action := r.Form.Get("action")
+	var results []models.BulkResult
+	switch action {
+	case "process":
+		results, err = repo.DB.ProcessReservations(ids)
+	case "cancel":
+		results, err = repo.DB.CancelReservations(ids)
+		if err == nil {
+			// offer the freed dates of each laptop to its waitlist once
+			notified := make(map[int]bool)
+			for _, result := range results {
+				if result.Err == nil && !notified[result.Reservation.LaptopID] {
+					notified[result.Reservation.LaptopID] = true
+					repo.notifyWaitlist(result.Reservation.LaptopID)
+				}
+			}
+		}
+	case "export":
+		repo.exportReservations(w, r, ids)
+		return
+	case "email":
+		subject := strings.TrimSpace(r.Form.Get("subject"))
+		message := strings.TrimSpace(r.Form.Get("message"))
+		if subject == "" || message == "" {
+			render.Error(w, r, apperrors.BadRequest(nil, "An email needs a subject and a message"))
+			return
+		}
+		results, err = repo.emailReservations(ids, subject, message)
+	default:
+		render.Error(w, r, apperrors.BadRequest(nil, "Invalid bulk action"))
+		return
+	}
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+		}
+	}
+
+	stringMap := make(map[string]string)
+	stringMap["type"] = tp
+	stringMap["done"] = bulkDone[action]
+
+	intMap := make(map[string]int)
+	intMap["succeeded"] = len(results) - failed
+	intMap["failed"] = failed
+
+	data := make(map[string]interface{})
+	data["results"] = results
+
+	render.Template(w, r, "admin-bulk-results.page.html", &models.TemplateData{
+		StringMap: stringMap,
+		IntMap:    intMap,
+		Data:      data,
+	})
+}
+
+// bulkIDs parses the checked reservation ids, dropping duplicates
+func bulkIDs(values []string) ([]int, error) {
+	var ids []int
+	seen := make(map[int]bool)
+	for _, v := range values {
+		id, err := strconv.Atoi(v)
+		if err != nil || id < 1 {
+			return nil, apperrors.BadRequest(err, "Invalid reservation ID")
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil, apperrors.BadRequest(nil, "Select at least one reservation")
+	}
+	if len(ids) > maxBulkReservations {
+		return nil, apperrors.BadRequest(nil, "At most %d reservations can be changed at once", maxBulkReservations)
+	}
+
+	return ids, nil
+}
+
+// lookupReservations returns the result of looking up each reservation, a missing one fails only its own result
+func (repo *Repository) lookupReservations(ids []int) ([]models.BulkResult, error) {
+	results := make([]models.BulkResult, 0, len(ids))
+	for _, id := range ids {
+		result := models.BulkResult{ReservationID: id}
+		result.Reservation, result.Err = repo.DB.GetReservatioByID(id)
+		if result.Err != nil && !errors.Is(result.Err, sql.ErrNoRows) {
+			return nil, result.Err
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// emailReservations queues the admin's message to the customer of each reservation, the mail-retry job sends
+// them in the background
+func (repo *Repository) emailReservations(ids []int, subject, message string) ([]models.BulkResult, error) {
+	results, err := repo.lookupReservations(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	body := strings.ReplaceAll(html.EscapeString(message), "\n", "<br>")
+	for i, result := range results {
+		if result.Err != nil {
+			continue
+		}
+		_, results[i].Err = repo.DB.QueueMail(&models.QueuedMail{
+			Mail: models.MailData{
+				To:       result.Reservation.Email,
+				From:     "kaito@laptop-rental.com",
+				Subject:  subject,
+				Content:  fmt.Sprintf("Dear %s,<br><br>%s", html.EscapeString(result.Reservation.FirstName), body),
+				Template: "basic.email.html",
+			},
+			Status:        models.MailPending,
+			NextAttemptAt: time.Now(),
+		})
+	}
+
+	return results, nil
+}
+
+// exportReservations downloads the reservations as CSV, skipping the ones that no longer exist
+func (repo *Repository) exportReservations(w http.ResponseWriter, r *http.Request, ids []int) {
+	results, err := repo.lookupReservations(ids)
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="reservations-%s.csv"`,
+		today().Format(dates.Layout)))
+
+	cw := csvsafe.NewWriter(w)
+	cw.Write([]string{"id", "first_name", "last_name", "email", "phone", "laptop", "start_date", "end_date", "processed", "created_at"})
+	for _, result := range results {
+		if result.Err != nil {
+			continue
+		}
+		res := result.Reservation
+		cw.Write([]string{
+			strconv.Itoa(res.ID),
+			res.FirstName,
+			res.LastName,
+			res.Email,
+			res.Phone,
+			res.Laptop.LaptopName,
+			res.StartDate.Format(dates.Layout),
+			res.EndDate.Format(dates.Layout),
+			strconv.Itoa(res.Processed),
+			res.CreatedAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+	cw.Flush()
+	if err = cw.Error(); err != nil {
+		repo.App.ErrorLog.Println(err)
+	}
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+)
+
+func TestPostAdminBulkReservations_Process(t *testing.T) {
+	repo := newListRepo(t)
+
+	data := url.Values{"action": {"process"}, "type": {"new"}, "id": {"1", "999", "3", "1"}}
+	rr := postForm(repo.PostAdminBulkReservations, "/admin/reservations/bulk", data)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected the results page, got %d", rr.Code)
+	}
+	body := rr.Body.String()
+	for _, s := range []string{"2 succeeded, 1 failed", "Customer01", "Customer03", "Marked as processed", "Reservation not found", `href="/admin/reservations-new"`} {
+		if !strings.Contains(body, s) {
+			t.Errorf("expected the results page to contain %q", s)
+		}
+	}
+
+	for _, id := range []int{1, 3} {
+		res, _ := repo.DB.GetReservatioByID(id)
+		if res.Processed != 1 {
+			t.Errorf("reservation %d wasn't processed", id)
+		}
+	}
+	if res, _ := repo.DB.GetReservatioByID(5); res.Processed != 0 {
+		t.Error("unchecked reservation 5 was processed")
+	}
+}
+
+func TestPostAdminBulkReservations_Cancel(t *testing.T) {
+	repo := newListRepo(t)
+
+	data := url.Values{"action": {"cancel"}, "id": {"2", "4"}}
+	rr := postForm(repo.PostAdminBulkReservations, "/admin/reservations/bulk", data)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "2 succeeded, 0 failed") {
+		t.Fatalf("expected the results page, got %d", rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), `href="/admin/reservations-all"`) {
+		t.Error("expected a link back to all reservations")
+	}
+
+	for _, id := range []int{2, 4} {
+		if _, err := repo.DB.GetReservatioByID(id); err == nil {
+			t.Errorf("reservation %d wasn't cancelled", id)
+		}
+	}
+}
+
+func TestPostAdminBulkReservations_Export(t *testing.T) {
+	repo := newListRepo(t)
+
+	// a name a spreadsheet would run as a formula
+	id, _ := repo.DB.InsertReservation(&models.Reservation{
+		FirstName: `=HYPERLINK("https://evil.example.com")`,
+		LastName:  "@Smith",
+		StartDate: time.Date(2099, 3, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2099, 3, 1, 0, 0, 0, 0, time.UTC),
+		LaptopID:  1,
+	})
+
+	data := url.Values{"action": {"export"}, "id": {"7", "999", "8", strconv.Itoa(id)}}
+	rr := postForm(repo.PostAdminBulkReservations, "/admin/reservations/bulk", data)
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "text/csv; charset=utf-8" {
+		t.Fatalf("expected a CSV, got %d %s", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	if !strings.HasPrefix(rr.Header().Get("Content-Disposition"), `attachment; filename="reservations-`) {
+		t.Errorf("unexpected Content-Disposition %q", rr.Header().Get("Content-Disposition"))
+	}
+
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected a header and 3 reservations, got %q", lines)
+	}
+	if !strings.HasPrefix(lines[1], "7,John,Customer07,customer07@example.com,,Alienware M15 R2,2099-01-08,2099-01-08,0,") {
+		t.Errorf("unexpected row %q", lines[1])
+	}
+	if !strings.HasPrefix(lines[2], "8,John,Customer08,") {
+		t.Errorf("unexpected row %q", lines[2])
+	}
+	if !strings.HasPrefix(lines[3], strconv.Itoa(id)+`,"'=HYPERLINK(""https://evil.example.com"")",'@Smith,`) {
+		t.Errorf("expected the formulas kept as text, got %q", lines[3])
+	}
+}
+
+func TestPostAdminBulkReservations_Email(t *testing.T) {
+	repo := newListRepo(t)
+	mailApp := app
+	mailApp.MailChan = make(chan models.MailData, 10)
+	repo.App = &mailApp
+	now := time.Now()
+
+	data := url.Values{
+		"action":  {"email"},
+		"id":      {"10", "999", "11"},
+		"subject": {"Pickup hours"},
+		"message": {"We open at 10.\n<b>Thanks</b>"},
+	}
+	rr := postForm(repo.PostAdminBulkReservations, "/admin/reservations/bulk", data)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "2 succeeded, 1 failed") {
+		t.Fatalf("expected the results page, got %d", rr.Code)
+	}
+
+	// the mails are queued for the mail-retry job instead of being sent during the request
+	if len(mailApp.MailChan) != 0 {
+		t.Errorf("expected no mail sent during the request, got %d", len(mailApp.MailChan))
+	}
+	queued, err := repo.DB.GetDueMail(now.Add(time.Second), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mails []models.MailData
+	for _, q := range queued {
+		mails = append(mails, q.Mail)
+	}
+	if len(mails) != 2 || mails[0].To != "customer10@example.com" || mails[1].To != "customer11@example.com" {
+		t.Fatalf("unexpected mails %+v", mails)
+	}
+	if mails[0].Subject != "Pickup hours" || !strings.Contains(mails[0].Content, "We open at 10.<br>&lt;b&gt;Thanks&lt;/b&gt;") {
+		t.Errorf("unexpected mail %+v", mails[0])
+	}
+}
+
+func TestPostAdminBulkReservations_BadRequest(t *testing.T) {
+	repo := newListRepo(t)
+
+	var tooMany []string
+	for i := 1; i <= maxBulkReservations+1; i++ {
+		tooMany = append(tooMany, strconv.Itoa(i))
+	}
+
+	tests := []struct {
+		name string
+		data url.Values
+	}{
+		{"no reservations", url.Values{"action": {"process"}}},
+		{"invalid id", url.Values{"action": {"process"}, "id": {"one"}}},
+		{"too many reservations", url.Values{"action": {"process"}, "id": tooMany}},
+		{"invalid action", url.Values{"action": {"refund"}, "id": {"1"}}},
+		{"email without a subject", url.Values{"action": {"email"}, "id": {"1"}, "message": {"hello"}}},
+	}
+
+	for _, test := range tests {
+		rr := postForm(repo.PostAdminBulkReservations, "/admin/reservations/bulk", test.data)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected %d, got %d", test.name, http.StatusBadRequest, rr.Code)
+		}
+	}
+
+	if res, _ := repo.DB.GetReservatioByID(1); res.Processed != 0 {
+		t.Error("a rejected bulk action processed reservation 1")
+	}
+}
+
+func TestAdminAllReservations_BulkForm(t *testing.T) {
+	repo := newListRepo(t)
+
+	rr := getList(repo.AdminAllReservations, "/admin/reservations-all")
+	body := rr.Body.String()
+	for _, s := range []string{`action="/admin/reservations/bulk"`, `name="csrf_token"`, `name="id" value="60" form="bulk"`, `id="bulk-all"`} {
+		if !strings.Contains(body, s) {
+			t.Errorf("expected the list to contain %q", s)
+		}
+	}
+}
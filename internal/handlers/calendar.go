@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/apperrors"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/dates"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/i18n"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/render"
+)
+
+// calendar day statuses, customers only see whether a day is free, never by whom it is taken
+const (
+	dayAvailable = "available"
+	dayBooked    = "booked"
+	dayBlocked   = "blocked"
+	dayPast      = "past"
+)
+
+// calendarResponse is the JSON variant of the laptop calendar
+type calendarResponse struct {
+	OK            bool                 `json:"ok"`
+	Message       string               `json:"message,omitempty"`
+	LaptopID      int                  `json:"laptop_id"`
+	LaptopName    string               `json:"laptop_name"`
+	Month         string               `json:"month"`
+	PreviousMonth string               `json:"previous_month"`
+	NextMonth     string               `json:"next_month"`
+	Days          []models.CalendarDay `json:"days"`
+}
+
+// LaptopCalendar shows the availability of a laptop for one month
+func (repo *Repository) LaptopCalendar(w http.ResponseWriter, r *http.Request) {
+	laptop, month, err := repo.calendarRequest(r)
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+
+	days, err := repo.laptopCalendar(laptop, month)
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+
+	// pad the first week so that days line up under their weekday, weeks start on Sunday
+	var weeks [][]models.CalendarDay
+	week := make([]models.CalendarDay, int(month.Weekday()))
+	for _, day := range days {
+		week = append(week, day)
+		if len(week) == 7 {
+			weeks = append(weeks, week)
+			week = nil
+		}
+	}
+	if len(week) > 0 {
+		weeks = append(weeks, append(week, make([]models.CalendarDay, 7-len(week))...))
+	}
+
+	data := make(map[string]interface{})
+	data["laptop"] = laptop
+	data["weeks"] = weeks
+
+	stringMap := make(map[string]string)
+	stringMap["month"] = i18n.FormatDate(i18n.FromContext(r.Context()), month, "January 2006")
+	stringMap["last_month"] = month.AddDate(0, -1, 0).Format("01")
+	stringMap["last_month_year"] = month.AddDate(0, -1, 0).Format("2006")
+	stringMap["next_month"] = month.AddDate(0, 1, 0).Format("01")
+	stringMap["next_month_year"] = month.AddDate(0, 1, 0).Format("2006")
+
+	render.Template(w, r, "laptop-calendar.page.html", &models.TemplateData{
+		StringMap: stringMap,
+		Data:      data,
+	})
+}
+
+// LaptopCalendarJSON returns the availability of a laptop for one month as JSON
+func (repo *Repository) LaptopCalendarJSON(w http.ResponseWriter, r *http.Request) {
+	laptop, month, err := repo.calendarRequest(r)
+	if err != nil {
+		render.ErrorJSON(w, r, err)
+		return
+	}
+
+	days, err := repo.laptopCalendar(laptop, month)
+	if err != nil {
+		render.ErrorJSON(w, r, err)
+		return
+	}
+
+	writeJSON(w, calendarResponse{
+		OK:            true,
+		LaptopID:      laptop.ID,
+		LaptopName:    laptop.LaptopName,
+		Month:         month.Format("2006-01"),
+		PreviousMonth: month.AddDate(0, -1, 0).Format("2006-01"),
+		NextMonth:     month.AddDate(0, 1, 0).Format("2006-01"),
+		Days:          days,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	out, _ := json.MarshalIndent(v, "", "     ")
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(out)
+}
+
+// calendarRequest returns the laptop of /laptops/{id}/calendar and the first day of the month given by
+// the y and m query parameters, the current month by default
+func (repo *Repository) calendarRequest(r *http.Request) (models.Laptop, time.Time, error) {
+	exploded := strings.Split(r.URL.Path, "/")
+	if len(exploded) < 3 {
+		return models.Laptop{}, time.Time{}, apperrors.BadRequest(nil, "Invalid Laptop ID")
+	}
+	laptopID, err := strconv.Atoi(exploded[2])
+	if err != nil {
+		return models.Laptop{}, time.Time{}, apperrors.BadRequest(err, "Invalid Laptop ID")
+	}
+
+	year, month, _ := dates.Today().Date()
+	if r.URL.Query().Get("y") != "" {
+		year, err = strconv.Atoi(r.URL.Query().Get("y"))
+		if err != nil {
+			return models.Laptop{}, time.Time{}, apperrors.BadRequest(err, "Invalid year")
+		}
+		m, err := strconv.Atoi(r.URL.Query().Get("m"))
+		if err != nil || m < 1 || m > 12 {
+			return models.Laptop{}, time.Time{}, apperrors.BadRequest(err, "Invalid month")
+		}
+		month = time.Month(m)
+	}
+
+	laptop, err := repo.DB.GetLaptopByID(laptopID)
+	if err == sql.ErrNoRows {
+		return laptop, time.Time{}, apperrors.NotFound(err, "Laptop not found")
+	}
+	if err != nil {
+		return laptop, time.Time{}, err
+	}
+
+	return laptop, time.Date(year, month, 1, 0, 0, 0, 0, time.UTC), nil
+}
+
+// laptopCalendar returns the status of every day of the month starting at firstDay,
+// days up to today can't be rented anymore, like in the date pickers
+func (repo *Repository) laptopCalendar(laptop models.Laptop, firstDay time.Time) ([]models.CalendarDay, error) {
+	lastDay := firstDay.AddDate(0, 1, -1)
+
+	turnaround := laptop.Turnaround()
+	restrictions, err := repo.DB.GetLaptopRestrictionsByDate(laptop.ID, firstDay.AddDate(0, 0, -turnaround), lastDay.AddDate(0, 0, turnaround))
+	if err != nil {
+		return nil, err
+	}
+
+	status := make(map[string]string)
+	for _, lr := range restrictions {
+		if lr.RestrictionID == models.RestrictionBlock {
+			for d := lr.StartDate; !d.After(lr.EndDate); d = d.AddDate(0, 0, 1) {
+				status[d.Format("2006-01-02")] = dayBlocked
+			}
+			continue
+		}
+		// the turnaround around reservations and holds can't be rented either, but isn't shown as booked
+		for d := lr.StartDate.AddDate(0, 0, -turnaround); !d.After(lr.EndDate.AddDate(0, 0, turnaround)); d = d.AddDate(0, 0, 1) {
+			if d.Before(lr.StartDate) || d.After(lr.EndDate) {
+				if _, ok := status[d.Format("2006-01-02")]; !ok {
+					status[d.Format("2006-01-02")] = dayBlocked
+				}
+				continue
+			}
+			status[d.Format("2006-01-02")] = dayBooked
+		}
+	}
+
+	today := dates.Today().Format(dates.Layout)
+
+	var days []models.CalendarDay
+	for d := firstDay; !d.After(lastDay); d = d.AddDate(0, 0, 1) {
+		date := d.Format("2006-01-02")
+		s, ok := status[date]
+		if !ok {
+			s = dayAvailable
+		}
+		if date <= today {
+			s = dayPast
+		}
+		days = append(days, models.CalendarDay{
+			Date:   date,
+			Day:    d.Day(),
+			Status: s,
+		})
+	}
+
+	return days, nil
+}
@@ -0,0 +1,369 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/database"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+)
+
+// newCalendarRepo returns a repository on the in-memory database with a reservation
+// from 2099-01-10 to 2099-01-12 and a block on 2099-01-20 for laptop 1
+func newCalendarRepo(t *testing.T) *Repository {
+	db := database.NewMemory(&app)
+
+	id, err := db.InsertReservation(&models.Reservation{
+		FirstName: "John",
+		LastName:  "Smith",
+		Email:     "john@smith.com",
+		StartDate: time.Date(2099, 1, 10, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2099, 1, 12, 0, 0, 0, 0, time.UTC),
+		LaptopID:  1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.InsertLaptopRestriction(&models.LaptopRestriction{
+		StartDate:     time.Date(2099, 1, 10, 0, 0, 0, 0, time.UTC),
+		EndDate:       time.Date(2099, 1, 12, 0, 0, 0, 0, time.UTC),
+		LaptopID:      1,
+		ReservationID: id,
+		RestrictionID: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.InsertOneDayBlockByLaptopID(1, time.Date(2099, 1, 20, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &Repository{App: &app, DB: db}
+}
+
+func TestLaptopCalendarJSON(t *testing.T) {
+	repo := newCalendarRepo(t)
+
+	req, _ := http.NewRequest("GET", "/laptops/1/calendar.json?y=2099&m=1", nil)
+	req = req.WithContext(getCtx(req))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(repo.LaptopCalendarJSON).ServeHTTP(rr, req)
+
+	if strings.Contains(rr.Body.String(), "Smith") || strings.Contains(rr.Body.String(), "john@smith.com") {
+		t.Error("calendar exposes customer details")
+	}
+
+	var resp calendarResponse
+	err := json.Unmarshal(rr.Body.Bytes(), &resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.OK || resp.LaptopName != "Alienware M15 R2" || resp.Month != "2099-01" || resp.PreviousMonth != "2098-12" || resp.NextMonth != "2099-02" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if len(resp.Days) != 31 {
+		t.Fatalf("expected 31 days, got %d", len(resp.Days))
+	}
+
+	expected := map[int]string{9: dayAvailable, 10: dayBooked, 12: dayBooked, 13: dayAvailable, 20: dayBlocked}
+	for day, status := range expected {
+		if resp.Days[day-1].Status != status {
+			t.Errorf("2099-01-%02d: expected %s, got %s", day, status, resp.Days[day-1].Status)
+		}
+	}
+
+	var errorTests = []struct {
+		name   string
+		path   string
+		status int
+	}{
+		{"invalid laptop id", "/laptops/abc/calendar.json", http.StatusBadRequest},
+		{"missing laptop", "/laptops/99/calendar.json", http.StatusNotFound},
+		{"invalid month", "/laptops/1/calendar.json?y=2099&m=13", http.StatusBadRequest},
+		{"invalid year", "/laptops/1/calendar.json?y=abc&m=1", http.StatusBadRequest},
+	}
+	for _, test := range errorTests {
+		req, _ := http.NewRequest("GET", test.path, nil)
+		req = req.WithContext(getCtx(req))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(repo.LaptopCalendarJSON).ServeHTTP(rr, req)
+
+		var resp calendarResponse
+		_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+		if resp.OK || resp.Message == "" {
+			t.Errorf("%s: expected an error response, got %+v", test.name, resp)
+		}
+		if rr.Code != test.status {
+			t.Errorf("%s: expected code %d, got %d", test.name, test.status, rr.Code)
+		}
+	}
+}
+
+func TestLaptopCalendar(t *testing.T) {
+	repo := newCalendarRepo(t)
+
+	req, _ := http.NewRequest("GET", "/laptops/1/calendar?y=2099&m=1", nil)
+	req = req.WithContext(getCtx(req))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(repo.LaptopCalendar).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected code %d, got %d", http.StatusOK, rr.Code)
+	}
+	body := rr.Body.String()
+	for _, s := range []string{`class="booked" data-date="2099-01-10"`, `class="blocked" data-date="2099-01-20"`, `class="available" data-date="2099-01-31"`} {
+		if !strings.Contains(body, s) {
+			t.Errorf("calendar doesn't contain %s", s)
+		}
+	}
+	if strings.Contains(body, "Smith") {
+		t.Error("calendar exposes customer details")
+	}
+	// 2099-01-01 is a Thursday, the first week has four empty days and the 31st is a Saturday
+	if !strings.Contains(body, "January 2099") || strings.Count(body, "<td></td>") != 4 {
+		t.Errorf("unexpected calendar layout, %d empty days", strings.Count(body, "<td></td>"))
+	}
+
+	req, _ = http.NewRequest("GET", "/laptops/99/calendar", nil)
+	req = req.WithContext(getCtx(req))
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(repo.LaptopCalendar).ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("missing laptop: expected code %d, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestLaptopCalendar_Past(t *testing.T) {
+	repo := newCalendarRepo(t)
+
+	days, err := repo.laptopCalendar(models.Laptop{ID: 1}, time.Date(2000, 2, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days) != 29 {
+		t.Errorf("expected 29 days in February 2000, got %d", len(days))
+	}
+	for _, day := range days {
+		if day.Status != dayPast {
+			t.Errorf("%s: expected %s, got %s", day.Date, dayPast, day.Status)
+		}
+	}
+}
+
+func TestLaptopCalendar_Turnaround(t *testing.T) {
+	repo := newCalendarRepo(t)
+	err := repo.DB.UpdateLaptopBuffers(1, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	laptop, _ := repo.DB.GetLaptopByID(1)
+
+	days, err := repo.laptopCalendar(laptop, time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the reservation from the 10th to the 12th keeps two days free on each side, the block on the 20th none
+	expected := map[int]string{
+		7: dayAvailable, 8: dayBlocked, 9: dayBlocked, 10: dayBooked, 12: dayBooked,
+		13: dayBlocked, 14: dayBlocked, 15: dayAvailable, 20: dayBlocked, 21: dayAvailable,
+	}
+	for _, day := range days {
+		if status, ok := expected[day.Day]; ok && day.Status != status {
+			t.Errorf("%s: expected %s, got %s", day.Date, status, day.Status)
+		}
+	}
+}
+
+func TestAdminReservationsCalendar_Turnaround(t *testing.T) {
+	repo := newCalendarRepo(t)
+	err := repo.DB.UpdateLaptopBuffers(1, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest("GET", "/admin/reservations-calendar?y=2099&m=1", nil)
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(repo.AdminReservationsCalendar).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected code %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	// like the availability checks, the reservation from the 10th to the 12th keeps the whole turnaround of
+	// three days free on each side
+	body := rr.Body.String()
+	if n := strings.Count(body, `class="text-center turnaround"`); n != 6 {
+		t.Errorf("expected 6 turnaround days, got %d", n)
+	}
+	for _, day := range []string{"2099-01-7", "2099-01-15"} {
+		available, _ := repo.DB.SearchAvailabilityByDatesByLaptopID(dayOf(day), dayOf(day), 1)
+		if available {
+			t.Errorf("%s is shaded but available", day)
+		}
+	}
+	for _, day := range []string{"2099-01-6", "2099-01-16"} {
+		available, _ := repo.DB.SearchAvailabilityByDatesByLaptopID(dayOf(day), dayOf(day), 1)
+		if !available {
+			t.Errorf("%s is not shaded but unavailable", day)
+		}
+	}
+	if !strings.Contains(body, "Turnaround: 1 day(s) before and 2 day(s) after, so 3 day(s)") {
+		t.Error("the buffers of the laptop are not shown")
+	}
+
+	// the turnaround isn't a block, only the block on the 20th can be removed
+	if n := strings.Count(rr.Body.String(), `name="remove_block_`); n != 1 {
+		t.Errorf("expected 1 block to remove, got %d", n)
+	}
+}
+
+// dayOf parses a date of the admin calendar like 2099-01-7
+func dayOf(s string) time.Time {
+	t, _ := time.Parse("2006-01-2", s)
+	return t
+}
+
+// postCalendar saves the admin calendar of January 2099 and returns the response and the error flashed
+func postCalendar(repo *Repository, data url.Values) (*httptest.ResponseRecorder, string) {
+	data.Set("y", "2099")
+	data.Set("m", "1")
+	req, _ := http.NewRequest("POST", "/admin/reservations-calendar", strings.NewReader(data.Encode()))
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(repo.PostAdminReservationsCalendar).ServeHTTP(rr, req)
+	return rr, app.Session.PopString(ctx, "error")
+}
+
+func TestPostAdminReservationsCalendar_Blocks(t *testing.T) {
+	repo := newCalendarRepo(t)
+
+	rr := getList(repo.AdminReservationsCalendar, "/admin/reservations-calendar?y=2099&m=1")
+	if !strings.Contains(rr.Body.String(), `name="remove_block_2_2099-01-20" value="1"`) {
+		t.Fatal("expected the block on the 20th to be removable at version 1")
+	}
+
+	rr, flash := postCalendar(repo, url.Values{"remove_block_2_2099-01-20": {"1"}, "add_block_2_2099-01-15": {"1"}})
+	if rr.Code != http.StatusSeeOther || flash != "" {
+		t.Fatalf("expected the changes to be saved, got %d %q", rr.Code, flash)
+	}
+	if loc := rr.Header().Get("Location"); loc != "/admin/reservations-calendar?y=2099&m=1" {
+		t.Errorf("unexpected redirect to %s", loc)
+	}
+	blocks, _ := repo.DB.GetBlocksByDate(time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2099, 1, 31, 0, 0, 0, 0, time.UTC))
+	if len(blocks) != 1 || blocks[0].LaptopID != 2 || blocks[0].StartDate.Day() != 15 {
+		t.Errorf("expected only the block of laptop 2 on the 15th, got %+v", blocks)
+	}
+}
+
+func TestPostAdminReservationsCalendar_SplitBlock(t *testing.T) {
+	repo := newCalendarRepo(t)
+	// a block of laptop 2 running into February
+	err := repo.DB.InsertBlockByLaptopID(2, time.Date(2099, 1, 28, 0, 0, 0, 0, time.UTC), time.Date(2099, 2, 3, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks, _ := repo.DB.GetBlocksByDate(time.Date(2099, 1, 28, 0, 0, 0, 0, time.UTC), time.Date(2099, 1, 28, 0, 0, 0, 0, time.UTC))
+	if len(blocks) != 1 {
+		t.Fatalf("expected the block, got %+v", blocks)
+	}
+	id := blocks[0].ID
+
+	// every day shown in January can be unblocked on its own
+	rr := getList(repo.AdminReservationsCalendar, "/admin/reservations-calendar?y=2099&m=1")
+	if n := strings.Count(rr.Body.String(), fmt.Sprintf(`name="remove_block_%d_`, id)); n != 4 {
+		t.Errorf("expected a box for each of the 4 days in January, got %d", n)
+	}
+
+	rr, flash := postCalendar(repo, url.Values{fmt.Sprintf("remove_block_%d_2099-01-29", id): {"1"}})
+	if rr.Code != http.StatusSeeOther || flash != "" {
+		t.Fatalf("expected the day to be unblocked, got %d %q", rr.Code, flash)
+	}
+
+	// the 28th and the days from the 30th into February stay blocked
+	blocks, _ = repo.DB.GetBlocksByDate(time.Date(2099, 1, 21, 0, 0, 0, 0, time.UTC), time.Date(2099, 2, 28, 0, 0, 0, 0, time.UTC))
+	var got []string
+	for _, b := range blocks {
+		got = append(got, b.StartDate.Format("01-02")+"/"+b.EndDate.Format("01-02"))
+	}
+	sort.Strings(got)
+	if strings.Join(got, " ") != "01-28/01-28 01-30/02-03" {
+		t.Errorf("expected the block split around the 29th, got %v", got)
+	}
+}
+
+func TestPostAdminReservationsCalendar_Stale(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(repo *Repository) error
+		data   url.Values
+	}{
+		{
+			"block moved by someone else",
+			func(repo *Repository) error {
+				_, err := repo.DB.MoveBlock(2, 1, time.Date(2099, 1, 21, 0, 0, 0, 0, time.UTC), time.Date(2099, 1, 21, 0, 0, 0, 0, time.UTC))
+				return err
+			},
+			url.Values{"remove_block_2_2099-01-20": {"1"}, "add_block_2_2099-01-15": {"1"}},
+		},
+		{
+			"block removed by someone else",
+			func(repo *Repository) error { return repo.DB.DeleteBlockByID(2) },
+			url.Values{"remove_block_2_2099-01-20": {"1"}, "add_block_2_2099-01-15": {"1"}},
+		},
+		{
+			"day blocked by someone else",
+			func(repo *Repository) error {
+				return repo.DB.InsertOneDayBlockByLaptopID(1, time.Date(2099, 1, 25, 0, 0, 0, 0, time.UTC))
+			},
+			url.Values{"add_block_1_2099-01-25": {"1"}, "add_block_2_2099-01-15": {"1"}},
+		},
+	}
+
+	for _, test := range tests {
+		repo := newCalendarRepo(t)
+		err := test.change(repo)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr, flash := postCalendar(repo, test.data)
+		if rr.Code != http.StatusSeeOther || !strings.Contains(flash, "changed by someone else") {
+			t.Errorf("%s: expected the calendar again with an error, got %d %q", test.name, rr.Code, flash)
+		}
+		// nothing is saved, not even the changes that were still possible
+		blocks, _ := repo.DB.GetBlocksByDate(time.Date(2099, 1, 15, 0, 0, 0, 0, time.UTC), time.Date(2099, 1, 15, 0, 0, 0, 0, time.UTC))
+		if len(blocks) != 0 {
+			t.Errorf("%s: a change was saved", test.name)
+		}
+	}
+}
+
+func TestPostAdminReservationsCalendar_Reservation(t *testing.T) {
+	repo := newCalendarRepo(t)
+
+	// restriction 1 is the reservation, it isn't removed as a block
+	rr, flash := postCalendar(repo, url.Values{"remove_block_1_2099-01-10": {"1"}})
+	if rr.Code != http.StatusSeeOther || flash == "" {
+		t.Fatalf("expected an error, got %d %q", rr.Code, flash)
+	}
+	if _, err := repo.DB.GetReservatioByID(1); err != nil {
+		t.Error(err)
+	}
+	restrictions, _ := repo.DB.GetLaptopRestrictionsByDate(1, time.Date(2099, 1, 10, 0, 0, 0, 0, time.UTC), time.Date(2099, 1, 10, 0, 0, 0, 0, time.UTC))
+	if len(restrictions) != 1 {
+		t.Error("the reservation's restriction was removed")
+	}
+}
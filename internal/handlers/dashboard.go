@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/dates"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/render"
+)
+
+const (
+	// dashboardTrendDays is how many days back the pickups chart goes, today included
+	dashboardTrendDays = 30
+	// dashboardBlocks is how many upcoming blocks the dashboard lists
+	dashboardBlocks = 10
+)
+
+// today returns the current calendar day, tests replace it
+var today = dates.Today
+
+// AdminDashbord shows the reservations to process, today's pickups and returns, how much each laptop
+// was rented over the last 30 and 90 days, the upcoming blocks and the pickups per day
+func (repo *Repository) AdminDashbord(w http.ResponseWriter, r *http.Request) {
+	day := today()
+
+	pending, err := repo.DB.CountNewReservations()
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+	pickups, err := repo.DB.GetReservationsStartingOn(day)
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+	returns, err := repo.DB.GetReservationsEndingOn(day)
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+	utilization30, err := repo.DB.GetLaptopUtilization(day.AddDate(0, 0, -29), day)
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+	utilization90, err := repo.DB.GetLaptopUtilization(day.AddDate(0, 0, -89), day)
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+	blocks, err := repo.DB.GetUpcomingBlocks(day, dashboardBlocks)
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+	trendStart := day.AddDate(0, 0, 1-dashboardTrendDays)
+	counts, err := repo.DB.CountReservationsByStartDate(trendStart, day)
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+
+	// the charts get their labels and values as JSON, utilization30 and utilization90 list the same laptops
+	var laptopNames []string
+	var percent30, percent90 []int
+	for i, u := range utilization30 {
+		laptopNames = append(laptopNames, u.Laptop.LaptopName)
+		percent30 = append(percent30, u.Percent())
+		if i < len(utilization90) {
+			percent90 = append(percent90, utilization90[i].Percent())
+		}
+	}
+	trendLabels, trendCounts := dailyTrend(trendStart, dashboardTrendDays, counts)
+
+	intMap := make(map[string]int)
+	intMap["pending"] = pending
+	intMap["pickups"] = len(pickups)
+	intMap["returns"] = len(returns)
+
+	data := make(map[string]interface{})
+	data["today"] = day
+	data["pickups"] = pickups
+	data["returns"] = returns
+	data["utilization30"] = utilization30
+	data["utilization90"] = utilization90
+	data["blocks"] = blocks
+	data["laptop_names"] = laptopNames
+	data["percent30"] = percent30
+	data["percent90"] = percent90
+	data["trend_labels"] = trendLabels
+	data["trend_counts"] = trendCounts
+
+	render.Template(w, r, "admin-dashboard.page.html", &models.TemplateData{
+		IntMap: intMap,
+		Data:   data,
+	})
+}
+
+// dailyTrend returns a label and a count for each of the days from start, the days missing from counts count 0
+func dailyTrend(start time.Time, days int, counts []models.DailyCount) ([]string, []int) {
+	byDate := make(map[string]int)
+	for _, c := range counts {
+		byDate[c.Date.Format(dates.Layout)] += c.Count
+	}
+
+	labels := make([]string, days)
+	values := make([]int, days)
+	for i := range labels {
+		date := start.AddDate(0, 0, i)
+		labels[i] = date.Format("01-02")
+		values[i] = byDate[date.Format(dates.Layout)]
+	}
+
+	return labels, values
+}
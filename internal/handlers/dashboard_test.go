@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdminDashbord(t *testing.T) {
+	repo := newCalendarRepo(t)
+
+	defer func(f func() time.Time) { today = f }(today)
+	today = func() time.Time { return time.Date(2099, 1, 10, 0, 0, 0, 0, time.UTC) }
+
+	req, _ := http.NewRequest("GET", "/admin/dashboard", nil)
+	req = req.WithContext(getCtx(req))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(repo.AdminDashbord).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	body := rr.Body.String()
+	for _, expected := range []string{
+		// the reservation is unprocessed and picked up today, nothing is returned today
+		`<h3 id="kpi-pending">1</h3>`,
+		`<h3 id="kpi-pickups">1</h3>`,
+		`<h3 id="kpi-returns">0</h3>`,
+		`/admin/reservations/all/1/show`,
+		// one of the last 30 and 90 days is rented
+		`3% (1/30 days)`,
+		`1% (1/90 days)`,
+		"2099-01-20",
+		`labels: ["Alienware M15 R2","Macbook Pro 15 inch"]`,
+		`data: [3,0]`,
+		`data: [0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,1]`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("dashboard is missing %q", expected)
+		}
+	}
+}
+
+func TestAdminDashbord_Empty(t *testing.T) {
+	repo := newCalendarRepo(t)
+
+	defer func(f func() time.Time) { today = f }(today)
+	today = func() time.Time { return time.Date(2099, 3, 1, 0, 0, 0, 0, time.UTC) }
+
+	req, _ := http.NewRequest("GET", "/admin/dashboard", nil)
+	req = req.WithContext(getCtx(req))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(repo.AdminDashbord).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	body := rr.Body.String()
+	if !strings.Contains(body, `<h3 id="kpi-pickups">0</h3>`) || !strings.Contains(body, "No upcoming blocks") {
+		t.Error("dashboard shows pickups or blocks on a day without any")
+	}
+}
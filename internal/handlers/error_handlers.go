@@ -3,14 +3,11 @@ package handlers
 import (
 	"net/http"
 
-	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/apperrors"
 	"github.com/kaitolucifer/go-laptop-rental-site/internal/render"
 )
 
+// NotFound answers requests for pages that don't exist
 func (repo *Repository) NotFound(w http.ResponseWriter, r *http.Request) {
-	stringMap := make(map[string]string)
-	stringMap["url"] = r.URL.Path
-	render.Template(w, r, "404.page.html", &models.TemplateData{
-		StringMap: stringMap,
-	})
+	render.Error(w, r, apperrors.NotFound(nil, "The requested URL %s was not found on this server", r.URL.Path))
 }
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+)
+
+// script is a reservation field that runs if a page doesn't escape it
+const script = `<script>alert("xss")</script>`
+
+// checkEscaped fails if body has script unescaped
+func checkEscaped(t *testing.T, page, body string) {
+	t.Helper()
+	if strings.Contains(body, script) || strings.Contains(body, `<script>alert(`) {
+		t.Errorf("%s renders a script tag from a reservation field", page)
+	}
+}
+
+func TestPostMakeReservation_EscapesFields(t *testing.T) {
+	repo := newCalendarRepo(t)
+	reservation := models.Reservation{
+		LaptopID:  1,
+		Laptop:    models.Laptop{ID: 1, LaptopName: "Alienware M15 R2"},
+		StartDate: time.Date(2099, 2, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2099, 2, 3, 0, 0, 0, 0, time.UTC),
+	}
+
+	// an invalid email sends the form back with the fields as they were typed
+	data := url.Values{
+		"start_date": {"2099-02-01"},
+		"end_date":   {"2099-02-03"},
+		"first_name": {script},
+		"last_name":  {script},
+		"email":      {`"><script>alert("xss")</script>`},
+		"phone":      {script},
+		"laptop_id":  {"1"},
+	}
+	req, _ := http.NewRequest("POST", "/make-reservation", strings.NewReader(data.Encode()))
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	app.Session.Put(ctx, "reservation", reservation)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(repo.PostMakeReservation).ServeHTTP(rr, req)
+	checkEscaped(t, "make-reservation", rr.Body.String())
+	if !strings.Contains(rr.Body.String(), `value="&lt;script&gt;alert(&#34;xss&#34;)&lt;/script&gt;"`) {
+		t.Error("the typed first name is not kept, escaped, in the form")
+	}
+}
+
+func TestReservationSummary_EscapesFields(t *testing.T) {
+	reservation := models.Reservation{
+		FirstName: script,
+		LastName:  script,
+		Email:     script,
+		Phone:     script,
+		LaptopID:  1,
+		Laptop:    models.Laptop{ID: 1, LaptopName: script},
+		StartDate: time.Date(2099, 2, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2099, 2, 3, 0, 0, 0, 0, time.UTC),
+	}
+
+	req, _ := http.NewRequest("GET", "/reservation-summary", nil)
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+	app.Session.Put(ctx, "reservation", reservation)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Repo.ReservationSummary).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected the summary, got %d", rr.Code)
+	}
+	checkEscaped(t, "reservation-summary", rr.Body.String())
+	if !strings.Contains(rr.Body.String(), "&lt;script&gt;") {
+		t.Error("the names are missing from the summary")
+	}
+}
+
+func TestAdminShowReservation_EscapesFields(t *testing.T) {
+	repo := newCalendarRepo(t)
+	id, err := repo.DB.InsertReservation(&models.Reservation{
+		FirstName: script,
+		LastName:  script,
+		Email:     "john@smith.com",
+		Phone:     script,
+		StartDate: time.Date(2099, 2, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2099, 2, 3, 0, 0, 0, 0, time.UTC),
+		LaptopID:  1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the year and month of the calendar are echoed into the page's script
+	path := "/admin/reservations/cal/" + strconv.Itoa(id) + "/show?y=" + url.QueryEscape(`";alert("xss");"`) + "&m=" + url.QueryEscape(`</script><script>alert("xss")</script>`)
+	req, _ := http.NewRequest("GET", path, nil)
+	req = req.WithContext(getCtx(req))
+	req.RequestURI = path
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(repo.AdminShowReservation).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected the reservation, got %d", rr.Code)
+	}
+	body := rr.Body.String()
+	checkEscaped(t, "admin-show-reservation", body)
+	if strings.Contains(body, `y=";alert(`) {
+		t.Error("the year query parameter breaks out of its script string")
+	}
+}
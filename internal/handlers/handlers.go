@@ -1,20 +1,31 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/apperrors"
 	"github.com/kaitolucifer/go-laptop-rental-site/internal/config"
 	"github.com/kaitolucifer/go-laptop-rental-site/internal/database"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/dates"
 	"github.com/kaitolucifer/go-laptop-rental-site/internal/driver"
 	"github.com/kaitolucifer/go-laptop-rental-site/internal/forms"
-	"github.com/kaitolucifer/go-laptop-rental-site/internal/helpers"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/i18n"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/jobs"
 	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
 	"github.com/kaitolucifer/go-laptop-rental-site/internal/render"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/rules"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/sessions"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/suggest"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/waitlist"
 )
 
 // Repo the repository used by the handlers
@@ -24,13 +35,15 @@ var Repo *Repository
 type Repository struct {
 	App *config.AppConfig
 	DB  database.DBRepository
+	// Jobs runs the background jobs, nil where there are none like in tests
+	Jobs *jobs.Scheduler
 }
 
 // NewRepo creates a new repository
 func NewRepo(a *config.AppConfig, db *driver.DB) *Repository {
 	return &Repository{
 		App: a,
-		DB:  database.NewPostgres(db.Conn, a),
+		DB:  database.NewRepository(db, a),
 	}
 }
 
@@ -47,6 +60,13 @@ func NewHandlers(r *Repository) {
 	Repo = r
 }
 
+// newForm returns a form of the values of r whose error messages are in the language of the request
+func newForm(r *http.Request, data url.Values) *forms.Form {
+	form := forms.New(data)
+	form.Locale = i18n.FromContext(r.Context())
+	return form
+}
+
 // Home renders home page
 func (repo *Repository) Home(w http.ResponseWriter, r *http.Request) {
 	render.Template(w, r, "home.page.html", &models.TemplateData{})
@@ -73,18 +93,29 @@ func (repo *Repository) MakeReservation(w http.ResponseWriter, r *http.Request)
 
 	laptop, err := repo.DB.GetLaptopByID(res.LaptopID)
 	if err != nil {
-		repo.App.Session.Put(r.Context(), "error", "can't find laptop by ID")
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		render.Error(w, r, err)
 		return
 	}
 
 	res.Laptop.LaptopName = laptop.LaptopName
 
+	hold, err := repo.holdReservation(r.Context(), res)
+	if err == database.ErrNotAvailable {
+		repo.App.Session.Put(r.Context(), "error", "sorry, the laptop has just been taken for those dates")
+		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+
 	repo.App.Session.Put(r.Context(), "reservation", res)
 
 	stringMap := make(map[string]string)
 	stringMap["start_date"] = res.StartDate.Format("2006-01-02")
 	stringMap["end_date"] = res.EndDate.Format("2006-01-02")
+	stringMap["hold_until"] = dates.In(hold.ExpiresAt).Format("15:04 MST")
 
 	data := make(map[string]interface{})
 	data["reservation"] = res
@@ -106,12 +137,11 @@ func (repo *Repository) PostMakeReservation(w http.ResponseWriter, r *http.Reque
 
 	err := r.ParseForm()
 	if err != nil {
-		repo.App.Session.Put(r.Context(), "error", "can't parse form")
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		render.Error(w, r, apperrors.BadRequest(err, "The form could not be read"))
 		return
 	}
 
-	form := forms.New(r.PostForm)
+	form := newForm(r, r.PostForm)
 
 	form.Required("first_name", "last_name", "email", "start_date", "end_date")
 	form.IsAboveMinLength("first_name", 3)
@@ -121,21 +151,18 @@ func (repo *Repository) PostMakeReservation(w http.ResponseWriter, r *http.Reque
 
 	startDate, err := form.GetTimeObj("start_date")
 	if err != nil {
-		repo.App.Session.Put(r.Context(), "error", "can't get reservation from session")
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		render.Error(w, r, apperrors.BadRequest(err, "Invalid Start Date"))
 		return
 	}
 	endDate, err := form.GetTimeObj("end_date")
 	if err != nil {
-		repo.App.Session.Put(r.Context(), "error", "can't parse start date")
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		render.Error(w, r, apperrors.BadRequest(err, "Invalid End Date"))
 		return
 	}
 
 	laptopID, err := strconv.Atoi(r.Form.Get("laptop_id"))
 	if err != nil {
-		repo.App.Session.Put(r.Context(), "error", "invalid data")
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		render.Error(w, r, apperrors.BadRequest(err, "Invalid Laptop ID"))
 		return
 	}
 
@@ -143,6 +170,13 @@ func (repo *Repository) PostMakeReservation(w http.ResponseWriter, r *http.Reque
 	reservation.LastName = r.Form.Get("last_name")
 	reservation.Email = r.Form.Get("email")
 	reservation.Phone = r.Form.Get("phone")
+	reservation.Locale = form.Locale
+
+	err = repo.checkBookingRules(form, laptopID, startDate, endDate)
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
 
 	if !form.Valid() {
 		data := make(map[string]interface{})
@@ -150,8 +184,7 @@ func (repo *Repository) PostMakeReservation(w http.ResponseWriter, r *http.Reque
 		stringMap := make(map[string]string)
 		stringMap["start_date"] = r.Form.Get("start_date")
 		stringMap["end_date"] = r.Form.Get("end_date")
-		w.WriteHeader(http.StatusSeeOther)
-		render.Template(w, r, "make-reservation.page.html", &models.TemplateData{
+		render.TemplateStatus(w, r, http.StatusUnprocessableEntity, "make-reservation.page.html", &models.TemplateData{
 			Form:      form,
 			Data:      data,
 			StringMap: stringMap,
@@ -159,48 +192,47 @@ func (repo *Repository) PostMakeReservation(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	newReservationID, err := repo.DB.InsertReservation(&reservation)
-	if err != nil {
-		repo.App.Session.Put(r.Context(), "error", "can't insert reservation into the database")
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+	// the dates are held for the reservation in the session, the form must not change them
+	if laptopID != reservation.LaptopID ||
+		startDate.Format("2006-01-02") != reservation.StartDate.Format("2006-01-02") ||
+		endDate.Format("2006-01-02") != reservation.EndDate.Format("2006-01-02") {
+		render.Error(w, r, apperrors.BadRequest(nil, "The form doesn't match the laptop and dates you chose"))
 		return
 	}
 
-	restriction := models.LaptopRestriction{
-		StartDate:     startDate,
-		EndDate:       endDate,
-		LaptopID:      laptopID,
-		ReservationID: newReservationID,
-		RestrictionID: 1,
+	// turns the hold placed by MakeReservation into the reservation, or books the dates if they are still free
+	hold, _ := repo.App.Session.Get(r.Context(), "hold").(models.LaptopRestriction)
+	newReservationID, err := repo.DB.ConvertHold(hold.ID, &reservation)
+	if err == database.ErrNotAvailable {
+		repo.App.Session.Remove(r.Context(), "hold")
+		repo.App.Session.Put(r.Context(), "error", "sorry, your hold expired and the laptop has been taken for those dates")
+		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+		return
 	}
-	err = repo.DB.InsertLaptopRestriction(&restriction)
 	if err != nil {
-		repo.App.Session.Put(r.Context(), "error", "can't insert laptop restriction into the database")
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		render.Error(w, r, err)
 		return
 	}
+	repo.App.Session.Remove(r.Context(), "hold")
+	reservation.ID = newReservationID
 
-	// send notification mail to user
-	htmlMessage := fmt.Sprintf(`
-	<strong>Reservation Confirmation</strong><br>
-	Dear %s:, <br>
-	This is a confirmation of your reservation from %s to %s.
-	`, reservation.FirstName, reservation.StartDate.Format("2006-01-02"), reservation.EndDate.Format("2006-01-02"))
-	mail := models.MailData{
-		To:       reservation.Email,
-		From:     "kaito@laptop-rental.com",
-		Subject:  "Reservation Confirmation",
-		Content:  htmlMessage,
-		Template: "basic.email.html",
+	// a reservation made from a waitlist hold link takes the customer off the waitlist
+	if token := repo.App.Session.PopString(r.Context(), "waitlist_token"); token != "" {
+		err = waitlist.Complete(repo.DB, token)
+		if err != nil {
+			repo.App.ErrorLog.Println(err)
+		}
 	}
-	repo.App.MailChan <- mail
+
+	// send notification mail to user, in the language they booked in
+	repo.mailConfirmation(i18n.FromContext(r.Context()), reservation)
 
 	// send notification mail to website Administrator
-	htmlMessage = fmt.Sprintf(`
+	htmlMessage := fmt.Sprintf(`
 	<strong>Reservation Confirmation</strong><br>
 	A reservation has been made for %s from %s to %s.
 	`, reservation.Laptop.LaptopName, reservation.StartDate.Format("2006-01-02"), reservation.EndDate.Format("2006-01-02"))
-	mail = models.MailData{
+	mail := models.MailData{
 		To:       "kaito@laptop-rental.com",
 		From:     "kaito@laptop-rental.com",
 		Subject:  "Reservation Confirmation",
@@ -213,6 +245,25 @@ func (repo *Repository) PostMakeReservation(w http.ResponseWriter, r *http.Reque
 	http.Redirect(w, r, "/reservation-summary", http.StatusSeeOther)
 }
 
+// mailConfirmation sends the customer the confirmation of their reservation in locale
+func (repo *Repository) mailConfirmation(locale string, reservation models.Reservation) {
+	htmlMessage := fmt.Sprintf(`
+	<strong>%s</strong><br>
+	%s <br>
+	%s
+	`, i18n.T(locale, "Reservation Confirmation"), i18n.T(locale, "Dear %s:,", html.EscapeString(reservation.FirstName)),
+		i18n.T(locale, "This is a confirmation of your reservation from %s to %s.",
+			i18n.FormatDate(locale, reservation.StartDate, "January 2, 2006"), i18n.FormatDate(locale, reservation.EndDate, "January 2, 2006")))
+	mail := models.MailData{
+		To:       reservation.Email,
+		From:     "kaito@laptop-rental.com",
+		Subject:  i18n.T(locale, "Reservation Confirmation"),
+		Content:  htmlMessage,
+		Template: "basic.email.html",
+	}
+	repo.App.MailChan <- mail
+}
+
 // Alienware renders the Alienware laptop page
 func (repo *Repository) Alienware(w http.ResponseWriter, r *http.Request) {
 	render.Template(w, r, "alienware.page.html", &models.TemplateData{})
@@ -225,44 +276,78 @@ func (repo *Repository) Macbook(w http.ResponseWriter, r *http.Request) {
 
 // SearchAvailability renders the search availalibity page
 func (repo *Repository) SearchAvailability(w http.ResponseWriter, r *http.Request) {
-	render.Template(w, r, "search-availability.page.html", &models.TemplateData{})
+	render.Template(w, r, "search-availability.page.html", &models.TemplateData{
+		Form: forms.New(nil),
+	})
 }
 
 // PostSearchAvailability handles request for availability
 func (repo *Repository) PostSearchAvailability(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
-		repo.App.Session.Put(r.Context(), "error", "can't parse form")
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		render.Error(w, r, apperrors.BadRequest(err, "The form could not be read"))
 		return
 	}
 
-	form := forms.New(r.PostForm)
+	form := newForm(r, r.PostForm)
 
 	startDate, err := form.GetTimeObj("start_date")
 	if err != nil {
-		repo.App.Session.Put(r.Context(), "error", "can't parse start date")
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		render.Error(w, r, apperrors.BadRequest(err, "Invalid Start Date"))
 		return
 	}
 	endDate, err := form.GetTimeObj("end_date")
 	if err != nil {
-		repo.App.Session.Put(r.Context(), "error", "can't parse end date")
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		render.Error(w, r, apperrors.BadRequest(err, "Invalid End Date"))
 		return
 	}
 
-	laptops, err := repo.DB.SearchAvailabilityForAllLaptops(startDate, endDate)
+	// the rules of every laptop are shown as form errors, the ones of single laptops just leave them out
+	err = repo.checkBookingRules(form, 0, startDate, endDate)
 	if err != nil {
-		fmt.Println(err)
-		repo.App.Session.Put(r.Context(), "error", "can't search availability")
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		render.Error(w, r, err)
+		return
+	}
+	if !form.Valid() {
+		render.Template(w, r, "search-availability.page.html", &models.TemplateData{
+			Form: form,
+		})
+		return
+	}
+
+	available, err := repo.DB.SearchAvailabilityForAllLaptops(startDate, endDate)
+	if err != nil {
+		render.Error(w, r, err)
 		return
 	}
 
+	var laptops []models.Laptop
+	for _, laptop := range available {
+		violations, err := rules.Check(repo.DB, laptop.ID, startDate, endDate)
+		if err != nil {
+			render.Error(w, r, err)
+			return
+		}
+		if len(violations) == 0 {
+			laptops = append(laptops, laptop)
+		}
+	}
+
 	if len(laptops) == 0 {
+		// offer the nearest free dates instead of a dead end
+		suggestions, err := suggest.ForAllLaptops(repo.DB, startDate, endDate)
+		if err != nil {
+			repo.App.ErrorLog.Println(err)
+		}
+
+		data := make(map[string]interface{})
+		data["suggestions"] = suggestions
+
 		repo.App.Session.Put(r.Context(), "error", "no availability")
-		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+		render.Template(w, r, "search-availability.page.html", &models.TemplateData{
+			Form: form,
+			Data: data,
+		})
 		return
 	}
 
@@ -283,28 +368,26 @@ func (repo *Repository) PostSearchAvailability(w http.ResponseWriter, r *http.Re
 
 // jsonResponse defines the schema of JSON repsonse sent by AvailabilityModal handler
 type jsonResponse struct {
-	OK        bool   `json:"ok"`
-	Message   string `json:"message"`
-	LaptopID  string `json:"laptop_id"`
-	StartDate string `json:"start_date"`
-	EndDate   string `json:"end_date"`
+	OK          bool                 `json:"ok"`
+	Message     string               `json:"message"`
+	LaptopID    string               `json:"laptop_id"`
+	StartDate   string               `json:"start_date"`
+	EndDate     string               `json:"end_date"`
+	Suggestions *suggest.Suggestions `json:"suggestions,omitempty"`
+	// Errors are the broken booking rules by form field
+	Errors map[string][]string `json:"errors,omitempty"`
 }
 
 // SearchAvailabilityModal handles request for availability on modal window and send JSON response
 func (repo *Repository) SearchAvailabilityModal(w http.ResponseWriter, r *http.Request) {
+	locale := i18n.FromContext(r.Context())
 	err := r.ParseForm()
 	if err != nil {
-		resp := jsonResponse{
-			OK:      false,
-			Message: "Internal server error",
-		}
-		out, _ := json.MarshalIndent(resp, "", "     ")
-		w.Header().Set("Content-Type", "application/json")
-		w.Write(out)
+		render.ErrorJSON(w, r, apperrors.BadRequest(err, "The form could not be read"))
 		return
 	}
 
-	form := forms.New(r.PostForm)
+	form := newForm(r, r.PostForm)
 
 	form.Required("start_date", "end_date")
 	form.ValidateDate("start_date")
@@ -312,32 +395,37 @@ func (repo *Repository) SearchAvailabilityModal(w http.ResponseWriter, r *http.R
 
 	startDate, err := form.GetTimeObj("start_date")
 	if err != nil {
-		resp := jsonResponse{
-			OK:      false,
-			Message: "Invalid Start Date",
-		}
-		out, _ := json.MarshalIndent(resp, "", "     ")
-		w.Header().Set("Content-Type", "application/json")
-		w.Write(out)
+		render.ErrorJSON(w, r, apperrors.BadRequest(err, "Invalid Start Date"))
 		return
 	}
 	endDate, err := form.GetTimeObj("end_date")
 	if err != nil {
-		resp := jsonResponse{
-			OK:      false,
-			Message: "Invalid End Date",
-		}
-		out, _ := json.MarshalIndent(resp, "", "     ")
-		w.Header().Set("Content-Type", "application/json")
-		w.Write(out)
+		render.ErrorJSON(w, r, apperrors.BadRequest(err, "Invalid End Date"))
 		return
 	}
 
 	laptopID, err := strconv.Atoi(r.Form.Get("laptop_id"))
 	if err != nil {
+		render.ErrorJSON(w, r, apperrors.BadRequest(err, "Invalid Laptop ID"))
+		return
+	}
+
+	violations, err := rules.Check(repo.DB, laptopID, startDate, endDate)
+	if err != nil {
+		render.ErrorJSON(w, r, err)
+		return
+	}
+	if len(violations) > 0 {
+		var messages []string
+		errs := make(map[string][]string)
+		for _, v := range violations {
+			messages = append(messages, i18n.T(locale, "%s.", v.In(locale)))
+			errs[v.Field] = append(errs[v.Field], v.In(locale))
+		}
 		resp := jsonResponse{
 			OK:      false,
-			Message: "Invalid Laptop ID",
+			Message: strings.Join(messages, " "),
+			Errors:  errs,
 		}
 		out, _ := json.MarshalIndent(resp, "", "     ")
 		w.Header().Set("Content-Type", "application/json")
@@ -347,27 +435,30 @@ func (repo *Repository) SearchAvailabilityModal(w http.ResponseWriter, r *http.R
 
 	available, err := repo.DB.SearchAvailabilityByDatesByLaptopID(startDate, endDate, laptopID)
 	if err != nil {
-		resp := jsonResponse{
-			OK:      false,
-			Message: "Error connecting to the database",
-		}
-		out, _ := json.MarshalIndent(resp, "", "     ")
-		w.Header().Set("Content-Type", "application/json")
-		w.Write(out)
+		render.ErrorJSON(w, r, err)
 		return
 	}
 
-	msg := "Available!"
+	msg := i18n.T(locale, "Available!")
+	var suggestions *suggest.Suggestions
 	if !available {
-		msg = "Not Available!"
+		msg = i18n.T(locale, "Not Available!")
+		// suggestions are a nicety, the answer is still valid without them
+		s, err := suggest.ForLaptop(repo.DB, laptopID, startDate, endDate)
+		if err != nil {
+			repo.App.ErrorLog.Println(err)
+		} else if !s.Empty() {
+			suggestions = &s
+		}
 	}
 
 	resp := jsonResponse{
-		OK:        available,
-		Message:   msg,
-		StartDate: r.Form.Get("start_date"),
-		EndDate:   r.Form.Get("end_date"),
-		LaptopID:  r.Form.Get("laptop_id"),
+		OK:          available,
+		Message:     msg,
+		StartDate:   r.Form.Get("start_date"),
+		EndDate:     r.Form.Get("end_date"),
+		LaptopID:    r.Form.Get("laptop_id"),
+		Suggestions: suggestions,
 	}
 
 	// the validity of the json response is certain at this point
@@ -404,8 +495,7 @@ func (repo *Repository) ChooseLaptop(w http.ResponseWriter, r *http.Request) {
 	exploded := strings.Split(r.RequestURI, "/")
 	laptopID, err := strconv.Atoi(exploded[2])
 	if err != nil {
-		repo.App.Session.Put(r.Context(), "error", "invalid Laptop ID")
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		render.Error(w, r, apperrors.BadRequest(err, "Invalid Laptop ID"))
 		return
 	}
 	res, ok := repo.App.Session.Get(r.Context(), "reservation").(models.Reservation)
@@ -426,22 +516,19 @@ func (repo *Repository) RentLaptop(w http.ResponseWriter, r *http.Request) {
 
 	LaptopID, err := strconv.Atoi(r.URL.Query().Get("id"))
 	if err != nil {
-		repo.App.Session.Put(r.Context(), "error", "invalid Laptop ID")
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		render.Error(w, r, apperrors.BadRequest(err, "Invalid Laptop ID"))
 		return
 	}
 
-	form := forms.New(r.Form)
+	form := newForm(r, r.Form)
 	startDate, err := form.GetTimeObj("s")
 	if err != nil {
-		repo.App.Session.Put(r.Context(), "error", "can't parse start date")
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		render.Error(w, r, apperrors.BadRequest(err, "Invalid Start Date"))
 		return
 	}
 	endDate, err := form.GetTimeObj("e")
 	if err != nil {
-		repo.App.Session.Put(r.Context(), "error", "can't parse end date")
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		render.Error(w, r, apperrors.BadRequest(err, "Invalid End Date"))
 		return
 	}
 
@@ -452,8 +539,7 @@ func (repo *Repository) RentLaptop(w http.ResponseWriter, r *http.Request) {
 
 	laptop, err := repo.DB.GetLaptopByID(res.LaptopID)
 	if err != nil {
-		repo.App.Session.Put(r.Context(), "error", "error connecting to the database")
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		render.Error(w, r, err)
 		return
 	}
 
@@ -476,12 +562,11 @@ func (repo *Repository) PostLogin(w http.ResponseWriter, r *http.Request) {
 
 	err := r.ParseForm()
 	if err != nil {
-		repo.App.Session.Put(r.Context(), "error", "can't parse form")
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		render.Error(w, r, apperrors.BadRequest(err, "The form could not be read"))
 		return
 	}
 
-	form := forms.New(r.PostForm)
+	form := newForm(r, r.PostForm)
 	form.Required("email", "password")
 	form.IsEmail("email")
 	if !form.Valid() {
@@ -514,36 +599,47 @@ func (repo *Repository) Logout(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
 }
 
-// AdminDashbord shows admin dashboard page
-func (repo *Repository) AdminDashbord(w http.ResponseWriter, r *http.Request) {
-	render.Template(w, r, "admin-dashboard.page.html", &models.TemplateData{})
-}
-
 // AdminNewReservations shows all new reservations
 func (repo *Repository) AdminNewReservations(w http.ResponseWriter, r *http.Request) {
-	reservations, err := repo.DB.AllNewReservations()
-	if err != nil {
-		helpers.ServerError(w, err)
-		return
-	}
-	data := make(map[string]interface{})
-	data["reservations"] = reservations
-	render.Template(w, r, "admin-new-reservations.page.html", &models.TemplateData{
-		Data: data,
-	})
+	repo.adminReservationList(w, r, "admin-new-reservations.page.html", models.ReservationsNew)
 }
 
 // AdminAllReservations shows all reservations
 func (repo *Repository) AdminAllReservations(w http.ResponseWriter, r *http.Request) {
-	reservations, err := repo.DB.AllReservations()
+	repo.adminReservationList(w, r, "admin-all-reservations.page.html", "")
+}
+
+// adminReservationList renders a page of reservations filtered, sorted and paged by the query,
+// only the ones in the processed state if it isn't empty
+func (repo *Repository) adminReservationList(w http.ResponseWriter, r *http.Request, page, processed string) {
+	list, err := repo.searchReservations(r, processed)
 	if err != nil {
-		helpers.ServerError(w, err)
+		render.Error(w, r, err)
 		return
 	}
+	laptops, err := repo.DB.AllLaptops()
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+
+	q := r.URL.Query()
+	stringMap := make(map[string]string)
+	for _, k := range []string{"q", "laptop", "start", "end", "processed", "sort", "dir"} {
+		stringMap[k] = q.Get(k)
+	}
+	stringMap["path"] = r.URL.Path
+	stringMap["type"] = "all"
+	if processed == models.ReservationsNew {
+		stringMap["type"] = "new"
+	}
+
 	data := make(map[string]interface{})
-	data["reservations"] = reservations
-	render.Template(w, r, "admin-all-reservations.page.html", &models.TemplateData{
-		Data: data,
+	data["list"] = list
+	data["laptops"] = laptops
+	render.Template(w, r, page, &models.TemplateData{
+		StringMap: stringMap,
+		Data:      data,
 	})
 }
 
@@ -553,7 +649,7 @@ func (repo *Repository) AdminShowReservation(w http.ResponseWriter, r *http.Requ
 
 	id, err := strconv.Atoi(splited[4])
 	if err != nil {
-		helpers.ServerError(w, err)
+		render.Error(w, r, apperrors.BadRequest(err, "Invalid reservation ID"))
 		return
 	}
 
@@ -567,28 +663,48 @@ func (repo *Repository) AdminShowReservation(w http.ResponseWriter, r *http.Requ
 
 	res, err := repo.DB.GetReservatioByID(id)
 	if err != nil {
-		repo.App.Session.Put(r.Context(), "error", "can't find reservation")
-		http.Redirect(w, r, fmt.Sprintf("/admin/reservations/%s/%d", tp, id), http.StatusSeeOther)
+		render.Error(w, r, err)
+		return
+	}
+
+	repo.renderAdminReservation(w, r, http.StatusOK, res, stringMap, forms.New(nil))
+}
+
+// renderAdminReservation shows the reservation form with the laptops it can be moved to
+func (repo *Repository) renderAdminReservation(w http.ResponseWriter, r *http.Request, status int, res models.Reservation,
+	stringMap map[string]string, form *forms.Form) {
+	laptops, err := repo.DB.AllLaptops()
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+
+	reminders, err := repo.DB.GetRemindersByReservationID(res.ID)
+	if err != nil {
+		render.Error(w, r, err)
 		return
 	}
 
 	data := make(map[string]interface{})
 	data["reservation"] = res
+	data["laptops"] = laptops
+	data["reminders"] = reminders
 
-	render.Template(w, r, "admin-show-reservation.page.html", &models.TemplateData{
+	render.TemplateStatus(w, r, status, "admin-show-reservation.page.html", &models.TemplateData{
 		StringMap: stringMap,
 		Data:      data,
-		Form:      forms.New(nil),
+		Form:      form,
 	})
 }
 
-// PostAdminShowReservation
+// PostAdminShowReservation saves the customer details of a reservation and moves it
+// to the laptop and dates of the form if they changed
 func (repo *Repository) PostAdminShowReservation(w http.ResponseWriter, r *http.Request) {
 	splited := strings.Split(r.RequestURI, "/")
 
 	id, err := strconv.Atoi(splited[4])
 	if err != nil {
-		helpers.ServerError(w, err)
+		render.Error(w, r, apperrors.BadRequest(err, "Invalid reservation ID"))
 		return
 	}
 
@@ -596,15 +712,13 @@ func (repo *Repository) PostAdminShowReservation(w http.ResponseWriter, r *http.
 
 	err = r.ParseForm()
 	if err != nil {
-		repo.App.Session.Put(r.Context(), "error", "can't parse form")
-		http.Redirect(w, r, fmt.Sprintf("/admin/reservations/%s/%d", tp, id), http.StatusSeeOther)
+		render.Error(w, r, apperrors.BadRequest(err, "The form could not be read"))
 		return
 	}
 
 	res, err := repo.DB.GetReservatioByID(id)
 	if err != nil {
-		repo.App.Session.Put(r.Context(), "error", "can't find reservation")
-		http.Redirect(w, r, fmt.Sprintf("/admin/reservations/%s/%d", tp, id), http.StatusSeeOther)
+		render.Error(w, r, err)
 		return
 	}
 
@@ -613,11 +727,65 @@ func (repo *Repository) PostAdminShowReservation(w http.ResponseWriter, r *http.
 	res.Email = r.Form.Get("email")
 	res.Phone = r.Form.Get("phone")
 
-	err = repo.DB.UpdateReservation(&res)
-	if err != nil {
-		repo.App.Session.Put(r.Context(), "error", "can't update database")
-		http.Redirect(w, r, fmt.Sprintf("/admin/reservations/%s/%d", tp, id), http.StatusSeeOther)
-		return
+	// the laptop and dates are kept unless the form has them, the customer details are saved with them
+	moved := res
+	if v := r.Form.Get("laptop_id"); v != "" {
+		moved.LaptopID, err = strconv.Atoi(v)
+		if err != nil {
+			render.Error(w, r, apperrors.BadRequest(err, "Invalid Laptop ID"))
+			return
+		}
+	}
+	if v := r.Form.Get("start_date"); v != "" {
+		moved.StartDate, err = dates.Parse(v)
+		if err != nil {
+			render.Error(w, r, apperrors.BadRequest(err, "Invalid Start Date"))
+			return
+		}
+	}
+	if v := r.Form.Get("end_date"); v != "" {
+		moved.EndDate, err = dates.Parse(v)
+		if err != nil {
+			render.Error(w, r, apperrors.BadRequest(err, "Invalid End Date"))
+			return
+		}
+	}
+
+	if moved.LaptopID != res.LaptopID || !sameDay(moved.StartDate, res.StartDate) || !sameDay(moved.EndDate, res.EndDate) {
+		if moved.EndDate.Before(moved.StartDate) {
+			render.Error(w, r, apperrors.BadRequest(nil, "The end date can't be before the start date"))
+			return
+		}
+		moved.Laptop, err = repo.DB.GetLaptopByID(moved.LaptopID)
+		if err != nil {
+			render.Error(w, r, apperrors.BadRequest(err, "Invalid Laptop ID"))
+			return
+		}
+
+		err = repo.DB.RescheduleReservation(&moved)
+		if errors.Is(err, database.ErrNotAvailable) {
+			form := newForm(r, r.PostForm)
+			form.Errors.Add("start_date", i18n.T(form.Locale, "The laptop isn't available for those dates"))
+			stringMap := map[string]string{"type": tp, "year": r.Form.Get("year"), "month": r.Form.Get("month")}
+			repo.renderAdminReservation(w, r, http.StatusUnprocessableEntity, moved, stringMap, form)
+			return
+		}
+		if err != nil {
+			render.Error(w, r, err)
+			return
+		}
+
+		// the old dates may be what someone on the waitlist is waiting for
+		repo.notifyWaitlist(res.LaptopID)
+		if r.Form.Get("notify") != "" {
+			repo.mailReservationChange(moved)
+		}
+	} else {
+		err = repo.DB.UpdateReservation(&res)
+		if err != nil {
+			render.Error(w, r, err)
+			return
+		}
 	}
 
 	month := r.Form.Get("month")
@@ -631,21 +799,45 @@ func (repo *Repository) PostAdminShowReservation(w http.ResponseWriter, r *http.
 	}
 }
 
+// sameDay reports whether a and b are the same calendar date
+func sameDay(a, b time.Time) bool {
+	return a.Format(dates.Layout) == b.Format(dates.Layout)
+}
+
+// mailReservationChange tells the customer the new laptop and dates of their reservation in the language
+// they booked in
+func (repo *Repository) mailReservationChange(res models.Reservation) {
+	locale := res.Locale
+	htmlMessage := fmt.Sprintf(`
+	<strong>%s</strong><br>
+	%s <br>
+	%s
+	`, i18n.T(locale, "Reservation Changed"), i18n.T(locale, "Dear %s:,", html.EscapeString(res.FirstName)),
+		i18n.T(locale, "Your reservation has been changed to %s from %s to %s.", html.EscapeString(res.Laptop.LaptopName),
+			i18n.FormatDate(locale, res.StartDate, "January 2, 2006"), i18n.FormatDate(locale, res.EndDate, "January 2, 2006")))
+	repo.App.MailChan <- models.MailData{
+		To:       res.Email,
+		From:     "kaito@laptop-rental.com",
+		Subject:  i18n.T(locale, "Reservation Changed"),
+		Content:  htmlMessage,
+		Template: "basic.email.html",
+	}
+}
+
 // AdminReservationsCalendar shows the reservations calendar
 func (repo *Repository) AdminReservationsCalendar(w http.ResponseWriter, r *http.Request) {
-	now := time.Now()
+	year, month, _ := dates.Today().Date()
+	now := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
 	if r.URL.Query().Get("y") != "" {
 		year, err := strconv.Atoi(r.URL.Query().Get("y"))
 		if err != nil {
-			repo.App.Session.Put(r.Context(), "error", "can't get year")
-			http.Redirect(w, r, "/admin/reservations-calendar", http.StatusSeeOther)
+			render.Error(w, r, apperrors.BadRequest(err, "Invalid year"))
 			return
 		}
 
 		month, err := strconv.Atoi(r.URL.Query().Get("m"))
 		if err != nil {
-			repo.App.Session.Put(r.Context(), "error", "can't get month")
-			http.Redirect(w, r, "/admin/reservations-calendar", http.StatusSeeOther)
+			render.Error(w, r, apperrors.BadRequest(err, "Invalid month"))
 			return
 		}
 
@@ -667,9 +859,8 @@ func (repo *Repository) AdminReservationsCalendar(w http.ResponseWriter, r *http
 	stringMap["this_month"] = now.Format("01")
 	stringMap["this_month_year"] = now.Format("2006")
 
-	currentYear, currentMonth, _ := now.Date()
-	currentLocation := now.Location()
-	firstDayOfMonth := time.Date(currentYear, currentMonth, 1, 0, 0, 0, 0, currentLocation)
+	// dates are calendar days at midnight UTC, like the ones read from the database
+	firstDayOfMonth := now
 	lastDayOfMonth := firstDayOfMonth.AddDate(0, 1, -1)
 
 	intMap := make(map[string]int)
@@ -677,26 +868,31 @@ func (repo *Repository) AdminReservationsCalendar(w http.ResponseWriter, r *http
 
 	laptops, err := repo.DB.AllLaptops()
 	if err != nil {
-		repo.App.Session.Put(r.Context(), "error", "can't get all laptops from database")
-		http.Redirect(w, r, "/admin/reservations-calendar", http.StatusSeeOther)
+		render.Error(w, r, err)
 		return
 	}
 
 	data["laptops"] = laptops
 
+	// blocks are removed at the version shown, so that a block changed meanwhile isn't removed
+	blockVersions := make(map[int]int)
+
 	for _, lp := range laptops {
 		reservationMap := make(map[string]int)
 		blockMap := make(map[string]int)
+		holdMap := make(map[string]int)
+		bufferMap := make(map[string]int)
 
 		for d := firstDayOfMonth; !d.After(lastDayOfMonth); d = d.AddDate(0, 0, 1) {
 			reservationMap[d.Format("2006-01-2")] = 0
 			blockMap[d.Format("2006-01-2")] = 0
+			holdMap[d.Format("2006-01-2")] = 0
+			bufferMap[d.Format("2006-01-2")] = 0
 		}
 
 		laptopRestrictions, err := repo.DB.GetLaptopRestrictionsByDate(lp.ID, firstDayOfMonth, lastDayOfMonth)
 		if err != nil {
-			repo.App.Session.Put(r.Context(), "error", "can't get laptop restrictions from database")
-			http.Redirect(w, r, "/admin/reservations-calendar", http.StatusSeeOther)
+			render.Error(w, r, err)
 			return
 		}
 
@@ -705,18 +901,48 @@ func (repo *Repository) AdminReservationsCalendar(w http.ResponseWriter, r *http
 				for d := lr.StartDate; !d.After(lr.EndDate); d = d.AddDate(0, 0, 1) {
 					reservationMap[d.Format("2006-01-2")] = lr.ReservationID
 				}
+			} else if lr.RestrictionID == models.RestrictionHold {
+				// holds expire by themselves, so they can't be removed like blocks
+				for d := lr.StartDate; !d.After(lr.EndDate); d = d.AddDate(0, 0, 1) {
+					holdMap[d.Format("2006-01-2")] = lr.ID
+				}
 			} else {
 				for d := lr.StartDate; !d.After(lr.EndDate); d = d.AddDate(0, 0, 1) {
 					blockMap[d.Format("2006-01-2")] = lr.ID
 				}
+				blockVersions[lr.ID] = lr.Version
+			}
+		}
+
+		// the availability checks keep the whole turnaround free on both sides of reservations and holds,
+		// and the turnaround of the ones in the months around can fall in this month too
+		if turnaround := lp.Turnaround(); turnaround > 0 {
+			nearby, err := repo.DB.GetLaptopRestrictionsByDate(lp.ID,
+				firstDayOfMonth.AddDate(0, 0, -turnaround), lastDayOfMonth.AddDate(0, 0, turnaround))
+			if err != nil {
+				render.Error(w, r, err)
+				return
+			}
+
+			for _, lr := range nearby {
+				if lr.RestrictionID == models.RestrictionBlock {
+					continue
+				}
+				for d := lr.StartDate.AddDate(0, 0, -turnaround); d.Before(lr.StartDate); d = d.AddDate(0, 0, 1) {
+					bufferMap[d.Format("2006-01-2")] = lr.ID
+				}
+				for d := lr.EndDate.AddDate(0, 0, 1); !d.After(lr.EndDate.AddDate(0, 0, turnaround)); d = d.AddDate(0, 0, 1) {
+					bufferMap[d.Format("2006-01-2")] = lr.ID
+				}
 			}
 		}
 
 		data[fmt.Sprintf("reservation_map_%d", lp.ID)] = reservationMap
 		data[fmt.Sprintf("block_map_%d", lp.ID)] = blockMap
-
-		repo.App.Session.Put(r.Context(), fmt.Sprintf("block_map_%d", lp.ID), blockMap)
+		data[fmt.Sprintf("hold_map_%d", lp.ID)] = holdMap
+		data[fmt.Sprintf("buffer_map_%d", lp.ID)] = bufferMap
 	}
+	data["block_versions"] = blockVersions
 
 	render.Template(w, r, "admin-reservations-calendar.page.html", &models.TemplateData{
 		StringMap: stringMap,
@@ -725,84 +951,170 @@ func (repo *Repository) AdminReservationsCalendar(w http.ResponseWriter, r *http
 	})
 }
 
-// PostAdminReservationsCalendar handles post of reservation calendar
+// PostAdminReservationsCalendar saves the blocks changed on the reservation calendar. Ticked add_block_{laptop id}_{date}
+// boxes add one day blocks and ticked remove_block_{restriction id}_{date} boxes unblock their day of the block at the
+// version they carry, the days of the block left unticked stay blocked. If another admin changed the calendar since it
+// was shown nothing is saved.
 func (repo *Repository) PostAdminReservationsCalendar(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
-		repo.App.Session.Put(r.Context(), "error", "can't parse form")
-		http.Redirect(w, r, "/admin/reservations-calendar", http.StatusSeeOther)
+		render.Error(w, r, apperrors.BadRequest(err, "The form could not be read"))
 		return
 	}
 
-	form := forms.New(r.PostForm)
+	form := newForm(r, r.PostForm)
 	form.Required("y", "m")
 	if !form.Valid() {
-		repo.App.Session.Put(r.Context(), "error", "can't get year or month")
-		http.Redirect(w, r, "/admin/reservations-calendar", http.StatusSeeOther)
+		render.Error(w, r, apperrors.BadRequest(nil, "Invalid year or month"))
 		return
 	}
 
 	year, err := strconv.Atoi(form.Get("y"))
 	if err != nil {
-		repo.App.Session.Put(r.Context(), "error", "can't get year")
-		http.Redirect(w, r, "/admin/reservations-calendar", http.StatusSeeOther)
+		render.Error(w, r, apperrors.BadRequest(err, "Invalid year"))
 		return
 	}
 
 	month, err := strconv.Atoi(form.Get("m"))
 	if err != nil {
-		repo.App.Session.Put(r.Context(), "error", "can't get month")
-		http.Redirect(w, r, "/admin/reservations-calendar", http.StatusSeeOther)
-		return
-	}
-
-	laptops, err := repo.DB.AllLaptops()
-	if err != nil {
-		repo.App.Session.Put(r.Context(), "error", "can't get all laptops from database")
-		http.Redirect(w, r, "/admin/reservations-calendar", http.StatusSeeOther)
+		render.Error(w, r, apperrors.BadRequest(err, "Invalid month"))
 		return
 	}
 
-	for _, lp := range laptops {
-		// get block_map from_session, if form data has remove_block but block_map
-		curMap := repo.App.Session.Get(r.Context(), fmt.Sprintf("block_map_%d", lp.ID)).(map[string]int)
-		for date, laptopRestrictionID := range curMap {
-			if laptopRestrictionID > 0 && !form.Has(fmt.Sprintf("remove_block_%d_%s", lp.ID, date)) {
-				err := repo.DB.DeleteBlockByID(laptopRestrictionID)
-				if err != nil {
-					repo.App.Session.Put(r.Context(), "error", "can't delete block from datebase")
-					http.Redirect(w, r, "/admin/reservations-calendar", http.StatusSeeOther)
-					return
-				}
-			}
-		}
-	}
-
+	var add, remove []models.LaptopRestriction
+	// the days to unblock and the version of each block they are removed from
+	unblockDays := make(map[int][]time.Time)
+	versions := make(map[int]int)
 	for inputName := range r.PostForm {
-		if strings.HasPrefix(inputName, "add_block") {
+		if strings.HasPrefix(inputName, "add_block_") {
 			splited := strings.Split(inputName, "_")
+			if len(splited) != 4 {
+				render.Error(w, r, apperrors.BadRequest(nil, "Invalid block %s", inputName))
+				return
+			}
 
 			laptopID, err := strconv.Atoi(splited[2])
 			if err != nil {
-				helpers.ServerError(w, err)
+				render.Error(w, r, apperrors.BadRequest(err, "Invalid block %s", inputName))
+				return
 			}
 
 			startDate, err := time.Parse("2006-01-2", splited[3])
 			if err != nil {
-				helpers.ServerError(w, err)
+				render.Error(w, r, apperrors.BadRequest(err, "Invalid block %s", inputName))
+				return
+			}
+
+			add = append(add, models.LaptopRestriction{LaptopID: laptopID, StartDate: startDate, EndDate: startDate})
+		} else if strings.HasPrefix(inputName, "remove_block_") {
+			splited := strings.Split(inputName, "_")
+			if len(splited) != 4 {
+				render.Error(w, r, apperrors.BadRequest(nil, "Invalid block %s", inputName))
+				return
+			}
+
+			id, err := strconv.Atoi(splited[2])
+			if err != nil {
+				render.Error(w, r, apperrors.BadRequest(err, "Invalid block %s", inputName))
+				return
+			}
+
+			day, err := time.Parse("2006-01-2", splited[3])
+			if err != nil {
+				render.Error(w, r, apperrors.BadRequest(err, "Invalid block %s", inputName))
+				return
 			}
 
-			err = repo.DB.InsertOneDayBlockByLaptopID(laptopID, startDate)
+			// every day of a block has a box, they all carry the same version
+			version, err := strconv.Atoi(r.PostForm.Get(inputName))
 			if err != nil {
-				repo.App.Session.Put(r.Context(), "error", "can't insert block into datebase")
-				http.Redirect(w, r, "/admin/reservations-calendar", http.StatusSeeOther)
+				render.Error(w, r, apperrors.BadRequest(err, "Invalid block %s", inputName))
 				return
 			}
+
+			unblockDays[id] = append(unblockDays[id], day)
+			versions[id] = version
 		}
 	}
 
+	calendar := fmt.Sprintf("/admin/reservations-calendar?y=%d&m=%d", year, month)
+	changed := func() {
+		repo.App.Session.Put(r.Context(), "error", "The calendar was changed by someone else, nothing was saved. Check it and try again")
+		http.Redirect(w, r, calendar, http.StatusSeeOther)
+	}
+
+	// a block is removed and the days left unticked, in this month or others, are blocked again
+	for id, days := range unblockDays {
+		kept, err := repo.blockRemainder(id, days)
+		if errors.Is(err, sql.ErrNoRows) {
+			changed()
+			return
+		}
+		if err != nil {
+			render.Error(w, r, err)
+			return
+		}
+		remove = append(remove, models.LaptopRestriction{ID: id, Version: versions[id]})
+		add = append(add, kept...)
+	}
+
+	unblocked, err := repo.DB.ChangeBlocks(add, remove)
+	if errors.Is(err, database.ErrBlockChanged) || errors.Is(err, database.ErrNotAvailable) {
+		changed()
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		render.Error(w, r, apperrors.BadRequest(err, "Invalid Laptop ID"))
+		return
+	}
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+
+	for _, laptopID := range unblocked {
+		repo.notifyWaitlist(laptopID)
+	}
+
 	repo.App.Session.Put(r.Context(), "flash", "changes saved")
-	http.Redirect(w, r, fmt.Sprintf("/admin/reservations-calendar?y=%d&m=%d", year, month), http.StatusSeeOther)
+	http.Redirect(w, r, calendar, http.StatusSeeOther)
+}
+
+// blockRemainder returns the blocks left of the block id once days are unblocked, sql.ErrNoRows is returned if
+// it no longer blocks the first of the days
+func (repo *Repository) blockRemainder(id int, days []time.Time) ([]models.LaptopRestriction, error) {
+	blocks, err := repo.DB.GetBlocksByDate(days[0], days[0])
+	if err != nil {
+		return nil, err
+	}
+	var block models.LaptopRestriction
+	for _, b := range blocks {
+		if b.ID == id {
+			block = b
+		}
+	}
+	if block.ID == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	unblock := make(map[string]bool)
+	for _, d := range days {
+		unblock[d.Format(dates.Layout)] = true
+	}
+
+	var kept []models.LaptopRestriction
+	for d := block.StartDate; !d.After(block.EndDate); d = d.AddDate(0, 0, 1) {
+		if unblock[d.Format(dates.Layout)] {
+			continue
+		}
+		if n := len(kept); n > 0 && kept[n-1].EndDate.AddDate(0, 0, 1).Equal(d) {
+			kept[n-1].EndDate = d
+			continue
+		}
+		kept = append(kept, models.LaptopRestriction{LaptopID: block.LaptopID, StartDate: d, EndDate: d})
+	}
+
+	return kept, nil
 }
 
 // AdminProcessReservation marks a reservation as processed
@@ -813,15 +1125,13 @@ func (repo *Repository) AdminProcessReservation(w http.ResponseWriter, r *http.R
 
 	id, err := strconv.Atoi(splited[4])
 	if err != nil {
-		repo.App.Session.Put(r.Context(), "error", "can't get id")
-		http.Redirect(w, r, fmt.Sprintf("/admin/reservations-%s", tp), http.StatusSeeOther)
+		render.Error(w, r, apperrors.BadRequest(err, "Invalid reservation ID"))
 		return
 	}
 
 	err = repo.DB.UpdateReservationProcessed(id, 1)
 	if err != nil {
-		repo.App.Session.Put(r.Context(), "error", "can't update database")
-		http.Redirect(w, r, fmt.Sprintf("/admin/reservations-%s", tp), http.StatusSeeOther)
+		render.Error(w, r, err)
 		return
 	}
 
@@ -846,17 +1156,25 @@ func (repo *Repository) AdminDeleteReservation(w http.ResponseWriter, r *http.Re
 
 	id, err := strconv.Atoi(splited[4])
 	if err != nil {
-		helpers.ServerError(w, err)
+		render.Error(w, r, apperrors.BadRequest(err, "Invalid reservation ID"))
+		return
+	}
+
+	// the laptop of the reservation is needed to offer its dates to the waitlist once it's gone
+	res, err := repo.DB.GetReservatioByID(id)
+	if err != nil {
+		render.Error(w, r, err)
 		return
 	}
 
 	err = repo.DB.DeleteReservation(id)
 	if err != nil {
-		repo.App.Session.Put(r.Context(), "error", "can't delete from database")
-		http.Redirect(w, r, r.RequestURI, http.StatusSeeOther)
+		render.Error(w, r, err)
 		return
 	}
 
+	repo.notifyWaitlist(res.LaptopID)
+
 	month := r.URL.Query().Get("m")
 	year := r.URL.Query().Get("y")
 
@@ -868,3 +1186,62 @@ func (repo *Repository) AdminDeleteReservation(w http.ResponseWriter, r *http.Re
 		http.Redirect(w, r, fmt.Sprintf("/admin/reservations-%s?y=%s&m=%s", tp, year, month), http.StatusSeeOther)
 	}
 }
+
+// AdminSessions shows the active sessions
+func (repo *Repository) AdminSessions(w http.ResponseWriter, r *http.Request) {
+	list, err := sessions.List(r.Context(), repo.App.Session)
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+
+	users := make(map[int]*models.User)
+	for _, s := range list {
+		if _, ok := users[s.UserID]; ok || s.UserID == 0 {
+			continue
+		}
+		// the user may have been deleted since logging in
+		u, err := repo.DB.GetUserByID(s.UserID)
+		if err != nil {
+			continue
+		}
+		users[s.UserID] = &u
+	}
+
+	data := make(map[string]interface{})
+	data["sessions"] = list
+	data["users"] = users
+	render.Template(w, r, "admin-sessions.page.html", &models.TemplateData{
+		Data: data,
+	})
+}
+
+// PostAdminRevokeSession revokes a session, its owner has to log in again
+func (repo *Repository) PostAdminRevokeSession(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		render.Error(w, r, apperrors.BadRequest(err, "The form could not be read"))
+		return
+	}
+
+	id := r.Form.Get("id")
+	if id == sessions.ID(repo.App.Session.Token(r.Context())) {
+		repo.App.Session.Put(r.Context(), "error", "log out to end your own session")
+		http.Redirect(w, r, "/admin/sessions", http.StatusSeeOther)
+		return
+	}
+
+	err = sessions.Revoke(repo.App.Session, id)
+	if err == sessions.ErrNotFound {
+		repo.App.Session.Put(r.Context(), "error", "session not found, it may have expired")
+		http.Redirect(w, r, "/admin/sessions", http.StatusSeeOther)
+		return
+	} else if err != nil {
+		repo.App.Session.Put(r.Context(), "error", "can't revoke session")
+		http.Redirect(w, r, "/admin/sessions", http.StatusSeeOther)
+		return
+	}
+
+	repo.App.Session.Put(r.Context(), "flash", "Session revoked")
+	http.Redirect(w, r, "/admin/sessions", http.StatusSeeOther)
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -13,6 +14,7 @@ import (
 
 	"github.com/kaitolucifer/go-laptop-rental-site/internal/driver"
 	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/sessions"
 )
 
 func TestNewRepo(t *testing.T) {
@@ -88,8 +90,8 @@ func TestRepository_MakeReservation(t *testing.T) {
 	reservation.LaptopID = 100
 	app.Session.Put(ctx, "reservation", reservation)
 	handler.ServeHTTP(rr, req)
-	if rr.Code != http.StatusSeeOther {
-		t.Errorf("MakeReservation handler returned wrong response code: got: %d, expected: %d", rr.Code, http.StatusSeeOther)
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("MakeReservation handler returned wrong response code: got: %d, expected: %d", rr.Code, http.StatusInternalServerError)
 	}
 }
 
@@ -147,8 +149,8 @@ func TestRepository_PostMakeReservation(t *testing.T) {
 	app.Session.Put(ctx, "reservation", reservation)
 
 	handler.ServeHTTP(rr, req)
-	if rr.Code != http.StatusSeeOther {
-		t.Errorf("PostMakeReservation handler returned wrong response code: got: %d, expected: %d", rr.Code, http.StatusSeeOther)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("PostMakeReservation handler returned wrong response code: got: %d, expected: %d", rr.Code, http.StatusBadRequest)
 	}
 
 	// test case: invalid start date
@@ -169,8 +171,8 @@ func TestRepository_PostMakeReservation(t *testing.T) {
 	app.Session.Put(ctx, "reservation", reservation)
 
 	handler.ServeHTTP(rr, req)
-	if rr.Code != http.StatusSeeOther {
-		t.Errorf("PostMakeReservation handler returned wrong response code: got: %d, expected: %d", rr.Code, http.StatusSeeOther)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("PostMakeReservation handler returned wrong response code: got: %d, expected: %d", rr.Code, http.StatusBadRequest)
 	}
 
 	// test case: invalid end date
@@ -191,8 +193,8 @@ func TestRepository_PostMakeReservation(t *testing.T) {
 	app.Session.Put(ctx, "reservation", reservation)
 
 	handler.ServeHTTP(rr, req)
-	if rr.Code != http.StatusSeeOther {
-		t.Errorf("PostMakeReservation handler returned wrong response code: got: %d, expected: %d", rr.Code, http.StatusSeeOther)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("PostMakeReservation handler returned wrong response code: got: %d, expected: %d", rr.Code, http.StatusBadRequest)
 	}
 
 	// test case: invalid laptop id
@@ -213,8 +215,8 @@ func TestRepository_PostMakeReservation(t *testing.T) {
 	app.Session.Put(ctx, "reservation", reservation)
 
 	handler.ServeHTTP(rr, req)
-	if rr.Code != http.StatusSeeOther {
-		t.Errorf("PostMakeReservation handler returned wrong response code: got: %d, expected: %d", rr.Code, http.StatusSeeOther)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("PostMakeReservation handler returned wrong response code: got: %d, expected: %d", rr.Code, http.StatusBadRequest)
 	}
 
 	// test case: invalid form data
@@ -235,8 +237,8 @@ func TestRepository_PostMakeReservation(t *testing.T) {
 	app.Session.Put(ctx, "reservation", reservation)
 
 	handler.ServeHTTP(rr, req)
-	if rr.Code != http.StatusSeeOther {
-		t.Errorf("PostMakeReservation handler returned wrong response code: got: %d, expected: %d", rr.Code, http.StatusSeeOther)
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("PostMakeReservation handler returned wrong response code: got: %d, expected: %d", rr.Code, http.StatusUnprocessableEntity)
 	}
 
 	// test case: failure insert reservation into the database
@@ -257,18 +259,18 @@ func TestRepository_PostMakeReservation(t *testing.T) {
 	app.Session.Put(ctx, "reservation", reservation)
 
 	handler.ServeHTTP(rr, req)
-	if rr.Code != http.StatusSeeOther {
-		t.Errorf("PostMakeReservation handler returned wrong response code: got: %d, expected: %d", rr.Code, http.StatusSeeOther)
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("PostMakeReservation handler returned wrong response code: got: %d, expected: %d", rr.Code, http.StatusInternalServerError)
 	}
 
-	// test case: failure insert restriction into the database
+	// test case: laptop id doesn't match the reservation in session
 	reqBody = "start_date=" + reservation.StartDate.Format("2006-01-02")
 	reqBody = fmt.Sprintf("%s&%s", reqBody, "end_date="+reservation.EndDate.Format("2006-01-02"))
 	reqBody = fmt.Sprintf("%s&%s", reqBody, "first_name=John")
 	reqBody = fmt.Sprintf("%s&%s", reqBody, "last_name=Smith")
 	reqBody = fmt.Sprintf("%s&%s", reqBody, "email=john@smith.com")
 	reqBody = fmt.Sprintf("%s&%s", reqBody, "phone=123456789")
-	reqBody = fmt.Sprintf("%s&%s", reqBody, "laptop_id=1000")
+	reqBody = fmt.Sprintf("%s&%s", reqBody, "laptop_id=2")
 
 	req, _ = http.NewRequest("POST", "/make-reservation", strings.NewReader(reqBody))
 	ctx = getCtx(req)
@@ -278,10 +280,40 @@ func TestRepository_PostMakeReservation(t *testing.T) {
 	rr = httptest.NewRecorder()
 	app.Session.Put(ctx, "reservation", reservation)
 
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("PostMakeReservation handler returned wrong response code: got: %d, expected: %d", rr.Code, http.StatusBadRequest)
+	}
+	if !strings.Contains(rr.Body.String(), "match the laptop and dates you chose") {
+		t.Error("PostMakeReservation handler did not explain the mismatch")
+	}
+
+	// test case: laptop taken after the hold expired
+	reqBody = "start_date=" + reservation.StartDate.Format("2006-01-02")
+	reqBody = fmt.Sprintf("%s&%s", reqBody, "end_date="+reservation.EndDate.Format("2006-01-02"))
+	reqBody = fmt.Sprintf("%s&%s", reqBody, "first_name=John")
+	reqBody = fmt.Sprintf("%s&%s", reqBody, "last_name=Smith")
+	reqBody = fmt.Sprintf("%s&%s", reqBody, "email=john@smith.com")
+	reqBody = fmt.Sprintf("%s&%s", reqBody, "phone=123456789")
+	reqBody = fmt.Sprintf("%s&%s", reqBody, "laptop_id=1000")
+
+	req, _ = http.NewRequest("POST", "/make-reservation", strings.NewReader(reqBody))
+	ctx = getCtx(req)
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr = httptest.NewRecorder()
+	taken := reservation
+	taken.LaptopID = 1000
+	app.Session.Put(ctx, "reservation", taken)
+
 	handler.ServeHTTP(rr, req)
 	if rr.Code != http.StatusSeeOther {
 		t.Errorf("PostMakeReservation handler returned wrong response code: got: %d, expected: %d", rr.Code, http.StatusSeeOther)
 	}
+	if rr.Header().Get("Location") != "/search-availability" {
+		t.Errorf("PostMakeReservation handler redirected to %s, expected /search-availability", rr.Header().Get("Location"))
+	}
 }
 
 func TestRepository_PostSearchAvailability(t *testing.T) {
@@ -299,7 +331,7 @@ func TestRepository_PostSearchAvailability(t *testing.T) {
 		t.Errorf("PostSearchAvailability handler returned wrong response code: got: %d, expected: %d", rr.Code, http.StatusOK)
 	}
 
-	// test case: failure search availibility from database
+	// test case: no availability, the search page is shown again with suggestions
 	reqBody = "start_date=" + time.Now().Add(72*time.Hour).Format("2006-01-02")
 	reqBody = fmt.Sprintf("%s&%s", reqBody, "end_date="+time.Now().Add(96*time.Hour).Format("2006-01-02"))
 	req, _ = http.NewRequest("POST", "/search-availability", strings.NewReader(reqBody))
@@ -309,11 +341,11 @@ func TestRepository_PostSearchAvailability(t *testing.T) {
 
 	rr = httptest.NewRecorder()
 	handler.ServeHTTP(rr, req)
-	if rr.Code != http.StatusSeeOther {
-		t.Errorf("PostSearchAvailability handler returned wrong response code: got: %d, expected: %d", rr.Code, http.StatusSeeOther)
+	if rr.Code != http.StatusOK {
+		t.Errorf("PostSearchAvailability handler returned wrong response code: got: %d, expected: %d", rr.Code, http.StatusOK)
 	}
 
-	// test case: no availability
+	// test case: failure search availibility from database
 	reqBody = "start_date=" + time.Now().Add(96*time.Hour).Format("2006-01-02")
 	reqBody = fmt.Sprintf("%s&%s", reqBody, "end_date="+time.Now().Add(120*time.Hour).Format("2006-01-02"))
 	req, _ = http.NewRequest("POST", "/search-availability", strings.NewReader(reqBody))
@@ -323,8 +355,8 @@ func TestRepository_PostSearchAvailability(t *testing.T) {
 
 	rr = httptest.NewRecorder()
 	handler.ServeHTTP(rr, req)
-	if rr.Code != http.StatusSeeOther {
-		t.Errorf("PostSearchAvailability handler returned wrong response code: got: %d, expected: %d", rr.Code, http.StatusSeeOther)
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("PostSearchAvailability handler returned wrong response code: got: %d, expected: %d", rr.Code, http.StatusInternalServerError)
 	}
 
 	// test case: invalid request body
@@ -336,8 +368,8 @@ func TestRepository_PostSearchAvailability(t *testing.T) {
 	rr = httptest.NewRecorder()
 	handler = http.HandlerFunc(Repo.PostSearchAvailability)
 	handler.ServeHTTP(rr, req)
-	if rr.Code != http.StatusSeeOther {
-		t.Errorf("PostSearchAvailability handler returned wrong response code: got: %d, expected: %d", rr.Code, http.StatusSeeOther)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("PostSearchAvailability handler returned wrong response code: got: %d, expected: %d", rr.Code, http.StatusBadRequest)
 	}
 
 	// test case: invalid start date
@@ -350,8 +382,8 @@ func TestRepository_PostSearchAvailability(t *testing.T) {
 
 	rr = httptest.NewRecorder()
 	handler.ServeHTTP(rr, req)
-	if rr.Code != http.StatusSeeOther {
-		t.Errorf("PostSearchAvailability handler returned wrong response code: got: %d, expected: %d", rr.Code, http.StatusSeeOther)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("PostSearchAvailability handler returned wrong response code: got: %d, expected: %d", rr.Code, http.StatusBadRequest)
 	}
 
 	// test case: invalid end date
@@ -364,8 +396,8 @@ func TestRepository_PostSearchAvailability(t *testing.T) {
 
 	rr = httptest.NewRecorder()
 	handler.ServeHTTP(rr, req)
-	if rr.Code != http.StatusSeeOther {
-		t.Errorf("PostSearchAvailability handler returned wrong response code: got: %d, expected: %d", rr.Code, http.StatusSeeOther)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("PostSearchAvailability handler returned wrong response code: got: %d, expected: %d", rr.Code, http.StatusBadRequest)
 	}
 }
 
@@ -504,6 +536,67 @@ func TestRepository_SearchAvailabilityModal(t *testing.T) {
 	}
 }
 
+func TestRepository_SearchAvailabilityModal_Suggestions(t *testing.T) {
+	repo := newCalendarRepo(t)
+
+	reqBody := "start_date=2099-01-10&end_date=2099-01-11&laptop_id=1"
+	req, _ := http.NewRequest("POST", "/search-availability-modal", strings.NewReader(reqBody))
+	req = req.WithContext(getCtx(req))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(repo.SearchAvailabilityModal).ServeHTTP(rr, req)
+
+	var jr jsonResponse
+	err := json.Unmarshal(rr.Body.Bytes(), &jr)
+	if err != nil {
+		t.Fatal("failed to parse json")
+	}
+	if jr.OK || jr.Suggestions == nil {
+		t.Fatalf("expected suggestions for an unavailable laptop, got %s", rr.Body.String())
+	}
+	if len(jr.Suggestions.Windows) == 0 || jr.Suggestions.Windows[0].StartDate != "2099-01-08" {
+		t.Errorf("expected the nearest free window to start on 2099-01-08, got %+v", jr.Suggestions.Windows)
+	}
+	if len(jr.Suggestions.Laptops) != 1 || jr.Suggestions.Laptops[0].LaptopID != 2 {
+		t.Errorf("expected laptop 2 to be suggested, got %+v", jr.Suggestions.Laptops)
+	}
+
+	// available laptops come without suggestions
+	reqBody = "start_date=2099-01-10&end_date=2099-01-11&laptop_id=2"
+	req, _ = http.NewRequest("POST", "/search-availability-modal", strings.NewReader(reqBody))
+	req = req.WithContext(getCtx(req))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(repo.SearchAvailabilityModal).ServeHTTP(rr, req)
+	if strings.Contains(rr.Body.String(), "suggestions") {
+		t.Errorf("unexpected suggestions for an available laptop: %s", rr.Body.String())
+	}
+}
+
+func TestRepository_PostSearchAvailability_Suggestions(t *testing.T) {
+	repo := newCalendarRepo(t)
+	err := repo.DB.InsertBlockByLaptopID(2, time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2099, 1, 31, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reqBody := "start_date=2099-01-10&end_date=2099-01-11"
+	req, _ := http.NewRequest("POST", "/search-availability", strings.NewReader(reqBody))
+	req = req.WithContext(getCtx(req))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(repo.PostSearchAvailability).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("PostSearchAvailability handler returned wrong response code: got: %d, expected: %d", rr.Code, http.StatusOK)
+	}
+	if !strings.Contains(rr.Body.String(), "/rent-laptop?id=1&s=2099-01-08&e=2099-01-09") {
+		t.Error("nearest free dates of laptop 1 not suggested")
+	}
+}
+
 func TestRepository_ReservationSummary(t *testing.T) {
 	reservation := models.Reservation{
 		LaptopID: 1,
@@ -598,8 +691,8 @@ func TestRepository_ChooseLaptop(t *testing.T) {
 
 	handler.ServeHTTP(rr, req)
 
-	if rr.Code != http.StatusSeeOther {
-		t.Errorf("ChooseLaptop handler returned wrong response code: got: %d, expected: %d", rr.Code, http.StatusSeeOther)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("ChooseLaptop handler returned wrong response code: got: %d, expected: %d", rr.Code, http.StatusBadRequest)
 	}
 }
 
@@ -638,8 +731,8 @@ func TestRepository_RentLaptop(t *testing.T) {
 
 	handler.ServeHTTP(rr, req)
 
-	if rr.Code != http.StatusSeeOther {
-		t.Errorf("RentLaptop handler returned wrong response code: got: %d, expected %d", rr.Code, http.StatusSeeOther)
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("RentLaptop handler returned wrong response code: got: %d, expected %d", rr.Code, http.StatusInternalServerError)
 	}
 
 	// test case: invalid laptop id
@@ -653,8 +746,8 @@ func TestRepository_RentLaptop(t *testing.T) {
 
 	handler.ServeHTTP(rr, req)
 
-	if rr.Code != http.StatusSeeOther {
-		t.Errorf("RentLaptop handler returned wrong response code: got: %d, expected %d", rr.Code, http.StatusSeeOther)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("RentLaptop handler returned wrong response code: got: %d, expected %d", rr.Code, http.StatusBadRequest)
 	}
 
 	// test case: invalid start date
@@ -668,8 +761,8 @@ func TestRepository_RentLaptop(t *testing.T) {
 
 	handler.ServeHTTP(rr, req)
 
-	if rr.Code != http.StatusSeeOther {
-		t.Errorf("RentLaptop handler returned wrong response code: got: %d, expected %d", rr.Code, http.StatusSeeOther)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("RentLaptop handler returned wrong response code: got: %d, expected %d", rr.Code, http.StatusBadRequest)
 	}
 
 	// test case: invalid end date
@@ -683,8 +776,8 @@ func TestRepository_RentLaptop(t *testing.T) {
 
 	handler.ServeHTTP(rr, req)
 
-	if rr.Code != http.StatusSeeOther {
-		t.Errorf("RentLaptop handler returned wrong response code: got: %d, expected %d", rr.Code, http.StatusSeeOther)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("RentLaptop handler returned wrong response code: got: %d, expected %d", rr.Code, http.StatusBadRequest)
 	}
 }
 
@@ -769,35 +862,10 @@ func TestPostAdminReservationCalendar(t *testing.T) {
 		} else {
 			req, _ = http.NewRequest("POST", "/admin/reservations-calendar", nil)
 		}
+		// the calendar doesn't have to be shown first, nothing is read from the session
 		ctx := getCtx(req)
 		req = req.WithContext(ctx)
 
-		now := time.Now()
-		bm := make(map[string]int)
-		rm := make(map[string]int)
-
-		currentYear, currentMonth, _ := now.Date()
-		currentLocation := now.Location()
-
-		firstOfMonth := time.Date(currentYear, currentMonth, 1, 0, 0, 0, 0, currentLocation)
-		lastOfMonth := firstOfMonth.AddDate(0, 1, -1)
-
-		for d := firstOfMonth; d.After(lastOfMonth) == false; d = d.AddDate(0, 0, 1) {
-			rm[d.Format("2006-01-2")] = 0
-			bm[d.Format("2006-01-2")] = 0
-		}
-
-		if e.blocks > 0 {
-			bm[firstOfMonth.Format("2006-01-2")] = e.blocks
-		}
-
-		if e.reservations > 0 {
-			rm[lastOfMonth.Format("2006-01-2")] = e.reservations
-		}
-
-		app.Session.Put(ctx, "block_map_1", bm)
-		app.Session.Put(ctx, "reservation_map_1", rm)
-
 		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 		rr := httptest.NewRecorder()
 
@@ -848,3 +916,52 @@ func TestAdminPostShowReservation(t *testing.T) {
 		}
 	}
 }
+
+func TestPostAdminRevokeSession(t *testing.T) {
+	// a session to revoke
+	otherCtx, _ := app.Session.Load(context.Background(), "")
+	app.Session.Put(otherCtx, "user_id", 1)
+	otherToken, _, err := app.Session.Commit(otherCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name          string
+		id            string
+		expectedFlash string
+		expectedError string
+	}{
+		{"own session", "", "", "log out to end your own session"},
+		{"revoke", sessions.ID(otherToken), "Session revoked", ""},
+		{"already revoked", sessions.ID(otherToken), "", "session not found, it may have expired"},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest("POST", "/admin/sessions/revoke", strings.NewReader(url.Values{"id": {test.id}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		if test.id == "" {
+			req.Form = url.Values{"id": {sessions.ID(app.Session.Token(ctx))}}
+		}
+
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(Repo.PostAdminRevokeSession)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusSeeOther {
+			t.Errorf("failed %s: expected code %d, but got %d", test.name, http.StatusSeeOther, rr.Code)
+		}
+		if flash := app.Session.GetString(ctx, "flash"); flash != test.expectedFlash {
+			t.Errorf("failed %s: expected flash %q, but got %q", test.name, test.expectedFlash, flash)
+		}
+		if msg := app.Session.GetString(ctx, "error"); msg != test.expectedError {
+			t.Errorf("failed %s: expected error %q, but got %q", test.name, test.expectedError, msg)
+		}
+	}
+
+	if _, found, _ := app.Session.Store.Find(otherToken); found {
+		t.Error("revoked session still in the store")
+	}
+}
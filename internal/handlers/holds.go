@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+)
+
+// holdReservation holds the dates of res for App.HoldDuration while the customer fills in the reservation form,
+// the hold already in the session is kept if it is for the same dates and hasn't expired
+func (repo *Repository) holdReservation(ctx context.Context, res models.Reservation) (models.LaptopRestriction, error) {
+	hold, ok := repo.App.Session.Get(ctx, "hold").(models.LaptopRestriction)
+	if ok {
+		if hold.LaptopID == res.LaptopID && hold.StartDate.Equal(res.StartDate) && hold.EndDate.Equal(res.EndDate) && hold.ExpiresAt.After(time.Now()) {
+			return hold, nil
+		}
+		// the customer chose other dates or took too long
+		err := repo.DB.DeleteHold(hold.ID)
+		if err != nil {
+			return models.LaptopRestriction{}, err
+		}
+		repo.App.Session.Remove(ctx, "hold")
+		repo.notifyWaitlist(hold.LaptopID)
+	}
+
+	expiresAt := time.Now().Add(repo.App.HoldDuration)
+	id, err := repo.DB.InsertHold(res.LaptopID, res.StartDate, res.EndDate, expiresAt)
+	if err != nil {
+		return models.LaptopRestriction{}, err
+	}
+
+	hold = models.LaptopRestriction{
+		ID:            id,
+		StartDate:     res.StartDate,
+		EndDate:       res.EndDate,
+		LaptopID:      res.LaptopID,
+		RestrictionID: models.RestrictionHold,
+		ExpiresAt:     expiresAt,
+	}
+	repo.App.Session.Put(ctx, "hold", hold)
+	return hold, nil
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+)
+
+func makeReservation(repo *Repository, ctx context.Context) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", "/make-reservation", nil)
+	req = req.WithContext(ctx)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(repo.MakeReservation).ServeHTTP(rr, req)
+	return rr
+}
+
+func TestMakeReservation_Hold(t *testing.T) {
+	// laptop 1 is reserved from 2099-01-10 to 2099-01-12
+	repo := newCalendarRepo(t)
+	res := models.Reservation{
+		LaptopID:  1,
+		StartDate: time.Date(2099, 2, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2099, 2, 3, 0, 0, 0, 0, time.UTC),
+	}
+
+	req, _ := http.NewRequest("GET", "/make-reservation", nil)
+	first := getCtx(req)
+	app.Session.Put(first, "reservation", res)
+	rr := makeReservation(repo, first)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected the reservation form, got %d", rr.Code)
+	}
+	if ok, _ := repo.DB.SearchAvailabilityByDatesByLaptopID(res.StartDate, res.EndDate, 1); ok {
+		t.Fatal("held dates are still available")
+	}
+
+	// reloading the form keeps the same hold
+	hold := app.Session.Get(first, "hold").(models.LaptopRestriction)
+	makeReservation(repo, first)
+	if app.Session.Get(first, "hold").(models.LaptopRestriction).ID != hold.ID {
+		t.Error("reloading the form placed a new hold")
+	}
+
+	// another customer can't get to the form for the same dates
+	req, _ = http.NewRequest("GET", "/make-reservation", nil)
+	second := getCtx(req)
+	app.Session.Put(second, "reservation", res)
+	rr = makeReservation(repo, second)
+	if loc, _ := rr.Result().Location(); rr.Code != http.StatusSeeOther || loc.String() != "/search-availability" {
+		t.Fatalf("expected a redirect to /search-availability, got %d %v", rr.Code, loc)
+	}
+
+	// submitting the form turns the hold into the reservation
+	data := url.Values{}
+	data.Add("first_name", "Jane")
+	data.Add("last_name", "Doe")
+	data.Add("email", "jane@doe.com")
+	data.Add("laptop_id", "1")
+	data.Add("start_date", "2099-02-01")
+	data.Add("end_date", "2099-02-03")
+	req, _ = http.NewRequest("POST", "/make-reservation", strings.NewReader(data.Encode()))
+	req = req.WithContext(first)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(repo.PostMakeReservation).ServeHTTP(rr, req)
+	if loc, _ := rr.Result().Location(); rr.Code != http.StatusSeeOther || loc.String() != "/reservation-summary" {
+		t.Fatalf("expected a redirect to /reservation-summary, got %d %v", rr.Code, loc)
+	}
+	if app.Session.Exists(first, "hold") {
+		t.Error("the hold was left in the session")
+	}
+
+	restrictions, _ := repo.DB.GetLaptopRestrictionsByDate(1, res.StartDate, res.EndDate)
+	if len(restrictions) != 1 || restrictions[0].RestrictionID != models.RestrictionReservation || restrictions[0].ReservationID == 0 {
+		t.Errorf("expected the hold to become a reservation, got %+v", restrictions)
+	}
+}
+
+func TestMakeReservation_ExpiredHold(t *testing.T) {
+	repo := newCalendarRepo(t)
+	res := models.Reservation{
+		LaptopID:  1,
+		StartDate: time.Date(2099, 2, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2099, 2, 3, 0, 0, 0, 0, time.UTC),
+	}
+
+	_, err := repo.DB.InsertHold(1, res.StartDate, res.EndDate, time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest("GET", "/make-reservation", nil)
+	ctx := getCtx(req)
+	app.Session.Put(ctx, "reservation", res)
+	rr := makeReservation(repo, ctx)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("an expired hold kept the dates, got %d", rr.Code)
+	}
+
+	n, err := repo.DB.DeleteExpiredHolds(time.Now())
+	if err != nil || n != 1 {
+		t.Errorf("expected 1 expired hold to be deleted, got %d, %v", n, err)
+	}
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/dates"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/render"
+)
+
+// jobRunsShown is how many runs the jobs page lists
+const jobRunsShown = 100
+
+// jobInfo is a background job on the jobs page
+type jobInfo struct {
+	Name     string
+	Schedule string
+	Retries  int
+	Next     time.Time
+	// Last is the last run of the job by any instance, nil if it never ran
+	Last *jobRunInfo
+}
+
+// jobRunInfo is a run on the jobs page, in the business timezone
+type jobRunInfo struct {
+	models.JobRun
+	Duration time.Duration
+}
+
+// AdminJobs shows the background jobs with their next and last runs, and the history of their runs,
+// of the job in the job query parameter only if it is set
+func (repo *Repository) AdminJobs(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("job")
+	now := dates.In(time.Now())
+
+	var list []jobInfo
+	if repo.Jobs != nil {
+		for _, j := range repo.Jobs.Jobs() {
+			info := jobInfo{
+				Name:     j.Name,
+				Schedule: j.Schedule.String(),
+				Retries:  j.Retries,
+				Next:     j.Schedule.Next(now),
+			}
+			last, err := repo.DB.GetJobRuns(j.Name, 1)
+			if err != nil {
+				render.Error(w, r, err)
+				return
+			}
+			if len(last) > 0 {
+				run := newJobRunInfo(last[0])
+				info.Last = &run
+			}
+			list = append(list, info)
+		}
+	}
+
+	runs, err := repo.DB.GetJobRuns(name, jobRunsShown)
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+	var history []jobRunInfo
+	for _, run := range runs {
+		history = append(history, newJobRunInfo(run))
+	}
+
+	stringMap := make(map[string]string)
+	stringMap["job"] = name
+	data := make(map[string]interface{})
+	data["jobs"] = list
+	data["runs"] = history
+	render.Template(w, r, "admin-jobs.page.html", &models.TemplateData{
+		StringMap: stringMap,
+		Data:      data,
+	})
+}
+
+func newJobRunInfo(run models.JobRun) jobRunInfo {
+	info := jobRunInfo{JobRun: run, Duration: run.FinishedAt.Sub(run.StartedAt).Round(time.Millisecond)}
+	info.ScheduledAt = dates.In(run.ScheduledAt)
+	info.StartedAt = dates.In(run.StartedAt)
+	info.FinishedAt = dates.In(run.FinishedAt)
+	return info
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/jobs"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+)
+
+func TestAdminJobs(t *testing.T) {
+	repo := newCalendarRepo(t)
+	repo.Jobs = jobs.New(repo.DB, "host-1", app.InfoLog, app.ErrorLog)
+	for _, name := range []string{"expire-holds", "reminders"} {
+		err := repo.Jobs.Add(jobs.Job{
+			Name:     name,
+			Schedule: jobs.MustParseSchedule("@hourly"),
+			Retries:  2,
+			Run:      func(ctx context.Context) (string, error) { return "", nil },
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	started := time.Now().Add(-time.Hour)
+	repo.DB.InsertJobRun(&models.JobRun{
+		Name: "reminders", Owner: "host-1", ScheduledAt: started, StartedAt: started, FinishedAt: started.Add(2 * time.Second),
+		Attempts: 3, Status: models.JobFailed, Message: "mail server down",
+	})
+	repo.DB.InsertJobRun(&models.JobRun{
+		Name: "expire-holds", Owner: "host-2", ScheduledAt: started, StartedAt: started, FinishedAt: started,
+		Attempts: 1, Status: models.JobSucceeded, Message: "released 2 expired holds",
+	})
+
+	rr := getList(repo.AdminJobs, "/admin/jobs")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected the jobs page, got %d", rr.Code)
+	}
+	body := rr.Body.String()
+	for _, expected := range []string{"expire-holds", "<code>@hourly</code>", "mail server down", "released 2 expired holds", "2s", "host-2"} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("jobs page is missing %q", expected)
+		}
+	}
+	if strings.Contains(body, "never") {
+		t.Error("expected the last run of both jobs")
+	}
+
+	body = getList(repo.AdminJobs, "/admin/jobs?job=reminders").Body.String()
+	if !strings.Contains(body, "Runs of reminders") || !strings.Contains(body, "mail server down") || strings.Contains(body, "released 2 expired holds") {
+		t.Error("expected only the runs of the reminders job")
+	}
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/apperrors"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/dates"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/render"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/reports"
+)
+
+// reportDefaultDays is how many days up to today a report covers when no dates are given
+const reportDefaultDays = 90
+
+// AdminReports shows the utilization and demand of each laptop between the start and end query parameters
+func (repo *Repository) AdminReports(w http.ResponseWriter, r *http.Request) {
+	start, end, err := reportRange(r)
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+
+	report, err := reports.Build(repo.DB, start, end)
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+
+	stringMap := make(map[string]string)
+	stringMap["start"] = start.Format(dates.Layout)
+	stringMap["end"] = end.Format(dates.Layout)
+
+	data := make(map[string]interface{})
+	data["report"] = report
+	data["weekdays"] = []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+
+	render.Template(w, r, "admin-reports.page.html", &models.TemplateData{
+		StringMap: stringMap,
+		Data:      data,
+	})
+}
+
+// AdminReportsCSV downloads the report of each laptop, or the demand heatmap with report=heatmap, as CSV
+func (repo *Repository) AdminReportsCSV(w http.ResponseWriter, r *http.Request) {
+	start, end, err := reportRange(r)
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+
+	report, err := reports.Build(repo.DB, start, end)
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+
+	write, name := report.WriteLaptopsCSV, "laptops"
+	if r.URL.Query().Get("report") == "heatmap" {
+		write, name = report.WriteHeatmapCSV, "heatmap"
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s-%s.csv"`,
+		name, start.Format(dates.Layout), end.Format(dates.Layout)))
+	err = write(w)
+	if err != nil {
+		repo.App.ErrorLog.Println(err)
+	}
+}
+
+// reportRange returns the start and end query parameters, the last reportDefaultDays up to today by default
+func reportRange(r *http.Request) (time.Time, time.Time, error) {
+	end := today()
+	if s := r.URL.Query().Get("end"); s != "" {
+		var err error
+		end, err = dates.Parse(s)
+		if err != nil {
+			return time.Time{}, time.Time{}, apperrors.BadRequest(err, "Invalid End Date")
+		}
+	}
+
+	start := end.AddDate(0, 0, 1-reportDefaultDays)
+	if s := r.URL.Query().Get("start"); s != "" {
+		var err error
+		start, err = dates.Parse(s)
+		if err != nil {
+			return time.Time{}, time.Time{}, apperrors.BadRequest(err, "Invalid Start Date")
+		}
+	}
+
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, apperrors.BadRequest(nil, "The end date can't be before the start date")
+	}
+	if end.Sub(start).Hours()/24 >= reports.MaxDays {
+		return time.Time{}, time.Time{}, apperrors.BadRequest(nil, "A report can cover at most %d days", reports.MaxDays)
+	}
+
+	return start, end, nil
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAdminReports(t *testing.T) {
+	repo := newCalendarRepo(t)
+
+	req, _ := http.NewRequest("GET", "/admin/reports?start=2099-01-01&end=2099-01-31", nil)
+	req = req.WithContext(getCtx(req))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(repo.AdminReports).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	body := rr.Body.String()
+	// laptop 1 is rented 3 of the 30 days it isn't blocked, the 10th to 12th are a Saturday to Monday
+	for _, expected := range []string{
+		"<td>3 / 30</td>",
+		"<td>10.0%</td>",
+		`<td class="heat-4">1</td>`,
+		`href="/admin/reports.csv?start=2099-01-01&end=2099-01-31"`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("report is missing %q", expected)
+		}
+	}
+}
+
+func TestAdminReports_InvalidRange(t *testing.T) {
+	repo := newCalendarRepo(t)
+
+	for _, query := range []string{
+		"start=2099-13-01",
+		"end=tomorrow",
+		"start=2099-02-01&end=2099-01-01",
+		"start=2090-01-01&end=2099-01-01",
+	} {
+		req, _ := http.NewRequest("GET", "/admin/reports?"+query, nil)
+		req = req.WithContext(getCtx(req))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(repo.AdminReports).ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", query, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+func TestAdminReportsCSV(t *testing.T) {
+	repo := newCalendarRepo(t)
+
+	req, _ := http.NewRequest("GET", "/admin/reports.csv?start=2099-01-01&end=2099-01-31", nil)
+	req = req.WithContext(getCtx(req))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(repo.AdminReportsCSV).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	if cd := rr.Header().Get("Content-Disposition"); cd != `attachment; filename="laptops-2099-01-01-2099-01-31.csv"` {
+		t.Errorf("unexpected content disposition %q", cd)
+	}
+	if !strings.Contains(rr.Body.String(), "\n1,Alienware M15 R2,2099-01-01,2099-01-31,31,1,30,3,10.0,1,0,0.0,") {
+		t.Errorf("unexpected CSV:\n%s", rr.Body.String())
+	}
+
+	req, _ = http.NewRequest("GET", "/admin/reports.csv?report=heatmap&start=2099-01-01&end=2099-01-31", nil)
+	req = req.WithContext(getCtx(req))
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(repo.AdminReportsCSV).ServeHTTP(rr, req)
+
+	if !strings.Contains(rr.Body.String(), "\nJanuary,1,1,0,0,0,0,1\n") {
+		t.Errorf("unexpected heatmap CSV:\n%s", rr.Body.String())
+	}
+}
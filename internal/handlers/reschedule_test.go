@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+)
+
+// postAdminReservation posts the reservation form, the handler reads the reservation id from the request URI
+func postAdminReservation(repo *Repository, path string, data url.Values) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", path, strings.NewReader(data.Encode()))
+	req = req.WithContext(getCtx(req))
+	req.RequestURI = path
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(repo.PostAdminShowReservation).ServeHTTP(rr, req)
+	return rr
+}
+
+func reservationForm(laptopID, start, end string) url.Values {
+	return url.Values{
+		"first_name": {"John"},
+		"last_name":  {"Smith"},
+		"email":      {"john@smith.com"},
+		"laptop_id":  {laptopID},
+		"start_date": {start},
+		"end_date":   {end},
+	}
+}
+
+func TestPostAdminShowReservation_Reschedule(t *testing.T) {
+	repo := newCalendarRepo(t)
+	mailApp := app
+	mailApp.MailChan = make(chan models.MailData, 10)
+	repo.App = &mailApp
+
+	// overlapping its own dates is fine
+	rr := postAdminReservation(repo, "/admin/reservations/all/1", reservationForm("1", "2099-01-11", "2099-01-13"))
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected a redirect, got %d", rr.Code)
+	}
+	res, _ := repo.DB.GetReservatioByID(1)
+	if !res.StartDate.Equal(time.Date(2099, 1, 11, 0, 0, 0, 0, time.UTC)) || !res.EndDate.Equal(time.Date(2099, 1, 13, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the reservation to be moved, got %s to %s", res.StartDate, res.EndDate)
+	}
+	if len(mailApp.MailChan) != 0 {
+		t.Error("the customer was emailed without asking")
+	}
+
+	data := reservationForm("2", "2099-01-11", "2099-01-13")
+	data.Set("notify", "1")
+	rr = postAdminReservation(repo, "/admin/reservations/all/1", data)
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected a redirect, got %d", rr.Code)
+	}
+	if res, _ := repo.DB.GetReservatioByID(1); res.LaptopID != 2 {
+		t.Errorf("expected the reservation on laptop 2, got %d", res.LaptopID)
+	}
+	available, _ := repo.DB.SearchAvailabilityByDatesByLaptopID(time.Date(2099, 1, 11, 0, 0, 0, 0, time.UTC), time.Date(2099, 1, 13, 0, 0, 0, 0, time.UTC), 1)
+	if !available {
+		t.Error("laptop 1 is still restricted on the old dates")
+	}
+	if len(mailApp.MailChan) != 1 {
+		t.Fatalf("expected 1 mail, got %d", len(mailApp.MailChan))
+	}
+	mail := <-mailApp.MailChan
+	if mail.To != "john@smith.com" || !strings.Contains(mail.Content, "Macbook Pro 15 inch from January 11, 2099 to January 13, 2099") {
+		t.Errorf("unexpected mail %+v", mail)
+	}
+}
+
+func TestMailReservationChange_Locale(t *testing.T) {
+	mailApp := app
+	mailApp.MailChan = make(chan models.MailData, 1)
+	repo := &Repository{App: &mailApp}
+
+	res := models.Reservation{
+		FirstName: "<b>Taro</b>",
+		Email:     "taro@example.com",
+		StartDate: time.Date(2099, 1, 11, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2099, 1, 13, 0, 0, 0, 0, time.UTC),
+		Locale:    "ja",
+	}
+	res.Laptop.LaptopName = "Macbook Pro 15 inch"
+	repo.mailReservationChange(res)
+
+	mail := <-mailApp.MailChan
+	if mail.Subject != "ご予約の変更" {
+		t.Errorf("expected the subject in Japanese, got %q", mail.Subject)
+	}
+	for _, want := range []string{"&lt;b&gt;Taro&lt;/b&gt; 様", "2099年1月11日", "2099年1月13日"} {
+		if !strings.Contains(mail.Content, want) {
+			t.Errorf("expected %q in the mail, got %s", want, mail.Content)
+		}
+	}
+}
+
+func TestPostAdminShowReservation_RescheduleNotAvailable(t *testing.T) {
+	repo := newCalendarRepo(t)
+
+	// laptop 1 is blocked on 2099-01-20
+	rr := postAdminReservation(repo, "/admin/reservations/all/1", reservationForm("1", "2099-01-19", "2099-01-21"))
+	if rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), "The laptop isn&#39;t available for those dates") {
+		t.Fatalf("expected the form with the availability error, got %d", rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), `value="2099-01-19"`) {
+		t.Error("expected the form to keep the chosen dates")
+	}
+	if res, _ := repo.DB.GetReservatioByID(1); !res.StartDate.Equal(time.Date(2099, 1, 10, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("the reservation was moved to %s", res.StartDate)
+	}
+}
+
+func TestPostAdminShowReservation_RescheduleBadRequest(t *testing.T) {
+	repo := newCalendarRepo(t)
+
+	tests := []struct {
+		name string
+		data url.Values
+	}{
+		{"end before start", reservationForm("1", "2099-01-13", "2099-01-11")},
+		{"invalid date", reservationForm("1", "2099-13-01", "2099-01-11")},
+		{"invalid laptop", reservationForm("x", "2099-01-11", "2099-01-13")},
+		{"unknown laptop", reservationForm("99", "2099-01-11", "2099-01-13")},
+	}
+
+	for _, test := range tests {
+		rr := postAdminReservation(repo, "/admin/reservations/all/1", test.data)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected %d, got %d", test.name, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+func TestAdminShowReservation_Reminders(t *testing.T) {
+	repo := newCalendarRepo(t)
+	_, err := repo.DB.RecordReminder(1, models.ReminderPickup, time.Date(2099, 1, 8, 9, 30, 0, 0, time.Local))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest("GET", "/admin/reservations/all/1/show", nil)
+	req = req.WithContext(getCtx(req))
+	req.RequestURI = "/admin/reservations/all/1/show"
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(repo.AdminShowReservation).ServeHTTP(rr, req)
+
+	body := rr.Body.String()
+	if !strings.Contains(body, "Reminders sent") || !strings.Contains(body, "<td>pickup</td>") || !strings.Contains(body, "2099-01-08 09:30") {
+		t.Errorf("expected the pickup reminder to be listed, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/apperrors"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/dates"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+)
+
+// reservationsPerPage is how many reservations a page of the admin lists shows
+const reservationsPerPage = 25
+
+// pageLinks is how many pages around the current one the admin lists link to
+const pageLinks = 2
+
+// reservationList is a page of an admin reservation list, the links keep the other query parameters
+type reservationList struct {
+	Reservations []models.Reservation
+	// Total is the number of reservations on all pages
+	Total int
+	Page  int
+	Pages int
+	// Prev and Next link to the previous and next page, empty on the first and last one
+	Prev string
+	Next string
+	// PageLinks link to the first, the last and the pages around the current one
+	PageLinks []pageLink
+	// Sorts link to the list sorted by each of models.ReservationSorts
+	Sorts map[string]sortLink
+}
+
+// pageLink links to a page of a list, a zero Page is a gap between links
+type pageLink struct {
+	Page    int
+	URL     string
+	Current bool
+}
+
+// sortLink links to a list sorted by a column, Arrow shows the direction if the list is sorted by it
+type sortLink struct {
+	URL   string
+	Arrow string
+}
+
+// reservationFilter reads the filter, sort and page of an admin reservation list from the query,
+// the list is sorted by start date, the latest first, by default
+func reservationFilter(r *http.Request) (models.ReservationFilter, int, error) {
+	q := r.URL.Query()
+	f := models.ReservationFilter{
+		Search:    q.Get("q"),
+		Processed: q.Get("processed"),
+		Sort:      "start",
+		Desc:      true,
+	}
+
+	var err error
+	if s := q.Get("laptop"); s != "" {
+		f.LaptopID, err = strconv.Atoi(s)
+		if err != nil {
+			return f, 0, apperrors.BadRequest(err, "Invalid Laptop ID")
+		}
+	}
+	if s := q.Get("start"); s != "" {
+		f.Start, err = dates.Parse(s)
+		if err != nil {
+			return f, 0, apperrors.BadRequest(err, "Invalid Start Date")
+		}
+	}
+	if s := q.Get("end"); s != "" {
+		f.End, err = dates.Parse(s)
+		if err != nil {
+			return f, 0, apperrors.BadRequest(err, "Invalid End Date")
+		}
+	}
+	if f.Processed != "" && f.Processed != models.ReservationsNew && f.Processed != models.ReservationsProcessed {
+		return f, 0, apperrors.BadRequest(nil, "Invalid processed state")
+	}
+
+	if s := q.Get("sort"); s != "" {
+		if !validSort(s) {
+			return f, 0, apperrors.BadRequest(nil, "Invalid sort")
+		}
+		f.Sort = s
+	}
+	switch q.Get("dir") {
+	case "":
+	case "asc":
+		f.Desc = false
+	case "desc":
+		f.Desc = true
+	default:
+		return f, 0, apperrors.BadRequest(nil, "Invalid sort")
+	}
+
+	page := 1
+	if s := q.Get("page"); s != "" {
+		page, err = strconv.Atoi(s)
+		if err != nil || page < 1 {
+			return f, 0, apperrors.BadRequest(err, "Invalid page")
+		}
+	}
+	f.Limit = reservationsPerPage
+	f.Offset = (page - 1) * reservationsPerPage
+
+	return f, page, nil
+}
+
+func validSort(s string) bool {
+	for _, sort := range models.ReservationSorts {
+		if s == sort {
+			return true
+		}
+	}
+	return false
+}
+
+// searchReservations returns the page of the admin reservation list asked for by the query of r,
+// processed overrides the processed query parameter if not empty
+func (repo *Repository) searchReservations(r *http.Request, processed string) (reservationList, error) {
+	f, page, err := reservationFilter(r)
+	if err != nil {
+		return reservationList{}, err
+	}
+	if processed != "" {
+		f.Processed = processed
+	}
+
+	reservations, total, err := repo.DB.SearchReservations(f)
+	if err != nil {
+		return reservationList{}, err
+	}
+
+	list := reservationList{
+		Reservations: reservations,
+		Total:        total,
+		Page:         page,
+		Pages:        (total + reservationsPerPage - 1) / reservationsPerPage,
+		Sorts:        make(map[string]sortLink),
+	}
+
+	link := func(set map[string]string) string {
+		q := r.URL.Query()
+		for k, v := range set {
+			if v == "" {
+				q.Del(k)
+			} else {
+				q.Set(k, v)
+			}
+		}
+		return (&url.URL{Path: r.URL.Path, RawQuery: q.Encode()}).String()
+	}
+	pageURL := func(p int) string {
+		if p == 1 {
+			return link(map[string]string{"page": ""})
+		}
+		return link(map[string]string{"page": strconv.Itoa(p)})
+	}
+
+	if page > 1 {
+		list.Prev = pageURL(page - 1)
+	}
+	if page < list.Pages {
+		list.Next = pageURL(page + 1)
+	}
+	for p := 1; p <= list.Pages; p++ {
+		if p != 1 && p != list.Pages && (p < page-pageLinks || p > page+pageLinks) {
+			// one gap for every run of pages left out
+			if n := len(list.PageLinks); n > 0 && list.PageLinks[n-1].Page != 0 {
+				list.PageLinks = append(list.PageLinks, pageLink{})
+			}
+			continue
+		}
+		list.PageLinks = append(list.PageLinks, pageLink{Page: p, URL: pageURL(p), Current: p == page})
+	}
+
+	// sorting by another column starts ascending, sorting by the same one again reverses it
+	for _, col := range models.ReservationSorts {
+		dir, arrow := "asc", ""
+		if col == f.Sort {
+			arrow = " ▲"
+			if f.Desc {
+				arrow = " ▼"
+			} else {
+				dir = "desc"
+			}
+		}
+		list.Sorts[col] = sortLink{URL: link(map[string]string{"sort": col, "dir": dir, "page": ""}), Arrow: arrow}
+	}
+
+	return list, nil
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/database"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+)
+
+// newListRepo returns a repository on the in-memory database with 60 reservations of laptop 1,
+// Customer01 to Customer60 starting on consecutive days, the even ones processed
+func newListRepo(t *testing.T) *Repository {
+	db := database.NewMemory(&app)
+
+	for i := 1; i <= 60; i++ {
+		id, err := db.InsertReservation(&models.Reservation{
+			FirstName: "John",
+			LastName:  fmt.Sprintf("Customer%02d", i),
+			Email:     fmt.Sprintf("customer%02d@example.com", i),
+			StartDate: time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, i),
+			EndDate:   time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, i),
+			LaptopID:  1,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i%2 == 0 {
+			db.UpdateReservationProcessed(id, 1)
+		}
+	}
+
+	return &Repository{App: &app, DB: db}
+}
+
+func getList(handler http.HandlerFunc, path string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", path, nil)
+	req = req.WithContext(getCtx(req))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestAdminAllReservations_List(t *testing.T) {
+	repo := newListRepo(t)
+
+	tests := []struct {
+		name       string
+		path       string
+		contains   []string
+		notContain []string
+	}{
+		{
+			"latest start date first",
+			"/admin/reservations-all",
+			[]string{"60 reservations", "Customer60", "Customer36", `href="/admin/reservations-all?page=2"`, "Start Date ▼"},
+			[]string{"Customer35", `href="/admin/reservations-all?page=4"`},
+		},
+		{
+			"second page",
+			"/admin/reservations-all?page=2",
+			[]string{"Customer35", "Customer11", `href="/admin/reservations-all"`, `href="/admin/reservations-all?page=3"`},
+			[]string{"Customer36", "Customer10"},
+		},
+		{
+			"sorted by name",
+			"/admin/reservations-all?sort=name&dir=asc",
+			[]string{"Customer01", "Last Name ▲", `href="/admin/reservations-all?dir=desc&amp;sort=name"`},
+			[]string{"Customer26"},
+		},
+		{
+			"search",
+			"/admin/reservations-all?q=CUSTOMER0",
+			[]string{"9 reservations", "Customer09", `value="CUSTOMER0"`},
+			[]string{"Customer10", "pagination"},
+		},
+		{
+			"processed",
+			"/admin/reservations-all?processed=processed&start=2099-01-02&end=2099-01-05",
+			[]string{"2 reservations", "Customer02", "Customer04", `<option value="processed" selected>`},
+			[]string{"Customer01", "Customer03"},
+		},
+		{
+			"laptop",
+			"/admin/reservations-all?laptop=2",
+			[]string{"0 reservations", "No reservations found"},
+			nil,
+		},
+	}
+
+	for _, test := range tests {
+		rr := getList(repo.AdminAllReservations, test.path)
+		if rr.Code != http.StatusOK {
+			t.Errorf("%s: expected status %d, got %d", test.name, http.StatusOK, rr.Code)
+			continue
+		}
+		body := rr.Body.String()
+		for _, s := range test.contains {
+			if !strings.Contains(body, s) {
+				t.Errorf("%s: page is missing %q", test.name, s)
+			}
+		}
+		for _, s := range test.notContain {
+			if strings.Contains(body, s) {
+				t.Errorf("%s: page shows %q", test.name, s)
+			}
+		}
+	}
+}
+
+func TestAdminNewReservations_List(t *testing.T) {
+	repo := newListRepo(t)
+
+	// the processed parameter can't show processed reservations on the list of new ones
+	rr := getList(repo.AdminNewReservations, "/admin/reservations-new?processed=processed")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	body := rr.Body.String()
+	if !strings.Contains(body, "30 reservations") || strings.Contains(body, "Customer60") || !strings.Contains(body, "Customer59") {
+		t.Error("list of new reservations shows processed ones")
+	}
+	if !strings.Contains(body, "/admin/reservations/new/59/show") {
+		t.Error("list of new reservations doesn't link to the new reservation page")
+	}
+}
+
+func TestAdminAllReservations_InvalidQuery(t *testing.T) {
+	repo := newListRepo(t)
+
+	for _, query := range []string{"page=0", "page=x", "sort=email", "dir=up", "laptop=x", "start=2099-02-30", "end=x", "processed=maybe"} {
+		rr := getList(repo.AdminAllReservations, "/admin/reservations-all?"+query)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", query, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/forms"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/rules"
+)
+
+// checkBookingRules adds the booking rules broken by renting laptopID from start to end to the errors of form,
+// laptop id 0 checks the rules of every laptop only
+func (repo *Repository) checkBookingRules(form *forms.Form, laptopID int, start, end time.Time) error {
+	violations, err := rules.Check(repo.DB, laptopID, start, end)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range violations {
+		form.Errors.Add(v.Field, v.In(form.Locale))
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/i18n"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+)
+
+func postForm(handler http.HandlerFunc, path string, data url.Values) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", path, strings.NewReader(data.Encode()))
+	req = req.WithContext(getCtx(req))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestPostSearchAvailability_BookingRules(t *testing.T) {
+	repo := newCalendarRepo(t)
+	err := repo.DB.SaveBookingRules(&models.BookingRules{MinDays: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repo.DB.InsertBlackout(&models.Blackout{LaptopID: 2, StartDate: time.Date(2099, 2, 3, 0, 0, 0, 0, time.UTC), EndDate: time.Date(2099, 2, 3, 0, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the rules of every laptop are form errors
+	data := url.Values{"start_date": {"2099-02-01"}, "end_date": {"2099-02-02"}}
+	rr := postForm(repo.PostSearchAvailability, "/search-availability", data)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Rentals must be at least 3 days long") {
+		t.Errorf("expected the search form with the minimum length error, got %d", rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), `value="2099-02-01"`) {
+		t.Error("the search form lost its dates")
+	}
+
+	data.Set("end_date", "2099-01-31")
+	rr = postForm(repo.PostSearchAvailability, "/search-availability", data)
+	if !strings.Contains(rr.Body.String(), "The end date can&#39;t be before the start date") {
+		t.Error("a reversed search was accepted")
+	}
+
+	// the blackout of laptop 2 leaves it out
+	data.Set("end_date", "2099-02-03")
+	rr = postForm(repo.PostSearchAvailability, "/search-availability", data)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Alienware M15 R2") || strings.Contains(rr.Body.String(), "Macbook Pro 15 inch") {
+		t.Errorf("expected only laptop 1 to be offered, got %d", rr.Code)
+	}
+}
+
+func TestSearchAvailabilityModal_BookingRules(t *testing.T) {
+	repo := newCalendarRepo(t)
+	err := repo.DB.SaveBookingRules(&models.BookingRules{LaptopID: 1, MaxDays: 2, PickupWeekdays: []time.Weekday{time.Monday}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Thursday to Saturday
+	data := url.Values{"start_date": {"2099-02-05"}, "end_date": {"2099-02-07"}, "laptop_id": {"1"}}
+	rr := postForm(repo.SearchAvailabilityModal, "/search-availability-modal", data)
+
+	var resp jsonResponse
+	err = json.Unmarshal(rr.Body.Bytes(), &resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.OK || resp.LaptopID != "" {
+		t.Errorf("expected a refusal without a waitlist, got %+v", resp)
+	}
+	if len(resp.Errors["end_date"]) != 1 || len(resp.Errors["start_date"]) != 1 {
+		t.Errorf("expected an error for each date, got %v", resp.Errors)
+	}
+	if resp.Message != "Rentals can't be longer than 2 days. Laptops can only be picked up on Monday." {
+		t.Errorf("unexpected message %q", resp.Message)
+	}
+
+	// Monday to Tuesday, and laptop 2 has no rules of its own
+	for _, data := range []url.Values{
+		{"start_date": {"2099-02-02"}, "end_date": {"2099-02-03"}, "laptop_id": {"1"}},
+		{"start_date": {"2099-02-05"}, "end_date": {"2099-02-07"}, "laptop_id": {"2"}},
+	} {
+		rr = postForm(repo.SearchAvailabilityModal, "/search-availability-modal", data)
+		resp = jsonResponse{}
+		json.Unmarshal(rr.Body.Bytes(), &resp)
+		if !resp.OK {
+			t.Errorf("%v: expected availability, got %+v", data, resp)
+		}
+	}
+}
+
+func TestPostMakeReservation_BookingRules(t *testing.T) {
+	repo := newCalendarRepo(t)
+	err := repo.DB.SaveBookingRules(&models.BookingRules{MinDays: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := models.Reservation{
+		LaptopID:  1,
+		StartDate: time.Date(2099, 2, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2099, 2, 2, 0, 0, 0, 0, time.UTC),
+	}
+	data := url.Values{
+		"first_name": {"Jane"},
+		"last_name":  {"Doe"},
+		"email":      {"jane@doe.com"},
+		"laptop_id":  {"1"},
+		"start_date": {"2099-02-01"},
+		"end_date":   {"2099-02-02"},
+	}
+
+	req, _ := http.NewRequest("POST", "/make-reservation", strings.NewReader(data.Encode()))
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	app.Session.Put(ctx, "reservation", res)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(repo.PostMakeReservation).ServeHTTP(rr, req)
+	if !strings.Contains(rr.Body.String(), "Rentals must be at least 3 days long") {
+		t.Error("the minimum length error is not shown")
+	}
+	if restrictions, _ := repo.DB.GetLaptopRestrictionsByDate(1, res.StartDate, res.EndDate); len(restrictions) != 0 {
+		t.Errorf("a reservation breaking the rules was made: %+v", restrictions)
+	}
+}
+
+func TestBookingRules_Japanese(t *testing.T) {
+	repo := newCalendarRepo(t)
+	err := repo.DB.SaveBookingRules(&models.BookingRules{MinDays: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := url.Values{"start_date": {"2099-02-01"}, "end_date": {"2099-02-02"}, "laptop_id": {"1"}}
+	req, _ := http.NewRequest("POST", "/search-availability", strings.NewReader(data.Encode()))
+	req = req.WithContext(i18n.WithLocale(getCtx(req), "ja"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(repo.PostSearchAvailability).ServeHTTP(rr, req)
+
+	body := rr.Body.String()
+	for _, s := range []string{`<html lang="ja">`, "空き状況を検索", "レンタル期間は3日以上にしてください"} {
+		if !strings.Contains(body, s) {
+			t.Errorf("the Japanese search page is missing %q", s)
+		}
+	}
+
+	req, _ = http.NewRequest("POST", "/search-availability-modal", strings.NewReader(data.Encode()))
+	req = req.WithContext(i18n.WithLocale(getCtx(req), "ja"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(repo.SearchAvailabilityModal).ServeHTTP(rr, req)
+
+	var resp jsonResponse
+	err = json.Unmarshal(rr.Body.Bytes(), &resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Message != "レンタル期間は3日以上にしてください。" {
+		t.Errorf("unexpected message %q", resp.Message)
+	}
+}
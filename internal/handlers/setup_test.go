@@ -17,6 +17,7 @@ import (
 	"github.com/kaitolucifer/go-laptop-rental-site/internal/config"
 	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
 	"github.com/kaitolucifer/go-laptop-rental-site/internal/render"
+	"github.com/kaitolucifer/go-laptop-rental-site/static"
 )
 
 var app config.AppConfig
@@ -40,6 +41,21 @@ var getTests = []struct {
 	{"new reservations", "/admin/reservations-new", http.StatusOK},
 	{"all reservations", "/admin/reservations-all", http.StatusOK},
 	{"show reservation", "/admin/reservations/new/1/show", http.StatusOK},
+	{"active sessions", "/admin/sessions", http.StatusOK},
+	{"background jobs", "/admin/jobs", http.StatusOK},
+	{"background jobs of one job", "/admin/jobs?job=expire-holds", http.StatusOK},
+	{"new booking", "/admin/create-reservation", http.StatusOK},
+	{"timeline", "/admin/timeline", http.StatusOK},
+	{"timeline week", "/admin/timeline?view=week&start=2099-01-05", http.StatusOK},
+	{"timeline invalid view", "/admin/timeline?view=year", http.StatusBadRequest},
+	{"timeline json", "/admin/timeline.json?start=2099-01-01&end=2099-01-31", http.StatusOK},
+	{"timeline json too long", "/admin/timeline.json?start=2099-01-01&end=2099-12-31", http.StatusBadRequest},
+	{"reports", "/admin/reports", http.StatusOK},
+	{"reports csv", "/admin/reports.csv", http.StatusOK},
+	{"reports reversed dates", "/admin/reports?start=2099-02-01&end=2099-01-01", http.StatusBadRequest},
+	{"laptop calendar", "/laptops/1/calendar", http.StatusOK},
+	{"laptop calendar json", "/laptops/1/calendar.json?y=2099&m=1", http.StatusOK},
+	{"waitlist", "/waitlist?id=1&s=2099-01-10&e=2099-01-12", http.StatusOK},
 }
 
 var loginTests = []struct {
@@ -118,29 +134,40 @@ var postAdminReservationCalendarTests = []struct {
 	expectedResponseCode int
 	expectedLocation     string
 	expectedHTML         string
-	blocks               int
-	reservations         int
 }{
 	{
 		name: "cal",
 		postedData: url.Values{
-			"year":  {time.Now().Format("2006")},
-			"month": {time.Now().Format("01")},
+			"y": {time.Now().Format("2006")},
+			"m": {time.Now().Format("01")},
 			fmt.Sprintf("add_block_1_%s", time.Now().AddDate(0, 0, 2).Format("2006-01-2")): {"1"},
 		},
 		expectedResponseCode: http.StatusSeeOther,
 	},
 	{
-		name:                 "cal-blocks",
-		postedData:           url.Values{},
+		name:                 "cal-remove-block",
+		postedData:           url.Values{"y": {time.Now().Format("2006")}, "m": {time.Now().Format("01")}, fmt.Sprintf("remove_block_1_%s", time.Now().Format("2006-01-2")): {"1"}},
 		expectedResponseCode: http.StatusSeeOther,
-		blocks:               1,
 	},
 	{
-		name:                 "cal-res",
-		postedData:           url.Values{},
+		name:                 "cal-nothing-changed",
+		postedData:           url.Values{"y": {time.Now().Format("2006")}, "m": {time.Now().Format("01")}},
 		expectedResponseCode: http.StatusSeeOther,
-		reservations:         1,
+	},
+	{
+		name:                 "cal-invalid-version",
+		postedData:           url.Values{"y": {time.Now().Format("2006")}, "m": {time.Now().Format("01")}, fmt.Sprintf("remove_block_1_%s", time.Now().Format("2006-01-2")): {"on"}},
+		expectedResponseCode: http.StatusBadRequest,
+	},
+	{
+		name:                 "cal-invalid-block",
+		postedData:           url.Values{"y": {time.Now().Format("2006")}, "m": {time.Now().Format("01")}, "add_block_1": {"1"}},
+		expectedResponseCode: http.StatusBadRequest,
+	},
+	{
+		name:                 "cal-no-month",
+		postedData:           url.Values{},
+		expectedResponseCode: http.StatusBadRequest,
 	},
 }
 
@@ -204,6 +231,7 @@ func TestMain(m *testing.M) {
 	gob.Register(map[string]int{})
 
 	app.InProduction = false
+	app.HoldDuration = 15 * time.Minute
 
 	app.InfoLog = log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
 	app.ErrorLog = log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
@@ -256,8 +284,13 @@ func getRoutes() http.Handler {
 	mux.Get("/alienware", Repo.Alienware)
 	mux.Get("/macbook", Repo.Macbook)
 	mux.Get("/search-availability", Repo.SearchAvailability)
+	mux.Get("/waitlist", Repo.JoinWaitlist)
+	mux.Post("/waitlist", Repo.PostJoinWaitlist)
+	mux.Get("/waitlist/claim", Repo.ClaimWaitlist)
 	mux.Get("/make-reservation", Repo.MakeReservation)
 	mux.Get("/reservation-summary", Repo.ReservationSummary)
+	mux.Get("/laptops/{id}/calendar", Repo.LaptopCalendar)
+	mux.Get("/laptops/{id}/calendar.json", Repo.LaptopCalendarJSON)
 
 	mux.Get("/user/login", Repo.Login)
 	mux.Get("/user/logout", Repo.Logout)
@@ -270,12 +303,24 @@ func getRoutes() http.Handler {
 		mux.Get("/reservations/{type}/{id}/show", Repo.AdminShowReservation)
 		mux.Get("/process-reservation/{type}/{id}/do", Repo.AdminProcessReservation)
 		mux.Get("/delete-reservation/{type}/{id}/do", Repo.AdminDeleteReservation)
+		mux.Post("/reservations/bulk", Repo.PostAdminBulkReservations)
+		mux.Get("/create-reservation", Repo.AdminCreateReservation)
+		mux.Post("/create-reservation", Repo.PostAdminCreateReservation)
 		mux.Get("/reservations-calendar", Repo.AdminReservationsCalendar)
 		mux.Post("/reservations-calendar", Repo.PostAdminReservationsCalendar)
+		mux.Get("/timeline", Repo.AdminTimeline)
+		mux.Get("/timeline.json", Repo.AdminTimelineJSON)
+		mux.Post("/timeline/reservations/{id}.json", Repo.PostAdminTimelineReservation)
+		mux.Post("/timeline/blocks/{id}.json", Repo.PostAdminTimelineBlock)
+		mux.Get("/reports", Repo.AdminReports)
+		mux.Get("/reports.csv", Repo.AdminReportsCSV)
+		mux.Get("/sessions", Repo.AdminSessions)
+		mux.Post("/sessions/revoke", Repo.PostAdminRevokeSession)
+		mux.Get("/jobs", Repo.AdminJobs)
 	})
 
 	// static files
-	fileServer := http.FileServer(http.Dir("./static/"))
+	fileServer := http.FileServer(http.FS(static.FS))
 	mux.Handle("/static/*", http.StripPrefix("/static", fileServer))
 	return mux
 }
@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/apperrors"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/database"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/dates"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/render"
+)
+
+// timelineMaxDays is the longest range the timeline JSON covers, a quarter
+const timelineMaxDays = 92
+
+// timelineViews are the ranges the timeline can show, by the view query parameter
+var timelineViews = map[string]struct {
+	months int
+	days   int
+}{
+	"week":    {days: 7},
+	"month":   {months: 1},
+	"quarter": {months: 3},
+}
+
+// timelineBar is a reservation or block on the timeline
+type timelineBar struct {
+	ID        int    `json:"id"`
+	Type      string `json:"type"`
+	LaptopID  int    `json:"laptop_id"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+	Label     string `json:"label"`
+	Processed bool   `json:"processed"`
+	URL       string `json:"url,omitempty"`
+}
+
+// timelineLaptop is a row of the timeline
+type timelineLaptop struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type timelineResponse struct {
+	OK        bool             `json:"ok"`
+	StartDate string           `json:"start_date"`
+	EndDate   string           `json:"end_date"`
+	Laptops   []timelineLaptop `json:"laptops"`
+	Bars      []timelineBar    `json:"bars"`
+}
+
+type timelineMoveResponse struct {
+	OK  bool        `json:"ok"`
+	Bar timelineBar `json:"bar"`
+}
+
+// AdminTimeline shows the reservations and blocks of every laptop as bars that can be dragged,
+// over the week, month or quarter of the view query parameter starting on start
+func (repo *Repository) AdminTimeline(w http.ResponseWriter, r *http.Request) {
+	view := r.URL.Query().Get("view")
+	if view == "" {
+		view = "month"
+	}
+	length, ok := timelineViews[view]
+	if !ok {
+		render.Error(w, r, apperrors.BadRequest(nil, "Invalid view"))
+		return
+	}
+
+	start := today()
+	if view != "week" {
+		start = start.AddDate(0, 0, 1-start.Day())
+	}
+	if s := r.URL.Query().Get("start"); s != "" {
+		var err error
+		start, err = dates.Parse(s)
+		if err != nil {
+			render.Error(w, r, apperrors.BadRequest(err, "Invalid Start Date"))
+			return
+		}
+	}
+	next := start.AddDate(0, length.months, length.days)
+
+	stringMap := make(map[string]string)
+	stringMap["view"] = view
+	stringMap["start"] = start.Format(dates.Layout)
+	stringMap["end"] = next.AddDate(0, 0, -1).Format(dates.Layout)
+	stringMap["previous"] = start.AddDate(0, -length.months, -length.days).Format(dates.Layout)
+	stringMap["next"] = next.Format(dates.Layout)
+
+	render.Template(w, r, "admin-timeline.page.html", &models.TemplateData{
+		StringMap: stringMap,
+	})
+}
+
+// AdminTimelineJSON returns the laptops and the reservations and blocks overlapping the start and end
+// query parameters as JSON
+func (repo *Repository) AdminTimelineJSON(w http.ResponseWriter, r *http.Request) {
+	start, err := dates.Parse(r.URL.Query().Get("start"))
+	if err != nil {
+		render.ErrorJSON(w, r, apperrors.BadRequest(err, "Invalid Start Date"))
+		return
+	}
+	end, err := dates.Parse(r.URL.Query().Get("end"))
+	if err != nil {
+		render.ErrorJSON(w, r, apperrors.BadRequest(err, "Invalid End Date"))
+		return
+	}
+	if end.Before(start) {
+		render.ErrorJSON(w, r, apperrors.BadRequest(nil, "The end date can't be before the start date"))
+		return
+	}
+	if end.Sub(start).Hours()/24 >= timelineMaxDays {
+		render.ErrorJSON(w, r, apperrors.BadRequest(nil, "The timeline can cover at most %d days", timelineMaxDays))
+		return
+	}
+
+	laptops, err := repo.DB.AllLaptops()
+	if err != nil {
+		render.ErrorJSON(w, r, err)
+		return
+	}
+	reservations, err := repo.DB.GetReservationsByDate(start, end)
+	if err != nil {
+		render.ErrorJSON(w, r, err)
+		return
+	}
+	blocks, err := repo.DB.GetBlocksByDate(start, end)
+	if err != nil {
+		render.ErrorJSON(w, r, err)
+		return
+	}
+
+	resp := timelineResponse{
+		OK:        true,
+		StartDate: start.Format(dates.Layout),
+		EndDate:   end.Format(dates.Layout),
+		Laptops:   []timelineLaptop{},
+		Bars:      []timelineBar{},
+	}
+	for _, l := range laptops {
+		resp.Laptops = append(resp.Laptops, timelineLaptop{ID: l.ID, Name: l.LaptopName})
+	}
+	for _, res := range reservations {
+		resp.Bars = append(resp.Bars, reservationBar(res))
+	}
+	for _, b := range blocks {
+		resp.Bars = append(resp.Bars, blockBar(b))
+	}
+
+	writeJSON(w, resp)
+}
+
+// PostAdminTimelineReservation moves the reservation of /admin/timeline/reservations/{id}.json to the laptop_id,
+// start_date and end_date form values, answering 409 if they overlap another reservation or block
+func (repo *Repository) PostAdminTimelineReservation(w http.ResponseWriter, r *http.Request) {
+	id, laptopID, start, end, err := timelineMove(r)
+	if err != nil {
+		render.ErrorJSON(w, r, err)
+		return
+	}
+
+	res, err := repo.DB.GetReservatioByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		render.ErrorJSON(w, r, apperrors.NotFound(err, "Reservation not found"))
+		return
+	}
+	if err != nil {
+		render.ErrorJSON(w, r, err)
+		return
+	}
+
+	from := res.LaptopID
+	res.LaptopID, res.StartDate, res.EndDate = laptopID, start, end
+	err = repo.DB.RescheduleReservation(&res)
+	if err != nil {
+		render.ErrorJSON(w, r, timelineError(err))
+		return
+	}
+	repo.notifyWaitlist(from)
+
+	writeJSON(w, timelineMoveResponse{OK: true, Bar: reservationBar(res)})
+}
+
+// PostAdminTimelineBlock moves the block of /admin/timeline/blocks/{id}.json to the laptop_id, start_date and
+// end_date form values, answering 409 if they overlap a reservation or another block
+func (repo *Repository) PostAdminTimelineBlock(w http.ResponseWriter, r *http.Request) {
+	id, laptopID, start, end, err := timelineMove(r)
+	if err != nil {
+		render.ErrorJSON(w, r, err)
+		return
+	}
+
+	from, err := repo.DB.MoveBlock(id, laptopID, start, end)
+	if errors.Is(err, sql.ErrNoRows) {
+		render.ErrorJSON(w, r, apperrors.NotFound(err, "Block not found"))
+		return
+	}
+	if err != nil {
+		render.ErrorJSON(w, r, timelineError(err))
+		return
+	}
+	// the laptop the block is moved from may free dates for its waitlist
+	repo.notifyWaitlist(from)
+
+	writeJSON(w, timelineMoveResponse{OK: true, Bar: blockBar(models.LaptopRestriction{
+		ID: id, LaptopID: laptopID, StartDate: start, EndDate: end,
+	})})
+}
+
+// timelineMove returns the id at the end of the path and the laptop and dates a bar is moved to
+func timelineMove(r *http.Request) (int, int, time.Time, time.Time, error) {
+	err := r.ParseForm()
+	if err != nil {
+		return 0, 0, time.Time{}, time.Time{}, apperrors.BadRequest(err, "The form could not be read")
+	}
+
+	name := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	id, err := strconv.Atoi(strings.TrimSuffix(name, ".json"))
+	if err != nil {
+		return 0, 0, time.Time{}, time.Time{}, apperrors.BadRequest(err, "Invalid ID")
+	}
+	laptopID, err := strconv.Atoi(r.Form.Get("laptop_id"))
+	if err != nil {
+		return 0, 0, time.Time{}, time.Time{}, apperrors.BadRequest(err, "Invalid Laptop ID")
+	}
+	start, err := dates.Parse(r.Form.Get("start_date"))
+	if err != nil {
+		return 0, 0, time.Time{}, time.Time{}, apperrors.BadRequest(err, "Invalid Start Date")
+	}
+	end, err := dates.Parse(r.Form.Get("end_date"))
+	if err != nil {
+		return 0, 0, time.Time{}, time.Time{}, apperrors.BadRequest(err, "Invalid End Date")
+	}
+	if end.Before(start) {
+		return 0, 0, time.Time{}, time.Time{}, apperrors.BadRequest(nil, "The end date can't be before the start date")
+	}
+
+	return id, laptopID, start, end, nil
+}
+
+// timelineError is the answer to a move that failed, a conflict when the dates are taken
+func timelineError(err error) error {
+	if errors.Is(err, database.ErrNotAvailable) {
+		return apperrors.New(http.StatusConflict, err, "The laptop isn't available for those dates")
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return apperrors.NotFound(err, "Invalid Laptop ID")
+	}
+	return err
+}
+
+func reservationBar(res models.Reservation) timelineBar {
+	return timelineBar{
+		ID:        res.ID,
+		Type:      "reservation",
+		LaptopID:  res.LaptopID,
+		StartDate: res.StartDate.Format(dates.Layout),
+		EndDate:   res.EndDate.Format(dates.Layout),
+		Label:     fmt.Sprintf("%s %s", res.FirstName, res.LastName),
+		Processed: res.Processed == 1,
+		URL:       fmt.Sprintf("/admin/reservations/all/%d/show", res.ID),
+	}
+}
+
+func blockBar(b models.LaptopRestriction) timelineBar {
+	return timelineBar{
+		ID:        b.ID,
+		Type:      "block",
+		LaptopID:  b.LaptopID,
+		StartDate: b.StartDate.Format(dates.Layout),
+		EndDate:   b.EndDate.Format(dates.Layout),
+		Label:     "Blocked",
+	}
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func moveForm(laptopID, start, end string) url.Values {
+	return url.Values{
+		"laptop_id":  {laptopID},
+		"start_date": {start},
+		"end_date":   {end},
+	}
+}
+
+func TestAdminTimelineJSON(t *testing.T) {
+	repo := newCalendarRepo(t)
+
+	rr := getList(repo.AdminTimelineJSON, "/admin/timeline.json?start=2099-01-01&end=2099-01-31")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected the timeline, got %d", rr.Code)
+	}
+	var resp timelineResponse
+	err := json.Unmarshal(rr.Body.Bytes(), &resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.OK || len(resp.Laptops) != 2 {
+		t.Fatalf("expected 2 laptops, got %+v", resp)
+	}
+	if len(resp.Bars) != 2 {
+		t.Fatalf("expected the reservation and the block, got %+v", resp.Bars)
+	}
+	res := resp.Bars[0]
+	if res.Type != "reservation" || res.ID != 1 || res.LaptopID != 1 || res.StartDate != "2099-01-10" || res.EndDate != "2099-01-12" ||
+		res.Label != "John Smith" || res.URL != "/admin/reservations/all/1/show" {
+		t.Errorf("unexpected reservation %+v", res)
+	}
+	if block := resp.Bars[1]; block.Type != "block" || block.StartDate != "2099-01-20" || block.EndDate != "2099-01-20" {
+		t.Errorf("unexpected block %+v", block)
+	}
+
+	rr = getList(repo.AdminTimelineJSON, "/admin/timeline.json?start=2099-02-01&end=2099-02-28")
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if len(resp.Bars) != 0 {
+		t.Errorf("expected no bars in February, got %+v", resp.Bars)
+	}
+}
+
+func TestPostAdminTimelineReservation(t *testing.T) {
+	repo := newCalendarRepo(t)
+
+	rr := postForm(repo.PostAdminTimelineReservation, "/admin/timeline/reservations/1.json", moveForm("2", "2099-01-14", "2099-01-17"))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected the reservation to be moved, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp timelineMoveResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if !resp.OK || resp.Bar.LaptopID != 2 || resp.Bar.StartDate != "2099-01-14" || resp.Bar.EndDate != "2099-01-17" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+
+	res, _ := repo.DB.GetReservatioByID(1)
+	if res.LaptopID != 2 || !res.StartDate.Equal(time.Date(2099, 1, 14, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the reservation on laptop 2 from 2099-01-14, got %d from %s", res.LaptopID, res.StartDate)
+	}
+	available, _ := repo.DB.SearchAvailabilityByDatesByLaptopID(time.Date(2099, 1, 10, 0, 0, 0, 0, time.UTC), time.Date(2099, 1, 12, 0, 0, 0, 0, time.UTC), 1)
+	if !available {
+		t.Error("laptop 1 is still restricted on the old dates")
+	}
+}
+
+func TestPostAdminTimelineBlock(t *testing.T) {
+	repo := newCalendarRepo(t)
+
+	// the block of laptop 1 on 2099-01-20 is restriction 2, stretched over two more days
+	rr := postForm(repo.PostAdminTimelineBlock, "/admin/timeline/blocks/2.json", moveForm("1", "2099-01-20", "2099-01-22"))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected the block to be resized, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	blocks, _ := repo.DB.GetBlocksByDate(time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2099, 1, 31, 0, 0, 0, 0, time.UTC))
+	if len(blocks) != 1 || !blocks[0].EndDate.Equal(time.Date(2099, 1, 22, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the block to end on 2099-01-22, got %+v", blocks)
+	}
+}
+
+func TestPostAdminTimeline_Errors(t *testing.T) {
+	repo := newCalendarRepo(t)
+
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		path     string
+		data     url.Values
+		code     int
+		expected string
+	}{
+		{"reservation onto the block", repo.PostAdminTimelineReservation, "/admin/timeline/reservations/1.json", moveForm("1", "2099-01-19", "2099-01-21"), http.StatusConflict, "The laptop isn't available for those dates"},
+		{"block onto the reservation", repo.PostAdminTimelineBlock, "/admin/timeline/blocks/2.json", moveForm("1", "2099-01-12", "2099-01-12"), http.StatusConflict, "The laptop isn't available for those dates"},
+		{"unknown reservation", repo.PostAdminTimelineReservation, "/admin/timeline/reservations/99.json", moveForm("1", "2099-02-01", "2099-02-02"), http.StatusNotFound, "Reservation not found"},
+		{"reservation moved as a block", repo.PostAdminTimelineBlock, "/admin/timeline/blocks/1.json", moveForm("1", "2099-02-01", "2099-02-02"), http.StatusNotFound, "Block not found"},
+		{"end before start", repo.PostAdminTimelineReservation, "/admin/timeline/reservations/1.json", moveForm("1", "2099-02-02", "2099-02-01"), http.StatusBadRequest, "The end date can't be before the start date"},
+		{"invalid date", repo.PostAdminTimelineBlock, "/admin/timeline/blocks/2.json", moveForm("1", "2099-02-30", "2099-03-01"), http.StatusBadRequest, "Invalid Start Date"},
+		{"invalid laptop", repo.PostAdminTimelineReservation, "/admin/timeline/reservations/1.json", moveForm("x", "2099-02-01", "2099-02-02"), http.StatusBadRequest, "Invalid Laptop ID"},
+		{"invalid id", repo.PostAdminTimelineReservation, "/admin/timeline/reservations/one.json", moveForm("1", "2099-02-01", "2099-02-02"), http.StatusBadRequest, "Invalid ID"},
+	}
+
+	for _, test := range tests {
+		rr := postForm(test.handler, test.path, test.data)
+		if rr.Code != test.code {
+			t.Errorf("%s: expected %d, got %d", test.name, test.code, rr.Code)
+			continue
+		}
+		var resp struct {
+			OK      bool   `json:"ok"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &resp)
+		if resp.OK || !strings.Contains(resp.Message, test.expected) {
+			t.Errorf("%s: expected %q, got %+v", test.name, test.expected, resp)
+		}
+	}
+
+	res, _ := repo.DB.GetReservatioByID(1)
+	if res.LaptopID != 1 || !res.StartDate.Equal(time.Date(2099, 1, 10, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("a refused move changed the reservation to %d from %s", res.LaptopID, res.StartDate)
+	}
+}
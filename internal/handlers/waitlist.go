@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/apperrors"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/forms"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/render"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/waitlist"
+)
+
+// JoinWaitlist shows the waitlist form for the laptop and dates given by the id, s and e URL parameters
+func (repo *Repository) JoinWaitlist(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+
+	laptopID, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		render.Error(w, r, apperrors.BadRequest(err, "Invalid Laptop ID"))
+		return
+	}
+
+	form := newForm(r, r.Form)
+	startDate, err := form.GetTimeObj("s")
+	if err != nil {
+		render.Error(w, r, apperrors.BadRequest(err, "Invalid Start Date"))
+		return
+	}
+	endDate, err := form.GetTimeObj("e")
+	if err != nil {
+		render.Error(w, r, apperrors.BadRequest(err, "Invalid End Date"))
+		return
+	}
+
+	laptop, err := repo.DB.GetLaptopByID(laptopID)
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+
+	entry := models.WaitlistEntry{
+		LaptopID:  laptopID,
+		StartDate: startDate,
+		EndDate:   endDate,
+		Locale:    form.Locale,
+		Laptop:    laptop,
+	}
+	repo.renderWaitlist(w, r, http.StatusOK, entry, forms.New(nil))
+}
+
+// PostJoinWaitlist puts a customer on the waitlist
+func (repo *Repository) PostJoinWaitlist(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		render.Error(w, r, apperrors.BadRequest(err, "The form could not be read"))
+		return
+	}
+
+	form := newForm(r, r.PostForm)
+
+	form.Required("first_name", "last_name", "email", "start_date", "end_date")
+	form.IsAboveMinLength("first_name", 3)
+	form.IsEmail("email")
+
+	startDate, err := form.GetTimeObj("start_date")
+	if err != nil {
+		render.Error(w, r, apperrors.BadRequest(err, "Invalid Start Date"))
+		return
+	}
+	endDate, err := form.GetTimeObj("end_date")
+	if err != nil {
+		render.Error(w, r, apperrors.BadRequest(err, "Invalid End Date"))
+		return
+	}
+	if endDate.Before(startDate) {
+		render.Error(w, r, apperrors.BadRequest(nil, "The end date can't be before the start date"))
+		return
+	}
+
+	laptopID, err := strconv.Atoi(r.Form.Get("laptop_id"))
+	if err != nil {
+		render.Error(w, r, apperrors.BadRequest(err, "Invalid Laptop ID"))
+		return
+	}
+
+	laptop, err := repo.DB.GetLaptopByID(laptopID)
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+
+	entry := models.WaitlistEntry{
+		FirstName: r.Form.Get("first_name"),
+		LastName:  r.Form.Get("last_name"),
+		Email:     r.Form.Get("email"),
+		LaptopID:  laptopID,
+		StartDate: startDate,
+		EndDate:   endDate,
+		Locale:    form.Locale,
+		Laptop:    laptop,
+	}
+
+	if !form.Valid() {
+		repo.renderWaitlist(w, r, http.StatusUnprocessableEntity, entry, form)
+		return
+	}
+
+	_, err = repo.DB.InsertWaitlistEntry(&entry)
+	if err != nil {
+		render.Error(w, r, err)
+		return
+	}
+
+	// the dates may have become free while the customer was filling in the form
+	repo.notifyWaitlist(laptopID)
+
+	repo.App.Session.Put(r.Context(), "flash", "You are on the waitlist, we'll email you when the laptop is available")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// ClaimWaitlist takes a customer from a hold link to the make reservation page
+func (repo *Repository) ClaimWaitlist(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+
+	entry, err := waitlist.Claim(repo.DB, token)
+	switch err {
+	case nil:
+	case waitlist.ErrHoldExpired:
+		// offer the dates to the next customer in line
+		repo.notifyWaitlist(entry.LaptopID)
+		repo.App.Session.Put(r.Context(), "error", "your hold has expired, please search again")
+		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+		return
+	case waitlist.ErrInvalidToken:
+		render.Error(w, r, apperrors.NotFound(err, "This waitlist link is invalid or has already been used"))
+		return
+	default:
+		render.Error(w, r, err)
+		return
+	}
+
+	res := models.Reservation{
+		FirstName: entry.FirstName,
+		LastName:  entry.LastName,
+		Email:     entry.Email,
+		LaptopID:  entry.LaptopID,
+		StartDate: entry.StartDate,
+		EndDate:   entry.EndDate,
+		Locale:    entry.Locale,
+	}
+	res.Laptop.LaptopName = entry.Laptop.LaptopName
+
+	// the dates are held for the customer until the hold link expires, their reservation converts the hold
+	hold := waitlist.Hold(entry)
+	old, ok := repo.App.Session.Get(r.Context(), "hold").(models.LaptopRestriction)
+	if ok && old.ID != hold.ID {
+		err = repo.DB.DeleteHold(old.ID)
+		if err != nil {
+			render.Error(w, r, err)
+			return
+		}
+		repo.notifyWaitlist(old.LaptopID)
+	}
+	repo.App.Session.Put(r.Context(), "hold", hold)
+
+	repo.App.Session.Put(r.Context(), "reservation", res)
+	repo.App.Session.Put(r.Context(), "waitlist_token", token)
+	http.Redirect(w, r, "/make-reservation", http.StatusSeeOther)
+}
+
+func (repo *Repository) renderWaitlist(w http.ResponseWriter, r *http.Request, status int, entry models.WaitlistEntry, form *forms.Form) {
+	data := make(map[string]interface{})
+	data["entry"] = entry
+
+	stringMap := make(map[string]string)
+	stringMap["start_date"] = entry.StartDate.Format("2006-01-02")
+	stringMap["end_date"] = entry.EndDate.Format("2006-01-02")
+
+	render.TemplateStatus(w, r, status, "waitlist.page.html", &models.TemplateData{
+		Form:      form,
+		Data:      data,
+		StringMap: stringMap,
+	})
+}
+
+// notifyWaitlist offers the free dates of a laptop to its waitlist, a failure must not fail the request that freed them
+func (repo *Repository) notifyWaitlist(laptopID int) {
+	_, err := waitlist.Notify(repo.App, repo.DB, laptopID)
+	if err != nil {
+		repo.App.ErrorLog.Println(err)
+	}
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/i18n"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+)
+
+func postWaitlist(repo *Repository, data url.Values) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", "/waitlist", strings.NewReader(data.Encode()))
+	req = req.WithContext(getCtx(req))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(repo.PostJoinWaitlist).ServeHTTP(rr, req)
+	return rr
+}
+
+func TestPostJoinWaitlist(t *testing.T) {
+	// laptop 1 is reserved from 2099-01-10 to 2099-01-12
+	repo := newCalendarRepo(t)
+
+	data := url.Values{}
+	data.Add("first_name", "Jane")
+	data.Add("last_name", "Doe")
+	data.Add("email", "jane@doe.com")
+	data.Add("laptop_id", "1")
+	data.Add("start_date", "2099-01-11")
+	data.Add("end_date", "2099-01-12")
+
+	rr := postWaitlist(repo, data)
+	if loc, _ := rr.Result().Location(); rr.Code != http.StatusSeeOther || loc.String() != "/" {
+		t.Fatalf("expected a redirect to /, got %d %v", rr.Code, loc)
+	}
+	entries, _ := repo.DB.GetWaitlistByLaptopID(1)
+	if len(entries) != 1 || entries[0].Email != "jane@doe.com" || entries[0].Status != models.WaitlistWaiting {
+		t.Fatalf("expected a waiting entry, got %+v", entries)
+	}
+
+	// invalid forms are shown again with errors
+	data.Set("email", "jane")
+	rr = postWaitlist(repo, data)
+	if rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), "Invalid email address") {
+		t.Errorf("invalid email not reported, got %d", rr.Code)
+	}
+
+	data.Set("email", "jane@doe.com")
+	data.Set("end_date", "2099-01-09")
+	rr = postWaitlist(repo, data)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected reversed dates to be refused, got %d", rr.Code)
+	}
+	entries, _ = repo.DB.GetWaitlistByLaptopID(1)
+	if len(entries) != 1 {
+		t.Errorf("invalid forms were put on the waitlist: %+v", entries)
+	}
+}
+
+func TestPostJoinWaitlist_Locale(t *testing.T) {
+	repo := newCalendarRepo(t)
+
+	data := url.Values{}
+	data.Add("first_name", "Hanako")
+	data.Add("last_name", "Yamada")
+	data.Add("email", "hanako@yamada.jp")
+	data.Add("laptop_id", "1")
+	data.Add("start_date", "2099-01-11")
+	data.Add("end_date", "2099-01-12")
+
+	req, _ := http.NewRequest("POST", "/waitlist", strings.NewReader(data.Encode()))
+	req = req.WithContext(i18n.WithLocale(getCtx(req), "ja"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(repo.PostJoinWaitlist).ServeHTTP(rr, req)
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected a redirect, got %d", rr.Code)
+	}
+
+	// the hold link is mailed in the language the customer joined in
+	entries, _ := repo.DB.GetWaitlistByLaptopID(1)
+	if len(entries) != 1 || entries[0].Locale != "ja" {
+		t.Fatalf("expected a Japanese entry, got %+v", entries)
+	}
+}
+
+func TestAdminDeleteReservation_NotifiesWaitlist(t *testing.T) {
+	repo := newCalendarRepo(t)
+
+	_, err := repo.DB.InsertWaitlistEntry(&models.WaitlistEntry{
+		FirstName: "Jane",
+		LastName:  "Doe",
+		Email:     "jane@doe.com",
+		LaptopID:  1,
+		StartDate: time.Date(2099, 1, 11, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2099, 1, 12, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest("GET", "/admin/delete-reservation/all/1/do", nil)
+	req = req.WithContext(getCtx(req))
+	req.RequestURI = "/admin/delete-reservation/all/1/do"
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(repo.AdminDeleteReservation).ServeHTTP(rr, req)
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected %d, got %d", http.StatusSeeOther, rr.Code)
+	}
+
+	entries, _ := repo.DB.GetWaitlistByLaptopID(1)
+	if len(entries) != 1 || entries[0].Status != models.WaitlistNotified || entries[0].Token == "" {
+		t.Fatalf("expected the waitlist to be notified, got %+v", entries)
+	}
+
+	// the hold link leads to the reservation form
+	req, _ = http.NewRequest("GET", "/waitlist/claim?token="+entries[0].Token, nil)
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(repo.ClaimWaitlist).ServeHTTP(rr, req)
+	if loc, _ := rr.Result().Location(); rr.Code != http.StatusSeeOther || loc.String() != "/make-reservation" {
+		t.Fatalf("expected a redirect to /make-reservation, got %d %v", rr.Code, loc)
+	}
+	res, ok := repo.App.Session.Get(ctx, "reservation").(models.Reservation)
+	if !ok || res.Email != "jane@doe.com" || res.LaptopID != 1 || res.StartDate.Format("2006-01-02") != "2099-01-11" {
+		t.Errorf("unexpected reservation in session: %+v", res)
+	}
+	// the dates stay held for the customer, their reservation converts the hold
+	hold, ok := repo.App.Session.Get(ctx, "hold").(models.LaptopRestriction)
+	if !ok || hold.ID != entries[0].HoldID || hold.RestrictionID != models.RestrictionHold ||
+		!hold.ExpiresAt.Equal(entries[0].HoldUntil) {
+		t.Errorf("expected the waitlist hold in session, got %+v", hold)
+	}
+
+	// unknown links are not found
+	req, _ = http.NewRequest("GET", "/waitlist/claim?token=unknown", nil)
+	req = req.WithContext(getCtx(req))
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(repo.ClaimWaitlist).ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected the 404 page, got %d", rr.Code)
+	}
+}
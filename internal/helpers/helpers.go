@@ -1,9 +1,7 @@
 package helpers
 
 import (
-	"fmt"
 	"net/http"
-	"runtime/debug"
 
 	"github.com/kaitolucifer/go-laptop-rental-site/internal/config"
 )
@@ -15,19 +13,6 @@ func NewHelpers(a *config.AppConfig) {
 	app = a
 }
 
-// ClientError handles client error
-func ClientError(w http.ResponseWriter, status int) {
-	app.InfoLog.Println("Client error with status of", status)
-	http.Error(w, http.StatusText(status), status)
-}
-
-// ServerError handles server error
-func ServerError(w http.ResponseWriter, err error) {
-	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
-	app.ErrorLog.Println(trace)
-	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-}
-
 // IsAuthenticated checks if user is authenticated
 func IsAuthenticated(r *http.Request) bool {
 	exists := app.Session.Exists(r.Context(), "user_id")
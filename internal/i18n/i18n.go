@@ -0,0 +1,155 @@
+package i18n
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// English is the language the messages are written in, it needs no catalog
+const English = "en"
+
+// catalogFS holds a catalog per language, locales/<language>.json maps English messages to their translation
+//
+//go:embed locales/*.json
+var catalogFS embed.FS
+
+// catalogs are the translations by language
+var catalogs = loadCatalogs()
+
+// loadCatalogs reads the embedded catalogs, a broken catalog is a build mistake so it panics
+func loadCatalogs() map[string]map[string]string {
+	files, err := catalogFS.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+
+	c := make(map[string]map[string]string)
+	for _, f := range files {
+		b, err := catalogFS.ReadFile(path.Join("locales", f.Name()))
+		if err != nil {
+			panic(err)
+		}
+		messages := make(map[string]string)
+		err = json.Unmarshal(b, &messages)
+		if err != nil {
+			panic(fmt.Sprintf("locales/%s: %s", f.Name(), err))
+		}
+		c[strings.TrimSuffix(f.Name(), ".json")] = messages
+	}
+	return c
+}
+
+// Supported reports whether locale is a language the site is served in
+func Supported(locale string) bool {
+	_, ok := catalogs[locale]
+	return locale == English || ok
+}
+
+// Locales returns the languages the site is served in, English first
+func Locales() []string {
+	locales := []string{English}
+	for l := range catalogs {
+		locales = append(locales, l)
+	}
+	sort.Strings(locales[1:])
+	return locales
+}
+
+// T translates the English message msg to locale and formats it with args like fmt.Sprintf,
+// messages missing from the catalog stay in English
+func T(locale, msg string, args ...interface{}) string {
+	if translated, ok := catalogs[locale][msg]; ok && translated != "" {
+		msg = translated
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
+
+// FormatDate formats t with layout in locale, the layout itself is translated first
+// so that a catalog can reorder it, then the month and weekday names are translated
+func FormatDate(locale string, t time.Time, layout string) string {
+	layout = T(locale, layout)
+	s := t.Format(layout)
+	if locale == English {
+		return s
+	}
+
+	switch {
+	case strings.Contains(layout, "January"):
+		s = strings.Replace(s, t.Month().String(), T(locale, t.Month().String()), 1)
+	case strings.Contains(layout, "Jan"):
+		s = strings.Replace(s, t.Month().String()[:3], T(locale, t.Month().String()[:3]), 1)
+	}
+	switch {
+	case strings.Contains(layout, "Monday"):
+		s = strings.Replace(s, t.Weekday().String(), T(locale, t.Weekday().String()), 1)
+	case strings.Contains(layout, "Mon"):
+		s = strings.Replace(s, t.Weekday().String()[:3], T(locale, t.Weekday().String()[:3]), 1)
+	}
+	return s
+}
+
+// Negotiate returns the supported language the Accept-Language header value prefers,
+// English if it prefers none of them
+func Negotiate(acceptLanguage string) string {
+	type choice struct {
+		locale string
+		q      float64
+	}
+
+	var choices []choice
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		// only the language matters, ja-JP is served in ja
+		locale := strings.ToLower(strings.SplitN(strings.TrimSpace(fields[0]), "-", 2)[0])
+		if locale == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				v, err := strconv.ParseFloat(param[2:], 64)
+				if err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 && Supported(locale) {
+			choices = append(choices, choice{locale, q})
+		}
+	}
+
+	// the order of the header breaks ties
+	sort.SliceStable(choices, func(i, j int) bool { return choices[i].q > choices[j].q })
+	if len(choices) == 0 {
+		return English
+	}
+	return choices[0].locale
+}
+
+type contextKey struct{}
+
+// WithLocale returns a copy of ctx carrying locale
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, contextKey{}, locale)
+}
+
+// FromContext returns the locale of a request, English if none was negotiated
+func FromContext(ctx context.Context) string {
+	locale, ok := ctx.Value(contextKey{}).(string)
+	if !ok || locale == "" {
+		return English
+	}
+	return locale
+}
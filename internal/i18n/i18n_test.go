@@ -0,0 +1,130 @@
+package i18n
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestT(t *testing.T) {
+	var tests = []struct {
+		locale   string
+		msg      string
+		args     []interface{}
+		expected string
+	}{
+		{"en", "This field cannot be blank", nil, "This field cannot be blank"},
+		{"ja", "This field cannot be blank", nil, "この項目は必須です"},
+		{"ja", "This field must be at least %d characters long", []interface{}{3}, "3 文字以上で入力してください"},
+		// messages missing from the catalog and unknown languages stay in English
+		{"ja", "Not in any catalog %d", []interface{}{1}, "Not in any catalog 1"},
+		{"fr", "This field cannot be blank", nil, "This field cannot be blank"},
+		{"", "This field cannot be blank", nil, "This field cannot be blank"},
+		// without args a message is not a format
+		{"en", "100% sure", nil, "100% sure"},
+	}
+
+	for _, e := range tests {
+		if got := T(e.locale, e.msg, e.args...); got != e.expected {
+			t.Errorf("%s %q: expected %q, got %q", e.locale, e.msg, e.expected, got)
+		}
+	}
+}
+
+// verbs matches the fmt verbs of a message
+var verbs = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+
+func TestCatalogs(t *testing.T) {
+	if len(catalogs["ja"]) == 0 {
+		t.Fatal("the ja catalog is missing")
+	}
+
+	for locale, messages := range catalogs {
+		for msg, translated := range messages {
+			if translated == "" {
+				t.Errorf("%s: %q is not translated", locale, msg)
+			}
+			// a translation may reorder its words but must keep the verbs of the message
+			want, got := verbs.FindAllString(msg, -1), verbs.FindAllString(translated, -1)
+			sort.Strings(want)
+			sort.Strings(got)
+			if strings.Join(want, " ") != strings.Join(got, " ") {
+				t.Errorf("%s: %q has verbs %v, its translation %q has %v", locale, msg, want, translated, got)
+			}
+		}
+	}
+}
+
+func TestLocales(t *testing.T) {
+	locales := Locales()
+	if len(locales) < 2 || locales[0] != English || locales[1] != "ja" {
+		t.Errorf("expected en first and ja, got %v", locales)
+	}
+	if !Supported("ja") || !Supported("en") || Supported("fr") || Supported("") {
+		t.Error("unexpected supported languages")
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	var tests = []struct {
+		header   string
+		expected string
+	}{
+		{"", "en"},
+		{"ja", "ja"},
+		{"ja-JP", "ja"},
+		{"JA-jp,en;q=0.5", "ja"},
+		{"en-US,en;q=0.9,ja;q=0.8", "en"},
+		{"fr-FR,fr;q=0.9,ja;q=0.8,en;q=0.7", "ja"},
+		{"en;q=0.5, ja;q=0.6", "ja"},
+		{"ja;q=0, en", "en"},
+		{"fr, de", "en"},
+		{"*", "en"},
+	}
+
+	for _, e := range tests {
+		if got := Negotiate(e.header); got != e.expected {
+			t.Errorf("%q: expected %s, got %s", e.header, e.expected, got)
+		}
+	}
+}
+
+func TestFormatDate(t *testing.T) {
+	// a Monday
+	d := time.Date(2099, 3, 2, 9, 5, 0, 0, time.UTC)
+
+	var tests = []struct {
+		locale   string
+		layout   string
+		expected string
+	}{
+		{"en", "January 2, 2006", "March 2, 2099"},
+		{"ja", "January 2, 2006", "2099年3月2日"},
+		{"en", "January 2006", "March 2099"},
+		{"ja", "January 2006", "2099年3月"},
+		{"ja", "January", "3月"},
+		{"ja", "2006-01-02", "2099-03-02"},
+		{"ja", "Mon Jan 2", "月 3月 2"},
+		{"ja", "Monday", "月曜日"},
+		{"fr", "January 2, 2006", "March 2, 2099"},
+	}
+
+	for _, e := range tests {
+		if got := FormatDate(e.locale, d, e.layout); got != e.expected {
+			t.Errorf("%s %q: expected %q, got %q", e.locale, e.layout, e.expected, got)
+		}
+	}
+}
+
+func TestContext(t *testing.T) {
+	ctx := context.Background()
+	if got := FromContext(ctx); got != English {
+		t.Errorf("expected English without a locale, got %q", got)
+	}
+	if got := FromContext(WithLocale(ctx, "ja")); got != "ja" {
+		t.Errorf("expected ja, got %q", got)
+	}
+}
@@ -0,0 +1,204 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/database"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/dates"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+)
+
+// DefaultLease is how long an instance holds a job it runs when the job doesn't say
+const DefaultLease = 5 * time.Minute
+
+// Job is a task run on a schedule by one instance of the app at a time
+type Job struct {
+	Name     string
+	Schedule Schedule
+	// Retries is how many more times a failed run is attempted, RetryDelay apart
+	Retries    int
+	RetryDelay time.Duration
+	// Lease is how long the instance running the job keeps the other instances from running it, every attempt
+	// has to end within it. A run the lease of the last one is still held for is skipped.
+	Lease time.Duration
+	// Run does the job and returns what it did for the history
+	Run func(ctx context.Context) (string, error)
+}
+
+// Scheduler runs the jobs added to it at the minutes they are scheduled for, in the business timezone. The
+// instances of the app sharing a database take a lease on each run, so that every run is done by one of them.
+type Scheduler struct {
+	DB database.DBRepository
+	// Owner names the instance in the leases and the history
+	Owner    string
+	InfoLog  *log.Logger
+	ErrorLog *log.Logger
+
+	mu   sync.RWMutex
+	jobs []Job
+}
+
+// New returns a scheduler without jobs, running them as owner
+func New(db database.DBRepository, owner string, infoLog, errorLog *log.Logger) *Scheduler {
+	return &Scheduler{
+		DB:       db,
+		Owner:    owner,
+		InfoLog:  infoLog,
+		ErrorLog: errorLog,
+	}
+}
+
+// Add adds a job to the scheduler, its name has to be unique
+func (s *Scheduler) Add(j Job) error {
+	if j.Name == "" || j.Run == nil || j.Schedule.IsZero() {
+		return errors.New("a job needs a name, a schedule and a run function")
+	}
+	if j.Retries < 0 {
+		return fmt.Errorf("job %s: negative retries", j.Name)
+	}
+	if j.Lease <= 0 {
+		j.Lease = DefaultLease
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, other := range s.jobs {
+		if other.Name == j.Name {
+			return fmt.Errorf("job %s already added", j.Name)
+		}
+	}
+	s.jobs = append(s.jobs, j)
+
+	return nil
+}
+
+// Jobs returns the jobs of the scheduler in the order they were added
+func (s *Scheduler) Jobs() []Job {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]Job(nil), s.jobs...)
+}
+
+// Start runs the jobs due at the start of every minute from now on
+func (s *Scheduler) Start() {
+	go func() {
+		for {
+			now := time.Now()
+			time.Sleep(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+			go s.RunDue(time.Now())
+		}
+	}()
+}
+
+// RunDue runs the jobs scheduled for the minute of now that no instance ran yet, and returns the runs it did
+// once they are over
+func (s *Scheduler) RunDue(now time.Time) []models.JobRun {
+	slot := dates.In(now).Truncate(time.Minute)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var runs []models.JobRun
+	for _, j := range s.Jobs() {
+		if !j.Schedule.Matches(slot) {
+			continue
+		}
+
+		wg.Add(1)
+		go func(j Job) {
+			defer wg.Done()
+			run, ok := s.run(j, slot)
+			if ok {
+				mu.Lock()
+				runs = append(runs, run)
+				mu.Unlock()
+			}
+		}(j)
+	}
+	wg.Wait()
+
+	return runs
+}
+
+// run runs the job scheduled at slot if it can take its lease, retrying it as many times as allowed, and
+// records the run. False is returned when the run was left to another instance.
+func (s *Scheduler) run(j Job, slot time.Time) (models.JobRun, bool) {
+	run := models.JobRun{
+		Name:        j.Name,
+		Owner:       s.Owner,
+		ScheduledAt: slot,
+		StartedAt:   time.Now(),
+	}
+
+	until := run.StartedAt.Add(j.Lease)
+	ok, err := s.DB.AcquireJobLease(j.Name, s.Owner, slot, until)
+	if err != nil {
+		s.ErrorLog.Printf("job %s: cannot take the lease: %s\n", j.Name, err)
+		return run, false
+	}
+	if !ok {
+		return run, false
+	}
+	defer func() {
+		err := s.DB.ReleaseJobLease(j.Name, s.Owner)
+		if err != nil {
+			s.ErrorLog.Printf("job %s: cannot release the lease: %s\n", j.Name, err)
+		}
+	}()
+
+	ctx, cancel := context.WithDeadline(context.Background(), until)
+	defer cancel()
+
+	var message string
+	for {
+		run.Attempts++
+		message, err = attempt(ctx, j)
+		if err == nil || run.Attempts > j.Retries {
+			break
+		}
+		s.ErrorLog.Printf("job %s: attempt %d failed: %s\n", j.Name, run.Attempts, err)
+
+		select {
+		case <-time.After(j.RetryDelay):
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	run.FinishedAt = time.Now()
+
+	run.Status = models.JobSucceeded
+	run.Message = message
+	if err != nil {
+		run.Status = models.JobFailed
+		run.Message = err.Error()
+		s.ErrorLog.Printf("job %s failed after %d attempts: %s\n", j.Name, run.Attempts, err)
+	} else if message != "" {
+		s.InfoLog.Printf("job %s: %s\n", j.Name, message)
+	}
+
+	run.ID, err = s.DB.InsertJobRun(&run)
+	if err != nil {
+		s.ErrorLog.Printf("job %s: cannot record the run: %s\n", j.Name, err)
+	}
+
+	return run, true
+}
+
+// attempt runs the job once, a panic is turned into an error so that a broken job doesn't stop the app
+func attempt(ctx context.Context, j Job) (message string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return j.Run(ctx)
+}
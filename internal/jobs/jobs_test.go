@@ -0,0 +1,160 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/config"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/database"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/dates"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+)
+
+func newScheduler(db database.DBRepository, owner string) *Scheduler {
+	discard := log.New(ioutil.Discard, "", 0)
+	return New(db, owner, discard, discard)
+}
+
+func TestScheduler_Add(t *testing.T) {
+	s := newScheduler(database.NewMemory(&config.AppConfig{}), "a")
+	run := func(ctx context.Context) (string, error) { return "", nil }
+
+	err := s.Add(Job{Name: "sweep", Schedule: MustParseSchedule("* * * * *"), Run: run})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jobs := s.Jobs(); len(jobs) != 1 || jobs[0].Lease != DefaultLease {
+		t.Errorf("expected the job with the default lease, got %+v", jobs)
+	}
+
+	for _, j := range []Job{
+		{Name: "sweep", Schedule: MustParseSchedule("* * * * *"), Run: run},
+		{Name: "no schedule", Run: run},
+		{Name: "no run", Schedule: MustParseSchedule("* * * * *")},
+		{Name: "negative", Schedule: MustParseSchedule("* * * * *"), Run: run, Retries: -1},
+	} {
+		if s.Add(j) == nil {
+			t.Errorf("expected %q to be refused", j.Name)
+		}
+	}
+}
+
+func TestScheduler_RunDue(t *testing.T) {
+	dates.SetLocation(time.UTC)
+	db := database.NewMemory(&config.AppConfig{})
+	var hourly, everyMinute int32
+
+	// two instances sharing the database
+	var schedulers []*Scheduler
+	for _, owner := range []string{"a", "b"} {
+		s := newScheduler(db, owner)
+		s.Add(Job{Name: "hourly", Schedule: MustParseSchedule("@hourly"), Run: func(ctx context.Context) (string, error) {
+			atomic.AddInt32(&hourly, 1)
+			return "did it", nil
+		}})
+		s.Add(Job{Name: "every minute", Schedule: MustParseSchedule("* * * * *"), Run: func(ctx context.Context) (string, error) {
+			atomic.AddInt32(&everyMinute, 1)
+			return "", nil
+		}})
+		schedulers = append(schedulers, s)
+	}
+
+	now := time.Date(2099, 1, 1, 9, 0, 30, 0, time.UTC)
+	runs := schedulers[0].RunDue(now)
+	if len(runs) != 2 {
+		t.Fatalf("expected both jobs to run at 9:00, got %+v", runs)
+	}
+	if runs := schedulers[1].RunDue(now); len(runs) != 0 {
+		t.Errorf("expected the other instance to skip the runs already done, got %+v", runs)
+	}
+	if runs := schedulers[1].RunDue(now.Add(time.Minute)); len(runs) != 1 || runs[0].Name != "every minute" || runs[0].Owner != "b" {
+		t.Errorf("expected b to run the next minute, got %+v", runs)
+	}
+	if hourly != 1 || everyMinute != 2 {
+		t.Errorf("expected 1 hourly run and 2 runs every minute, got %d and %d", hourly, everyMinute)
+	}
+
+	history, _ := db.GetJobRuns("hourly", 10)
+	if len(history) != 1 {
+		t.Fatalf("expected the hourly run in the history, got %+v", history)
+	}
+	r := history[0]
+	if r.Status != models.JobSucceeded || r.Message != "did it" || r.Attempts != 1 || r.Owner != "a" ||
+		!r.ScheduledAt.Equal(time.Date(2099, 1, 1, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected run %+v", r)
+	}
+}
+
+func TestScheduler_Retries(t *testing.T) {
+	db := database.NewMemory(&config.AppConfig{})
+	s := newScheduler(db, "a")
+	now := time.Date(2099, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	var attempts int32
+	s.Add(Job{Name: "flaky", Schedule: MustParseSchedule("* * * * *"), Retries: 2, Run: func(ctx context.Context) (string, error) {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			return "", errors.New("mail server down")
+		}
+		return "sent", nil
+	}})
+	s.Add(Job{Name: "broken", Schedule: MustParseSchedule("* * * * *"), Retries: 1, Run: func(ctx context.Context) (string, error) {
+		panic("nil map")
+	}})
+
+	s.RunDue(now)
+
+	runs, _ := db.GetJobRuns("flaky", 10)
+	if len(runs) != 1 || runs[0].Status != models.JobSucceeded || runs[0].Attempts != 3 || runs[0].Message != "sent" {
+		t.Errorf("expected the flaky job to succeed on the third attempt, got %+v", runs)
+	}
+	runs, _ = db.GetJobRuns("broken", 10)
+	if len(runs) != 1 || runs[0].Status != models.JobFailed || runs[0].Attempts != 2 || !strings.Contains(runs[0].Message, "nil map") {
+		t.Errorf("expected the broken job to fail after 2 attempts, got %+v", runs)
+	}
+
+	// a failed run doesn't keep the lease, the next minute runs again
+	if runs := s.RunDue(now.Add(time.Minute)); len(runs) != 2 {
+		t.Errorf("expected both jobs to run the next minute, got %+v", runs)
+	}
+}
+
+func TestScheduler_Lease(t *testing.T) {
+	db := database.NewMemory(&config.AppConfig{})
+	a, b := newScheduler(db, "a"), newScheduler(db, "b")
+	now := time.Date(2099, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	started, finish := make(chan bool), make(chan bool)
+	slow := Job{Name: "slow", Schedule: MustParseSchedule("* * * * *"), Run: func(ctx context.Context) (string, error) {
+		started <- true
+		<-finish
+		return "", nil
+	}}
+	a.Add(slow)
+	b.Add(Job{Name: "slow", Schedule: slow.Schedule, Run: func(ctx context.Context) (string, error) {
+		return "", nil
+	}})
+
+	done := make(chan []models.JobRun)
+	go func() {
+		done <- a.RunDue(now)
+	}()
+	<-started
+
+	// the run of the next minute is skipped while a still holds the lease
+	if runs := b.RunDue(now.Add(time.Minute)); len(runs) != 0 {
+		t.Errorf("expected b to wait for the lease of a, got %+v", runs)
+	}
+	close(finish)
+	if runs := <-done; len(runs) != 1 {
+		t.Errorf("expected a to finish its run, got %+v", runs)
+	}
+	if runs := b.RunDue(now.Add(2 * time.Minute)); len(runs) != 1 {
+		t.Errorf("expected b to run once the lease is released, got %+v", runs)
+	}
+}
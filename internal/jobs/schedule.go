@@ -0,0 +1,189 @@
+package jobs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxNextYears is how far ahead Next looks for a matching minute, long enough for February 29th across a
+// century like 2100, schedules like 0 0 30 2 * never match
+const maxNextYears = 8
+
+// shortcuts are the cron expressions with a name
+var shortcuts = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+	"@yearly":  "0 0 1 1 *",
+}
+
+// field is the range of values of a cron field
+type field struct {
+	name     string
+	min, max int
+}
+
+// fields are the cron fields in order, Sunday is both 0 and 7 in the day of week
+var fields = [5]field{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// Schedule is when a job runs, parsed from a five field cron expression: minute, hour, day of month, month
+// and day of week. A field is *, a value, a range like 1-5 or a list like 1,15 of them, each optionally
+// followed by a step like */10.
+type Schedule struct {
+	expr string
+	// sets are the values matched by each field as bits
+	sets [5]uint64
+	// like cron, a job runs on the days matching both the day of month and the day of week when one of them
+	// is *, and on the days matching either otherwise
+	anyDay bool
+}
+
+// ParseSchedule parses a cron expression or one of @hourly, @daily, @weekly, @monthly and @yearly
+func ParseSchedule(expr string) (Schedule, error) {
+	s := Schedule{expr: expr}
+
+	spec := expr
+	if e, ok := shortcuts[spec]; ok {
+		spec = e
+	}
+	parts := strings.Fields(spec)
+	if len(parts) != len(fields) {
+		return s, fmt.Errorf("invalid schedule %q: expected %d fields", expr, len(fields))
+	}
+
+	for i, part := range parts {
+		set, err := parseField(part, fields[i])
+		if err != nil {
+			return s, fmt.Errorf("invalid schedule %q: %w", expr, err)
+		}
+		s.sets[i] = set
+	}
+	// Sunday is 0
+	if s.sets[4]&(1<<7) != 0 {
+		s.sets[4] |= 1
+	}
+	s.anyDay = parts[2] == "*" || parts[4] == "*"
+
+	return s, nil
+}
+
+// MustParseSchedule is ParseSchedule for the schedules written in the code, it panics if expr is invalid
+func MustParseSchedule(expr string) Schedule {
+	s, err := ParseSchedule(expr)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// parseField returns the values matched by the comma separated list part of the cron field f
+func parseField(part string, f field) (uint64, error) {
+	var set uint64
+
+	for _, item := range strings.Split(part, ",") {
+		step := 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(item[i+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q in the %s", item[i+1:], f.name)
+			}
+			item = item[:i]
+		}
+
+		from, to := f.min, f.max
+		if item != "*" {
+			bounds := strings.SplitN(item, "-", 2)
+			var err error
+			from, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return 0, fmt.Errorf("invalid %s %q", f.name, item)
+			}
+			to = from
+			if len(bounds) == 2 {
+				to, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return 0, fmt.Errorf("invalid %s %q", f.name, item)
+				}
+			} else if step > 1 {
+				// 5/15 is every 15 from 5
+				to = f.max
+			}
+		}
+		if from < f.min || to > f.max || from > to {
+			return 0, fmt.Errorf("%s %q out of range %d-%d", f.name, item, f.min, f.max)
+		}
+
+		for v := from; v <= to; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+
+	return set, nil
+}
+
+// String returns the expression the schedule was parsed from
+func (s Schedule) String() string {
+	return s.expr
+}
+
+// IsZero reports whether the schedule was never parsed, it matches no time
+func (s Schedule) IsZero() bool {
+	return s.sets[0] == 0
+}
+
+// Matches reports whether the minute of t, in its location, is scheduled
+func (s Schedule) Matches(t time.Time) bool {
+	return s.has(0, t.Minute()) && s.has(1, t.Hour()) && s.matchesDay(t)
+}
+
+// matchesDay reports whether the day of t is scheduled
+func (s Schedule) matchesDay(t time.Time) bool {
+	if !s.has(3, int(t.Month())) {
+		return false
+	}
+	dom, dow := s.has(2, t.Day()), s.has(4, int(t.Weekday()))
+	if s.anyDay {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+func (s Schedule) has(field, v int) bool {
+	return s.sets[field]&(1<<uint(v)) != 0
+}
+
+// Next returns the first scheduled minute after t in the location of t, the zero time if there is none
+func (s Schedule) Next(t time.Time) time.Time {
+	if s.IsZero() {
+		return time.Time{}
+	}
+
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(maxNextYears, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case !s.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case !s.has(1, t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case !s.has(0, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,100 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+func minute(s string) time.Time {
+	t, err := time.Parse("2006-01-02 15:04", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParseSchedule_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"@often",
+	} {
+		_, err := ParseSchedule(expr)
+		if err == nil {
+			t.Errorf("expected %q to be refused", expr)
+		}
+	}
+}
+
+func TestSchedule_Matches(t *testing.T) {
+	tests := []struct {
+		expr     string
+		time     string
+		expected bool
+	}{
+		{"* * * * *", "2099-01-01 12:34", true},
+		{"*/5 * * * *", "2099-01-01 12:35", true},
+		{"*/5 * * * *", "2099-01-01 12:36", false},
+		{"5/15 * * * *", "2099-01-01 12:50", true},
+		{"0 9-17 * * *", "2099-01-01 17:00", true},
+		{"0 9-17 * * *", "2099-01-01 18:00", false},
+		{"30 3 * * *", "2099-01-01 03:30", true},
+		{"0,30 * * * *", "2099-01-01 08:30", true},
+		{"@hourly", "2099-01-01 08:00", true},
+		{"@daily", "2099-01-01 08:00", false},
+		// 2099-01-04 is a Sunday, both 0 and 7
+		{"0 0 * * 0", "2099-01-04 00:00", true},
+		{"0 0 * * 7", "2099-01-04 00:00", true},
+		{"0 0 * * 1-5", "2099-01-04 00:00", false},
+		// with both days restricted either one matches
+		{"0 0 15 * 0", "2099-01-04 00:00", true},
+		{"0 0 15 * 0", "2099-01-15 00:00", true},
+		{"0 0 15 * 0", "2099-01-16 00:00", false},
+		{"0 0 1 2 *", "2099-01-01 00:00", false},
+	}
+
+	for _, test := range tests {
+		s, err := ParseSchedule(test.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := s.Matches(minute(test.time)); got != test.expected {
+			t.Errorf("%s at %s: expected %v, got %v", test.expr, test.time, test.expected, got)
+		}
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	tests := []struct {
+		expr     string
+		after    string
+		expected string
+	}{
+		{"* * * * *", "2099-01-01 12:34", "2099-01-01 12:35"},
+		{"*/15 * * * *", "2099-01-01 12:34", "2099-01-01 12:45"},
+		{"@hourly", "2099-01-01 23:00", "2099-01-02 00:00"},
+		{"30 3 * * *", "2099-01-01 03:30", "2099-01-02 03:30"},
+		{"0 0 1 * *", "2099-01-15 10:00", "2099-02-01 00:00"},
+		{"0 0 29 2 *", "2099-01-01 00:00", "2104-02-29 00:00"},
+	}
+
+	for _, test := range tests {
+		s := MustParseSchedule(test.expr)
+		got := s.Next(minute(test.after))
+		if !got.Equal(minute(test.expected)) {
+			t.Errorf("%s after %s: expected %s, got %s", test.expr, test.after, test.expected, got)
+		}
+	}
+
+	if next := MustParseSchedule("0 0 30 2 *").Next(minute("2099-01-01 00:00")); !next.IsZero() {
+		t.Errorf("expected February 30th never to come, got %s", next)
+	}
+}
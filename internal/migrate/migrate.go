@@ -0,0 +1,306 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/driver"
+)
+
+// schemaTable is the table soda records applied migrations in, sharing it keeps both tools interchangeable
+const schemaTable = "schema_migration"
+
+// migrationFile matches soda migration file names: <version>_<name>[.<dialect>].<up|down>.sql
+var migrationFile = regexp.MustCompile(`^(\d+)_([^.]+)(\.[a-z0-9]+)?\.(up|down)\.sql$`)
+
+// Migration is one schema change with its rollback
+type Migration struct {
+	Version string
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is a migration and whether it has been applied
+type Status struct {
+	Migration
+	Applied bool
+}
+
+// Migrator applies the migrations of one dialect to a database
+type Migrator struct {
+	DB         *sql.DB
+	Dialect    string
+	Migrations []Migration
+}
+
+// Dialect returns the soda dialect name of a database driver
+func Dialect(driverName string) (string, error) {
+	switch driverName {
+	case driver.Postgres:
+		return "postgres", nil
+	case driver.SQLite:
+		return "sqlite3", nil
+	}
+	return "", fmt.Errorf("%s database has no migrations", driverName)
+}
+
+// New loads the migrations for dialect from fsys
+func New(db *sql.DB, dialect string, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys, dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		DB:         db,
+		Dialect:    dialect,
+		Migrations: migrations,
+	}, nil
+}
+
+// Load reads the migrations for dialect from the root of fsys, ordered by version
+func Load(fsys fs.FS, dialect string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[string]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		m := migrationFile.FindStringSubmatch(entry.Name())
+		if m == nil {
+			continue
+		}
+		version, name, fileDialect, direction := m[1], m[2], strings.TrimPrefix(m[3], "."), m[4]
+		if fileDialect != "" && fileDialect != dialect {
+			continue
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		}
+		if direction == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	var migrations []Migration
+	for _, migration := range byVersion {
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// createSchemaTable creates the table of applied migrations if it doesn't exist yet
+func (m *Migrator) createSchemaTable(ctx context.Context) error {
+	query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (version VARCHAR(14) NOT NULL);
+			  CREATE UNIQUE INDEX IF NOT EXISTS %s_version_idx ON %s (version);`,
+		schemaTable, schemaTable, schemaTable)
+	_, err := m.DB.ExecContext(ctx, query)
+	return err
+}
+
+// applied returns the versions that have been applied
+func (m *Migrator) applied(ctx context.Context) (map[string]bool, error) {
+	err := m.createSchemaTable(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := m.DB.QueryContext(ctx, fmt.Sprintf("SELECT version FROM %s", schemaTable))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := make(map[string]bool)
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		versions[version] = true
+	}
+
+	return versions, rows.Err()
+}
+
+// Status returns every migration and whether it has been applied
+func (m *Migrator) Status() ([]Status, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []Status
+	for _, migration := range m.Migrations {
+		statuses = append(statuses, Status{
+			Migration: migration,
+			Applied:   applied[migration.Version],
+		})
+	}
+
+	return statuses, nil
+}
+
+// Pending returns the migrations that have not been applied yet
+func (m *Migrator) Pending() ([]Migration, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, s := range statuses {
+		if !s.Applied {
+			pending = append(pending, s.Migration)
+		}
+	}
+
+	return pending, nil
+}
+
+// Up applies all pending migrations in order and returns them
+func (m *Migrator) Up() ([]Migration, error) {
+	pending, err := m.Pending()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, migration := range pending {
+		err := m.run(migration.Up, fmt.Sprintf("INSERT INTO %s (version) VALUES (%s)", schemaTable, m.placeholder(1)), migration.Version)
+		if err != nil {
+			return pending[:i], fmt.Errorf("migration %s_%s: %w", migration.Version, migration.Name, err)
+		}
+	}
+
+	return pending, nil
+}
+
+// Down rolls back the last steps applied migrations and returns them
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	var rolledBack []Migration
+	for i := len(statuses) - 1; i >= 0 && len(rolledBack) < steps; i-- {
+		if !statuses[i].Applied {
+			continue
+		}
+
+		migration := statuses[i].Migration
+		err := m.run(migration.Down, fmt.Sprintf("DELETE FROM %s WHERE version = %s", schemaTable, m.placeholder(1)), migration.Version)
+		if err != nil {
+			return rolledBack, fmt.Errorf("migration %s_%s: %w", migration.Version, migration.Name, err)
+		}
+		rolledBack = append(rolledBack, migration)
+	}
+
+	return rolledBack, nil
+}
+
+// run executes the statements of a migration and records it in one transaction
+func (m *Migrator) run(statements, record, version string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if strings.TrimSpace(stripComments(statements)) != "" {
+		_, err = tx.ExecContext(ctx, statements)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, record, version)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Seed runs the seed files of the dialect in seeds/, in name order
+func (m *Migrator) Seed(fsys fs.FS) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	files, err := fs.Glob(fsys, path.Join("seeds", fmt.Sprintf("*.%s.sql", m.Dialect)))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	for _, file := range files {
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.ExecContext(ctx, string(content))
+		if err != nil {
+			return nil, fmt.Errorf("seed %s: %w", file, err)
+		}
+	}
+
+	return files, tx.Commit()
+}
+
+// placeholder returns the n-th bind parameter of the dialect
+func (m *Migrator) placeholder(n int) string {
+	if m.Dialect == "postgres" {
+		return fmt.Sprintf("$%d", n)
+	}
+	return "?"
+}
+
+// stripComments removes -- line comments, so that migrations consisting only of comments are skipped
+func stripComments(statements string) string {
+	var lines []string
+	for _, line := range strings.Split(statements, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,154 @@
+package migrate
+
+import (
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/driver"
+	"github.com/kaitolucifer/go-laptop-rental-site/migrations"
+)
+
+func newTestMigrator(t *testing.T) *Migrator {
+	db, err := driver.ConnectSQLite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	m, err := New(db.Conn, "sqlite3", migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func count(t *testing.T, m *Migrator, query string) int {
+	t.Helper()
+
+	var n int
+	err := m.DB.QueryRow(query).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"2_second.up.sql":               {Data: []byte("second up")},
+		"1_first.postgres.up.sql":       {Data: []byte("postgres up")},
+		"1_first.postgres.down.sql":     {Data: []byte("postgres down")},
+		"1_first.sqlite3.up.sql":        {Data: []byte("sqlite up")},
+		"1_first.sqlite3.down.sql":      {Data: []byte("sqlite down")},
+		"README.md":                     {Data: []byte("not a migration")},
+		"seeds/demo.postgres.sql":       {Data: []byte("seed")},
+		"3_third.mysql.up.sql":          {Data: []byte("mysql up")},
+		"20210101000000_old.up.fizz":    {Data: []byte("fizz")},
+		"20210101000000_old.sqlite3.sq": {Data: []byte("typo")},
+	}
+
+	migrations, err := Load(fsys, "sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 2 {
+		t.Fatalf("expected 2 migrations, got %d: %+v", len(migrations), migrations)
+	}
+	if migrations[0].Version != "1" || migrations[0].Name != "first" || migrations[0].Up != "sqlite up" || migrations[0].Down != "sqlite down" {
+		t.Errorf("unexpected first migration: %+v", migrations[0])
+	}
+	if migrations[1].Version != "2" || migrations[1].Up != "second up" || migrations[1].Down != "" {
+		t.Errorf("unexpected second migration: %+v", migrations[1])
+	}
+}
+
+func TestMigrator_UpDown(t *testing.T) {
+	m := newTestMigrator(t)
+
+	pending, err := m.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != len(m.Migrations) || len(pending) == 0 {
+		t.Fatalf("expected all %d migrations pending, got %d", len(m.Migrations), len(pending))
+	}
+
+	applied, err := m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(m.Migrations) {
+		t.Errorf("expected %d migrations applied, got %d", len(m.Migrations), len(applied))
+	}
+	if n := count(t, m, "SELECT COUNT(*) FROM laptops"); n != 2 {
+		t.Errorf("expected 2 seeded laptops, got %d", n)
+	}
+
+	// running up again is a no-op
+	applied, err = m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("expected no migrations applied, got %d", len(applied))
+	}
+
+	rolledBack, err := m.Down(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := m.Migrations[len(m.Migrations)-1]
+	if len(rolledBack) != 1 || rolledBack[0].Version != last.Version {
+		t.Fatalf("expected %s to be rolled back, got %+v", last.Version, rolledBack)
+	}
+
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range statuses {
+		if s.Applied != (i < len(statuses)-1) {
+			t.Errorf("migration %s: applied %v", s.Version, s.Applied)
+		}
+	}
+
+	// every down migration works
+	_, err = m.Down(len(m.Migrations))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, m, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'laptops'"); n != 0 {
+		t.Error("laptops table still exists after rolling back every migration")
+	}
+
+	_, err = m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrator_Seed(t *testing.T) {
+	m := newTestMigrator(t)
+	_, err := m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		files, err := m.Seed(migrations.FS)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) == 0 {
+			t.Fatal("no seed files for sqlite3")
+		}
+	}
+
+	if n := count(t, m, "SELECT COUNT(*) FROM reservations"); n != 2 {
+		t.Errorf("expected 2 demo reservations after seeding twice, got %d", n)
+	}
+	if n := count(t, m, "SELECT COUNT(*) FROM laptop_restrictions"); n != 3 {
+		t.Errorf("expected 3 demo laptop restrictions after seeding twice, got %d", n)
+	}
+}
@@ -20,8 +20,18 @@ type User struct {
 type Laptop struct {
 	ID         int
 	LaptopName string
-	CreatedAt  time.Time
-	UpdatedAt  time.Time
+	// BufferDaysBefore and BufferDaysAfter are kept free before and after each reservation
+	// to ship, wipe and re-image the laptop
+	BufferDaysBefore int
+	BufferDaysAfter  int
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+// Turnaround returns how many days must be left free between two reservations of the laptop,
+// the days after the first one plus the days before the second one
+func (l Laptop) Turnaround() int {
+	return l.BufferDaysBefore + l.BufferDaysAfter
 }
 
 // Restriction is the restriction model
@@ -46,8 +56,54 @@ type Reservation struct {
 	UpdatedAt time.Time
 	Laptop    Laptop
 	Processed int
+	// Locale is the language the customer booked in, the reminders are mailed in it
+	Locale string
+}
+
+// reservation processed states a ReservationFilter can select
+const (
+	ReservationsNew       = "new"
+	ReservationsProcessed = "processed"
+)
+
+// ReservationSorts are the columns a reservation list can be sorted by
+var ReservationSorts = []string{"id", "name", "laptop", "start", "end", "created"}
+
+// ReservationFilter selects, orders and pages a list of reservations, zero values select everything
+type ReservationFilter struct {
+	// Search matches each of its words against the first name, last name, email or phone, ignoring case
+	Search   string
+	LaptopID int
+	// Start and End select the reservations overlapping them
+	Start time.Time
+	End   time.Time
+	// Processed is ReservationsNew or ReservationsProcessed
+	Processed string
+	// Sort is one of ReservationSorts, by id if empty, Desc reverses it, ties are ordered by id
+	Sort string
+	Desc bool
+	// Offset and Limit select a page, all of it if Limit is 0
+	Offset int
+	Limit  int
+}
+
+// BulkResult is the outcome of a bulk action for one reservation
+type BulkResult struct {
+	ReservationID int
+	// Reservation is the reservation as it was before the action
+	Reservation Reservation
+	// Err is why the action failed for this reservation, like sql.ErrNoRows, nil if it succeeded
+	Err error
 }
 
+// restriction ids, see the restriction seed migrations
+const (
+	RestrictionReservation = 1
+	RestrictionBlock       = 2
+	// RestrictionHold keeps dates free for a customer during checkout until ExpiresAt
+	RestrictionHold = 3
+)
+
 // LaptopRestriction is the laptop restriction model
 type LaptopRestriction struct {
 	ID            int
@@ -56,6 +112,8 @@ type LaptopRestriction struct {
 	LaptopID      int
 	ReservationID int
 	RestrictionID int
+	ExpiresAt     time.Time
+	Version       int
 	CreatedAt     time.Time
 	UpdatedAt     time.Time
 	Laptop        Laptop
@@ -71,3 +129,166 @@ type MailData struct {
 	Content  string
 	Template string
 }
+
+// CalendarDay is the availability of a laptop on one day, without any customer details
+type CalendarDay struct {
+	Date   string `json:"date"`
+	Day    int    `json:"day"`
+	Status string `json:"status"`
+}
+
+// waitlist entry statuses
+const (
+	WaitlistWaiting  = "waiting"
+	WaitlistNotified = "notified"
+	WaitlistClaimed  = "claimed"
+	WaitlistExpired  = "expired"
+)
+
+// WaitlistEntry is a customer waiting for a laptop to become free for a date range
+type WaitlistEntry struct {
+	ID        int
+	FirstName string
+	LastName  string
+	Email     string
+	LaptopID  int
+	StartDate time.Time
+	EndDate   time.Time
+	Status    string
+	// Token identifies the hold link mailed when the dates become free, HoldUntil is when it expires
+	// and HoldID the hold restriction keeping the dates for the customer until then
+	Token     string
+	HoldUntil time.Time
+	HoldID    int
+	// Locale is the language the customer joined in, the hold link is mailed in it
+	Locale    string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Laptop    Laptop
+}
+
+// BookingRules limit the reservations of a laptop, or of every laptop when LaptopID is 0,
+// zero values don't limit anything
+type BookingRules struct {
+	ID       int
+	LaptopID int
+	// MinDays and MaxDays limit the length of a rental, the start and end date included
+	MinDays int
+	MaxDays int
+	// MaxAdvanceDays limits how many days ahead of today a rental can start
+	MaxAdvanceDays int
+	// PickupWeekdays are the weekdays a rental can start on, any weekday if empty
+	PickupWeekdays []time.Weekday
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// Blackout is a date range during which a laptop, or every laptop when LaptopID is 0, can't be rented
+type Blackout struct {
+	ID        int
+	LaptopID  int
+	StartDate time.Time
+	EndDate   time.Time
+	Reason    string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Cancellation is a reservation that was deleted, kept for the cancellation rate of the reports
+type Cancellation struct {
+	ID            int
+	ReservationID int
+	LaptopID      int
+	StartDate     time.Time
+	EndDate       time.Time
+	// BookedAt is when the cancelled reservation was made
+	BookedAt  time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// reminder kinds, each is mailed at most once per reservation
+const (
+	ReminderPickup  = "pickup"
+	ReminderReturn  = "return"
+	ReminderOverdue = "overdue"
+)
+
+// Reminder records a reminder mailed to the customer of a reservation
+type Reminder struct {
+	ID            int
+	ReservationID int
+	Kind          string
+	SentAt        time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// job run statuses
+const (
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// JobRun records a scheduled run of a background job
+type JobRun struct {
+	ID   int
+	Name string
+	// Owner is the instance that ran the job
+	Owner       string
+	ScheduledAt time.Time
+	StartedAt   time.Time
+	FinishedAt  time.Time
+	// Attempts is 1 plus the retries it took, Message what the last attempt did or its error
+	Attempts  int
+	Status    string
+	Message   string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// queued mail statuses
+const (
+	MailPending = "pending"
+	MailFailed  = "failed"
+)
+
+// QueuedMail is a mail waiting in the mail queue to be sent, again if sending it failed
+type QueuedMail struct {
+	ID     int
+	Mail   MailData
+	Status string
+	// Attempts is how many times sending it failed, LastError the error of the last attempt
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// LaptopUtilization is how many days of a period a laptop was rented
+type LaptopUtilization struct {
+	Laptop Laptop
+	// RentedDays are the days from Start to End covered by a reservation, PeriodDays all the days from Start to End
+	RentedDays int
+	PeriodDays int
+	Start      time.Time
+	End        time.Time
+}
+
+// Percent returns the share of the period the laptop was rented, from 0 to 100
+func (u LaptopUtilization) Percent() int {
+	if u.PeriodDays <= 0 {
+		return 0
+	}
+	if u.RentedDays >= u.PeriodDays {
+		return 100
+	}
+	return u.RentedDays * 100 / u.PeriodDays
+}
+
+// DailyCount is a number of things, like pickups, on one day
+type DailyCount struct {
+	Date  time.Time
+	Count int
+}
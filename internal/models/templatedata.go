@@ -14,4 +14,6 @@ type TemplateData struct {
 	Error     string
 	Form      *forms.Form
 	IsAuthenticated int
+	// Locale is the language the page is rendered in
+	Locale string
 }
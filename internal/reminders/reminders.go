@@ -0,0 +1,141 @@
+package reminders
+
+import (
+	"fmt"
+	"html"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/config"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/database"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/i18n"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+)
+
+// CatchUpDays is how long after it was due an overdue notice is still mailed, so that the notices due while
+// the app was down are sent when it is back without mailing the customers of long past rentals
+const CatchUpDays = 7
+
+// Config is when the reminders are mailed and the email templates they are mailed with
+type Config struct {
+	// PickupDays is how many days before the start date the pickup reminder is mailed, 0 for on the start date
+	// and negative for never
+	PickupDays int
+	// ReturnDays is how many days before the end date the return reminder is mailed, 0 for on the end date
+	// and negative for never
+	ReturnDays int
+	// OverdueDays is how many days after the end date the overdue notice is mailed, 0 for on the end date
+	// and negative for never
+	OverdueDays int
+	// Templates are the email templates by reminder kind, basic.email.html for the kinds missing
+	Templates map[string]string
+}
+
+// DefaultConfig mails the pickup reminder two days before, the return reminder the day before and the overdue
+// notice the day after
+var DefaultConfig = Config{PickupDays: 2, ReturnDays: 1, OverdueDays: 1}
+
+// Send mails the reminders due today that weren't mailed yet and returns how many it mailed. Every reminder is
+// recorded before it is mailed, so that a reservation never gets the same reminder twice, even across restarts.
+func Send(app *config.AppConfig, db database.DBRepository, cfg Config, today time.Time) (int, error) {
+	// return reminders are only due for the rentals going on today
+	from, to := today, today
+	if cfg.OverdueDays >= 0 {
+		from = today.AddDate(0, 0, -cfg.OverdueDays-CatchUpDays)
+	}
+	if cfg.PickupDays >= 0 {
+		to = today.AddDate(0, 0, cfg.PickupDays)
+	}
+
+	reservations, err := db.GetReservationsByDate(from, to)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, res := range reservations {
+		for _, kind := range []string{models.ReminderPickup, models.ReminderReturn, models.ReminderOverdue} {
+			if !cfg.Due(kind, res, today) {
+				continue
+			}
+
+			recorded, err := db.RecordReminder(res.ID, kind, time.Now())
+			if err != nil {
+				return sent, err
+			}
+			if !recorded {
+				continue
+			}
+
+			app.MailChan <- cfg.Mail(kind, res)
+			sent++
+		}
+	}
+
+	return sent, nil
+}
+
+// Due reports whether the reminder of kind is to be mailed today for res. The pickup reminder is due from
+// PickupDays before the start date until the day before, or on the start date when PickupDays is 0, the
+// return reminder from ReturnDays before the end date until the end date once the laptop was picked up and
+// the overdue notice from OverdueDays after the end date for CatchUpDays.
+func (cfg Config) Due(kind string, res models.Reservation, today time.Time) bool {
+	switch kind {
+	case models.ReminderPickup:
+		last := res.StartDate.AddDate(0, 0, -1)
+		if cfg.PickupDays == 0 {
+			last = res.StartDate
+		}
+		return cfg.PickupDays >= 0 && !today.Before(res.StartDate.AddDate(0, 0, -cfg.PickupDays)) &&
+			!today.After(last)
+	case models.ReminderReturn:
+		return cfg.ReturnDays >= 0 && !today.Before(res.EndDate.AddDate(0, 0, -cfg.ReturnDays)) &&
+			!today.After(res.EndDate) && !today.Before(res.StartDate)
+	case models.ReminderOverdue:
+		due := res.EndDate.AddDate(0, 0, cfg.OverdueDays)
+		return cfg.OverdueDays >= 0 && !today.Before(due) && today.Before(due.AddDate(0, 0, CatchUpDays))
+	}
+	return false
+}
+
+// Mail returns the reminder of kind for res in the language res was booked in
+func (cfg Config) Mail(kind string, res models.Reservation) models.MailData {
+	template := cfg.Templates[kind]
+	if template == "" {
+		template = "basic.email.html"
+	}
+
+	locale := res.Locale
+	laptop := html.EscapeString(res.Laptop.LaptopName)
+	start := i18n.FormatDate(locale, res.StartDate, "January 2, 2006")
+	end := i18n.FormatDate(locale, res.EndDate, "January 2, 2006")
+
+	var subject, message string
+	switch kind {
+	case models.ReminderPickup:
+		subject = i18n.T(locale, "Your laptop is ready for pickup soon")
+		message = i18n.T(locale, "This is a reminder that your rental of %s starts on %s, it can be picked up from that day.",
+			laptop, start)
+	case models.ReminderReturn:
+		subject = i18n.T(locale, "Your laptop is due back soon")
+		message = i18n.T(locale, "This is a reminder that your rental of %s ends on %s, please return it by then.",
+			laptop, end)
+	case models.ReminderOverdue:
+		subject = i18n.T(locale, "Your laptop is overdue")
+		message = i18n.T(locale, "Your rental of %s ended on %s. If you haven't returned it yet, please return it as soon as possible. "+
+			"If you already have, please ignore this email.", laptop, end)
+	}
+
+	htmlMessage := fmt.Sprintf(`
+	<strong>%s</strong><br>
+	%s <br>
+	%s
+	`, subject, i18n.T(locale, "Dear %s:,", html.EscapeString(res.FirstName)), message)
+
+	return models.MailData{
+		To:       res.Email,
+		From:     "kaito@laptop-rental.com",
+		Subject:  subject,
+		Content:  htmlMessage,
+		Template: template,
+	}
+}
@@ -0,0 +1,184 @@
+package reminders
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/config"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/database"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+)
+
+func date(day int) time.Time {
+	return time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, day-1)
+}
+
+// reserve books laptop 1 from day start to day end of January 2099 for email and returns the reservation id
+func reserve(t *testing.T, db database.DBRepository, email string, start, end int) int {
+	t.Helper()
+
+	id, err := db.InsertReservation(&models.Reservation{
+		FirstName: "John",
+		Email:     email,
+		LaptopID:  1,
+		StartDate: date(start),
+		EndDate:   date(end),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// mails returns the mails sent so far
+func mails(app *config.AppConfig) []models.MailData {
+	var sent []models.MailData
+	for len(app.MailChan) > 0 {
+		sent = append(sent, <-app.MailChan)
+	}
+	return sent
+}
+
+func TestSend(t *testing.T) {
+	app := &config.AppConfig{MailChan: make(chan models.MailData, 10)}
+	db := database.NewMemory(app)
+	reserve(t, db, "pickup@example.com", 12, 14)
+	reserve(t, db, "return@example.com", 5, 11)
+	reserve(t, db, "overdue@example.com", 1, 9)
+	reserve(t, db, "later@example.com", 20, 22)
+
+	n, err := Send(app, db, DefaultConfig, date(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := mails(app)
+	if n != 3 || len(sent) != 3 {
+		t.Fatalf("expected 3 reminders, got %d %+v", n, sent)
+	}
+
+	expected := map[string]string{
+		"pickup@example.com":  "Your laptop is ready for pickup soon",
+		"return@example.com":  "Your laptop is due back soon",
+		"overdue@example.com": "Your laptop is overdue",
+	}
+	for _, m := range sent {
+		if m.Subject != expected[m.To] {
+			t.Errorf("unexpected mail %q to %s", m.Subject, m.To)
+		}
+		if m.Template != "basic.email.html" || !strings.Contains(m.Content, "Alienware M15 R2") {
+			t.Errorf("unexpected mail %+v", m)
+		}
+	}
+
+	// a reminder is mailed once, even when it is still due the next time or the next day
+	n, _ = Send(app, db, DefaultConfig, date(10))
+	if n != 0 {
+		t.Errorf("expected no reminders twice, got %d", n)
+	}
+	n, _ = Send(app, db, DefaultConfig, date(11))
+	if n != 0 {
+		t.Errorf("expected no new reminders on the 11th, got %d %+v", n, mails(app))
+	}
+}
+
+func TestSend_Config(t *testing.T) {
+	app := &config.AppConfig{MailChan: make(chan models.MailData, 10)}
+	db := database.NewMemory(app)
+	id := reserve(t, db, "john@example.com", 15, 16)
+
+	cfg := Config{
+		PickupDays:  7,
+		ReturnDays:  -1,
+		OverdueDays: -1,
+		Templates:   map[string]string{models.ReminderPickup: "pickup.email.html"},
+	}
+	n, err := Send(app, db, cfg, date(7))
+	if err != nil || n != 0 {
+		t.Fatalf("expected nothing 8 days before, got %d %v", n, err)
+	}
+	n, _ = Send(app, db, cfg, date(8))
+	sent := mails(app)
+	if n != 1 || sent[0].Template != "pickup.email.html" {
+		t.Fatalf("expected the pickup reminder in its template, got %+v", sent)
+	}
+
+	// the return reminder and the overdue notice are turned off
+	for day := 9; day <= 31; day++ {
+		if n, _ = Send(app, db, cfg, date(day)); n != 0 {
+			t.Errorf("expected no reminder on the %dth, got %+v", day, mails(app))
+		}
+	}
+
+	reminders, _ := db.GetRemindersByReservationID(id)
+	if len(reminders) != 1 || reminders[0].Kind != models.ReminderPickup {
+		t.Errorf("expected the pickup reminder to be recorded, got %+v", reminders)
+	}
+}
+
+func TestConfig_Due(t *testing.T) {
+	res := models.Reservation{StartDate: date(10), EndDate: date(12)}
+
+	tests := []struct {
+		kind     string
+		day      int
+		expected bool
+	}{
+		{models.ReminderPickup, 7, false},
+		{models.ReminderPickup, 8, true},
+		{models.ReminderPickup, 9, true},
+		{models.ReminderPickup, 10, false},
+		{models.ReminderReturn, 10, false},
+		{models.ReminderReturn, 11, true},
+		{models.ReminderReturn, 12, true},
+		{models.ReminderReturn, 13, false},
+		{models.ReminderOverdue, 12, false},
+		{models.ReminderOverdue, 13, true},
+		{models.ReminderOverdue, 13 + CatchUpDays - 1, true},
+		{models.ReminderOverdue, 13 + CatchUpDays, false},
+	}
+
+	for _, test := range tests {
+		if due := DefaultConfig.Due(test.kind, res, date(test.day)); due != test.expected {
+			t.Errorf("%s on the %dth: expected %v, got %v", test.kind, test.day, test.expected, due)
+		}
+	}
+
+	// 0 days mails the pickup reminder on the start date
+	onTheDay := Config{PickupDays: 0, ReturnDays: -1, OverdueDays: -1}
+	for day, expected := range map[int]bool{9: false, 10: true, 11: false} {
+		if due := onTheDay.Due(models.ReminderPickup, res, date(day)); due != expected {
+			t.Errorf("pickup reminder 0 days before on the %dth: expected %v, got %v", day, expected, due)
+		}
+	}
+
+	// a one day rental gets its return reminder once it was picked up
+	oneDay := models.Reservation{StartDate: date(10), EndDate: date(10)}
+	if DefaultConfig.Due(models.ReminderReturn, oneDay, date(9)) || !DefaultConfig.Due(models.ReminderReturn, oneDay, date(10)) {
+		t.Error("expected the return reminder of a one day rental on its day")
+	}
+}
+
+func TestConfig_Mail(t *testing.T) {
+	res := models.Reservation{
+		FirstName: "<b>John</b>",
+		Email:     "john@example.com",
+		StartDate: date(10),
+		EndDate:   date(12),
+		Locale:    "ja",
+	}
+	res.Laptop.LaptopName = "<i>Alienware</i>"
+
+	m := DefaultConfig.Mail(models.ReminderReturn, res)
+	if m.Subject != "まもなくノートパソコンの返却日です" {
+		t.Errorf("expected the subject in Japanese, got %q", m.Subject)
+	}
+	for _, want := range []string{"&lt;b&gt;John&lt;/b&gt; 様", "&lt;i&gt;Alienware&lt;/i&gt;", "2099年1月12日"} {
+		if !strings.Contains(m.Content, want) {
+			t.Errorf("expected %q in the mail, got %s", want, m.Content)
+		}
+	}
+	if strings.Contains(m.Content, "<b>") || strings.Contains(m.Content, "<i>") {
+		t.Errorf("names not escaped in %s", m.Content)
+	}
+}
@@ -0,0 +1,89 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"runtime/debug"
+	"strings"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/apperrors"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/i18n"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+)
+
+// errorPages are the error pages by status, any other status gets the page of 400 or 500
+var errorPages = map[int]string{
+	http.StatusBadRequest:          "400.page.html",
+	http.StatusForbidden:           "403.page.html",
+	http.StatusNotFound:            "404.page.html",
+	http.StatusInternalServerError: "500.page.html",
+}
+
+// errorResponse is the JSON body of an error, it has the ok and message fields of every JSON answer
+type errorResponse struct {
+	OK      bool   `json:"ok"`
+	Message string `json:"message"`
+}
+
+// WantsJSON reports whether r is an API request, which is answered in JSON
+func WantsJSON(r *http.Request) bool {
+	return strings.HasSuffix(r.URL.Path, ".json") || strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+// Error answers r with the status of err, with the error page of the status or, for API requests, a JSON body.
+// Only the message of err is shown, its details are logged
+func Error(w http.ResponseWriter, r *http.Request, err error) {
+	if WantsJSON(r) {
+		ErrorJSON(w, r, err)
+		return
+	}
+
+	e := apperrors.From(err)
+	logError(e)
+
+	page, ok := errorPages[e.Status]
+	if !ok && e.Status < http.StatusInternalServerError {
+		page = errorPages[http.StatusBadRequest]
+	} else if !ok {
+		page = errorPages[http.StatusInternalServerError]
+	}
+
+	td := AddDefaultData(&models.TemplateData{
+		StringMap: map[string]string{"message": e.In(i18n.FromContext(r.Context()))},
+	}, r)
+
+	buf := new(bytes.Buffer)
+	t, err := localizedPage(page, td.Locale)
+	if err == nil {
+		err = t.Execute(buf, td)
+	}
+	if err != nil {
+		app.ErrorLog.Printf("Cannot render the error page %s: %s\n", page, err)
+		http.Error(w, http.StatusText(e.Status), e.Status)
+		return
+	}
+
+	w.WriteHeader(e.Status)
+	_, _ = buf.WriteTo(w)
+}
+
+// ErrorJSON answers r with the status of err and a JSON body with its message, its details are logged
+func ErrorJSON(w http.ResponseWriter, r *http.Request, err error) {
+	e := apperrors.From(err)
+	logError(e)
+
+	out, _ := json.MarshalIndent(errorResponse{Message: e.In(i18n.FromContext(r.Context()))}, "", "     ")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.Status)
+	_, _ = w.Write(out)
+}
+
+// logError logs e with its details, with a stack trace for failures on our side
+func logError(e *apperrors.Error) {
+	if e.Status >= http.StatusInternalServerError {
+		app.ErrorLog.Printf("%s\n%s", e, debug.Stack())
+		return
+	}
+	app.InfoLog.Printf("Client error with status of %d: %s\n", e.Status, e)
+}
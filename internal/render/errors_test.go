@@ -0,0 +1,89 @@
+package render
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/apperrors"
+)
+
+func TestError(t *testing.T) {
+	defer func(tc map[string]*template.Template) { app.TemplateCache = tc }(app.TemplateCache)
+	tc, err := CreateTemplateCache("./../../templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.TemplateCache = tc
+
+	details := errors.New("pq: password authentication failed for user \"rental\"")
+
+	tests := []struct {
+		name    string
+		err     error
+		status  int
+		heading string
+		message string
+	}{
+		{"bad request", apperrors.BadRequest(details, "Invalid Start Date"), http.StatusBadRequest, "Bad Request", "Invalid Start Date"},
+		{"forbidden", apperrors.Forbidden(details, "No entry"), http.StatusForbidden, "Forbidden", "No entry"},
+		{"not found", apperrors.NotFound(nil, "Laptop not found"), http.StatusNotFound, "Not Found", "Laptop not found"},
+		{"conflict gets the 400 page", apperrors.New(http.StatusConflict, nil, "Taken"), http.StatusConflict, "Bad Request", "Taken"},
+		{"plain errors are internal", details, http.StatusInternalServerError, "Something went wrong", "please try again later"},
+		{"wrapped", fmt.Errorf("showing laptop 3: %w", apperrors.NotFound(details, "Laptop not found")), http.StatusNotFound, "Not Found", "Laptop not found"},
+	}
+
+	for _, test := range tests {
+		r, err := getSession()
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		Error(rr, r, test.err)
+
+		body := rr.Body.String()
+		if rr.Code != test.status {
+			t.Errorf("%s: got status %d, want %d", test.name, rr.Code, test.status)
+		}
+		if !strings.Contains(body, test.heading) || !strings.Contains(body, test.message) {
+			t.Errorf("%s: the page lacks %q or %q", test.name, test.heading, test.message)
+		}
+		if strings.Contains(body, "password authentication") {
+			t.Errorf("%s: the page shows the details of the error", test.name)
+		}
+	}
+}
+
+func TestError_JSON(t *testing.T) {
+	details := errors.New("sql: database is closed")
+
+	for _, path := range []string{"/laptops/1/calendar.json", "/search-availability-modal"} {
+		r, err := getSession()
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.URL.Path = path
+		if !strings.HasSuffix(path, ".json") {
+			r.Header.Set("Accept", "application/json")
+		}
+
+		rr := httptest.NewRecorder()
+		Error(rr, r, details)
+
+		if rr.Code != http.StatusInternalServerError || rr.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("%s: got status %d and %s", path, rr.Code, rr.Header().Get("Content-Type"))
+		}
+		var resp errorResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: %s", path, err)
+		}
+		if resp.OK || resp.Message == "" || strings.Contains(resp.Message, "closed") {
+			t.Errorf("%s: unexpected body %+v", path, resp)
+		}
+	}
+}
@@ -3,23 +3,58 @@ package render
 import (
 	"bytes"
 	"errors"
-	"fmt"
+	"html/template"
+	"io/fs"
 	"net/http"
-	"path/filepath"
-	"text/template"
+	"os"
+	"sync"
 	"time"
 
 	"github.com/justinas/nosurf"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/apperrors"
 	"github.com/kaitolucifer/go-laptop-rental-site/internal/config"
-	"github.com/kaitolucifer/go-laptop-rental-site/internal/helpers"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/i18n"
 	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
 )
 
+// cacheMu guards app.TemplateCache, whose pages the template watcher replaces in dev mode
+var cacheMu sync.RWMutex
+
 var functions = template.FuncMap{
-	"ymdDate":    YMDDate,
-	"formatDate": FormatDate,
-	"iterate":    Iterate,
-	"add":        Add,
+	"ymdDate":      YMDDate,
+	"formatDate":   FormatDate,
+	"iterate":      Iterate,
+	"add":          Add,
+	"t":            i18n.T,
+	"locales":      i18n.Locales,
+	"languageName": LanguageName,
+}
+
+// localeFunctions returns the template functions that depend on the language of the page,
+// they replace the English ones when a page is rendered
+func localeFunctions(locale string) template.FuncMap {
+	return template.FuncMap{
+		"t": func(msg string, args ...interface{}) string {
+			return i18n.T(locale, msg, args...)
+		},
+		"formatDate": func(t time.Time, f string) string {
+			return i18n.FormatDate(locale, t, f)
+		},
+	}
+}
+
+// languageNames are the languages in their own words, for the language switcher
+var languageNames = map[string]string{
+	"en": "English",
+	"ja": "日本語",
+}
+
+// LanguageName returns the name of a language in that language
+func LanguageName(locale string) string {
+	if name, ok := languageNames[locale]; ok {
+		return name
+	}
+	return locale
 }
 
 var app *config.AppConfig
@@ -61,6 +96,7 @@ func AddDefaultData(td *models.TemplateData, r *http.Request) *models.TemplateDa
 	td.Warning = app.Session.PopString(r.Context(), "warning")
 	td.Error = app.Session.PopString(r.Context(), "error")
 	td.CSRFToken = nosurf.Token(r)
+	td.Locale = i18n.FromContext(r.Context())
 	if app.Session.Exists(r.Context(), "user_id") {
 		td.IsAuthenticated = 1
 	}
@@ -69,63 +105,118 @@ func AddDefaultData(td *models.TemplateData, r *http.Request) *models.TemplateDa
 
 // Tamplate renders templates using html/template
 func Template(w http.ResponseWriter, r *http.Request, tmpl string, td *models.TemplateData) error {
-	var tc map[string]*template.Template
-	// get the template cache from the app config
-	if app.UseCache {
-		tc = app.TemplateCache
-	} else {
-		tc, _ = CreateTemplateCache(PathTemplates)
-	}
+	return TemplateStatus(w, r, http.StatusOK, tmpl, td)
+}
 
-	t, ok := tc[tmpl]
-	if !ok {
-		return errors.New("can't get template from cache")
+// TemplateStatus renders the page tmpl with status, like 422 for a form shown again with its errors.
+// The error page is sent instead when the page can't be rendered.
+func TemplateStatus(w http.ResponseWriter, r *http.Request, status int, tmpl string, td *models.TemplateData) error {
+	td = AddDefaultData(td, r)
+
+	t, err := localizedPage(tmpl, td.Locale)
+	if err != nil {
+		Error(w, r, apperrors.Internal(err))
+		return err
 	}
 
 	buf := new(bytes.Buffer)
+	err = t.Execute(buf, td)
+	if err != nil {
+		Error(w, r, apperrors.Internal(err))
+		return err
+	}
 
-	_ = t.Execute(buf, AddDefaultData(td, r))
-
-	_, err := buf.WriteTo(w)
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+	}
+	// a failed write means the client is gone, there is no one left to show an error page to
+	_, err = buf.WriteTo(w)
 	if err != nil {
-		helpers.ServerError(w, err)
+		app.ErrorLog.Printf("Cannot send %s: %s\n", tmpl, err)
 		return err
 	}
 
 	return nil
 }
 
-//CreateTemplateCache creates a template cache as a map
-func CreateTemplateCache(pathTemplates string) (map[string]*template.Template, error) {
-	tmplCache := make(map[string]*template.Template)
+// localized holds the pages of the template cache with the functions of a language set, by page and language.
+// An html/template page can't be cloned once it has run, so each language gets its own copy, made on first use
+var localized = make(map[*template.Template]map[string]*template.Template)
+
+// localizedPage returns the page tmpl of the template cache with the translating functions of locale
+func localizedPage(tmpl, locale string) (*template.Template, error) {
+	// get the template cache from the app config, in dev mode the template watcher keeps it up to date
+	cacheMu.RLock()
+	page, ok := app.TemplateCache[tmpl]
+	t, done := localized[page][locale]
+	cacheMu.RUnlock()
+	if !ok {
+		return nil, errors.New("can't get template from cache")
+	}
+	if done {
+		return t, nil
+	}
 
-	layouts, err := filepath.Glob(fmt.Sprintf("%s/*.layout.html", pathTemplates))
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	if t, done := localized[page][locale]; done {
+		return t, nil
+	}
+	t, err := page.Clone()
 	if err != nil {
-		return tmplCache, err
+		return nil, err
 	}
+	t.Funcs(localeFunctions(locale))
+	if localized[page] == nil {
+		localized[page] = make(map[string]*template.Template)
+	}
+	localized[page][locale] = t
+	return t, nil
+}
+
+//CreateTemplateCache creates a template cache as a map from the templates directory at pathTemplates
+func CreateTemplateCache(pathTemplates string) (map[string]*template.Template, error) {
+	return CreateTemplateCacheFS(os.DirFS(pathTemplates))
+}
+
+// CreateTemplateCacheFS creates a template cache as a map from the pages and layouts at the root of fsys
+func CreateTemplateCacheFS(fsys fs.FS) (map[string]*template.Template, error) {
+	tmplCache := make(map[string]*template.Template)
 
-	pages, err := filepath.Glob(fmt.Sprintf("%s/*.page.html", pathTemplates))
+	pages, err := fs.Glob(fsys, "*.page.html")
 	if err != nil {
 		return tmplCache, err
 	}
 
 	for _, page := range pages {
-		name := filepath.Base(page)
-
-		ts, err := template.New(name).Funcs(functions).ParseFiles(page)
+		ts, err := parsePage(fsys, page)
 		if err != nil {
 			return tmplCache, err
 		}
 
-		if len(layouts) > 0 {
-			ts, err = ts.ParseGlob(fmt.Sprintf("%s/*.layout.html", pathTemplates))
-			if err != nil {
-				return tmplCache, err
-			}
-		}
-
-		tmplCache[name] = ts
+		tmplCache[page] = ts
 	}
 
 	return tmplCache, nil
 }
+
+// parsePage parses a page of fsys together with all the layouts
+func parsePage(fsys fs.FS, page string) (*template.Template, error) {
+	ts, err := template.New(page).Funcs(functions).ParseFS(fsys, page)
+	if err != nil {
+		return nil, err
+	}
+
+	layouts, err := fs.Glob(fsys, "*.layout.html")
+	if err != nil {
+		return nil, err
+	}
+	if len(layouts) > 0 {
+		ts, err = ts.ParseFS(fsys, layouts...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ts, nil
+}
@@ -1,9 +1,18 @@
 package render
 
 import (
+	"errors"
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+	"github.com/kaitolucifer/go-laptop-rental-site/templates"
 )
 
 func TestAddDefaultData(t *testing.T) {
@@ -35,22 +44,25 @@ func TestTemplate(t *testing.T) {
 	}
 
 	var tw testWriter
-	app.UseCache = false
-	err = Template(&tw, r, "home.page.html", &models.TemplateData{})
-	if err == nil {
-		t.Error(err)
-	}
-
-	app.UseCache = true
 	err = Template(&tw, r, "home.page.html", &models.TemplateData{})
 	if err != nil {
 		t.Error(err)
 	}
 
-	err = Template(&tw, r, "non-existent.page.html", &models.TemplateData{})
+	rr := httptest.NewRecorder()
+	err = Template(rr, r, "non-existent.page.html", &models.TemplateData{})
 	if err == nil {
 		t.Error("rendered template that does not exist")
 	}
+	if rr.Code != http.StatusInternalServerError || rr.Body.Len() == 0 {
+		t.Errorf("expected the 500 page for a missing template, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	err = TemplateStatus(rr, r, http.StatusUnprocessableEntity, "home.page.html", &models.TemplateData{})
+	if err != nil || rr.Code != http.StatusUnprocessableEntity || rr.Body.Len() == 0 {
+		t.Errorf("expected the page with status 422, got %d %v", rr.Code, err)
+	}
 }
 
 func TestNewRenderer(t *testing.T) {
@@ -63,3 +75,109 @@ func TestCreateTemplateCache(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestCreateTemplateCacheFS(t *testing.T) {
+	tc, err := CreateTemplateCacheFS(templates.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	onDisk, err := CreateTemplateCache("./../../templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tc) != len(onDisk) {
+		t.Errorf("embedded %d pages, the templates directory has %d", len(tc), len(onDisk))
+	}
+	if _, ok := tc["home.page.html"]; !ok {
+		t.Error("home.page.html is not embedded")
+	}
+	if _, ok := tc["basic.email.html"]; ok {
+		t.Error("email template parsed as a page")
+	}
+}
+
+func TestTemplate_Escaping(t *testing.T) {
+	tc, err := CreateTemplateCache("./../../templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// html/template escapes a page on its first run, a page it can't make safe fails then
+	for name, page := range tc {
+		err := page.Execute(io.Discard, &models.TemplateData{})
+		var escapeErr *template.Error
+		if errors.As(err, &escapeErr) {
+			t.Errorf("%s can't be escaped: %s", name, err)
+		}
+	}
+}
+
+func TestTemplate_ExecutionError(t *testing.T) {
+	defer func(tc map[string]*template.Template) { app.TemplateCache = tc }(app.TemplateCache)
+
+	dir := t.TempDir()
+	start := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	writeTemplate(t, dir, "base.layout.html", `{{define "base"}}<main>{{block "content" .}}{{end}}</main>{{end}}`, start)
+	writeTemplate(t, dir, "broken.page.html", `{{template "base" .}}{{define "content"}}half a page {{.Data.laptop.LaptopName}}{{end}}`, start)
+	writeTemplate(t, dir, "500.page.html", `{{template "base" .}}{{define "content"}}{{t "Something went wrong"}}{{end}}`, start)
+
+	tc, err := CreateTemplateCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.TemplateCache = tc
+
+	r, err := getSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	td := &models.TemplateData{Data: map[string]interface{}{"laptop": 1}}
+	rr := httptest.NewRecorder()
+	if err := Template(rr, r, "broken.page.html", td); err == nil {
+		t.Error("no error from a page that failed")
+	}
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("got status %d, want %d", rr.Code, http.StatusInternalServerError)
+	}
+	if body := rr.Body.String(); body != "<main>Something went wrong</main>" {
+		t.Errorf("expected the 500 page alone, got %q", body)
+	}
+
+	// without a 500 page a bare response is sent
+	if err := os.Remove(dir + "/500.page.html"); err != nil {
+		t.Fatal(err)
+	}
+	tc, err = CreateTemplateCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.TemplateCache = tc
+
+	rr = httptest.NewRecorder()
+	_ = Template(rr, r, "broken.page.html", td)
+	if rr.Code != http.StatusInternalServerError || strings.Contains(rr.Body.String(), "half a page") {
+		t.Errorf("got status %d and %q, want a bare 500", rr.Code, rr.Body.String())
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	page := `{{ymdDate .Date}} {{formatDate .Date "Jan 2"}} {{range iterate 3}}{{add . 1}}{{end}} {{t "Start"}} {{.Name}}`
+	ts, err := template.New("functions").Funcs(functions).Funcs(localeFunctions("en")).Parse(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sb strings.Builder
+	err = ts.Execute(&sb, map[string]interface{}{
+		"Date": time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC),
+		"Name": "<b>John</b>",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "2026-10-19 Oct 19 123 Start &lt;b&gt;John&lt;/b&gt;"; sb.String() != want {
+		t.Errorf("got %q, want %q", sb.String(), want)
+	}
+}
@@ -0,0 +1,137 @@
+package render
+
+import (
+	"html/template"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+)
+
+// templateWatcher reparses the pages of a templates directory whose files changed since the last check
+type templateWatcher struct {
+	fsys     fs.FS
+	modTimes map[string]time.Time
+}
+
+// newTemplateWatcher returns a watcher of fsys that takes the files as they are now as unchanged
+func newTemplateWatcher(fsys fs.FS) (*templateWatcher, error) {
+	tw := &templateWatcher{fsys: fsys}
+	modTimes, err := tw.scan()
+	if err != nil {
+		return nil, err
+	}
+	tw.modTimes = modTimes
+	return tw, nil
+}
+
+// scan returns the modification times of the pages and layouts
+func (tw *templateWatcher) scan() (map[string]time.Time, error) {
+	modTimes := make(map[string]time.Time)
+	for _, pattern := range []string{"*.page.html", "*.layout.html"} {
+		names, err := fs.Glob(tw.fsys, pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			info, err := fs.Stat(tw.fsys, name)
+			if err != nil {
+				return nil, err
+			}
+			modTimes[name] = info.ModTime()
+		}
+	}
+	return modTimes, nil
+}
+
+// reload reparses the pages that were changed or added and drops the removed ones from the cache.
+// Every page includes the layouts, so a changed layout reparses them all. It returns the reparsed pages
+func (tw *templateWatcher) reload() ([]string, error) {
+	modTimes, err := tw.scan()
+	if err != nil {
+		return nil, err
+	}
+
+	layoutChanged := false
+	var changed, removed []string
+	for name, modTime := range modTimes {
+		if old, ok := tw.modTimes[name]; ok && old.Equal(modTime) {
+			continue
+		}
+		if strings.HasSuffix(name, ".layout.html") {
+			layoutChanged = true
+		} else {
+			changed = append(changed, name)
+		}
+	}
+	for name := range tw.modTimes {
+		if _, ok := modTimes[name]; ok {
+			continue
+		}
+		if strings.HasSuffix(name, ".layout.html") {
+			layoutChanged = true
+		} else {
+			removed = append(removed, name)
+		}
+	}
+	if layoutChanged {
+		changed = changed[:0]
+		for name := range modTimes {
+			if strings.HasSuffix(name, ".page.html") {
+				changed = append(changed, name)
+			}
+		}
+	}
+
+	// parse before taking the lock, a page with an error keeps its last good version and is retried next time
+	parsed := make(map[string]*template.Template, len(changed))
+	for _, page := range changed {
+		ts, err := parsePage(tw.fsys, page)
+		if err != nil {
+			return nil, err
+		}
+		parsed[page] = ts
+	}
+
+	cacheMu.Lock()
+	tc := make(map[string]*template.Template, len(app.TemplateCache))
+	for name, ts := range app.TemplateCache {
+		tc[name] = ts
+	}
+	for name, ts := range parsed {
+		tc[name] = ts
+	}
+	for _, name := range removed {
+		delete(tc, name)
+	}
+	app.TemplateCache = tc
+	localized = make(map[*template.Template]map[string]*template.Template)
+	cacheMu.Unlock()
+
+	tw.modTimes = modTimes
+	return changed, nil
+}
+
+// WatchTemplates checks the templates directory at pathTemplates every interval and reloads the
+// templates that changed. It is for development, production serves the cache built at startup
+func WatchTemplates(pathTemplates string, interval time.Duration) error {
+	tw, err := newTemplateWatcher(os.DirFS(pathTemplates))
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for range time.Tick(interval) {
+			pages, err := tw.reload()
+			if err != nil {
+				app.ErrorLog.Printf("Cannot reload templates: %s\n", err)
+				continue
+			}
+			if len(pages) > 0 {
+				app.InfoLog.Printf("Reloaded %s\n", strings.Join(pages, ", "))
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package render
+
+import (
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTemplate writes a template file and dates it at modTime, so that tests do not depend on
+// the resolution of the file system clock
+func writeTemplate(t *testing.T, dir, name, content string, modTime time.Time) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTemplateWatcher(t *testing.T) {
+	defer func(tc map[string]*template.Template) { app.TemplateCache = tc }(app.TemplateCache)
+
+	dir := t.TempDir()
+	start := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	writeTemplate(t, dir, "base.layout.html", `{{define "base"}}<main>{{block "content" .}}{{end}}</main>{{end}}`, start)
+	writeTemplate(t, dir, "home.page.html", `{{template "base" .}}{{define "content"}}home{{end}}`, start)
+	writeTemplate(t, dir, "about.page.html", `{{template "base" .}}{{define "content"}}about{{end}}`, start)
+
+	tc, err := CreateTemplateCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.TemplateCache = tc
+
+	tw, err := newTemplateWatcher(os.DirFS(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	render := func(page string) string {
+		t.Helper()
+		var sb strings.Builder
+		if err := app.TemplateCache[page].Execute(&sb, nil); err != nil {
+			t.Fatal(err)
+		}
+		return sb.String()
+	}
+
+	pages, err := tw.reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 0 {
+		t.Errorf("reloaded %v with nothing changed", pages)
+	}
+
+	// a changed page is reparsed alone
+	about := app.TemplateCache["about.page.html"]
+	writeTemplate(t, dir, "home.page.html", `{{template "base" .}}{{define "content"}}welcome{{end}}`, start.Add(time.Minute))
+	pages, err = tw.reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 1 || pages[0] != "home.page.html" {
+		t.Errorf("reloaded %v, want only home.page.html", pages)
+	}
+	if got := render("home.page.html"); got != "<main>welcome</main>" {
+		t.Errorf("home renders %q after the change", got)
+	}
+	if app.TemplateCache["about.page.html"] != about {
+		t.Error("unchanged about.page.html was reparsed")
+	}
+
+	// a page with an error keeps its last good version until it is fixed
+	writeTemplate(t, dir, "home.page.html", `{{template "base" .}}{{define "content"}}{{end`, start.Add(2*time.Minute))
+	if _, err = tw.reload(); err == nil {
+		t.Error("reloaded a broken page")
+	}
+	if got := render("home.page.html"); got != "<main>welcome</main>" {
+		t.Errorf("home renders %q with a broken page on disk", got)
+	}
+	writeTemplate(t, dir, "home.page.html", `{{template "base" .}}{{define "content"}}hello{{end}}`, start.Add(3*time.Minute))
+	if _, err = tw.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := render("home.page.html"); got != "<main>hello</main>" {
+		t.Errorf("home renders %q after the fix", got)
+	}
+
+	// a changed layout reparses every page
+	writeTemplate(t, dir, "base.layout.html", `{{define "base"}}<div>{{block "content" .}}{{end}}</div>{{end}}`, start.Add(4*time.Minute))
+	pages, err = tw.reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 2 {
+		t.Errorf("reloaded %v after a layout change, want both pages", pages)
+	}
+	if got := render("about.page.html"); got != "<div>about</div>" {
+		t.Errorf("about renders %q after the layout change", got)
+	}
+
+	// pages can be added and removed
+	writeTemplate(t, dir, "contact.page.html", `{{template "base" .}}{{define "content"}}contact{{end}}`, start.Add(5*time.Minute))
+	if err := os.Remove(filepath.Join(dir, "about.page.html")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tw.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := render("contact.page.html"); got != "<div>contact</div>" {
+		t.Errorf("contact renders %q", got)
+	}
+	if _, ok := app.TemplateCache["about.page.html"]; ok {
+		t.Error("removed about.page.html is still cached")
+	}
+}
@@ -0,0 +1,305 @@
+package reports
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/csvsafe"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/database"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/dates"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+)
+
+// MaxDays is the longest period a report can cover, two years
+const MaxDays = 731
+
+// heatLevels is the number of shades of the heatmap, level 0 is a cell without any rented day
+const heatLevels = 5
+
+// Report is the utilization and demand of every laptop from Start to End, both included
+type Report struct {
+	Start   time.Time
+	End     time.Time
+	Laptops []LaptopReport
+	// Total adds up every laptop, its days are laptop days
+	Total LaptopReport
+	// Heatmap counts the rented laptop days of each month and weekday
+	Heatmap []HeatmapRow
+}
+
+// LaptopReport is the utilization and demand of one laptop
+type LaptopReport struct {
+	Laptop models.Laptop
+	// PeriodDays are the days of the report, BlockedDays the ones the laptop was blocked
+	// and BookedDays the other ones it was rented
+	PeriodDays  int
+	BlockedDays int
+	BookedDays  int
+	// Reservations and Cancellations count the kept and cancelled reservations starting during the report
+	Reservations  int
+	Cancellations int
+	// LeadDays and RentalDays add up the days from booking to pickup and the length of those reservations
+	LeadDays   int
+	RentalDays int
+}
+
+// HeatmapRow is the number of rented laptop days of one month on each weekday, Sunday first
+type HeatmapRow struct {
+	Month    time.Month
+	Weekdays [7]int
+}
+
+// AvailableDays returns the days the laptop could be rented, the ones it wasn't blocked
+func (l LaptopReport) AvailableDays() int {
+	return l.PeriodDays - l.BlockedDays
+}
+
+// Utilization returns the percentage of the available days the laptop was rented
+func (l LaptopReport) Utilization() float64 {
+	return percent(l.BookedDays, l.AvailableDays())
+}
+
+// AverageLeadDays returns how many days ahead of pickup reservations were made on average
+func (l LaptopReport) AverageLeadDays() float64 {
+	return average(l.LeadDays, l.Reservations)
+}
+
+// AverageRentalDays returns how many days reservations lasted on average, the start and end date included
+func (l LaptopReport) AverageRentalDays() float64 {
+	return average(l.RentalDays, l.Reservations)
+}
+
+// CancellationRate returns the percentage of the reservations starting during the report that were cancelled
+func (l LaptopReport) CancellationRate() float64 {
+	return percent(l.Cancellations, l.Reservations+l.Cancellations)
+}
+
+func percent(n, of int) float64 {
+	if of <= 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(of)
+}
+
+func average(sum, n int) float64 {
+	if n == 0 {
+		return 0
+	}
+	return float64(sum) / float64(n)
+}
+
+// Build computes the report of every laptop from start to end
+func Build(db database.DBRepository, start, end time.Time) (Report, error) {
+	laptops, err := db.AllLaptops()
+	if err != nil {
+		return Report{}, err
+	}
+	reservations, err := db.GetReservationsByDate(start, end)
+	if err != nil {
+		return Report{}, err
+	}
+	blocks, err := db.GetBlocksByDate(start, end)
+	if err != nil {
+		return Report{}, err
+	}
+	cancellations, err := db.GetCancellationsByDate(start, end)
+	if err != nil {
+		return Report{}, err
+	}
+
+	return compute(start, end, laptops, reservations, blocks, cancellations), nil
+}
+
+// compute builds the report from the laptops and the reservations, blocks and cancellations overlapping start to end
+func compute(start, end time.Time, laptops []models.Laptop, reservations []models.Reservation,
+	blocks []models.LaptopRestriction, cancellations []models.Cancellation) Report {
+	r := Report{Start: start, End: end}
+	periodDays := int(end.Sub(start).Hours()/24) + 1
+
+	// days blocked and rented per laptop, overlapping blocks or reservations count once
+	blocked := make(map[int]map[time.Time]bool)
+	for _, b := range blocks {
+		if blocked[b.LaptopID] == nil {
+			blocked[b.LaptopID] = make(map[time.Time]bool)
+		}
+		eachDay(b.StartDate, b.EndDate, start, end, func(day time.Time) {
+			blocked[b.LaptopID][day] = true
+		})
+	}
+	booked := make(map[int]map[time.Time]bool)
+	for _, res := range reservations {
+		if booked[res.LaptopID] == nil {
+			booked[res.LaptopID] = make(map[time.Time]bool)
+		}
+		eachDay(res.StartDate, res.EndDate, start, end, func(day time.Time) {
+			if !blocked[res.LaptopID][day] {
+				booked[res.LaptopID][day] = true
+			}
+		})
+	}
+
+	var heatmap [12][7]int
+	index := make(map[int]int)
+	for i, laptop := range laptops {
+		index[laptop.ID] = i
+		l := LaptopReport{
+			Laptop:      models.Laptop{ID: laptop.ID, LaptopName: laptop.LaptopName},
+			PeriodDays:  periodDays,
+			BlockedDays: len(blocked[laptop.ID]),
+			BookedDays:  len(booked[laptop.ID]),
+		}
+		for day := range booked[laptop.ID] {
+			heatmap[day.Month()-1][day.Weekday()]++
+		}
+		r.Laptops = append(r.Laptops, l)
+	}
+
+	startsDuring := func(day time.Time) bool {
+		return !day.Before(start) && !day.After(end)
+	}
+	for _, res := range reservations {
+		i, ok := index[res.LaptopID]
+		if !ok || !startsDuring(res.StartDate) {
+			continue
+		}
+		r.Laptops[i].Reservations++
+		r.Laptops[i].LeadDays += int(res.StartDate.Sub(dates.Day(res.CreatedAt)).Hours() / 24)
+		r.Laptops[i].RentalDays += int(res.EndDate.Sub(res.StartDate).Hours()/24) + 1
+	}
+	for _, c := range cancellations {
+		i, ok := index[c.LaptopID]
+		if ok && startsDuring(c.StartDate) {
+			r.Laptops[i].Cancellations++
+		}
+	}
+
+	r.Total.Laptop.LaptopName = "Total"
+	for _, l := range r.Laptops {
+		r.Total.PeriodDays += l.PeriodDays
+		r.Total.BlockedDays += l.BlockedDays
+		r.Total.BookedDays += l.BookedDays
+		r.Total.Reservations += l.Reservations
+		r.Total.Cancellations += l.Cancellations
+		r.Total.LeadDays += l.LeadDays
+		r.Total.RentalDays += l.RentalDays
+	}
+
+	for month := range heatmap {
+		r.Heatmap = append(r.Heatmap, HeatmapRow{Month: time.Month(month + 1), Weekdays: heatmap[month]})
+	}
+
+	return r
+}
+
+// eachDay calls f with each day from from to to that falls between start and end
+func eachDay(from, to, start, end time.Time, f func(day time.Time)) {
+	if from.Before(start) {
+		from = start
+	}
+	if to.After(end) {
+		to = end
+	}
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		f(day)
+	}
+}
+
+// HeatLevel returns the shade of a heatmap cell of n rented days, from 0 for none
+// to heatLevels-1 for the busiest cell of the report
+func (r Report) HeatLevel(n int) int {
+	max := 0
+	for _, row := range r.Heatmap {
+		for _, count := range row.Weekdays {
+			if count > max {
+				max = count
+			}
+		}
+	}
+	if n <= 0 || max == 0 {
+		return 0
+	}
+	return 1 + (n*(heatLevels-1)-1)/max
+}
+
+// WriteLaptopsCSV writes the report of each laptop and the total as CSV
+func (r Report) WriteLaptopsCSV(w io.Writer) error {
+	cw := csvsafe.NewWriter(w)
+	cw.Write([]string{
+		"laptop_id", "laptop", "start_date", "end_date", "period_days", "blocked_days", "available_days", "booked_days",
+		"utilization_percent", "reservations", "cancellations", "cancellation_rate_percent",
+		"average_lead_days", "average_rental_days",
+	})
+	for _, l := range append(r.Laptops, r.Total) {
+		cw.Write([]string{
+			strconv.Itoa(l.Laptop.ID),
+			l.Laptop.LaptopName,
+			r.Start.Format(dates.Layout),
+			r.End.Format(dates.Layout),
+			strconv.Itoa(l.PeriodDays),
+			strconv.Itoa(l.BlockedDays),
+			strconv.Itoa(l.AvailableDays()),
+			strconv.Itoa(l.BookedDays),
+			formatFloat(l.Utilization()),
+			strconv.Itoa(l.Reservations),
+			strconv.Itoa(l.Cancellations),
+			formatFloat(l.CancellationRate()),
+			formatFloat(l.AverageLeadDays()),
+			formatFloat(l.AverageRentalDays()),
+		})
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// WriteHeatmapCSV writes the rented laptop days of each month and weekday as CSV
+func (r Report) WriteHeatmapCSV(w io.Writer) error {
+	cw := csvsafe.NewWriter(w)
+	header := []string{"month"}
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		header = append(header, d.String())
+	}
+	cw.Write(header)
+	for _, row := range r.Heatmap {
+		record := []string{row.Month.String()}
+		for _, n := range row.Weekdays {
+			record = append(record, strconv.Itoa(n))
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// Mail returns the report of each laptop and the total as a table mailed from from to to
+func (r Report) Mail(from, to string) models.MailData {
+	period := fmt.Sprintf("%s to %s", r.Start.Format(dates.Layout), r.End.Format(dates.Layout))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<strong>Laptop report from %s</strong><br>\n", period)
+	b.WriteString("<table>\n<tr><th>Laptop</th><th>Available days</th><th>Booked days</th><th>Utilization</th>" +
+		"<th>Reservations</th><th>Cancellations</th><th>Average rental days</th></tr>\n")
+	for _, l := range append(r.Laptops, r.Total) {
+		fmt.Fprintf(&b, "<tr><td>%s</td><td>%d</td><td>%d</td><td>%s%%</td><td>%d</td><td>%d</td><td>%s</td></tr>\n",
+			html.EscapeString(l.Laptop.LaptopName), l.AvailableDays(), l.BookedDays, formatFloat(l.Utilization()),
+			l.Reservations, l.Cancellations, formatFloat(l.AverageRentalDays()))
+	}
+	b.WriteString("</table>")
+
+	return models.MailData{
+		To:       to,
+		From:     from,
+		Subject:  "Laptop report from " + period,
+		Content:  b.String(),
+		Template: "basic.email.html",
+	}
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', 1, 64)
+}
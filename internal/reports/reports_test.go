@@ -0,0 +1,190 @@
+package reports
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/config"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/database"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/dates"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+)
+
+func date(day int) time.Time {
+	return time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, day-1)
+}
+
+var laptops = []models.Laptop{
+	{ID: 1, LaptopName: "Alienware M15 R2"},
+	{ID: 2, LaptopName: "Macbook Pro 15 inch"},
+}
+
+func TestCompute(t *testing.T) {
+	// the lead time counts from the day the reservation was made in the business timezone
+	defer dates.SetLocation(dates.Location())
+	dates.SetLocation(time.UTC)
+
+	reservations := []models.Reservation{
+		// started before the report, only its last 2 days count
+		{ID: 1, LaptopID: 1, StartDate: date(0), EndDate: date(2), CreatedAt: date(-10)},
+		// booked 5 days ahead, one of its days is blocked
+		{ID: 2, LaptopID: 1, StartDate: date(5), EndDate: date(8), CreatedAt: date(0).Add(10 * time.Hour)},
+		{ID: 3, LaptopID: 2, StartDate: date(9), EndDate: date(10), CreatedAt: date(8)},
+	}
+	blocks := []models.LaptopRestriction{
+		{LaptopID: 1, StartDate: date(8), EndDate: date(12), RestrictionID: models.RestrictionBlock},
+	}
+	cancellations := []models.Cancellation{
+		{LaptopID: 2, StartDate: date(3), EndDate: date(4)},
+		// starts after the report
+		{LaptopID: 2, StartDate: date(11), EndDate: date(12)},
+	}
+
+	r := compute(date(1), date(10), laptops, reservations, blocks, cancellations)
+
+	if len(r.Laptops) != 2 {
+		t.Fatalf("expected 2 laptops, got %d", len(r.Laptops))
+	}
+	alienware, macbook := r.Laptops[0], r.Laptops[1]
+
+	if alienware.PeriodDays != 10 || alienware.BlockedDays != 3 || alienware.AvailableDays() != 7 || alienware.BookedDays != 5 {
+		t.Errorf("unexpected days of laptop 1: %+v", alienware)
+	}
+	if alienware.Reservations != 1 || alienware.AverageLeadDays() != 5 || alienware.AverageRentalDays() != 4 {
+		t.Errorf("unexpected reservations of laptop 1: %+v", alienware)
+	}
+	if u := alienware.Utilization(); u < 71.4 || u > 71.5 {
+		t.Errorf("expected a utilization of 5/7, got %f", u)
+	}
+
+	if macbook.BookedDays != 2 || macbook.Utilization() != 20 {
+		t.Errorf("unexpected days of laptop 2: %+v", macbook)
+	}
+	if macbook.Reservations != 1 || macbook.Cancellations != 1 || macbook.CancellationRate() != 50 {
+		t.Errorf("unexpected cancellations of laptop 2: %+v", macbook)
+	}
+
+	if r.Total.PeriodDays != 20 || r.Total.BookedDays != 7 || r.Total.Reservations != 2 || r.Total.Cancellations != 1 {
+		t.Errorf("unexpected total: %+v", r.Total)
+	}
+
+	// January 1st 2099 is a Thursday, the rented days are the 1st, 2nd, 5th to 7th, 9th and 10th
+	expected := [7]int{0, 1, 1, 1, 1, 2, 1}
+	if r.Heatmap[0].Month != time.January || r.Heatmap[0].Weekdays != expected {
+		t.Errorf("expected January %v, got %v %v", expected, r.Heatmap[0].Month, r.Heatmap[0].Weekdays)
+	}
+	if len(r.Heatmap) != 12 || r.Heatmap[1].Weekdays != [7]int{} {
+		t.Errorf("unexpected heatmap %v", r.Heatmap)
+	}
+	if r.HeatLevel(0) != 0 || r.HeatLevel(1) != 2 || r.HeatLevel(2) != 4 {
+		t.Errorf("unexpected heat levels %d %d %d", r.HeatLevel(0), r.HeatLevel(1), r.HeatLevel(2))
+	}
+}
+
+func TestCompute_Empty(t *testing.T) {
+	r := compute(date(1), date(10), laptops, nil, nil, nil)
+
+	for _, l := range append(r.Laptops, r.Total) {
+		if l.Utilization() != 0 || l.AverageLeadDays() != 0 || l.AverageRentalDays() != 0 || l.CancellationRate() != 0 {
+			t.Errorf("unexpected report without reservations: %+v", l)
+		}
+	}
+	if r.HeatLevel(0) != 0 {
+		t.Error("empty heatmap cell is shaded")
+	}
+}
+
+func TestBuild(t *testing.T) {
+	db := database.NewMemory(&config.AppConfig{})
+	_, err := db.InsertReservation(&models.Reservation{LaptopID: 2, StartDate: date(2), EndDate: date(3)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.InsertBlockByLaptopID(2, date(4), date(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := db.InsertReservation(&models.Reservation{LaptopID: 2, StartDate: date(6), EndDate: date(6)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.DeleteReservation(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Build(db, date(1), date(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	macbook := r.Laptops[1]
+	if macbook.Laptop.ID != 2 || macbook.BookedDays != 2 || macbook.BlockedDays != 1 || macbook.Cancellations != 1 {
+		t.Errorf("unexpected report of laptop 2: %+v", macbook)
+	}
+}
+
+func TestReport_WriteCSV(t *testing.T) {
+	reservations := []models.Reservation{
+		{LaptopID: 1, StartDate: date(1), EndDate: date(2), CreatedAt: date(1)},
+	}
+	r := compute(date(1), date(10), laptops, reservations, nil, nil)
+
+	var buf bytes.Buffer
+	err := r.WriteLaptopsCSV(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected a header, 2 laptops and the total, got %q", lines)
+	}
+	if !strings.HasPrefix(lines[0], "laptop_id,laptop,start_date,end_date,") {
+		t.Errorf("unexpected header %q", lines[0])
+	}
+	if lines[1] != "1,Alienware M15 R2,2099-01-01,2099-01-10,10,0,10,2,20.0,1,0,0.0,0.0,2.0" {
+		t.Errorf("unexpected row %q", lines[1])
+	}
+	if !strings.HasPrefix(lines[3], "0,Total,") {
+		t.Errorf("unexpected total %q", lines[3])
+	}
+
+	// a laptop name a spreadsheet would run as a formula is kept as text
+	buf.Reset()
+	compute(date(1), date(10), []models.Laptop{{ID: 1, LaptopName: "=1+1"}}, nil, nil, nil).WriteLaptopsCSV(&buf)
+	if !strings.HasPrefix(strings.Split(buf.String(), "\n")[1], "1,'=1+1,") {
+		t.Errorf("expected the formula kept as text, got %q", buf.String())
+	}
+
+	buf.Reset()
+	err = r.WriteHeatmapCSV(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines = strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 13 || lines[0] != "month,Sunday,Monday,Tuesday,Wednesday,Thursday,Friday,Saturday" {
+		t.Fatalf("unexpected heatmap %q", lines)
+	}
+	if lines[1] != "January,0,0,0,0,1,1,0" {
+		t.Errorf("unexpected January %q", lines[1])
+	}
+}
+
+func TestReport_Mail(t *testing.T) {
+	reservations := []models.Reservation{
+		{LaptopID: 1, StartDate: date(1), EndDate: date(2), CreatedAt: date(1)},
+	}
+	r := compute(date(1), date(10), []models.Laptop{{ID: 1, LaptopName: "<b>Alienware</b>"}}, reservations, nil, nil)
+
+	m := r.Mail("shop@example.com", "owner@example.com")
+	if m.To != "owner@example.com" || m.From != "shop@example.com" || m.Subject != "Laptop report from 2099-01-01 to 2099-01-10" {
+		t.Errorf("unexpected mail %+v", m)
+	}
+	if !strings.Contains(m.Content, "<td>&lt;b&gt;Alienware&lt;/b&gt;</td><td>10</td><td>2</td><td>20.0%</td><td>1</td>") {
+		t.Errorf("expected the escaped laptop row, got %s", m.Content)
+	}
+	if !strings.Contains(m.Content, "<td>Total</td>") {
+		t.Errorf("expected the total row, got %s", m.Content)
+	}
+}
@@ -0,0 +1,174 @@
+package rules
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/database"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/dates"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/i18n"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+)
+
+// today returns the current date in the business timezone, a variable so that tests can move it
+var today = dates.Today
+
+// Violation is a broken booking rule, Field is the form field it is reported on
+type Violation struct {
+	Field string
+	// Message is in English, its verbs are filled in with Args
+	Message string
+	Args    []interface{}
+}
+
+// localizer is an argument of a message that depends on the language, like a list of weekdays
+type localizer interface {
+	In(locale string) string
+}
+
+// In returns the message of v in locale
+func (v Violation) In(locale string) string {
+	args := make([]interface{}, len(v.Args))
+	for i, a := range v.Args {
+		if l, ok := a.(localizer); ok {
+			a = l.In(locale)
+		}
+		args[i] = a
+	}
+	return i18n.T(locale, v.Message, args...)
+}
+
+// For returns the booking rules of laptopID, the global rules with the ones set for the laptop taking precedence,
+// laptop id 0 returns the global rules
+func For(db database.DBRepository, laptopID int) (models.BookingRules, error) {
+	global, err := db.GetBookingRules(0)
+	if err != nil && err != sql.ErrNoRows {
+		return global, err
+	}
+	if laptopID == 0 {
+		return global, nil
+	}
+
+	own, err := db.GetBookingRules(laptopID)
+	if err == sql.ErrNoRows {
+		return global, nil
+	}
+	if err != nil {
+		return global, err
+	}
+
+	r := global
+	r.ID = own.ID
+	r.LaptopID = laptopID
+	if own.MinDays > 0 {
+		r.MinDays = own.MinDays
+	}
+	if own.MaxDays > 0 {
+		r.MaxDays = own.MaxDays
+	}
+	if own.MaxAdvanceDays > 0 {
+		r.MaxAdvanceDays = own.MaxAdvanceDays
+	}
+	if len(own.PickupWeekdays) > 0 {
+		r.PickupWeekdays = own.PickupWeekdays
+	}
+
+	return r, nil
+}
+
+// Check returns the booking rules broken by renting laptopID from start to end,
+// laptop id 0 checks the rules and blackouts of every laptop only
+func Check(db database.DBRepository, laptopID int, start, end time.Time) ([]Violation, error) {
+	r, err := For(db, laptopID)
+	if err != nil {
+		return nil, err
+	}
+
+	blackouts, err := db.GetBlackoutsByDate(laptopID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	return Evaluate(r, blackouts, start, end), nil
+}
+
+// Evaluate returns the rules of r and the blackouts broken by a rental from start to end
+func Evaluate(r models.BookingRules, blackouts []models.Blackout, start, end time.Time) []Violation {
+	if end.Before(start) {
+		return []Violation{{Field: "end_date", Message: "The end date can't be before the start date"}}
+	}
+
+	var violations []Violation
+
+	// both the start and the end date are rented
+	length := int(end.Sub(start).Hours()/24) + 1
+	if r.MinDays > 0 && length < r.MinDays {
+		violations = append(violations, Violation{"end_date", "Rentals must be at least %s long", []interface{}{days(r.MinDays)}})
+	}
+	if r.MaxDays > 0 && length > r.MaxDays {
+		violations = append(violations, Violation{"end_date", "Rentals can't be longer than %s", []interface{}{days(r.MaxDays)}})
+	}
+
+	if r.MaxAdvanceDays > 0 && start.After(today().AddDate(0, 0, r.MaxAdvanceDays)) {
+		violations = append(violations, Violation{"start_date",
+			"Rentals can't start more than %s ahead, the last possible start date is %s",
+			[]interface{}{days(r.MaxAdvanceDays), today().AddDate(0, 0, r.MaxAdvanceDays).Format(dates.Layout)}})
+	}
+
+	if len(r.PickupWeekdays) > 0 && !pickupDay(r.PickupWeekdays, start.Weekday()) {
+		violations = append(violations, Violation{"start_date",
+			"Laptops can only be picked up on %s", []interface{}{weekdays(r.PickupWeekdays)}})
+	}
+
+	for _, b := range blackouts {
+		v := Violation{"start_date", "Laptops can't be rented from %s to %s",
+			[]interface{}{b.StartDate.Format(dates.Layout), b.EndDate.Format(dates.Layout)}}
+		if b.LaptopID != 0 {
+			v.Message = "This laptop can't be rented from %s to %s"
+		}
+		if b.Reason != "" {
+			v.Message += " (%s)"
+			v.Args = append(v.Args, b.Reason)
+		}
+		violations = append(violations, v)
+	}
+
+	return violations
+}
+
+// days is a number of days, in words in a message
+type days int
+
+// In returns n days in words
+func (n days) In(locale string) string {
+	if n == 1 {
+		return i18n.T(locale, "1 day")
+	}
+	return i18n.T(locale, "%d days", int(n))
+}
+
+// pickupDay reports whether d is one of the pickup weekdays
+func pickupDay(pickup []time.Weekday, d time.Weekday) bool {
+	for _, p := range pickup {
+		if p == d {
+			return true
+		}
+	}
+	return false
+}
+
+// weekdays are the pickup weekdays, in words in a message
+type weekdays []time.Weekday
+
+// In returns the weekdays in words, like "Monday, Wednesday or Friday"
+func (w weekdays) In(locale string) string {
+	names := make([]string, len(w))
+	for i, d := range w {
+		names[i] = i18n.T(locale, d.String())
+	}
+	if len(names) == 1 {
+		return names[0]
+	}
+	return i18n.T(locale, "%s or %s", strings.Join(names[:len(names)-1], i18n.T(locale, ", ")), names[len(names)-1])
+}
@@ -0,0 +1,142 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/config"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/database"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/i18n"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+)
+
+// date returns day of January 2099, the 5th is a Monday
+func date(day int) time.Time {
+	return time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, day-1)
+}
+
+func fields(violations []Violation) []string {
+	var f []string
+	for _, v := range violations {
+		f = append(f, v.Field+": "+v.In(i18n.English))
+	}
+	return f
+}
+
+func TestEvaluate(t *testing.T) {
+	defer func(f func() time.Time) { today = f }(today)
+	today = func() time.Time { return date(1) }
+
+	r := models.BookingRules{
+		MinDays:        2,
+		MaxDays:        7,
+		MaxAdvanceDays: 30,
+		PickupWeekdays: []time.Weekday{time.Monday, time.Friday},
+	}
+
+	tests := []struct {
+		name       string
+		start, end time.Time
+		expected   []string
+	}{
+		{"valid", date(5), date(6), nil},
+		{"reversed", date(6), date(5), []string{"end_date: The end date can't be before the start date"}},
+		{"too short", date(5), date(5), []string{"end_date: Rentals must be at least 2 days long"}},
+		{"too long", date(5), date(12), []string{"end_date: Rentals can't be longer than 7 days"}},
+		{"too far ahead", date(33), date(34), []string{
+			"start_date: Rentals can't start more than 30 days ahead, the last possible start date is 2099-01-31",
+		}},
+		{"wrong weekday", date(6), date(7), []string{"start_date: Laptops can only be picked up on Monday or Friday"}},
+	}
+
+	for _, tt := range tests {
+		got := fields(Evaluate(r, nil, tt.start, tt.end))
+		if len(got) != len(tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.expected[i] {
+				t.Errorf("%s: expected %q, got %q", tt.name, tt.expected[i], got[i])
+			}
+		}
+	}
+
+	// no rules, no violations
+	if v := Evaluate(models.BookingRules{}, nil, date(6), date(60)); len(v) != 0 {
+		t.Errorf("empty rules were broken: %v", fields(v))
+	}
+}
+
+func TestViolation_In(t *testing.T) {
+	r := models.BookingRules{MinDays: 3, PickupWeekdays: []time.Weekday{time.Monday, time.Wednesday, time.Friday}}
+	blackouts := []models.Blackout{{LaptopID: 1, StartDate: date(6), EndDate: date(7), Reason: "メンテナンス"}}
+
+	var got []string
+	for _, v := range Evaluate(r, blackouts, date(6), date(6)) {
+		got = append(got, v.In("ja"))
+	}
+	expected := []string{
+		"レンタル期間は3日以上にしてください",
+		"ノートパソコンの受け取りは月曜日、水曜日または金曜日のみです",
+		"このノートパソコンは 2099-01-06 から 2099-01-07 までレンタルできません（メンテナンス）",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	defer func(f func() time.Time) { today = f }(today)
+	today = func() time.Time { return date(1) }
+
+	db := database.NewMemory(&config.AppConfig{})
+	err := db.SaveBookingRules(&models.BookingRules{MinDays: 2, MaxDays: 7, PickupWeekdays: []time.Weekday{time.Monday}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// laptop 1 can be rented for longer and has its own pickup day
+	err = db.SaveBookingRules(&models.BookingRules{LaptopID: 1, MaxDays: 14, PickupWeekdays: []time.Weekday{time.Tuesday}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.InsertBlackout(&models.Blackout{StartDate: date(20), EndDate: date(21), Reason: "inventory"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.InsertBlackout(&models.Blackout{LaptopID: 2, StartDate: date(13), EndDate: date(13)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := For(db, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.MinDays != 2 || r.MaxDays != 14 || len(r.PickupWeekdays) != 1 || r.PickupWeekdays[0] != time.Tuesday {
+		t.Errorf("expected the rules of laptop 1 over the global ones, got %+v", r)
+	}
+
+	// Tuesday the 6th to the 15th
+	v, _ := Check(db, 1, date(6), date(15))
+	if len(v) != 0 {
+		t.Errorf("laptop 1: unexpected violations %v", fields(v))
+	}
+	v, _ = Check(db, 2, date(6), date(15))
+	expected := []string{
+		"end_date: Rentals can't be longer than 7 days",
+		"start_date: Laptops can only be picked up on Monday",
+		"start_date: This laptop can't be rented from 2099-01-13 to 2099-01-13",
+	}
+	if got := fields(v); len(got) != len(expected) || got[0] != expected[0] || got[1] != expected[1] || got[2] != expected[2] {
+		t.Errorf("laptop 2: expected %v, got %v", expected, got)
+	}
+
+	// the global rules only, for searches over every laptop
+	v, _ = Check(db, 0, date(19), date(20))
+	expected = []string{"start_date: Laptops can't be rented from 2099-01-20 to 2099-01-21 (inventory)"}
+	if got := fields(v); len(got) != 1 || got[0] != expected[0] {
+		t.Errorf("global: expected %v, got %v", expected, got)
+	}
+}
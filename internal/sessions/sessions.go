@@ -0,0 +1,175 @@
+package sessions
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/alexedwards/scs/postgresstore"
+	"github.com/alexedwards/scs/redisstore"
+	"github.com/alexedwards/scs/sqlite3store"
+	"github.com/alexedwards/scs/v2"
+	"github.com/alexedwards/scs/v2/memstore"
+	"github.com/gomodule/redigo/redis"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/driver"
+)
+
+// supported session stores
+const (
+	Memory   = "memory"
+	Database = "database"
+	Redis    = "redis"
+)
+
+// cleanupInterval is how often expired sessions are deleted from memory, Redis expires them by itself and the
+// session-cleanup job deletes them from the database
+const cleanupInterval = 5 * time.Minute
+
+// ErrNotFound is returned when revoking a session that doesn't exist (anymore)
+var ErrNotFound = errors.New("session not found")
+
+// Config holds the session store settings, read from the environment
+type Config struct {
+	Store         string
+	RedisAddr     string
+	RedisPassword string
+}
+
+// Validate checks that the settings needed by the store are present, an empty store means Memory
+func (c *Config) Validate() error {
+	if c.Store == "" {
+		c.Store = Memory
+	}
+
+	switch c.Store {
+	case Memory, Database:
+	case Redis:
+		if c.RedisAddr == "" {
+			return errors.New("missing redis address")
+		}
+	default:
+		return fmt.Errorf("unknown session store: %s", c.Store)
+	}
+
+	return nil
+}
+
+// NewStore returns the session store selected by the config, Database keeps sessions in the
+// sessions table of db, which falls back to memory if db itself is the memory driver
+func NewStore(c Config, db *driver.DB) (scs.Store, error) {
+	switch c.Store {
+	case Database:
+		switch db.Driver {
+		case driver.Postgres:
+			return postgresstore.NewWithCleanupInterval(db.Conn, 0), nil
+		case driver.SQLite:
+			return sqlite3store.NewWithCleanupInterval(db.Conn, 0), nil
+		}
+	case Redis:
+		pool := &redis.Pool{
+			MaxIdle:     10,
+			IdleTimeout: 240 * time.Second,
+			Dial: func() (redis.Conn, error) {
+				return redis.Dial("tcp", c.RedisAddr, redis.DialPassword(c.RedisPassword))
+			},
+		}
+		conn := pool.Get()
+		defer conn.Close()
+		_, err := conn.Do("PING")
+		if err != nil {
+			return nil, err
+		}
+		return redisstore.New(pool), nil
+	}
+
+	return memstore.NewWithCleanupInterval(cleanupInterval), nil
+}
+
+// DeleteExpired deletes the expired sessions kept in db by the Database store and returns how many there were
+func DeleteExpired(ctx context.Context, db *driver.DB) (int, error) {
+	var query string
+	switch db.Driver {
+	case driver.Postgres:
+		query = "DELETE FROM sessions WHERE expiry < current_timestamp"
+	case driver.SQLite:
+		query = "DELETE FROM sessions WHERE expiry < julianday('now')"
+	default:
+		return 0, nil
+	}
+
+	result, err := db.Conn.ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// Info describes an active session for the admin sessions page
+type Info struct {
+	ID             string
+	UserID         int
+	Deadline       time.Time
+	HasReservation bool
+	Current        bool
+}
+
+// ID returns the public id of a session token, the token itself is a credential and is never shown
+func ID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
+}
+
+// List returns the active sessions of sm, logged in users first and the session of ctx marked as current
+func List(ctx context.Context, sm *scs.SessionManager) ([]Info, error) {
+	current := sm.Token(ctx)
+
+	var infos []Info
+	err := sm.Iterate(context.Background(), func(ctx context.Context) error {
+		token := sm.Token(ctx)
+		infos = append(infos, Info{
+			ID:             ID(token),
+			UserID:         sm.GetInt(ctx, "user_id"),
+			Deadline:       sm.Deadline(ctx),
+			HasReservation: sm.Exists(ctx, "reservation"),
+			Current:        token == current,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		if (infos[i].UserID == 0) != (infos[j].UserID == 0) {
+			return infos[i].UserID != 0
+		}
+		return infos[i].Deadline.After(infos[j].Deadline)
+	})
+
+	return infos, nil
+}
+
+// Revoke deletes the session with the public id, its owner has to log in again
+func Revoke(sm *scs.SessionManager, id string) error {
+	var token string
+	err := sm.Iterate(context.Background(), func(ctx context.Context) error {
+		if t := sm.Token(ctx); ID(t) == id {
+			token = t
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		return ErrNotFound
+	}
+
+	return sm.Store.Delete(token)
+}
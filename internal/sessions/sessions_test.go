@@ -0,0 +1,145 @@
+package sessions
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/driver"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/migrate"
+	"github.com/kaitolucifer/go-laptop-rental-site/migrations"
+)
+
+// newSession commits a session with values to the store of sm and returns its token
+func newSession(t *testing.T, sm *scs.SessionManager, values map[string]interface{}) string {
+	t.Helper()
+
+	ctx, err := sm.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range values {
+		sm.Put(ctx, key, value)
+	}
+	token, _, err := sm.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestConfig_Validate(t *testing.T) {
+	var tests = []struct {
+		config Config
+		valid  bool
+	}{
+		{Config{}, true},
+		{Config{Store: Database}, true},
+		{Config{Store: Redis}, false},
+		{Config{Store: Redis, RedisAddr: "localhost:6379"}, true},
+		{Config{Store: "files"}, false},
+	}
+
+	for _, test := range tests {
+		err := test.config.Validate()
+		if (err == nil) != test.valid {
+			t.Errorf("%+v: expected valid %v, got %v", test.config, test.valid, err)
+		}
+	}
+}
+
+func TestListAndRevoke(t *testing.T) {
+	sm := scs.New()
+	sm.Store, _ = NewStore(Config{Store: Memory}, driver.ConnectMemory())
+
+	admin := newSession(t, sm, map[string]interface{}{"user_id": 1})
+	guest := newSession(t, sm, map[string]interface{}{"reservation": "in progress"})
+
+	ctx, _ := sm.Load(context.Background(), admin)
+	list, err := List(ctx, sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("expected 2 sessions, got %d", len(list))
+	}
+	if list[0].ID != ID(admin) || list[0].UserID != 1 || !list[0].Current || list[0].HasReservation {
+		t.Errorf("unexpected admin session: %+v", list[0])
+	}
+	if list[1].ID != ID(guest) || list[1].UserID != 0 || list[1].Current || !list[1].HasReservation {
+		t.Errorf("unexpected guest session: %+v", list[1])
+	}
+	if list[0].ID == admin {
+		t.Error("session id is the token")
+	}
+
+	err = Revoke(sm, ID(guest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := sm.Store.Find(guest); found {
+		t.Error("revoked session still exists")
+	}
+
+	err = Revoke(sm, ID(guest))
+	if err != ErrNotFound {
+		t.Errorf("expected ErrNotFound revoking twice, got %v", err)
+	}
+}
+
+func TestNewStore_Database(t *testing.T) {
+	db, err := driver.ConnectSQLite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	m, err := migrate.New(db.Conn, "sqlite3", migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sm := scs.New()
+	sm.Store, err = NewStore(Config{Store: Database}, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := newSession(t, sm, map[string]interface{}{"user_id": 7})
+
+	// a second manager, as after a restart or on another instance, sees the session
+	other := scs.New()
+	other.Store, _ = NewStore(Config{Store: Database}, db)
+	ctx, err := other.Load(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.GetInt(ctx, "user_id") != 7 {
+		t.Error("session not persisted in the database")
+	}
+
+	// expired sessions are not listed
+	_, err = db.Conn.Exec("UPDATE sessions SET expiry = julianday(?)", time.Now().Add(-time.Hour).UTC().Format("2006-01-02T15:04:05"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, _ = other.Load(context.Background(), "")
+	list, err := List(ctx, other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 0 {
+		t.Errorf("expected no active sessions, got %d", len(list))
+	}
+
+	n, err := DeleteExpired(context.Background(), db)
+	if err != nil || n != 1 {
+		t.Errorf("expected the expired session to be deleted, got %d %v", n, err)
+	}
+}
@@ -0,0 +1,182 @@
+package suggest
+
+import (
+	"sort"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/database"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/dates"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+)
+
+// maxShift is how many days before and after the requested dates free windows are looked for
+const maxShift = 30
+
+// maxWindows is how many alternative windows are suggested
+const maxWindows = 3
+
+// today returns the current date in the business timezone, a variable so that tests can move it
+var today = dates.Today
+
+// Window is a date range during which a laptop is free
+type Window struct {
+	LaptopID   int    `json:"laptop_id"`
+	LaptopName string `json:"laptop_name"`
+	StartDate  string `json:"start_date"`
+	EndDate    string `json:"end_date"`
+	// Shift is the number of days from the requested start date, negative is earlier
+	Shift int `json:"shift"`
+}
+
+// Suggestions are the alternatives to a search without availability
+type Suggestions struct {
+	// Windows are the nearest free windows of the same length as the search
+	Windows []Window `json:"windows"`
+	// Laptops are the other laptops free for the exact dates of the search
+	Laptops []Window `json:"laptops"`
+}
+
+// Empty reports whether there is nothing to suggest
+func (s Suggestions) Empty() bool {
+	return len(s.Windows) == 0 && len(s.Laptops) == 0
+}
+
+// ForLaptop suggests the nearest free windows of laptopID and the other laptops that are free from start to end
+func ForLaptop(db database.DBRepository, laptopID int, start, end time.Time) (Suggestions, error) {
+	var s Suggestions
+
+	laptop, err := db.GetLaptopByID(laptopID)
+	if err != nil {
+		return s, err
+	}
+
+	s.Windows, err = freeWindows(db, laptop, start, end)
+	if err != nil {
+		return s, err
+	}
+
+	laptops, err := db.SearchAvailabilityForAllLaptops(start, end)
+	if err != nil {
+		return s, err
+	}
+	for _, l := range laptops {
+		if l.ID != laptopID {
+			s.Laptops = append(s.Laptops, newWindow(l, start, end, 0))
+		}
+	}
+
+	return s, nil
+}
+
+// ForAllLaptops suggests the nearest free windows of any laptop, for when no laptop is free from start to end
+func ForAllLaptops(db database.DBRepository, start, end time.Time) (Suggestions, error) {
+	var s Suggestions
+
+	laptops, err := db.AllLaptops()
+	if err != nil {
+		return s, err
+	}
+
+	for _, laptop := range laptops {
+		windows, err := freeWindows(db, laptop, start, end)
+		if err != nil {
+			return s, err
+		}
+		s.Windows = append(s.Windows, windows...)
+	}
+
+	sort.SliceStable(s.Windows, func(i, j int) bool {
+		return closer(s.Windows[i].Shift, s.Windows[j].Shift)
+	})
+	if len(s.Windows) > maxWindows {
+		s.Windows = s.Windows[:maxWindows]
+	}
+
+	return s, nil
+}
+
+// freeWindows returns up to maxWindows free windows of laptop as long as start to end, nearest first,
+// windows can't start before tomorrow, like in the date pickers
+func freeWindows(db database.DBRepository, laptop models.Laptop, start, end time.Time) ([]Window, error) {
+	length := int(end.Sub(start).Hours() / 24)
+	earliest := today().AddDate(0, 0, 1)
+
+	from := start.AddDate(0, 0, -maxShift)
+	if from.Before(earliest) {
+		from = earliest
+	}
+	to := end.AddDate(0, 0, maxShift)
+	if to.Before(from) {
+		return nil, nil
+	}
+
+	// reservations and holds also take the turnaround days of the laptop around them, like in the availability queries
+	turnaround := laptop.Turnaround()
+	restrictions, err := db.GetLaptopRestrictionsByDate(laptop.ID, from.AddDate(0, 0, -turnaround), to.AddDate(0, 0, turnaround))
+	if err != nil {
+		return nil, err
+	}
+	taken := make(map[string]bool)
+	for _, lr := range restrictions {
+		first, last := lr.StartDate, lr.EndDate
+		if lr.RestrictionID != models.RestrictionBlock {
+			first, last = first.AddDate(0, 0, -turnaround), last.AddDate(0, 0, turnaround)
+		}
+		for d := first; !d.After(last); d = d.AddDate(0, 0, 1) {
+			taken[d.Format(dates.Layout)] = true
+		}
+	}
+
+	var windows []Window
+	for shift := 1; shift <= maxShift && len(windows) < maxWindows; shift++ {
+		// later dates first, customers searching for a date in the past is rare
+		for _, s := range []int{shift, -shift} {
+			ws := start.AddDate(0, 0, s)
+			we := ws.AddDate(0, 0, length)
+			if ws.Before(earliest) || !free(taken, ws, we) {
+				continue
+			}
+			windows = append(windows, newWindow(laptop, ws, we, s))
+			if len(windows) == maxWindows {
+				break
+			}
+		}
+	}
+
+	return windows, nil
+}
+
+// free reports whether no day from start to end is taken
+func free(taken map[string]bool, start, end time.Time) bool {
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		if taken[d.Format(dates.Layout)] {
+			return false
+		}
+	}
+	return true
+}
+
+// closer reports whether shift a is nearer to the requested dates than b, later wins a tie
+func closer(a, b int) bool {
+	if abs(a) != abs(b) {
+		return abs(a) < abs(b)
+	}
+	return a > b
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func newWindow(laptop models.Laptop, start, end time.Time, shift int) Window {
+	return Window{
+		LaptopID:   laptop.ID,
+		LaptopName: laptop.LaptopName,
+		StartDate:  start.Format(dates.Layout),
+		EndDate:    end.Format(dates.Layout),
+		Shift:      shift,
+	}
+}
@@ -0,0 +1,166 @@
+package suggest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/config"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/database"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/dates"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+)
+
+func date(day int) time.Time {
+	return time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, day-1)
+}
+
+// reserve books laptopID from day start to day end of January 2099
+func reserve(t *testing.T, db database.DBRepository, laptopID, start, end int) {
+	t.Helper()
+
+	id, err := db.InsertReservation(&models.Reservation{LaptopID: laptopID, StartDate: date(start), EndDate: date(end)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.InsertLaptopRestriction(&models.LaptopRestriction{
+		StartDate:     date(start),
+		EndDate:       date(end),
+		LaptopID:      laptopID,
+		ReservationID: id,
+		RestrictionID: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func windowDates(windows []Window) [][2]string {
+	var d [][2]string
+	for _, w := range windows {
+		d = append(d, [2]string{w.StartDate, w.EndDate})
+	}
+	return d
+}
+
+func TestForLaptop(t *testing.T) {
+	db := database.NewMemory(&config.AppConfig{})
+	reserve(t, db, 1, 10, 12)
+	reserve(t, db, 1, 15, 15)
+
+	s, err := ForLaptop(db, 1, date(10), date(11))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 8-9 is just before the reservations, 13-14 fits between them
+	expected := [][2]string{{"2099-01-08", "2099-01-09"}, {"2099-01-13", "2099-01-14"}, {"2099-01-07", "2099-01-08"}}
+	got := windowDates(s.Windows)
+	if len(got) != len(expected) {
+		t.Fatalf("expected windows %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("window %d: expected %v, got %v", i, expected[i], got[i])
+		}
+	}
+	if s.Windows[0].Shift != -2 || s.Windows[1].Shift != 3 || s.Windows[0].LaptopName != "Alienware M15 R2" {
+		t.Errorf("unexpected windows: %+v", s.Windows)
+	}
+
+	if len(s.Laptops) != 1 || s.Laptops[0].LaptopID != 2 || s.Laptops[0].StartDate != "2099-01-10" || s.Laptops[0].Shift != 0 {
+		t.Errorf("expected laptop 2 for the exact dates, got %+v", s.Laptops)
+	}
+}
+
+func TestForLaptop_Turnaround(t *testing.T) {
+	db := database.NewMemory(&config.AppConfig{})
+	err := db.UpdateLaptopBuffers(1, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reserve(t, db, 1, 10, 12)
+
+	s, err := ForLaptop(db, 1, date(10), date(11))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the 9th and the 13th are the turnaround
+	expected := [][2]string{{"2099-01-07", "2099-01-08"}, {"2099-01-14", "2099-01-15"}, {"2099-01-06", "2099-01-07"}}
+	got := windowDates(s.Windows)
+	if len(got) != len(expected) {
+		t.Fatalf("expected windows %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("window %d: expected %v, got %v", i, expected[i], got[i])
+		}
+		start, _ := time.Parse(dates.Layout, s.Windows[i].StartDate)
+		end, _ := time.Parse(dates.Layout, s.Windows[i].EndDate)
+		available, _ := db.SearchAvailabilityByDatesByLaptopID(start, end, 1)
+		if !available {
+			t.Errorf("window %v is not available", got[i])
+		}
+	}
+}
+
+func TestForAllLaptops(t *testing.T) {
+	db := database.NewMemory(&config.AppConfig{})
+	reserve(t, db, 1, 5, 20)
+	reserve(t, db, 2, 10, 12)
+
+	s, err := ForAllLaptops(db, date(10), date(12))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Laptops) != 0 {
+		t.Errorf("expected no laptops for the exact dates, got %+v", s.Laptops)
+	}
+	if len(s.Windows) != maxWindows {
+		t.Fatalf("expected %d windows, got %+v", maxWindows, s.Windows)
+	}
+	// laptop 2 is free from the 13th and until the 9th, laptop 1 only after the 20th
+	expected := []int{3, -3, 4}
+	for i, w := range s.Windows {
+		if w.LaptopID != 2 || w.Shift != expected[i] {
+			t.Errorf("window %d: expected laptop 2 shifted %d, got %+v", i, expected[i], w)
+		}
+	}
+}
+
+func TestForLaptop_NotBeforeTomorrow(t *testing.T) {
+	defer func(f func() time.Time) { today = f }(today)
+	today = func() time.Time { return date(9) }
+
+	db := database.NewMemory(&config.AppConfig{})
+	reserve(t, db, 1, 10, 10)
+
+	s, err := ForLaptop(db, 1, date(10), date(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, w := range s.Windows {
+		if w.Shift < 0 {
+			t.Errorf("suggested a window starting before tomorrow: %+v", w)
+		}
+	}
+	if len(s.Windows) != maxWindows || s.Windows[0].StartDate != "2099-01-11" {
+		t.Errorf("unexpected windows: %+v", s.Windows)
+	}
+}
+
+func TestForLaptop_NothingFree(t *testing.T) {
+	db := database.NewMemory(&config.AppConfig{})
+	reserve(t, db, 1, 1, 80)
+
+	s, err := ForLaptop(db, 1, date(40), date(41))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Windows) != 0 {
+		t.Errorf("expected no windows within %d days, got %+v", maxShift, s.Windows)
+	}
+	if s.Empty() {
+		t.Error("laptop 2 is free")
+	}
+}
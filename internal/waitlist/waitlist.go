@@ -0,0 +1,182 @@
+package waitlist
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/config"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/database"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/dates"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/i18n"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+)
+
+// HoldDuration is how long a notified customer has to book the freed dates before they are offered to the next one
+const HoldDuration = 24 * time.Hour
+
+var (
+	// ErrInvalidToken is returned for a hold link that was never sent or was already used
+	ErrInvalidToken = errors.New("invalid waitlist link")
+	// ErrHoldExpired is returned for a hold link used after HoldDuration
+	ErrHoldExpired = errors.New("waitlist hold expired")
+)
+
+// now returns the current time, a variable so that tests can move it
+var now = time.Now
+
+// Notify offers the free dates of laptopID to its waitlist in the order customers joined, and returns how many
+// customers were notified. A customer whose dates are free gets them held by a hold restriction and a hold link
+// by mail, later customers wanting any of those dates wait until the hold expires. Expired holds and dates in
+// the past leave the waitlist.
+func Notify(app *config.AppConfig, db database.DBRepository, laptopID int) (int, error) {
+	entries, err := db.GetWaitlistByLaptopID(laptopID)
+	if err != nil {
+		return 0, err
+	}
+
+	today := dates.Day(now())
+
+	// the dates held for customers who didn't book them are released first, so that they can be offered again
+	var waiting []models.WaitlistEntry
+	for _, e := range entries {
+		if e.Status == models.WaitlistNotified && now().Before(e.HoldUntil) {
+			continue
+		}
+		if e.Status == models.WaitlistWaiting && e.StartDate.After(today) {
+			waiting = append(waiting, e)
+			continue
+		}
+
+		if e.HoldID != 0 {
+			err = db.DeleteHold(e.HoldID)
+			if err != nil {
+				return 0, err
+			}
+		}
+		e.Status = models.WaitlistExpired
+		e.HoldID = 0
+		err = db.UpdateWaitlistEntry(&e)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	notified := 0
+	for _, e := range waiting {
+		e.Token, err = newToken()
+		if err != nil {
+			return notified, err
+		}
+		e.HoldUntil = now().Add(HoldDuration)
+		e.HoldID, err = db.InsertHold(laptopID, e.StartDate, e.EndDate, e.HoldUntil)
+		if errors.Is(err, database.ErrNotAvailable) {
+			continue
+		}
+		if err != nil {
+			return notified, err
+		}
+
+		e.Status = models.WaitlistNotified
+		err = db.UpdateWaitlistEntry(&e)
+		if err != nil {
+			return notified, err
+		}
+
+		app.MailChan <- holdMail(app, e)
+		notified++
+	}
+
+	return notified, nil
+}
+
+// NotifyAll offers the free dates of every laptop to its waitlist, and returns how many customers were notified
+func NotifyAll(app *config.AppConfig, db database.DBRepository) (int, error) {
+	laptops, err := db.AllLaptops()
+	if err != nil {
+		return 0, err
+	}
+
+	notified := 0
+	for _, laptop := range laptops {
+		n, err := Notify(app, db, laptop.ID)
+		notified += n
+		if err != nil {
+			return notified, err
+		}
+	}
+
+	return notified, nil
+}
+
+// Claim returns the entry of a hold link that can still be booked, its dates are held until HoldUntil. The entry
+// stays notified so that the link keeps working until the hold expires or the reservation is made.
+func Claim(db database.DBRepository, token string) (models.WaitlistEntry, error) {
+	e, err := db.GetWaitlistEntryByToken(token)
+	if err != nil || e.Status != models.WaitlistNotified {
+		return e, ErrInvalidToken
+	}
+	if !now().Before(e.HoldUntil) {
+		return e, ErrHoldExpired
+	}
+
+	return e, nil
+}
+
+// Hold returns the hold restriction keeping the dates of e, the hold of the checkout once the link is claimed
+func Hold(e models.WaitlistEntry) models.LaptopRestriction {
+	return models.LaptopRestriction{
+		ID:            e.HoldID,
+		StartDate:     e.StartDate,
+		EndDate:       e.EndDate,
+		LaptopID:      e.LaptopID,
+		RestrictionID: models.RestrictionHold,
+		ExpiresAt:     e.HoldUntil,
+	}
+}
+
+// Complete takes the entry of token off the waitlist once its reservation is made
+func Complete(db database.DBRepository, token string) error {
+	e, err := db.GetWaitlistEntryByToken(token)
+	if err != nil {
+		return err
+	}
+
+	e.Status = models.WaitlistClaimed
+	return db.UpdateWaitlistEntry(&e)
+}
+
+// newToken returns a random URL safe token for a hold link
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func holdMail(app *config.AppConfig, e models.WaitlistEntry) models.MailData {
+	link := fmt.Sprintf("%s/waitlist/claim?token=%s", app.SiteURL, e.Token)
+	// in the language the customer joined the waitlist in
+	locale := e.Locale
+	htmlMessage := fmt.Sprintf(`
+	<strong>%s</strong><br>
+	%s <br>
+	%s
+	`, i18n.T(locale, "Your laptop is available"), i18n.T(locale, "Dear %s:,", html.EscapeString(e.FirstName)),
+		i18n.T(locale, `%s is now available from %s to %s. It is held for you until %s, <a href="%s">book it here</a>.`,
+			html.EscapeString(e.Laptop.LaptopName), i18n.FormatDate(locale, e.StartDate, "January 2, 2006"), i18n.FormatDate(locale, e.EndDate, "January 2, 2006"),
+			i18n.FormatDate(locale, dates.In(e.HoldUntil), "January 2, 2006 15:04 MST"), link))
+
+	return models.MailData{
+		To:       e.Email,
+		From:     "kaito@laptop-rental.com",
+		Subject:  i18n.T(locale, "Your waitlisted laptop is available"),
+		Content:  htmlMessage,
+		Template: "basic.email.html",
+	}
+}
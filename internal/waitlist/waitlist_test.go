@@ -0,0 +1,306 @@
+package waitlist
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/config"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/database"
+	"github.com/kaitolucifer/go-laptop-rental-site/internal/models"
+)
+
+func date(day int) time.Time {
+	return time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, day-1)
+}
+
+// newTestApp returns an app whose mails can be read from its buffered MailChan
+func newTestApp() *config.AppConfig {
+	return &config.AppConfig{
+		MailChan: make(chan models.MailData, 10),
+		SiteURL:  "https://laptops.example.com",
+	}
+}
+
+// reserve books laptopID from day start to day end of January 2099 and returns the reservation id
+func reserve(t *testing.T, db database.DBRepository, laptopID, start, end int) int {
+	t.Helper()
+
+	id, err := db.InsertReservation(&models.Reservation{LaptopID: laptopID, StartDate: date(start), EndDate: date(end)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.InsertLaptopRestriction(&models.LaptopRestriction{
+		StartDate:     date(start),
+		EndDate:       date(end),
+		LaptopID:      laptopID,
+		ReservationID: id,
+		RestrictionID: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// join puts email on the waitlist of laptop 1 from day start to day end of January 2099
+func join(t *testing.T, db database.DBRepository, email string, start, end int) {
+	t.Helper()
+
+	_, err := db.InsertWaitlistEntry(&models.WaitlistEntry{
+		FirstName: "John",
+		LastName:  "Smith",
+		Email:     email,
+		LaptopID:  1,
+		StartDate: date(start),
+		EndDate:   date(end),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// mails returns the addresses of the mails sent so far
+func mails(app *config.AppConfig) []string {
+	var to []string
+	for {
+		select {
+		case m := <-app.MailChan:
+			to = append(to, m.To)
+		default:
+			return to
+		}
+	}
+}
+
+func TestNotify(t *testing.T) {
+	app := newTestApp()
+	db := database.NewMemory(app)
+
+	id := reserve(t, db, 1, 10, 12)
+	join(t, db, "first@example.com", 10, 11)
+	join(t, db, "second@example.com", 11, 12)
+	join(t, db, "third@example.com", 12, 12)
+	join(t, db, "later@example.com", 20, 21)
+
+	// nothing was freed, the dates of later@ were never taken and it is offered them
+	_, err := Notify(app, db, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if to := mails(app); len(to) != 1 || to[0] != "later@example.com" {
+		t.Errorf("expected only later@example.com to be notified, got %v", to)
+	}
+
+	err = db.DeleteReservation(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Notify(app, db, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// second@ wants a day held for first@, third@ doesn't
+	to := mails(app)
+	if len(to) != 2 || to[0] != "first@example.com" || to[1] != "third@example.com" {
+		t.Errorf("expected first@ and third@ to be notified in order, got %v", to)
+	}
+
+	entries, _ := db.GetWaitlistByLaptopID(1)
+	for _, e := range entries {
+		notified := e.Email != "second@example.com"
+		if notified != (e.Status == models.WaitlistNotified) || notified != (e.Token != "") {
+			t.Errorf("unexpected entry %+v", e)
+		}
+		if notified && !e.HoldUntil.After(time.Now().Add(HoldDuration-time.Minute)) {
+			t.Errorf("hold of %s ends too early: %s", e.Email, e.HoldUntil)
+		}
+	}
+
+	// notifying again doesn't mail anybody twice
+	Notify(app, db, 1)
+	if to := mails(app); len(to) != 0 {
+		t.Errorf("expected no mails, got %v", to)
+	}
+}
+
+func TestNotify_HoldExpires(t *testing.T) {
+	defer func(f func() time.Time) { now = f }(now)
+
+	app := newTestApp()
+	db := database.NewMemory(app)
+
+	join(t, db, "first@example.com", 10, 11)
+	join(t, db, "second@example.com", 11, 12)
+
+	Notify(app, db, 1)
+	if to := mails(app); len(to) != 1 || to[0] != "first@example.com" {
+		t.Fatalf("expected first@ to be notified, got %v", to)
+	}
+	entries, _ := db.GetWaitlistByLaptopID(1)
+	token := entries[0].Token
+
+	now = func() time.Time { return time.Now().Add(HoldDuration + time.Minute) }
+
+	_, err := Claim(db, token)
+	if err != ErrHoldExpired {
+		t.Errorf("expected ErrHoldExpired, got %v", err)
+	}
+
+	Notify(app, db, 1)
+	if to := mails(app); len(to) != 1 || to[0] != "second@example.com" {
+		t.Errorf("expected second@ to be notified once the hold expired, got %v", to)
+	}
+	entries, _ = db.GetWaitlistByLaptopID(1)
+	if len(entries) != 1 || entries[0].Email != "second@example.com" {
+		t.Errorf("expected the expired entry to leave the waitlist, got %+v", entries)
+	}
+}
+
+func TestNotify_PastDates(t *testing.T) {
+	defer func(f func() time.Time) { now = f }(now)
+	now = func() time.Time { return date(15) }
+
+	app := newTestApp()
+	db := database.NewMemory(app)
+	join(t, db, "late@example.com", 10, 11)
+
+	Notify(app, db, 1)
+	if to := mails(app); len(to) != 0 {
+		t.Errorf("expected no mails for past dates, got %v", to)
+	}
+	entries, _ := db.GetWaitlistByLaptopID(1)
+	if len(entries) != 0 {
+		t.Errorf("expected past entries to leave the waitlist, got %+v", entries)
+	}
+}
+
+func TestClaim(t *testing.T) {
+	app := newTestApp()
+	db := database.NewMemory(app)
+
+	join(t, db, "first@example.com", 10, 11)
+	Notify(app, db, 1)
+	mails(app)
+	entries, _ := db.GetWaitlistByLaptopID(1)
+	token := entries[0].Token
+
+	_, err := Claim(db, "unknown")
+	if err != ErrInvalidToken {
+		t.Errorf("expected ErrInvalidToken, got %v", err)
+	}
+
+	e, err := Claim(db, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Email != "first@example.com" || e.Laptop.LaptopName != "Alienware M15 R2" {
+		t.Errorf("unexpected entry %+v", e)
+	}
+
+	// the link keeps working until the reservation is made
+	_, err = Claim(db, token)
+	if err != nil {
+		t.Errorf("expected the link to work twice, got %v", err)
+	}
+	err = Complete(db, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Claim(db, token)
+	if err != ErrInvalidToken {
+		t.Errorf("expected ErrInvalidToken after the reservation, got %v", err)
+	}
+}
+
+func TestNotify_Holds(t *testing.T) {
+	defer func(f func() time.Time) { now = f }(now)
+
+	app := newTestApp()
+	db := database.NewMemory(app)
+
+	join(t, db, "first@example.com", 10, 11)
+	n, err := Notify(app, db, 1)
+	if err != nil || n != 1 {
+		t.Fatalf("expected first@ to be notified, got %d %v", n, err)
+	}
+	mails(app)
+	entries, _ := db.GetWaitlistByLaptopID(1)
+	e := entries[0]
+	if e.HoldID == 0 || Hold(e).ID != e.HoldID || !Hold(e).ExpiresAt.Equal(e.HoldUntil) {
+		t.Fatalf("expected the entry to keep its hold, got %+v", e)
+	}
+
+	// nobody else can take the held dates
+	available, _ := db.SearchAvailabilityByDatesByLaptopID(date(11), date(11), 1)
+	if available {
+		t.Error("expected the held dates to be unavailable")
+	}
+	_, err = db.InsertHold(1, date(11), date(12), time.Now().Add(time.Hour))
+	if err != database.ErrNotAvailable {
+		t.Errorf("expected ErrNotAvailable holding the same dates, got %v", err)
+	}
+
+	// the hold is released with the expired entry
+	now = func() time.Time { return time.Now().Add(HoldDuration + time.Minute) }
+	Notify(app, db, 1)
+	available, _ = db.SearchAvailabilityByDatesByLaptopID(date(10), date(11), 1)
+	if !available {
+		t.Error("expected the dates to be released once the hold expired")
+	}
+}
+
+func TestNotifyAll(t *testing.T) {
+	app := newTestApp()
+	db := database.NewMemory(app)
+
+	join(t, db, "first@example.com", 10, 11)
+	_, err := db.InsertWaitlistEntry(&models.WaitlistEntry{
+		Email:     "second@example.com",
+		LaptopID:  2,
+		StartDate: date(10),
+		EndDate:   date(11),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := NotifyAll(app, db)
+	if err != nil || n != 2 {
+		t.Errorf("expected the waitlists of both laptops notified, got %d %v", n, err)
+	}
+	if to := mails(app); len(to) != 2 {
+		t.Errorf("expected 2 mails, got %v", to)
+	}
+}
+
+func TestHoldMail_Locale(t *testing.T) {
+	app := newTestApp()
+	e := models.WaitlistEntry{
+		FirstName: "<i>Taro</i>",
+		Email:     "taro@example.com",
+		StartDate: date(10),
+		EndDate:   date(12),
+		Token:     "abc",
+		HoldUntil: time.Date(2099, 1, 5, 3, 0, 0, 0, time.UTC),
+		Laptop:    models.Laptop{LaptopName: "Alienware M15 R2"},
+	}
+
+	m := holdMail(app, e)
+	if m.Subject != "Your waitlisted laptop is available" || !strings.Contains(m.Content, "from January 10, 2099 to January 12, 2099") {
+		t.Errorf("unexpected English mail %q: %s", m.Subject, m.Content)
+	}
+
+	e.Locale = "ja"
+	m = holdMail(app, e)
+	if m.Subject != "キャンセル待ちのノートパソコンが空きました" || !strings.Contains(m.Content, "2099年1月10日 から 2099年1月12日 まで空きました") {
+		t.Errorf("unexpected Japanese mail %q: %s", m.Subject, m.Content)
+	}
+	if !strings.Contains(m.Content, "&lt;i&gt;Taro&lt;/i&gt;") {
+		t.Errorf("expected the name escaped: %s", m.Content)
+	}
+	if !strings.Contains(m.Content, "https://laptops.example.com/waitlist/claim?token=abc") {
+		t.Errorf("the Japanese mail lost its link: %s", m.Content)
+	}
+}
@@ -0,0 +1,14 @@
+-- SQLite can't add foreign keys to an existing table, they are declared here instead of in
+-- 20210505171427_create_create_fk_for_reservations_tables
+CREATE TABLE reservations (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  first_name VARCHAR(255) NOT NULL DEFAULT '',
+  last_name VARCHAR(255) NOT NULL DEFAULT '',
+  email VARCHAR(255) NOT NULL UNIQUE,
+  phone VARCHAR(255) NOT NULL DEFAULT '',
+  start_date DATE NOT NULL,
+  end_date DATE NOT NULL,
+  laptop_id INTEGER NOT NULL REFERENCES laptops (id) ON DELETE CASCADE ON UPDATE CASCADE,
+  created_at DATETIME NOT NULL,
+  updated_at DATETIME NOT NULL
+);
@@ -0,0 +1,6 @@
+CREATE TABLE laptops (
+  id SERIAL PRIMARY KEY,
+  laptop_name VARCHAR(255) NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL
+);
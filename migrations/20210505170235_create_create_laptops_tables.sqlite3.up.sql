@@ -0,0 +1,6 @@
+CREATE TABLE laptops (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  laptop_name VARCHAR(255) NOT NULL DEFAULT '',
+  created_at DATETIME NOT NULL,
+  updated_at DATETIME NOT NULL
+);
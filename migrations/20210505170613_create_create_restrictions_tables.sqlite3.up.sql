@@ -0,0 +1,6 @@
+CREATE TABLE restrictions (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  restriction_name VARCHAR(255) NOT NULL DEFAULT '',
+  created_at DATETIME NOT NULL,
+  updated_at DATETIME NOT NULL
+);
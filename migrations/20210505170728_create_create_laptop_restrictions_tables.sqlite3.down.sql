@@ -0,0 +1 @@
+DROP TABLE laptop_restrictions;
@@ -0,0 +1,13 @@
+-- SQLite can't add foreign keys or drop NOT NULL on an existing table, they are declared here instead of in
+-- 20210505172055_create_create_fk_for_laptop_restrictions_tables and
+-- 20210508030948_create_add_not_null_to_reservation_id_for_laptop_restrictions_table
+CREATE TABLE laptop_restrictions (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  start_date DATE NOT NULL,
+  end_date DATE NOT NULL,
+  laptop_id INTEGER NOT NULL REFERENCES laptops (id) ON DELETE CASCADE ON UPDATE CASCADE,
+  reservation_id INTEGER REFERENCES reservations (id) ON DELETE CASCADE ON UPDATE CASCADE,
+  restriction_id INTEGER NOT NULL REFERENCES restrictions (id) ON DELETE CASCADE ON UPDATE CASCADE,
+  created_at DATETIME NOT NULL,
+  updated_at DATETIME NOT NULL
+);
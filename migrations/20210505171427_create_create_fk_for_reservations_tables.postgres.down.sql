@@ -0,0 +1 @@
+ALTER TABLE reservations DROP CONSTRAINT reservations_laptops_id_fk;
@@ -0,0 +1,2 @@
+ALTER TABLE reservations ADD CONSTRAINT reservations_laptops_id_fk
+  FOREIGN KEY (laptop_id) REFERENCES laptops (id) ON DELETE CASCADE ON UPDATE CASCADE;
@@ -0,0 +1 @@
+-- declared in 20210505165833_create_create_reservations_tables
@@ -0,0 +1,3 @@
+ALTER TABLE laptop_restrictions DROP CONSTRAINT laptop_restrictions_laptops_id_fk;
+ALTER TABLE laptop_restrictions DROP CONSTRAINT laptop_restrictions_restrictions_id_fk;
+ALTER TABLE laptop_restrictions DROP CONSTRAINT laptop_restrictions_reservations_id_fk;
@@ -0,0 +1,6 @@
+ALTER TABLE laptop_restrictions ADD CONSTRAINT laptop_restrictions_laptops_id_fk
+  FOREIGN KEY (laptop_id) REFERENCES laptops (id) ON DELETE CASCADE ON UPDATE CASCADE;
+ALTER TABLE laptop_restrictions ADD CONSTRAINT laptop_restrictions_restrictions_id_fk
+  FOREIGN KEY (restriction_id) REFERENCES restrictions (id) ON DELETE CASCADE ON UPDATE CASCADE;
+ALTER TABLE laptop_restrictions ADD CONSTRAINT laptop_restrictions_reservations_id_fk
+  FOREIGN KEY (reservation_id) REFERENCES reservations (id) ON DELETE CASCADE ON UPDATE CASCADE;
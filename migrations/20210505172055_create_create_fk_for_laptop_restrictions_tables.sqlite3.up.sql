@@ -0,0 +1 @@
+-- declared in 20210505170728_create_create_laptop_restrictions_tables
@@ -0,0 +1,3 @@
+DROP INDEX laptop_restrictions_reservation_id_idx;
+DROP INDEX laptop_restrictions_laptop_id_idx;
+DROP INDEX laptop_restrictions_start_date_end_date_idx;
@@ -0,0 +1,3 @@
+CREATE INDEX laptop_restrictions_start_date_end_date_idx ON laptop_restrictions (start_date, end_date);
+CREATE INDEX laptop_restrictions_laptop_id_idx ON laptop_restrictions (laptop_id);
+CREATE INDEX laptop_restrictions_reservation_id_idx ON laptop_restrictions (reservation_id);
@@ -0,0 +1 @@
+ALTER TABLE laptop_restrictions ALTER COLUMN reservation_id DROP NOT NULL;
@@ -0,0 +1,3 @@
+INSERT INTO laptops (laptop_name, created_at, updated_at) VALUES
+('Alienware M15 R2', '2021-05-09', '2021-05-09'),
+('Macbook Pro 15 inch', '2021-05-09', '2021-05-09');
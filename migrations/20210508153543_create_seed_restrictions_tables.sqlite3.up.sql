@@ -0,0 +1,3 @@
+INSERT INTO restrictions (restriction_name, created_at, updated_at) VALUES
+('Reservation', '2021-05-09', '2021-05-09'),
+('Block', '2021-05-09', '2021-05-09');
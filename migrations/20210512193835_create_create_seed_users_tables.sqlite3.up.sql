@@ -0,0 +1,2 @@
+INSERT INTO users (first_name, last_name, email, password, access_level, created_at, updated_at) VALUES
+('Big', 'Brother', 'admin@admin.com', '$2a$12$m//Xx2T.WoZCD3QMMJZ.D.mNzzSE5dI8APom/IavcK6HNXuDjrQqa', 3, '2021-05-09', '2021-05-09');
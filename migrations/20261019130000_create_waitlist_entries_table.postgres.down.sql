@@ -0,0 +1 @@
+DROP TABLE waitlist_entries;
@@ -0,0 +1,17 @@
+CREATE TABLE waitlist_entries (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  first_name VARCHAR(255) NOT NULL DEFAULT '',
+  last_name VARCHAR(255) NOT NULL DEFAULT '',
+  email VARCHAR(255) NOT NULL,
+  laptop_id INTEGER NOT NULL REFERENCES laptops (id) ON DELETE CASCADE ON UPDATE CASCADE,
+  start_date DATE NOT NULL,
+  end_date DATE NOT NULL,
+  status VARCHAR(255) NOT NULL DEFAULT 'waiting',
+  token VARCHAR(255) NOT NULL DEFAULT '',
+  hold_until DATETIME,
+  created_at DATETIME NOT NULL,
+  updated_at DATETIME NOT NULL
+);
+
+CREATE INDEX waitlist_entries_laptop_id_status_idx ON waitlist_entries (laptop_id, status);
+CREATE INDEX waitlist_entries_token_idx ON waitlist_entries (token);
@@ -0,0 +1,4 @@
+DROP INDEX laptop_restrictions_expires_at_idx;
+ALTER TABLE laptop_restrictions DROP COLUMN expires_at;
+
+DELETE FROM restrictions WHERE id = 3;
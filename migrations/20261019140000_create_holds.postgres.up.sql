@@ -0,0 +1,6 @@
+INSERT INTO restrictions (id, restriction_name, created_at, updated_at) VALUES
+(3, 'Hold', '2026-10-19', '2026-10-19');
+SELECT setval('restrictions_id_seq', (SELECT MAX(id) FROM restrictions));
+
+ALTER TABLE laptop_restrictions ADD COLUMN expires_at TIMESTAMP;
+CREATE INDEX laptop_restrictions_expires_at_idx ON laptop_restrictions (expires_at);
@@ -0,0 +1,5 @@
+INSERT INTO restrictions (id, restriction_name, created_at, updated_at) VALUES
+(3, 'Hold', '2026-10-19', '2026-10-19');
+
+ALTER TABLE laptop_restrictions ADD COLUMN expires_at DATETIME;
+CREATE INDEX laptop_restrictions_expires_at_idx ON laptop_restrictions (expires_at);
@@ -0,0 +1,2 @@
+ALTER TABLE laptops DROP COLUMN buffer_days_after;
+ALTER TABLE laptops DROP COLUMN buffer_days_before;
@@ -0,0 +1,2 @@
+ALTER TABLE laptops ADD COLUMN buffer_days_before INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE laptops ADD COLUMN buffer_days_after INTEGER NOT NULL DEFAULT 0;
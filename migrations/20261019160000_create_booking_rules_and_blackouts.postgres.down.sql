@@ -0,0 +1,2 @@
+DROP TABLE blackouts;
+DROP TABLE booking_rules;
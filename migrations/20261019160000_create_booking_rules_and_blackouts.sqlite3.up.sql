@@ -0,0 +1,24 @@
+CREATE TABLE booking_rules (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  laptop_id INTEGER NOT NULL DEFAULT 0,
+  min_days INTEGER NOT NULL DEFAULT 0,
+  max_days INTEGER NOT NULL DEFAULT 0,
+  max_advance_days INTEGER NOT NULL DEFAULT 0,
+  pickup_weekdays VARCHAR(255) NOT NULL DEFAULT '',
+  created_at DATETIME NOT NULL,
+  updated_at DATETIME NOT NULL
+);
+
+CREATE UNIQUE INDEX booking_rules_laptop_id_idx ON booking_rules (laptop_id);
+
+CREATE TABLE blackouts (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  laptop_id INTEGER NOT NULL DEFAULT 0,
+  start_date DATE NOT NULL,
+  end_date DATE NOT NULL,
+  reason VARCHAR(255) NOT NULL DEFAULT '',
+  created_at DATETIME NOT NULL,
+  updated_at DATETIME NOT NULL
+);
+
+CREATE INDEX blackouts_start_date_end_date_idx ON blackouts (start_date, end_date);
@@ -0,0 +1 @@
+ALTER TABLE waitlist_entries ADD COLUMN locale VARCHAR(10) NOT NULL DEFAULT 'en';
@@ -0,0 +1,12 @@
+CREATE TABLE reservation_cancellations (
+  id SERIAL PRIMARY KEY,
+  reservation_id INTEGER NOT NULL,
+  laptop_id INTEGER NOT NULL,
+  start_date DATE NOT NULL,
+  end_date DATE NOT NULL,
+  booked_at TIMESTAMP NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX reservation_cancellations_start_date_end_date_idx ON reservation_cancellations (start_date, end_date);
@@ -0,0 +1 @@
+ALTER TABLE laptop_restrictions DROP COLUMN version;
@@ -0,0 +1 @@
+ALTER TABLE laptop_restrictions ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
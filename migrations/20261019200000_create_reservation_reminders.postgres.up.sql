@@ -0,0 +1,10 @@
+CREATE TABLE reservation_reminders (
+  id SERIAL PRIMARY KEY,
+  reservation_id INTEGER NOT NULL,
+  kind VARCHAR(20) NOT NULL,
+  sent_at TIMESTAMP NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL
+);
+
+CREATE UNIQUE INDEX reservation_reminders_reservation_id_kind_idx ON reservation_reminders (reservation_id, kind);
@@ -0,0 +1,10 @@
+CREATE TABLE reservation_reminders (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  reservation_id INTEGER NOT NULL,
+  kind VARCHAR(20) NOT NULL,
+  sent_at DATETIME NOT NULL,
+  created_at DATETIME NOT NULL,
+  updated_at DATETIME NOT NULL
+);
+
+CREATE UNIQUE INDEX reservation_reminders_reservation_id_kind_idx ON reservation_reminders (reservation_id, kind);
@@ -0,0 +1,25 @@
+CREATE TABLE job_leases (
+  name VARCHAR(100) PRIMARY KEY,
+  owner VARCHAR(255) NOT NULL,
+  scheduled_at TIMESTAMP NOT NULL,
+  lease_until TIMESTAMP NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE job_runs (
+  id SERIAL PRIMARY KEY,
+  name VARCHAR(100) NOT NULL,
+  owner VARCHAR(255) NOT NULL,
+  scheduled_at TIMESTAMP NOT NULL,
+  started_at TIMESTAMP NOT NULL,
+  finished_at TIMESTAMP NOT NULL,
+  attempts INTEGER NOT NULL,
+  status VARCHAR(20) NOT NULL,
+  message TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX job_runs_name_started_at_idx ON job_runs (name, started_at);
+CREATE INDEX job_runs_started_at_idx ON job_runs (started_at);
@@ -0,0 +1,25 @@
+CREATE TABLE job_leases (
+  name VARCHAR(100) PRIMARY KEY,
+  owner VARCHAR(255) NOT NULL,
+  scheduled_at DATETIME NOT NULL,
+  lease_until DATETIME NOT NULL,
+  created_at DATETIME NOT NULL,
+  updated_at DATETIME NOT NULL
+);
+
+CREATE TABLE job_runs (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name VARCHAR(100) NOT NULL,
+  owner VARCHAR(255) NOT NULL,
+  scheduled_at DATETIME NOT NULL,
+  started_at DATETIME NOT NULL,
+  finished_at DATETIME NOT NULL,
+  attempts INTEGER NOT NULL,
+  status VARCHAR(20) NOT NULL,
+  message TEXT NOT NULL DEFAULT '',
+  created_at DATETIME NOT NULL,
+  updated_at DATETIME NOT NULL
+);
+
+CREATE INDEX job_runs_name_started_at_idx ON job_runs (name, started_at);
+CREATE INDEX job_runs_started_at_idx ON job_runs (started_at);
@@ -0,0 +1 @@
+ALTER TABLE waitlist_entries ADD COLUMN hold_id INTEGER NOT NULL DEFAULT 0;
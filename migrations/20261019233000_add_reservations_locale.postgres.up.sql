@@ -0,0 +1 @@
+ALTER TABLE reservations ADD COLUMN locale VARCHAR(10) NOT NULL DEFAULT 'en';
@@ -0,0 +1,4 @@
+DROP INDEX reservations_phone_trgm_idx;
+DROP INDEX reservations_email_trgm_idx;
+DROP INDEX reservations_last_name_trgm_idx;
+DROP INDEX reservations_first_name_trgm_idx;
@@ -0,0 +1,5 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX reservations_first_name_trgm_idx ON reservations USING gin (first_name gin_trgm_ops);
+CREATE INDEX reservations_last_name_trgm_idx ON reservations USING gin (last_name gin_trgm_ops);
+CREATE INDEX reservations_email_trgm_idx ON reservations USING gin (email gin_trgm_ops);
+CREATE INDEX reservations_phone_trgm_idx ON reservations USING gin (phone gin_trgm_ops);
@@ -0,0 +1 @@
+-- SQLite has no trigram indexes, the reservation search falls back to LIKE over the table
@@ -0,0 +1,9 @@
+package migrations
+
+import "embed"
+
+// FS holds the SQL migrations and seeds, so that the binary can migrate without soda.
+// The files follow soda's naming, <version>_<name>.<dialect>.<up|down>.sql
+//
+//go:embed *.sql seeds/*.sql
+var FS embed.FS
@@ -0,0 +1,21 @@
+-- demo reservations and blocks around the current date, safe to run more than once
+INSERT INTO reservations (first_name, last_name, email, phone, start_date, end_date, laptop_id, processed, created_at, updated_at)
+SELECT 'Taro', 'Yamada', 'taro.yamada@example.com', '090-1234-5678', CURRENT_DATE + 3, CURRENT_DATE + 6, 1, 0, NOW(), NOW()
+WHERE NOT EXISTS (SELECT 1 FROM reservations WHERE email = 'taro.yamada@example.com');
+
+INSERT INTO reservations (first_name, last_name, email, phone, start_date, end_date, laptop_id, processed, created_at, updated_at)
+SELECT 'Hanako', 'Suzuki', 'hanako.suzuki@example.com', '080-8765-4321', CURRENT_DATE + 10, CURRENT_DATE + 12, 2, 1, NOW(), NOW()
+WHERE NOT EXISTS (SELECT 1 FROM reservations WHERE email = 'hanako.suzuki@example.com');
+
+INSERT INTO laptop_restrictions (start_date, end_date, laptop_id, reservation_id, restriction_id, created_at, updated_at)
+SELECT r.start_date, r.end_date, r.laptop_id, r.id, 1, NOW(), NOW()
+FROM reservations r
+WHERE r.email IN ('taro.yamada@example.com', 'hanako.suzuki@example.com')
+AND NOT EXISTS (SELECT 1 FROM laptop_restrictions lr WHERE lr.reservation_id = r.id);
+
+INSERT INTO laptop_restrictions (start_date, end_date, laptop_id, restriction_id, created_at, updated_at)
+SELECT CURRENT_DATE + 8, CURRENT_DATE + 8, 1, 2, NOW(), NOW()
+WHERE NOT EXISTS (
+    SELECT 1 FROM laptop_restrictions
+    WHERE laptop_id = 1 AND restriction_id = 2 AND start_date = CURRENT_DATE + 8
+);
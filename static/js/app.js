@@ -98,3 +98,56 @@ function Prompt() {
     custom: custom,
   };
 }
+
+// suggestionsHTML renders the alternatives of an unavailable search as rent links
+function suggestionsHTML(suggestions) {
+  if (!suggestions) {
+    return "";
+  }
+
+  let link = function (s, label) {
+    return (
+      '<li><a href="/rent-laptop?id=' +
+      s.laptop_id +
+      "&s=" +
+      s.start_date +
+      "&e=" +
+      s.end_date +
+      '">' +
+      label +
+      "</a></li>"
+    );
+  };
+
+  let html = "";
+  if (suggestions.windows && suggestions.windows.length > 0) {
+    html += "<p>" + messages.freeDates + "</p><ul class='list-unstyled'>";
+    suggestions.windows.forEach(function (s) {
+      html += link(s, s.start_date + " - " + s.end_date);
+    });
+    html += "</ul>";
+  }
+  if (suggestions.laptops && suggestions.laptops.length > 0) {
+    html += "<p>" + messages.freeLaptops + "</p><ul class='list-unstyled'>";
+    suggestions.laptops.forEach(function (s) {
+      html += link(s, s.laptop_name);
+    });
+    html += "</ul>";
+  }
+  return html;
+}
+
+// waitlistHTML links an unavailable search of the laptop modal to the waitlist
+function waitlistHTML(data) {
+  return (
+    '<p><a href="/waitlist?id=' +
+    data.laptop_id +
+    "&s=" +
+    data.start_date +
+    "&e=" +
+    data.end_date +
+    '" class="btn btn-outline-primary">' +
+    messages.joinWaitlist +
+    "</a></p>"
+  );
+}
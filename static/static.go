@@ -0,0 +1,9 @@
+package static
+
+import "embed"
+
+// FS holds the static files served under /static/. Only the built assets of the admin theme are
+// embedded, its sources and documentation are left out
+//
+//go:embed css images js admin/css admin/fonts admin/images admin/js admin/vendors
+var FS embed.FS
@@ -0,0 +1,9 @@
+package templates
+
+import "embed"
+
+// FS holds the page, layout and email templates, so that a production binary does not depend
+// on the directory it is started from
+//
+//go:embed *.html
+var FS embed.FS